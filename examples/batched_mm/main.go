@@ -2,7 +2,7 @@ package main
 
 import (
 	"fmt"
-	"math"
+	"math/rand"
 
 	"kylesmith19091/fastgo/internal/metal"
 	"kylesmith19091/fastgo/internal/tensor"
@@ -16,14 +16,15 @@ func main() {
 		cols  = 5
 	)
 
-	tA, err := tensor.NewRandom3D(tensor.Float32, batch, rows, inner)
+	rng := rand.New(rand.NewSource(1))
+	tA, err := tensor.Random(rng, tensor.Uniform(-1, 1), tensor.Float32, batch, rows, inner)
 	if err != nil {
 		fmt.Printf("A alloc/init error: %v\n", err)
 		return
 	}
 	defer tA.Close()
 
-	tB, err := tensor.NewRandom3D(tensor.Float32, batch, inner, cols)
+	tB, err := tensor.Random(rng, tensor.Uniform(-1, 1), tensor.Float32, batch, inner, cols)
 	if err != nil {
 		fmt.Printf("B alloc/init error: %v\n", err)
 		return
@@ -42,20 +43,14 @@ func main() {
 		return
 	}
 
-	tC, err := tensor.New(tensor.Float32, batch, rows, cols)
-	if err != nil {
-		fmt.Printf("C alloc error: %v\n", err)
-		return
-	}
-	defer tC.Close()
-
 	metal.CompileLibrary()
-	metal.EnsureKernel("matrix_multiply_batched_naive")
 
-	if err := metal.MatMulBatchedBuffers(tA.Buffer(), tB.Buffer(), tC.Buffer(), batch, rows, inner, cols); err != nil {
-		fmt.Printf("kernel error: %v\n", err)
+	tC, err := tensor.MatMul(tA, tB)
+	if err != nil {
+		fmt.Printf("matmul error: %v\n", err)
 		return
 	}
+	defer tC.Close()
 
 	gpuOut := make([]float32, tC.Numel())
 	if err := tC.DownloadFloat32(gpuOut); err != nil {
@@ -64,7 +59,17 @@ func main() {
 	}
 
 	hostRef := batchedMatMulCPU(batch, rows, inner, cols, hA, hB)
-	maxErr := maxAbsDiff(gpuOut, hostRef)
+	tRef, err := tensor.FromFloat32(tensor.Float32, hostRef, batch, rows, cols)
+	if err != nil {
+		fmt.Printf("reference upload error: %v\n", err)
+		return
+	}
+	defer tRef.Close()
+	report, err := tensor.Compare(tC, tRef, 1e-5, 1e-6)
+	if err != nil {
+		fmt.Printf("compare error: %v\n", err)
+		return
+	}
 
 	preview := len(gpuOut)
 	if preview > 10 {
@@ -73,7 +78,7 @@ func main() {
 	fmt.Printf("Batched MatMul: B=%d M=%d K=%d N=%d\n", batch, rows, inner, cols)
 	fmt.Printf("First few GPU C values: %v\n", gpuOut[:preview])
 	fmt.Printf("First few CPU Cref values: %v\n", hostRef[:preview])
-	fmt.Printf("Max |GPU-CPU| error: %g\n", maxErr)
+	fmt.Printf("GPU vs CPU: %v\n", report)
 }
 
 func batchedMatMulCPU(batches, rows, inner, cols int, a, b []float32) []float32 {
@@ -101,14 +106,3 @@ func batchedMatMulCPU(batches, rows, inner, cols int, a, b []float32) []float32
 
 	return c
 }
-
-func maxAbsDiff(a, b []float32) float32 {
-	maxErr := float32(0)
-	for i, v := range a {
-		diff := float32(math.Abs(float64(v - b[i])))
-		if diff > maxErr {
-			maxErr = diff
-		}
-	}
-	return maxErr
-}
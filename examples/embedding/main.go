@@ -2,6 +2,9 @@ package main
 
 import (
 	"fmt"
+	"math"
+	"math/rand"
+
 	"kylesmith19091/fastgo/internal/layers"
 	"kylesmith19091/fastgo/internal/tensor"
 )
@@ -9,7 +12,9 @@ import (
 func main() {
 	// Create a small [vocab, dim] embedding matrix
 	vocab, dim := 5, 4
-	weights, err := tensor.NewRandom2D(tensor.Float32, vocab, dim)
+	rng := rand.New(rand.NewSource(1))
+	bound := 1 / math.Sqrt(float64(dim)) // as NewRandom2D: uniform in ±1/sqrt(fan_in)
+	weights, err := tensor.Random(rng, tensor.Uniform(-bound, bound), tensor.Float32, vocab, dim)
 	if err != nil {
 		fmt.Println("alloc error:", err)
 		return
@@ -24,6 +29,7 @@ func main() {
 		fmt.Println("lookup error:", err)
 		return
 	}
+	defer vec.Close()
 	fmt.Println("Embedding: ", emb)
 
 	// Print the 1D tensor view (values rendered by Tensor.String)
@@ -33,4 +39,19 @@ func main() {
 	if val, err := vec.At(1); err == nil {
 		fmt.Println("vec[1] =", val)
 	}
+
+	// Gather a batch of ids in one op: [2,2] ids -> [2,2,dim]
+	ids, err := tensor.FromFloat32(tensor.Int32, []float32{0, 2, 4, 2}, 2, 2)
+	if err != nil {
+		fmt.Println("ids error:", err)
+		return
+	}
+	defer ids.Close()
+	batch, err := emb.LookupBatch(ids)
+	if err != nil {
+		fmt.Println("batch lookup error:", err)
+		return
+	}
+	defer batch.Close()
+	fmt.Println("Batch lookup shape:", batch.Shape)
 }
@@ -1,8 +1,9 @@
 package layers
 
 import (
+	"fmt"
+
 	"kylesmith19091/fastgo/internal/tensor"
-	"strings"
 )
 
 type Embedding struct {
@@ -22,17 +23,13 @@ func (e *Embedding) Lookup(id int) (*tensor.Tensor, error) {
 	return e.inner.Row(id)
 }
 
+// LookupBatch gathers the embedding vectors for a tensor of integer token
+// ids in one op, returning a new tensor of shape ids.Shape + [dim].
+func (e *Embedding) LookupBatch(ids *tensor.Tensor) (*tensor.Tensor, error) {
+	return tensor.IndexSelect(&e.inner, 0, ids)
+}
+
+// String prints the weight table, summarized for large vocabularies.
 func (e *Embedding) String() string {
-	var sb strings.Builder
-	sb.WriteString("Embedding(\n")
-	for idx := range e.inner.Shape[0] {
-		t, err := e.inner.Row(idx)
-		if err != nil {
-			panic("could not print embedding due to row")
-		}
-		sb.WriteString(t.String())
-		sb.WriteString("\n")
-	}
-	sb.WriteString(")")
-	return sb.String()
+	return fmt.Sprintf("Embedding(%v, shape=%v)\n%v", e.inner.DT, e.inner.Shape, &e.inner)
 }
@@ -69,4 +69,675 @@ kernel void matrix_multiply_batched_naive(
     sum += A[aBase + row * K + kk] * B[bBase + kk * N + col];
   }
   C[cBase + row * N + col] = sum;
-}
\ No newline at end of file
+}
+// ---------- Dtype casts ----------
+
+// Element type codes; mirror tensor.DType. Int4 and E2M1 pack two elements
+// per byte, low nibble first; E8M0 is only ever a source.
+constant int DT_F16 = 0;
+constant int DT_BF16 = 1;
+constant int DT_F32 = 2;
+constant int DT_I8 = 3;
+constant int DT_I4 = 4;
+constant int DT_E4M3 = 5;
+constant int DT_E5M2 = 6;
+constant int DT_E2M1 = 7;
+constant int DT_E8M0 = 8;
+
+constant float FP4_VALUES[8] = {0.0f, 0.5f, 1.0f, 1.5f, 2.0f, 3.0f, 4.0f, 6.0f};
+
+// Params for cast_elementwise over n contiguous elements. Offsets are bytes.
+typedef struct CastParams {
+  int n;
+  int src_dtype, dst_dtype;
+  int src_offset, dst_offset;
+} CastParams;
+
+// The minifloat helpers work on bits, as tensor/fp8.go does, so results do
+// not depend on fast-math or subnormal flushing.
+
+// v >> s rounded to nearest, ties to even.
+inline uint round_shift_rne(uint v, uint s) {
+  if (s == 0) return v;
+  if (s > 25) return 0;
+  uint q = v >> s;
+  uint rem = v & ((1u << s) - 1);
+  uint half_ulp = 1u << (s - 1);
+  if (rem > half_ulp || (rem == half_ulp && (q & 1) == 1)) q++;
+  return q;
+}
+
+// Magnitude code (exponent << m | mantissa) of the finite, non-negative
+// float32 bits x in a small float with m mantissa bits; a carry past the
+// largest finite code signals overflow.
+inline uint round_minifloat(uint x, uint m, int bias) {
+  if ((x >> 23) == 0) return 0;
+  int e = int(x >> 23) - 127;
+  uint mant = x & 0x007fffff;
+  int emin = 1 - bias;
+  if (e >= emin) return round_shift_rne(uint(e + bias) << 23 | mant, 23 - m);
+  return round_shift_rne(mant | 0x00800000, 23 - m + uint(emin - e));
+}
+
+inline float decode_minifloat(uint code, uint m, int bias) {
+  uint e = code >> m;
+  uint mant = code & ((1u << m) - 1);
+  if (e == 0) return ldexp(float(mant), 1 - bias - int(m));
+  return ldexp(float(mant | (1u << m)), int(e) - bias - int(m));
+}
+
+// Saturating FP8 encode: overflow and Inf clamp to max_code, NaN keeps its
+// sign and becomes 0x7F.
+inline uchar encode_fp8(float v, uint m, int bias, uint max_code) {
+  uint x = as_type<uint>(v);
+  uint sign = (x >> 24) & 0x80;
+  uint mag = x & 0x7fffffff;
+  if (mag > 0x7f800000) return uchar(sign | 0x7F);
+  if (mag == 0x7f800000) return uchar(sign | max_code);
+  return uchar(sign | min(round_minifloat(mag, m, bias), max_code));
+}
+
+inline float load_elem(device const uchar *base, int dt, uint i) {
+  if (dt == DT_F16) return float(((device const half *)base)[i]);
+  if (dt == DT_BF16) return as_type<float>(uint(((device const ushort *)base)[i]) << 16);
+  if (dt == DT_F32) return ((device const float *)base)[i];
+  if (dt == DT_I4 || dt == DT_E2M1) {
+    uint nib = (base[i >> 1] >> (4 * (i & 1))) & 0xF;
+    if (dt == DT_I4) return float(int(nib ^ 8) - 8);
+    float f = FP4_VALUES[nib & 7];
+    return (nib & 8) ? -f : f;
+  }
+  if (dt == DT_E4M3 || dt == DT_E5M2) {
+    uint b = base[i];
+    uint mag = b & 0x7F;
+    float f;
+    if (dt == DT_E4M3) {
+      f = mag == 0x7F ? as_type<float>(0x7fc00000u) : decode_minifloat(mag, 3, 7);
+    } else if (mag >= 0x7C) {
+      f = as_type<float>(mag == 0x7C ? 0x7f800000u : 0x7fc00000u);
+    } else {
+      f = decode_minifloat(mag, 2, 15);
+    }
+    return (b & 0x80) ? -f : f;
+  }
+  if (dt == DT_E8M0) {
+    uint b = base[i];
+    if (b == 0xFF) return as_type<float>(0x7fc00000u);
+    return as_type<float>(b == 0 ? 0x00400000u : b << 23); // 2^(b-127)
+  }
+  return float(((device const char *)base)[i]); // int8
+}
+
+// Four-bit code of v for Int4 (round half to even, saturate, NaN -> 0) or
+// E2M1 (round to nearest even, saturate to 6, NaN -> +0).
+inline uint encode_nibble(int dt, float v) {
+  uint x = as_type<uint>(v);
+  uint mag = x & 0x7fffffff;
+  if (dt == DT_I4) {
+    float r = mag > 0x7f800000 ? 0.0f : clamp(rint(v), -8.0f, 7.0f);
+    return uint(int(r)) & 0xF;
+  }
+  if (mag > 0x7f800000) return 0;
+  uint q = mag == 0x7f800000 ? 7 : min(round_minifloat(mag, 1, 1), 7u);
+  return ((x >> 28) & 0x8) | q;
+}
+
+inline void store_elem(device uchar *base, int dt, uint i, float v) {
+  if (dt == DT_F16) {
+    ((device half *)base)[i] = half(v); // round-to-nearest-even, overflow to Inf
+  } else if (dt == DT_BF16) {
+    uint u = as_type<uint>(v);
+    if ((u & 0x7fffffff) > 0x7f800000) {
+      u = (u | 0x00400000); // keep NaN quiet after truncation
+    } else {
+      u += 0x7fff + ((u >> 16) & 1);
+    }
+    ((device ushort *)base)[i] = ushort(u >> 16);
+  } else if (dt == DT_F32) {
+    ((device float *)base)[i] = v;
+  } else if (dt == DT_E4M3) {
+    base[i] = encode_fp8(v, 3, 7, 0x7E);
+  } else if (dt == DT_E5M2) {
+    base[i] = encode_fp8(v, 2, 15, 0x7B);
+  } else { // int8: round half to even, saturate, NaN -> 0
+    float r = (as_type<uint>(v) & 0x7fffffff) > 0x7f800000 ? 0.0f : clamp(rint(v), -128.0f, 127.0f);
+    ((device char *)base)[i] = char(r);
+  }
+}
+
+// Converts between the float, int8 and int4 dtypes with the same rounding as
+// the host conversions in tensor.To. Grid = (n, 1, 1), or one thread per
+// output byte, (n+1)/2, when dst packs two elements per byte.
+kernel void cast_elementwise(
+  device const CastParams *params,
+  device const uchar *src,
+  device uchar *dst,
+  uint gid [[thread_position_in_grid]]
+) {
+  device const uchar *s = src + params->src_offset;
+  device uchar *d = dst + params->dst_offset;
+  uint n = (uint)params->n;
+  int sdt = params->src_dtype, ddt = params->dst_dtype;
+  if (ddt == DT_I4 || ddt == DT_E2M1) {
+    uint i = 2 * gid;
+    if (i >= n) {
+      return;
+    }
+    uint lo = encode_nibble(ddt, load_elem(s, sdt, i));
+    uint hi = i + 1 < n ? encode_nibble(ddt, load_elem(s, sdt, i + 1)) : 0;
+    d[gid] = uchar(lo | hi << 4);
+    return;
+  }
+  if (gid >= n) {
+    return;
+  }
+  store_elem(d, ddt, gid, load_elem(s, sdt, gid));
+}
+
+// ---------- Strided elementwise ops (float32) ----------
+
+#define MAX_DIMS 8
+
+// Params for elementwise_binary_f32 / elementwise_unary_f32. The output is
+// dense; inputs are addressed through per-dimension element strides (0 for
+// broadcast dims). Offsets and strides are in elements.
+typedef struct ElementwiseParams {
+  int op;
+  int rank;
+  int n;
+  float scalar;
+  int off_a, off_b, off_out;
+  int shape[MAX_DIMS];
+  int stride_a[MAX_DIMS];
+  int stride_b[MAX_DIMS];
+} ElementwiseParams;
+
+// Binary op codes; mirror tensor.binaryOp.
+inline float apply_binary(int op, float x, float y) {
+  switch (op) {
+    case 0: return x + y;
+    case 1: return x - y;
+    case 2: return x * y;
+    case 3: return x / y;
+    case 4: return (isnan(x) || isnan(y)) ? x + y : max(x, y);
+    case 5: return (isnan(x) || isnan(y)) ? x + y : min(x, y);
+    default: return pow(x, y);
+  }
+}
+
+// Unary op codes; mirror tensor.unaryOp. Scalar ops use params->scalar as y.
+inline float apply_unary(int op, float x, float s) {
+  switch (op) {
+    case 0: return -x;
+    case 1: return exp(x);
+    case 2: return log(x);
+    case 3: return sqrt(x);
+    case 4: return rsqrt(x);
+    default: return apply_binary(op - 5, x, s);
+  }
+}
+
+inline void strided_offsets(device const ElementwiseParams *p, uint gid, thread int &oa, thread int &ob) {
+  uint idx = gid;
+  oa = p->off_a;
+  ob = p->off_b;
+  for (int d = p->rank - 1; d >= 0; --d) {
+    uint dim = (uint)p->shape[d];
+    int i = (int)(idx % dim);
+    idx /= dim;
+    oa += i * p->stride_a[d];
+    ob += i * p->stride_b[d];
+  }
+}
+
+// Grid = (n, 1, 1); out[gid] = op(A[...], B[...]) with broadcasting strides.
+kernel void elementwise_binary_f32(
+  device const ElementwiseParams *params,
+  device const float *A,
+  device const float *B,
+  device float *Out,
+  uint gid [[thread_position_in_grid]]
+) {
+  if (gid >= (uint)params->n) {
+    return;
+  }
+  int oa, ob;
+  strided_offsets(params, gid, oa, ob);
+  Out[params->off_out + gid] = apply_binary(params->op, A[oa], B[ob]);
+}
+
+// Grid = (n, 1, 1); out[gid] = op(A[...]) (B is unused and may alias A).
+kernel void elementwise_unary_f32(
+  device const ElementwiseParams *params,
+  device const float *A,
+  device float *Out,
+  uint gid [[thread_position_in_grid]]
+) {
+  if (gid >= (uint)params->n) {
+    return;
+  }
+  int oa, ob;
+  strided_offsets(params, gid, oa, ob);
+  Out[params->off_out + gid] = apply_unary(params->op, A[oa], params->scalar);
+}
+
+// ---------- Axis reductions (float32) ----------
+
+// Params for reduce_f32 / reduce_arg_f32. Each thread produces one output
+// element: kept dims select the base offset, reduced dims are looped over.
+// Offsets and strides are in elements of the input.
+typedef struct ReduceParams {
+  int op;
+  int n_out;
+  int n_red;
+  int correction;
+  int off_in, off_out;
+  int out_rank, red_rank;
+  int out_shape[MAX_DIMS];
+  int out_stride[MAX_DIMS];
+  int red_shape[MAX_DIMS];
+  int red_stride[MAX_DIMS];
+} ReduceParams;
+
+inline int reduce_offset(int rank, uint idx, device const int *shape, device const int *stride) {
+  int off = 0;
+  for (int d = rank - 1; d >= 0; --d) {
+    uint dim = (uint)shape[d];
+    off += (int)(idx % dim) * stride[d];
+    idx /= dim;
+  }
+  return off;
+}
+
+// Op codes mirror tensor.reduceOp: 0=sum 1=mean 2=max 3=min 4=var.
+kernel void reduce_f32(
+  device const ReduceParams *p,
+  device const float *A,
+  device float *Out,
+  uint gid [[thread_position_in_grid]]
+) {
+  if (gid >= (uint)p->n_out) {
+    return;
+  }
+  int base = p->off_in + reduce_offset(p->out_rank, gid, p->out_shape, p->out_stride);
+  float acc = (p->op == 2) ? -INFINITY : ((p->op == 3) ? INFINITY : 0.0f);
+  bool nan_seen = false;
+  for (int j = 0; j < p->n_red; ++j) {
+    float v = A[base + reduce_offset(p->red_rank, (uint)j, p->red_shape, p->red_stride)];
+    nan_seen = nan_seen || isnan(v);
+    if (p->op == 2) acc = max(acc, v);
+    else if (p->op == 3) acc = min(acc, v);
+    else acc += v;
+  }
+  if (p->op == 1 || p->op == 4) {
+    acc /= (float)p->n_red;
+  }
+  if (p->op == 4) {
+    float mean = acc;
+    float ss = 0.0f;
+    for (int j = 0; j < p->n_red; ++j) {
+      float d = A[base + reduce_offset(p->red_rank, (uint)j, p->red_shape, p->red_stride)] - mean;
+      ss += d * d;
+    }
+    acc = ss / (float)max(p->n_red - p->correction, 0);
+  }
+  if (nan_seen && (p->op == 2 || p->op == 3)) {
+    acc = NAN;
+  }
+  Out[p->off_out + gid] = acc;
+}
+
+// Op codes mirror tensor.reduceOp: 5=argmax 6=argmin. Writes int32 indices;
+// the first maximum/minimum wins and NaN counts as the extreme value.
+kernel void reduce_arg_f32(
+  device const ReduceParams *p,
+  device const float *A,
+  device int *Out,
+  uint gid [[thread_position_in_grid]]
+) {
+  if (gid >= (uint)p->n_out) {
+    return;
+  }
+  int base = p->off_in + reduce_offset(p->out_rank, gid, p->out_shape, p->out_stride);
+  float best = A[base];
+  int best_j = 0;
+  for (int j = 1; j < p->n_red && !isnan(best); ++j) {
+    float v = A[base + reduce_offset(p->red_rank, (uint)j, p->red_shape, p->red_stride)];
+    bool better = isnan(v) || ((p->op == 5) ? (v > best) : (v < best));
+    if (better) {
+      best = v;
+      best_j = j;
+    }
+  }
+  Out[p->off_out + gid] = best_j;
+}
+
+// ---------- Softmax / LogSoftmax (float32) ----------
+
+// Params for softmax_f32. Each thread handles one row along the softmax dim:
+// shape/strides describe the other dims, and stride_* step along the row.
+// Offsets and strides are in elements.
+typedef struct SoftmaxParams {
+  int log;
+  int has_mask;
+  int n_rows;
+  int n;
+  float inv_temp;
+  int off_in, off_mask, off_out;
+  int stride_in, stride_mask, stride_out;
+  int rank;
+  int shape[MAX_DIMS];
+  int in_stride[MAX_DIMS];
+  int mask_stride[MAX_DIMS];
+  int out_stride[MAX_DIMS];
+} SoftmaxParams;
+
+// Computes softmax(x*inv_temp + mask) with max subtraction. Rows whose
+// logits are all -inf produce 0 (softmax) or -inf (log-softmax).
+kernel void softmax_f32(
+  device const SoftmaxParams *p,
+  device const float *A,
+  device const float *M,
+  device float *Out,
+  uint gid [[thread_position_in_grid]]
+) {
+  if (gid >= (uint)p->n_rows) {
+    return;
+  }
+  int a0 = p->off_in + reduce_offset(p->rank, gid, p->shape, p->in_stride);
+  int m0 = p->off_mask + reduce_offset(p->rank, gid, p->shape, p->mask_stride);
+  int o0 = p->off_out + reduce_offset(p->rank, gid, p->shape, p->out_stride);
+  float mx = -INFINITY;
+  for (int j = 0; j < p->n; ++j) {
+    float v = A[a0 + j * p->stride_in] * p->inv_temp;
+    if (p->has_mask) v += M[m0 + j * p->stride_mask];
+    mx = max(mx, v);
+  }
+  if (mx == -INFINITY) {
+    for (int j = 0; j < p->n; ++j) {
+      Out[o0 + j * p->stride_out] = p->log ? -INFINITY : 0.0f;
+    }
+    return;
+  }
+  float sum = 0.0f;
+  for (int j = 0; j < p->n; ++j) {
+    float v = A[a0 + j * p->stride_in] * p->inv_temp;
+    if (p->has_mask) v += M[m0 + j * p->stride_mask];
+    sum += exp(v - mx);
+  }
+  float lse = log(sum);
+  for (int j = 0; j < p->n; ++j) {
+    float v = A[a0 + j * p->stride_in] * p->inv_temp;
+    if (p->has_mask) v += M[m0 + j * p->stride_mask];
+    Out[o0 + j * p->stride_out] = p->log ? (v - mx - lse) : exp(v - mx) / sum;
+  }
+}
+
+// ---------- Strided batched matmul (float32) ----------
+
+// Params for matmul_strided_f32: C[batch..., m, n] = A[..., m, k] x B[..., k, n].
+// A and B are addressed through element strides (so transposed and
+// batch-broadcast views need no copy); C is dense. Grid = (n, m, batch).
+typedef struct MatMulStridedParams {
+  int batch, m, k, n;
+  int off_a, off_b, off_c;
+  int a_row, a_col, b_row, b_col;
+  int batch_rank;
+  int batch_shape[MAX_DIMS];
+  int a_batch_stride[MAX_DIMS];
+  int b_batch_stride[MAX_DIMS];
+} MatMulStridedParams;
+
+kernel void matmul_strided_f32(
+  device const MatMulStridedParams *p,
+  device const float *A,
+  device const float *B,
+  device float *C,
+  uint3 gid [[thread_position_in_grid]]
+) {
+  if (gid.x >= (uint)p->n || gid.y >= (uint)p->m || gid.z >= (uint)p->batch) {
+    return;
+  }
+  int a = p->off_a + reduce_offset(p->batch_rank, gid.z, p->batch_shape, p->a_batch_stride) + (int)gid.y * p->a_row;
+  int b = p->off_b + reduce_offset(p->batch_rank, gid.z, p->batch_shape, p->b_batch_stride) + (int)gid.x * p->b_col;
+  float sum = 0.0f;
+  for (int kk = 0; kk < p->k; ++kk) {
+    sum += A[a + kk * p->a_col] * B[b + kk * p->b_row];
+  }
+  C[p->off_c + ((int)gid.z * p->m + (int)gid.y) * p->n + (int)gid.x] = sum;
+}
+
+// ---------- Strided copy (any dtype of 1, 2, 4 or 8 bytes) ----------
+
+// Params for copy_strided: copies n elements of elem_size bytes from a
+// strided source view into a strided destination view. Offsets and strides
+// are in bytes; one thread per element.
+typedef struct CopyParams {
+  int n, elem_size, rank;
+  int off_src, off_dst;
+  int shape[MAX_DIMS];
+  int src_stride[MAX_DIMS];
+  int dst_stride[MAX_DIMS];
+} CopyParams;
+
+kernel void copy_strided(
+  device const CopyParams *p,
+  device const uchar *Src,
+  device uchar *Dst,
+  uint gid [[thread_position_in_grid]]
+) {
+  if (gid >= (uint)p->n) {
+    return;
+  }
+  int s = p->off_src + reduce_offset(p->rank, gid, p->shape, p->src_stride);
+  int d = p->off_dst + reduce_offset(p->rank, gid, p->shape, p->dst_stride);
+  for (int i = 0; i < p->elem_size; ++i) {
+    Dst[d + i] = Src[s + i];
+  }
+}
+
+// ---------- Index gather/scatter (any dtype of 1, 2, 4 or 8 bytes) ----------
+
+// Params for index_copy_strided. The grid covers `shape` (one thread per
+// element). Along `dim` one side is addressed through the index tensor:
+// the source for gathers (scatter == 0) and the destination for scatters.
+// Byte offsets/strides for src and dst; index offset/strides in index
+// elements (int32, or int64 when idx64 is set). Indices are validated on the
+// host; `limit` (the indexed side's dim size) only guards against races.
+typedef struct IndexParams {
+  int n, elem_size, rank, dim;
+  int scatter, idx64, limit;
+  int off_src, off_dst, off_idx;
+  int shape[MAX_DIMS];
+  int src_stride[MAX_DIMS];
+  int dst_stride[MAX_DIMS];
+  int idx_stride[MAX_DIMS];
+} IndexParams;
+
+kernel void index_copy_strided(
+  device const IndexParams *p,
+  device const uchar *Src,
+  device const int *Idx,
+  device uchar *Dst,
+  uint gid [[thread_position_in_grid]]
+) {
+  if (gid >= (uint)p->n) {
+    return;
+  }
+  int s = p->off_src, d = p->off_dst, k = p->off_idx, pos = 0;
+  uint rem = gid;
+  for (int r = p->rank - 1; r >= 0; --r) {
+    uint size = (uint)p->shape[r];
+    int i = (int)(rem % size);
+    rem /= size;
+    k += i * p->idx_stride[r];
+    if (r == p->dim) {
+      pos = i;
+    } else {
+      s += i * p->src_stride[r];
+      d += i * p->dst_stride[r];
+    }
+  }
+  long j = p->idx64 ? ((device const long *)Idx)[k] : (long)Idx[k];
+  j = clamp(j, 0L, (long)(p->limit - 1));
+  if (p->scatter) {
+    s += pos * p->src_stride[p->dim];
+    d += (int)j * p->dst_stride[p->dim];
+  } else {
+    s += (int)j * p->src_stride[p->dim];
+    d += pos * p->dst_stride[p->dim];
+  }
+  for (int b = 0; b < p->elem_size; ++b) {
+    Dst[d + b] = Src[s + b];
+  }
+}
+
+// ---------- Row ops along one dim: cumsum / top-k (float32) ----------
+
+// Params shared by row kernels. One thread per row; shape/in_stride/
+// out_stride cover every dim except the op's dim, and stride_in/stride_out
+// step along it. n is the row length, k the output row length for top-k.
+// Offsets and strides are in elements.
+typedef struct RowOpParams {
+  int n_rows, n, k;
+  int off_in, off_out;
+  int stride_in, stride_out;
+  int rank;
+  int shape[MAX_DIMS];
+  int in_stride[MAX_DIMS];
+  int out_stride[MAX_DIMS];
+  int descending;
+} RowOpParams;
+
+kernel void cumsum_f32(
+  device const RowOpParams *p,
+  device const float *A,
+  device float *Out,
+  uint gid [[thread_position_in_grid]]
+) {
+  if (gid >= (uint)p->n_rows) {
+    return;
+  }
+  int a = p->off_in + reduce_offset(p->rank, gid, p->shape, p->in_stride);
+  int o = p->off_out + reduce_offset(p->rank, gid, p->shape, p->out_stride);
+  float acc = 0.0f;
+  for (int j = 0; j < p->n; ++j) {
+    acc += A[a + j * p->stride_in];
+    Out[o + j * p->stride_out] = acc;
+  }
+}
+
+#define TOPK_MAX 64
+
+// greater orders NaN above every number, matching tensor.TopK.
+inline bool topk_greater(float a, float b) {
+  return isnan(a) ? !isnan(b) : (!isnan(b) && a > b);
+}
+
+// Largest k (<= TOPK_MAX) entries per row in descending order; ties keep the
+// lower index first. Writes values and int64 indices.
+kernel void topk_f32(
+  device const RowOpParams *p,
+  device const float *A,
+  device float *Values,
+  device long *Indices,
+  uint gid [[thread_position_in_grid]]
+) {
+  if (gid >= (uint)p->n_rows) {
+    return;
+  }
+  int a = p->off_in + reduce_offset(p->rank, gid, p->shape, p->in_stride);
+  int o = p->off_out + reduce_offset(p->rank, gid, p->shape, p->out_stride);
+  float vals[TOPK_MAX];
+  int idx[TOPK_MAX];
+  int count = 0;
+  for (int j = 0; j < p->n; ++j) {
+    float v = A[a + j * p->stride_in];
+    if (count == p->k && !topk_greater(v, vals[count - 1])) {
+      continue;
+    }
+    int pos = min(count, p->k - 1);
+    while (pos > 0 && topk_greater(v, vals[pos - 1])) {
+      vals[pos] = vals[pos - 1];
+      idx[pos] = idx[pos - 1];
+      --pos;
+    }
+    vals[pos] = v;
+    idx[pos] = j;
+    count = min(count + 1, p->k);
+  }
+  for (int j = 0; j < p->k; ++j) {
+    Values[o + j * p->stride_out] = vals[j];
+    Indices[o + j * p->stride_out] = (long)idx[j];
+  }
+}
+
+// Stable sort of each row by bottom-up merge sort of its indices, which
+// ping-pong between Indices and Scratch (laid out alike); Values receives the
+// sorted row. Descending puts NaN first, ascending last, as in tensor.Sort.
+kernel void argsort_f32(
+  device const RowOpParams *p,
+  device const float *A,
+  device float *Values,
+  device long *Indices,
+  device long *Scratch,
+  uint gid [[thread_position_in_grid]]
+) {
+  if (gid >= (uint)p->n_rows) {
+    return;
+  }
+  int a = p->off_in + reduce_offset(p->rank, gid, p->shape, p->in_stride);
+  int o = p->off_out + reduce_offset(p->rank, gid, p->shape, p->out_stride);
+  int n = p->n, si = p->stride_in, so = p->stride_out;
+  device long *src = Indices;
+  device long *dst = Scratch;
+  for (int j = 0; j < n; ++j) {
+    src[o + j * so] = j;
+  }
+  for (int width = 1; width < n; width *= 2) {
+    for (int lo = 0; lo < n; lo += 2 * width) {
+      int mid = min(lo + width, n), hi = min(lo + 2 * width, n);
+      int i = lo, j = mid;
+      for (int w = lo; w < hi; ++w) {
+        bool right = j < hi && i >= mid;
+        if (j < hi && i < mid) {
+          float vl = A[a + (int)src[o + i * so] * si];
+          float vr = A[a + (int)src[o + j * so] * si];
+          right = p->descending ? topk_greater(vr, vl) : topk_greater(vl, vr);
+        }
+        dst[o + w * so] = right ? src[o + (j++) * so] : src[o + (i++) * so];
+      }
+    }
+    device long *t = src;
+    src = dst;
+    dst = t;
+  }
+  for (int j = 0; j < n; ++j) {
+    long k = src[o + j * so];
+    Indices[o + j * so] = k;
+    Values[o + j * so] = A[a + (int)k * si];
+  }
+}
+
+// ---------- Masked select (float32) ----------
+
+// Grid = (n, 1, 1); Out[gid] = A[...] where Cond[...] is nonzero, leaving the
+// other outputs untouched. Out is pre-filled with the "false" operand. Cond is
+// bool (one byte per element), so its offset and strides (off_a, stride_a)
+// count bytes; A uses off_b/stride_b in elements.
+kernel void where_f32(
+  device const ElementwiseParams *params,
+  device const uchar *Cond,
+  device const float *A,
+  device float *Out,
+  uint gid [[thread_position_in_grid]]
+) {
+  if (gid >= (uint)params->n) {
+    return;
+  }
+  int oc, oa;
+  strided_offsets(params, gid, oc, oa);
+  if (Cond[oc] != 0) {
+    Out[params->off_out + gid] = A[oa];
+  }
+}
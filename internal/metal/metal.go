@@ -64,10 +64,14 @@ func InitializeBuffersFloat32(a, b []float32, aRows, aCols, bRows, bCols int) (i
 	return outCount, nil
 }
 
-// Buffer is a thin wrapper over an MTLBuffer for tensor storage.
+// Buffer is a thin wrapper over an MTLBuffer for tensor storage. A host
+// buffer from NewHostBuffer holds Go memory instead, and a meta buffer from
+// NewMetaBuffer holds nothing; neither is ever bound to a kernel.
 type Buffer struct {
 	ptr  unsafe.Pointer
 	size int
+	data []byte // host buffers only
+	meta bool   // meta buffers: a size without storage
 }
 
 // NewBuffer allocates an MTLBuffer of given size in bytes.
@@ -82,14 +86,55 @@ func NewBuffer(size int) (*Buffer, error) {
 	return &Buffer{ptr: p, size: size}, nil
 }
 
+// NewHostBuffer allocates a buffer of size bytes in Go memory.
+func NewHostBuffer(size int) (*Buffer, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid buffer size: %d", size)
+	}
+	return &Buffer{size: size, data: make([]byte, size)}, nil
+}
+
+// NewMetaBuffer returns a buffer that records size but has no storage;
+// reads and writes fail with ErrNoStorage.
+func NewMetaBuffer(size int) (*Buffer, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid buffer size: %d", size)
+	}
+	return &Buffer{size: size, meta: true}, nil
+}
+
+// Meta reports whether b was allocated by NewMetaBuffer.
+func (b *Buffer) Meta() bool { return b != nil && b.meta }
+
+// ErrNoStorage is returned by reads and writes of a meta buffer.
+var ErrNoStorage = errors.New("meta buffer has no storage")
+
+// Host reports whether b was allocated by NewHostBuffer.
+func (b *Buffer) Host() bool { return b != nil && b.data != nil }
+
+// Bytes returns the memory of a host buffer, or nil for a device buffer.
+func (b *Buffer) Bytes() []byte {
+	if b == nil {
+		return nil
+	}
+	return b.data
+}
+
 // Write copies host bytes into the buffer.
 func (b *Buffer) Write(src []byte) error {
-	if b == nil || b.ptr == nil {
+	if b.Meta() {
+		return ErrNoStorage
+	}
+	if b == nil || b.ptr == nil && b.data == nil {
 		return fmt.Errorf("nil buffer")
 	}
 	if len(src) > b.size {
 		return fmt.Errorf("write overflow: %d > %d", len(src), b.size)
 	}
+	if b.data != nil {
+		copy(b.data, src)
+		return nil
+	}
 	if len(src) == 0 {
 		return nil
 	}
@@ -99,12 +144,19 @@ func (b *Buffer) Write(src []byte) error {
 
 // Read copies buffer bytes into dst.
 func (b *Buffer) Read(dst []byte) error {
-	if b == nil || b.ptr == nil {
+	if b.Meta() {
+		return ErrNoStorage
+	}
+	if b == nil || b.ptr == nil && b.data == nil {
 		return fmt.Errorf("nil buffer")
 	}
 	if len(dst) > b.size {
 		return fmt.Errorf("read overflow: %d > %d", len(dst), b.size)
 	}
+	if b.data != nil {
+		copy(dst, b.data)
+		return nil
+	}
 	if len(dst) == 0 {
 		return nil
 	}
@@ -113,6 +165,9 @@ func (b *Buffer) Read(dst []byte) error {
 }
 
 func (b *Buffer) ReadN(start int, numberBytes int) ([]byte, error) {
+	if b.Meta() {
+		return nil, ErrNoStorage
+	}
 	if b == nil {
 		return nil, errors.New("nil buffer")
 	}
@@ -128,6 +183,9 @@ func (b *Buffer) ReadN(start int, numberBytes int) ([]byte, error) {
 	if numberBytes == 0 {
 		return []byte{}, nil
 	}
+	if b.data != nil {
+		return append([]byte{}, b.data[start:start+numberBytes]...), nil
+	}
 
 	// construct destination buffer with size equal to the number of bytes we want to read
 	dst := make([]byte, numberBytes)
@@ -137,6 +195,28 @@ func (b *Buffer) ReadN(start int, numberBytes int) ([]byte, error) {
 	return dst, nil
 }
 
+// WriteAt copies host bytes into the buffer starting at byte offset start.
+func (b *Buffer) WriteAt(start int, src []byte) error {
+	if b.Meta() {
+		return ErrNoStorage
+	}
+	if b == nil || b.ptr == nil && b.data == nil {
+		return fmt.Errorf("nil buffer")
+	}
+	if start < 0 || start+len(src) > b.size {
+		return fmt.Errorf("write [%d,%d) out of bounds of buffer with size %d", start, start+len(src), b.size)
+	}
+	if b.data != nil {
+		copy(b.data[start:], src)
+		return nil
+	}
+	if len(src) == 0 {
+		return nil
+	}
+	C.mtl_buffer_write_at(b.ptr, C.int(start), unsafe.Pointer(&src[0]), C.int(len(src)))
+	return nil
+}
+
 // Size returns the buffer length in bytes.
 func (b *Buffer) Size() int {
 	if b == nil {
@@ -153,9 +233,17 @@ func (b *Buffer) Ptr() unsafe.Pointer {
 	return b.ptr
 }
 
-// Close releases the underlying MTLBuffer.
+// Close releases the underlying MTLBuffer, or drops a host buffer's memory.
 func (b *Buffer) Close() error {
-	if b == nil || b.ptr == nil {
+	if b == nil {
+		return nil
+	}
+	if b.data != nil || b.meta {
+		b.data = nil
+		b.size = 0
+		return nil
+	}
+	if b.ptr == nil {
 		return nil
 	}
 	C.mtl_release_buffer(b.ptr)
@@ -180,6 +268,10 @@ func MultiplyNaiveBuffers(a, b, c *Buffer, aRows, aCols, bRows, bCols int) error
 
 // -------- Generic multi-kernel helpers --------
 
+// Ready reports whether a kernel library has been compiled, i.e. whether
+// callers can dispatch kernels instead of falling back to host code.
+func Ready() bool { return C.mtl_library_ready() != 0 }
+
 // EnsureKernel compiles and caches a pipeline for the given kernel name using the current library.
 func EnsureKernel(kernelName string) {
 	c := C.CString(kernelName)
@@ -188,8 +280,12 @@ func EnsureKernel(kernelName string) {
 }
 
 // RunKernel3 runs a named kernel with raw params pointer and three buffers, over a 3D grid.
-// paramsPtr may be nil if the kernel takes no params.
+// paramsPtr may be nil if the kernel takes no params. An empty grid, as for an
+// empty tensor, dispatches nothing.
 func RunKernel3(kernelName string, paramsPtr unsafe.Pointer, paramsLen int, gridX, gridY, gridZ int, b0, b1, b2 *Buffer) error {
+	if gridX <= 0 || gridY <= 0 || gridZ <= 0 {
+		return nil
+	}
 	cname := C.CString(kernelName)
 	defer C.free(unsafe.Pointer(cname))
 	var p0, p1, p2 unsafe.Pointer
@@ -232,4 +328,331 @@ func MatMulBatchedBuffers(a, b, c *Buffer, batch, m, k, n int) error {
 		n, m, batch,
 		a, b, c,
 	)
-}
\ No newline at end of file
+}
+
+// CastParams mirrors CastParams in mm.metal for cast_elementwise.
+type CastParams struct {
+	N         int32
+	SrcDType  int32
+	DstDType  int32
+	SrcOffset int32
+	DstOffset int32
+}
+
+// CastBuffers runs cast_elementwise over n contiguous elements, converting
+// src (dtype code srcDT at byte offset srcOff) into dst. Dtype codes follow
+// tensor.DType: any float, int8 or int4 type, with float8_e8m0 as a source
+// only.
+func CastBuffers(src, dst *Buffer, n, srcDT, dstDT, srcOff, dstOff int) error {
+	if src == nil || dst == nil {
+		return fmt.Errorf("nil buffer")
+	}
+	if n <= 0 {
+		return fmt.Errorf("invalid element count: %d", n)
+	}
+	threads := n
+	if dstDT == dtInt4 || dstDT == dtFloat4E2M1 {
+		threads = (n + 1) / 2 // one thread per packed output byte
+	}
+	params := CastParams{N: int32(n), SrcDType: int32(srcDT), DstDType: int32(dstDT), SrcOffset: int32(srcOff), DstOffset: int32(dstOff)}
+	return RunKernel3(
+		"cast_elementwise",
+		unsafe.Pointer(&params), int(unsafe.Sizeof(params)),
+		threads, 1, 1,
+		src, dst, nil,
+	)
+}
+
+// Codes of tensor.Int4 and tensor.Float4E2M1, which pack two elements per
+// byte.
+const (
+	dtInt4       = 4
+	dtFloat4E2M1 = 7
+)
+
+// MaxDims is the highest rank supported by the strided elementwise kernels.
+const MaxDims = 8
+
+// ElementwiseParams mirrors ElementwiseParams in mm.metal. Offsets and strides
+// are in elements; the output is dense starting at OffOut.
+type ElementwiseParams struct {
+	Op      int32
+	Rank    int32
+	N       int32
+	Scalar  float32
+	OffA    int32
+	OffB    int32
+	OffOut  int32
+	Shape   [MaxDims]int32
+	StrideA [MaxDims]int32
+	StrideB [MaxDims]int32
+}
+
+// ElementwiseBinaryBuffers runs elementwise_binary_f32: out = op(a, b).
+func ElementwiseBinaryBuffers(p *ElementwiseParams, a, b, out *Buffer) error {
+	if a == nil || b == nil || out == nil || p == nil {
+		return fmt.Errorf("nil buffer")
+	}
+	return RunKernel3("elementwise_binary_f32", unsafe.Pointer(p), int(unsafe.Sizeof(*p)), int(p.N), 1, 1, a, b, out)
+}
+
+// ElementwiseUnaryBuffers runs elementwise_unary_f32: out = op(a, p.Scalar).
+func ElementwiseUnaryBuffers(p *ElementwiseParams, a, out *Buffer) error {
+	if a == nil || out == nil || p == nil {
+		return fmt.Errorf("nil buffer")
+	}
+	return RunKernel3("elementwise_unary_f32", unsafe.Pointer(p), int(unsafe.Sizeof(*p)), int(p.N), 1, 1, a, out, nil)
+}
+
+// Limits of the generated fused elementwise kernels.
+const (
+	MaxFusedInputs  = 8
+	MaxFusedScalars = 16
+)
+
+// FusedParams is the params struct of generated fused elementwise kernels.
+// Offsets and strides are in elements; input i is bound at buffer i+2 after
+// the dense output at buffer 1.
+type FusedParams struct {
+	N      int32
+	Rank   int32
+	OffOut int32
+	Shape  [MaxDims]int32
+	Off    [MaxFusedInputs]int32
+	Stride [MaxFusedInputs][MaxDims]int32
+	Scalar [MaxFusedScalars]float32
+}
+
+// CompileKernel compiles source, which must define a kernel called name, into
+// a library of its own and registers the pipeline for RunKernelN. A name that
+// is already registered is not compiled again.
+func CompileKernel(name, source string) error {
+	cname := C.CString(name)
+	csrc := C.CString(source)
+	defer C.free(unsafe.Pointer(cname))
+	defer C.free(unsafe.Pointer(csrc))
+	if C.mtl_compile_kernel(cname, csrc) != 0 {
+		return fmt.Errorf("compile kernel %s failed", name)
+	}
+	return nil
+}
+
+// RunKernelN runs a named kernel with params at index 0 and bufs bound from
+// index 1, over a 1-D grid of gridX threads.
+func RunKernelN(kernelName string, paramsPtr unsafe.Pointer, paramsLen int, gridX int, bufs ...*Buffer) error {
+	if gridX <= 0 {
+		return nil
+	}
+	var ptrs [MaxFusedInputs + 1]unsafe.Pointer
+	if len(bufs) > len(ptrs) {
+		return fmt.Errorf("%d buffers, at most %d", len(bufs), len(ptrs))
+	}
+	for i, b := range bufs {
+		if b == nil {
+			return fmt.Errorf("nil buffer")
+		}
+		ptrs[i] = b.ptr
+	}
+	cname := C.CString(kernelName)
+	defer C.free(unsafe.Pointer(cname))
+	C.mtl_run_kernel_named_n(cname, paramsPtr, C.int(paramsLen), &ptrs[0], C.int(len(bufs)), C.int(gridX))
+	return nil
+}
+
+// ReduceParams mirrors ReduceParams in mm.metal. Offsets and strides are in
+// input elements; the output is dense starting at OffOut.
+type ReduceParams struct {
+	Op         int32
+	NOut       int32
+	NRed       int32
+	Correction int32
+	OffIn      int32
+	OffOut     int32
+	OutRank    int32
+	RedRank    int32
+	OutShape   [MaxDims]int32
+	OutStride  [MaxDims]int32
+	RedShape   [MaxDims]int32
+	RedStride  [MaxDims]int32
+}
+
+// ReduceBuffers runs reduce_f32 (sum/mean/max/min/var), or reduce_arg_f32
+// writing int32 indices when arg is true.
+func ReduceBuffers(p *ReduceParams, arg bool, a, out *Buffer) error {
+	if a == nil || out == nil || p == nil {
+		return fmt.Errorf("nil buffer")
+	}
+	name := "reduce_f32"
+	if arg {
+		name = "reduce_arg_f32"
+	}
+	return RunKernel3(name, unsafe.Pointer(p), int(unsafe.Sizeof(*p)), int(p.NOut), 1, 1, a, out, nil)
+}
+
+// SoftmaxParams mirrors SoftmaxParams in mm.metal. Offsets and strides are in
+// elements; Shape and the *Stride arrays cover every dim except the softmax dim.
+type SoftmaxParams struct {
+	Log        int32
+	HasMask    int32
+	NRows      int32
+	N          int32
+	InvTemp    float32
+	OffIn      int32
+	OffMask    int32
+	OffOut     int32
+	StrideIn   int32
+	StrideMask int32
+	StrideOut  int32
+	Rank       int32
+	Shape      [MaxDims]int32
+	InStride   [MaxDims]int32
+	MaskStride [MaxDims]int32
+	OutStride  [MaxDims]int32
+}
+
+// SoftmaxBuffers runs softmax_f32. mask is ignored unless p.HasMask is set.
+func SoftmaxBuffers(p *SoftmaxParams, a, mask, out *Buffer) error {
+	if a == nil || mask == nil || out == nil || p == nil {
+		return fmt.Errorf("nil buffer")
+	}
+	return RunKernel3("softmax_f32", unsafe.Pointer(p), int(unsafe.Sizeof(*p)), int(p.NRows), 1, 1, a, mask, out)
+}
+
+// MatMulStridedParams mirrors MatMulStridedParams in mm.metal. Offsets and
+// strides are in elements; C is dense.
+type MatMulStridedParams struct {
+	Batch        int32
+	M            int32
+	K            int32
+	N            int32
+	OffA         int32
+	OffB         int32
+	OffC         int32
+	ARow         int32
+	ACol         int32
+	BRow         int32
+	BCol         int32
+	BatchRank    int32
+	BatchShape   [MaxDims]int32
+	ABatchStride [MaxDims]int32
+	BBatchStride [MaxDims]int32
+}
+
+// MatMulStridedBuffers runs matmul_strided_f32 over grid (N, M, Batch).
+func MatMulStridedBuffers(p *MatMulStridedParams, a, b, c *Buffer) error {
+	if a == nil || b == nil || c == nil || p == nil {
+		return fmt.Errorf("nil buffer")
+	}
+	return RunKernel3("matmul_strided_f32", unsafe.Pointer(p), int(unsafe.Sizeof(*p)), int(p.N), int(p.M), int(p.Batch), a, b, c)
+}
+
+// CopyParams mirrors CopyParams in mm.metal. Offsets and strides are in bytes.
+type CopyParams struct {
+	N         int32
+	ElemSize  int32
+	Rank      int32
+	OffSrc    int32
+	OffDst    int32
+	Shape     [MaxDims]int32
+	SrcStride [MaxDims]int32
+	DstStride [MaxDims]int32
+}
+
+// CopyStridedBuffers runs copy_strided, copying p.N elements between strided
+// views of src and dst.
+func CopyStridedBuffers(p *CopyParams, src, dst *Buffer) error {
+	if src == nil || dst == nil || p == nil {
+		return fmt.Errorf("nil buffer")
+	}
+	return RunKernel3("copy_strided", unsafe.Pointer(p), int(unsafe.Sizeof(*p)), int(p.N), 1, 1, src, dst, nil)
+}
+
+// IndexParams mirrors IndexParams in mm.metal. Src/dst offsets and strides
+// are in bytes; index offsets and strides are in index elements.
+type IndexParams struct {
+	N         int32
+	ElemSize  int32
+	Rank      int32
+	Dim       int32
+	Scatter   int32
+	Idx64     int32
+	Limit     int32
+	OffSrc    int32
+	OffDst    int32
+	OffIdx    int32
+	Shape     [MaxDims]int32
+	SrcStride [MaxDims]int32
+	DstStride [MaxDims]int32
+	IdxStride [MaxDims]int32
+}
+
+// IndexCopyBuffers runs index_copy_strided, gathering from src or scattering
+// into dst through the int32/int64 indices in idx.
+func IndexCopyBuffers(p *IndexParams, src, idx, dst *Buffer) error {
+	if src == nil || idx == nil || dst == nil || p == nil {
+		return fmt.Errorf("nil buffer")
+	}
+	return RunKernel3("index_copy_strided", unsafe.Pointer(p), int(unsafe.Sizeof(*p)), int(p.N), 1, 1, src, idx, dst)
+}
+
+// TopKMax is the largest k topk_f32 supports.
+const TopKMax = 64
+
+// RowOpParams mirrors RowOpParams in mm.metal, shared by cumsum_f32,
+// topk_f32 and argsort_f32. Offsets and strides are in elements.
+type RowOpParams struct {
+	NRows     int32
+	N         int32
+	K         int32
+	OffIn     int32
+	OffOut    int32
+	StrideIn  int32
+	StrideOut int32
+	Rank      int32
+	Shape     [MaxDims]int32
+	InStride  [MaxDims]int32
+	OutStride [MaxDims]int32
+	// Descending orders argsort_f32 from largest to smallest.
+	Descending int32
+}
+
+// CumSumBuffers runs cumsum_f32, one thread per row.
+func CumSumBuffers(p *RowOpParams, a, out *Buffer) error {
+	if a == nil || out == nil || p == nil {
+		return fmt.Errorf("nil buffer")
+	}
+	return RunKernel3("cumsum_f32", unsafe.Pointer(p), int(unsafe.Sizeof(*p)), int(p.NRows), 1, 1, a, out, nil)
+}
+
+// TopKBuffers runs topk_f32, writing float32 values and int64 indices.
+func TopKBuffers(p *RowOpParams, a, values, indices *Buffer) error {
+	if a == nil || values == nil || indices == nil || p == nil {
+		return fmt.Errorf("nil buffer")
+	}
+	if p.K <= 0 || p.K > TopKMax {
+		return fmt.Errorf("k must be in [1, %d]", TopKMax)
+	}
+	return RunKernel3("topk_f32", unsafe.Pointer(p), int(unsafe.Sizeof(*p)), int(p.NRows), 1, 1, a, values, indices)
+}
+
+// ArgSortBuffers runs argsort_f32, a stable sort of each row on the GPU
+// writing the sorted float32 values and their int64 indices. scratch holds
+// int64 merge buffers laid out like indices.
+func ArgSortBuffers(p *RowOpParams, a, values, indices, scratch *Buffer) error {
+	if a == nil || values == nil || indices == nil || scratch == nil || p == nil {
+		return fmt.Errorf("nil buffer")
+	}
+	EnsureKernel("argsort_f32")
+	return RunKernelN("argsort_f32", unsafe.Pointer(p), int(unsafe.Sizeof(*p)), int(p.NRows), a, values, indices, scratch)
+}
+
+// WhereBuffers runs where_f32: out[i] = a[...] wherever cond[...] is set. The
+// params use ElementwiseParams with OffA/StrideA addressing cond in bytes and
+// OffB/StrideB addressing a in elements; out must already hold the values for
+// unset positions.
+func WhereBuffers(p *ElementwiseParams, cond, a, out *Buffer) error {
+	if cond == nil || a == nil || out == nil || p == nil {
+		return fmt.Errorf("nil buffer")
+	}
+	return RunKernel3("where_f32", unsafe.Pointer(p), int(unsafe.Sizeof(*p)), int(p.N), 1, 1, cond, a, out)
+}
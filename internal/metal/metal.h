@@ -30,6 +30,11 @@ void  mtl_buffer_write(void* buf, void* src, int length_bytes);
 void  mtl_buffer_read(void* buf, void* dst, int length_bytes);
 // Read from buffer starting at byte offset into dst for length_bytes.
 void  mtl_buffer_read_at(void* buf, int offset_bytes, void* dst, int length_bytes);
+// Write length_bytes from src into buffer starting at byte offset.
+void  mtl_buffer_write_at(void* buf, int offset_bytes, void* src, int length_bytes);
+
+// Reports whether a kernel library has been compiled (1) or not (0).
+int   mtl_library_ready(void);
 
 // Kernel invocation using provided buffers (2D naive)
 void* metal_mult_naive_with_buffers(MatrixParams *params, void* bufA, void* bufB, void* bufC);
@@ -45,4 +50,19 @@ void* mtl_run_kernel_named_3(
   int gridX,
   int gridY,
   int gridZ
-);
\ No newline at end of file
+);
+// Compiles source into a library of its own and registers its kernel_name
+// pipeline for the named-kernel runners. Already registered names are kept.
+// Returns 0 on success.
+int mtl_compile_kernel(char* kernel_name, char* source);
+
+// Generic named-kernel runner binding params at index 0 and n buffers from
+// index 1, over a 1-D grid of gridX threads.
+void mtl_run_kernel_named_n(
+  char* kernel_name,
+  void* params,
+  int params_len,
+  void** bufs,
+  int n,
+  int gridX
+);
@@ -275,6 +275,22 @@ mtl_buffer_read_at(void* buf, int offset_bytes, void* dst, int length_bytes) {
   memcpy(dst, (void *)((char*)o.contents + off), len);
 }
 
+void
+mtl_buffer_write_at(void* buf, int offset_bytes, void* src, int length_bytes) {
+  if (buf == nil || src == nil || length_bytes <= 0) return;
+  id<MTLBuffer> o = (__bridge id<MTLBuffer>)buf;
+  if (offset_bytes < 0) return;
+  size_t off = (size_t)offset_bytes;
+  size_t len = (size_t)length_bytes;
+  if (off + len > o.length) return;
+  memcpy((void *)((char*)o.contents + off), src, len);
+}
+
+int
+mtl_library_ready(void) {
+  return (gLibrary != nil && device != nil && commandQueue != nil) ? 1 : 0;
+}
+
 static void*
 metal_mult_with_buffers(MatrixParams *params, id<MTLComputePipelineState> pipelineState, id<MTLBuffer> a, id<MTLBuffer> b, id<MTLBuffer> c)
 {
@@ -373,4 +389,76 @@ mtl_run_kernel_named_3(
     }
     return nil;
   }
-}
\ No newline at end of file
+}
+int
+mtl_compile_kernel(char* kernel_name, char* source) {
+  @autoreleasepool {
+    ensureDeviceAndQueue();
+    if (device == nil) return -1;
+    if (!pipelineMap) pipelineMap = [NSMutableDictionary new];
+    NSString *k = [NSString stringWithUTF8String:kernel_name];
+    if (pipelineMap[k] != nil) return 0;
+    NSError *error = nil;
+    // Same options as the main library, so shared math compiles identically.
+    MTLCompileOptions *compileOptions = [MTLCompileOptions new];
+    compileOptions.languageVersion = MTLLanguageVersion2_4;
+    NSString *ss = [NSString stringWithUTF8String:source];
+    id<MTLLibrary> lib = [device newLibraryWithSource:ss options:compileOptions error:&error];
+    if (lib == nil) {
+      NSLog(@"Failed to compile kernel '%@': %@", k, error);
+      return -1;
+    }
+    id<MTLFunction> fn = [lib newFunctionWithName:k];
+    if (fn == nil) {
+      NSLog(@"Kernel '%@' not found in its source.", k);
+      return -1;
+    }
+    id<MTLComputePipelineState> p = [device newComputePipelineStateWithFunction:fn error:&error];
+    if (p == nil) {
+      NSLog(@"Failed to create pipeline for '%@': %@", k, error);
+      return -1;
+    }
+    pipelineMap[k] = p;
+    return 0;
+  }
+}
+
+void
+mtl_run_kernel_named_n(
+  char* kernel_name,
+  void* params,
+  int params_len,
+  void** bufs,
+  int n,
+  int gridX
+) {
+  @autoreleasepool {
+    ensureDeviceAndQueue();
+    if (device == nil || commandQueue == nil) return;
+    NSString *k = [NSString stringWithUTF8String:kernel_name];
+    id<MTLComputePipelineState> p = pipelineMap[k];
+    if (p == nil) {
+      NSLog(@"Kernel '%@' not compiled.", k);
+      return;
+    }
+    id<MTLCommandBuffer> commandBuffer = [commandQueue commandBuffer];
+    if (commandBuffer == nil) return;
+    id<MTLComputeCommandEncoder> computeEncoder = [commandBuffer computeCommandEncoder];
+    if (computeEncoder == nil) return;
+    [computeEncoder setComputePipelineState:p];
+    if (params != nil && params_len > 0) {
+      [computeEncoder setBytes:params length:params_len atIndex:0];
+    }
+    for (int i = 0; i < n; i++) {
+      if (bufs[i]) [computeEncoder setBuffer:(__bridge id<MTLBuffer>)bufs[i] offset:0 atIndex:i + 1];
+    }
+    MTLSize threadsPerGrid = MTLSizeMake((NSUInteger)gridX, 1, 1);
+    NSUInteger w = p.threadExecutionWidth;
+    NSUInteger h = MAX((NSUInteger)1, p.maxTotalThreadsPerThreadgroup / w);
+    MTLSize threadsPerThreadgroup = MTLSizeMake(w, h, 1);
+    [computeEncoder dispatchThreads:threadsPerGrid threadsPerThreadgroup:threadsPerThreadgroup];
+    [computeEncoder endEncoding];
+    [commandBuffer commit];
+    [commandBuffer waitUntilCompleted];
+  }
+}
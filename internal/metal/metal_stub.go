@@ -2,9 +2,16 @@
 
 package metal
 
-import "unsafe"
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+)
 
 // Stubs for non-macOS or when cgo is disabled, so the package compiles.
+// Buffers keep their bytes in host memory and Ready reports false, so the
+// tensor package runs every op on its CPU path; kernel entry points do
+// nothing.
 
 type MatrixParams struct {
 	a_rows, a_cols int32
@@ -18,6 +25,141 @@ type MatMul3DParams struct {
 	N     int32
 }
 
+const MaxDims = 8
+
+type ElementwiseParams struct {
+	Op      int32
+	Rank    int32
+	N       int32
+	Scalar  float32
+	OffA    int32
+	OffB    int32
+	OffOut  int32
+	Shape   [MaxDims]int32
+	StrideA [MaxDims]int32
+	StrideB [MaxDims]int32
+}
+
+const (
+	MaxFusedInputs  = 8
+	MaxFusedScalars = 16
+)
+
+type FusedParams struct {
+	N      int32
+	Rank   int32
+	OffOut int32
+	Shape  [MaxDims]int32
+	Off    [MaxFusedInputs]int32
+	Stride [MaxFusedInputs][MaxDims]int32
+	Scalar [MaxFusedScalars]float32
+}
+
+type ReduceParams struct {
+	Op         int32
+	NOut       int32
+	NRed       int32
+	Correction int32
+	OffIn      int32
+	OffOut     int32
+	OutRank    int32
+	RedRank    int32
+	OutShape   [MaxDims]int32
+	OutStride  [MaxDims]int32
+	RedShape   [MaxDims]int32
+	RedStride  [MaxDims]int32
+}
+
+type SoftmaxParams struct {
+	Log        int32
+	HasMask    int32
+	NRows      int32
+	N          int32
+	InvTemp    float32
+	OffIn      int32
+	OffMask    int32
+	OffOut     int32
+	StrideIn   int32
+	StrideMask int32
+	StrideOut  int32
+	Rank       int32
+	Shape      [MaxDims]int32
+	InStride   [MaxDims]int32
+	MaskStride [MaxDims]int32
+	OutStride  [MaxDims]int32
+}
+
+type MatMulStridedParams struct {
+	Batch        int32
+	M            int32
+	K            int32
+	N            int32
+	OffA         int32
+	OffB         int32
+	OffC         int32
+	ARow         int32
+	ACol         int32
+	BRow         int32
+	BCol         int32
+	BatchRank    int32
+	BatchShape   [MaxDims]int32
+	ABatchStride [MaxDims]int32
+	BBatchStride [MaxDims]int32
+}
+
+type CopyParams struct {
+	N         int32
+	ElemSize  int32
+	Rank      int32
+	OffSrc    int32
+	OffDst    int32
+	Shape     [MaxDims]int32
+	SrcStride [MaxDims]int32
+	DstStride [MaxDims]int32
+}
+
+type IndexParams struct {
+	N         int32
+	ElemSize  int32
+	Rank      int32
+	Dim       int32
+	Scatter   int32
+	Idx64     int32
+	Limit     int32
+	OffSrc    int32
+	OffDst    int32
+	OffIdx    int32
+	Shape     [MaxDims]int32
+	SrcStride [MaxDims]int32
+	DstStride [MaxDims]int32
+	IdxStride [MaxDims]int32
+}
+
+const TopKMax = 64
+
+type RowOpParams struct {
+	NRows      int32
+	N          int32
+	K          int32
+	OffIn      int32
+	OffOut     int32
+	StrideIn   int32
+	StrideOut  int32
+	Rank       int32
+	Shape      [MaxDims]int32
+	InStride   [MaxDims]int32
+	OutStride  [MaxDims]int32
+	Descending int32
+}
+
+type CastParams struct {
+	N         int32
+	SrcDType  int32
+	DstDType  int32
+	SrcOffset int32
+	DstOffset int32
+}
+
 func initializePipelineAndCommandQueue(_ *byte)                                           {}
 func initializeMTLBuffers(_ unsafe.Pointer, _ unsafe.Pointer, _ int, _ int, _ int, _ int) {}
 func metal_mult_naive(_ *MatrixParams) unsafe.Pointer                                     { return nil }
@@ -29,20 +171,102 @@ func InitializeBuffersFloat32(_ []float32, _ []float32, _ int, _ int, _ int, _ i
 }
 func MultiplyNaive(_ int, _ int, _ int, _ int) []float32 { return nil }
 
-// Buffer stub for non-metal builds
+// Buffer stub for non-metal builds. Storage lives in host memory so tensor
+// code (and its CPU reference ops) still works without a GPU.
 type Buffer struct {
 	ptr  unsafe.Pointer
 	size int
+	data []byte
+	host bool
+	meta bool
 }
 
-func NewBuffer(size int) (*Buffer, error) { return &Buffer{ptr: nil, size: size}, nil }
-func (b *Buffer) Write(_ []byte) error    { return nil }
-func (b *Buffer) Read(_ []byte) error     { return nil }
-func (b *Buffer) ReadN(_ int, numberBytes int) ([]byte, error) {
-	if numberBytes <= 0 {
-		return []byte{}, nil
+func NewBuffer(size int) (*Buffer, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid buffer size: %d", size)
+	}
+	return &Buffer{size: size, data: make([]byte, size)}, nil
+}
+func NewHostBuffer(size int) (*Buffer, error) {
+	b, err := NewBuffer(size)
+	if b != nil {
+		b.host = true
+	}
+	return b, err
+}
+func NewMetaBuffer(size int) (*Buffer, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid buffer size: %d", size)
 	}
-	return make([]byte, numberBytes), nil
+	return &Buffer{size: size, meta: true}, nil
+}
+func (b *Buffer) Meta() bool { return b != nil && b.meta }
+
+var ErrNoStorage = errors.New("meta buffer has no storage")
+
+func (b *Buffer) Host() bool { return b != nil && b.host && b.data != nil }
+func (b *Buffer) Bytes() []byte {
+	if b == nil {
+		return nil
+	}
+	return b.data
+}
+func (b *Buffer) Write(src []byte) error {
+	if b.Meta() {
+		return ErrNoStorage
+	}
+	if b == nil || b.data == nil {
+		return fmt.Errorf("nil buffer")
+	}
+	if len(src) > b.size {
+		return fmt.Errorf("write overflow: %d > %d", len(src), b.size)
+	}
+	copy(b.data, src)
+	return nil
+}
+func (b *Buffer) WriteAt(start int, src []byte) error {
+	if b.Meta() {
+		return ErrNoStorage
+	}
+	if b == nil || b.data == nil {
+		return fmt.Errorf("nil buffer")
+	}
+	if start < 0 || start+len(src) > b.size {
+		return fmt.Errorf("write [%d,%d) out of bounds of buffer with size %d", start, start+len(src), b.size)
+	}
+	copy(b.data[start:], src)
+	return nil
+}
+func (b *Buffer) Read(dst []byte) error {
+	if b.Meta() {
+		return ErrNoStorage
+	}
+	if b == nil || b.data == nil {
+		return fmt.Errorf("nil buffer")
+	}
+	if len(dst) > b.size {
+		return fmt.Errorf("read overflow: %d > %d", len(dst), b.size)
+	}
+	copy(dst, b.data)
+	return nil
+}
+func (b *Buffer) ReadN(start int, numberBytes int) ([]byte, error) {
+	if b.Meta() {
+		return nil, ErrNoStorage
+	}
+	if b == nil || b.data == nil {
+		return nil, errors.New("nil buffer")
+	}
+	if start < 0 || start > b.size {
+		return nil, fmt.Errorf("%d out of bounds of buffer with size %d", start, b.size)
+	}
+	if numberBytes < 0 {
+		return nil, fmt.Errorf("negative read length: %d", numberBytes)
+	}
+	if start+numberBytes > b.size {
+		return nil, fmt.Errorf("%d can not read more bytes than in buffer with size %d", start+numberBytes, b.size)
+	}
+	return append([]byte{}, b.data[start:start+numberBytes]...), nil
 }
 func (b *Buffer) Size() int {
 	if b == nil {
@@ -56,13 +280,35 @@ func (b *Buffer) Ptr() unsafe.Pointer {
 	}
 	return b.ptr
 }
-func (b *Buffer) Close() error { return nil }
+func (b *Buffer) Close() error {
+	if b == nil {
+		return nil
+	}
+	b.data = nil
+	b.size = 0
+	return nil
+}
 
 func MultiplyNaiveBuffers(_ *Buffer, _ *Buffer, _ *Buffer, _ int, _ int, _ int, _ int) error { return nil }
 
 // New multi-kernel generic API stubs
+func Ready() bool           { return false }
 func EnsureKernel(_ string) {}
 func RunKernel3(_ string, _ unsafe.Pointer, _ int, _ int, _ int, _ int, _ *Buffer, _ *Buffer, _ *Buffer) error {
 	return nil
 }
-func MatMulBatchedBuffers(_ *Buffer, _ *Buffer, _ *Buffer, _ int, _ int, _ int, _ int) error { return nil }
\ No newline at end of file
+func MatMulBatchedBuffers(_ *Buffer, _ *Buffer, _ *Buffer, _ int, _ int, _ int, _ int) error { return nil }
+func CastBuffers(_ *Buffer, _ *Buffer, _ int, _ int, _ int, _ int, _ int) error { return nil }
+func ElementwiseBinaryBuffers(_ *ElementwiseParams, _ *Buffer, _ *Buffer, _ *Buffer) error { return nil }
+func ElementwiseUnaryBuffers(_ *ElementwiseParams, _ *Buffer, _ *Buffer) error          { return nil }
+func ReduceBuffers(_ *ReduceParams, _ bool, _ *Buffer, _ *Buffer) error { return nil }
+func CompileKernel(_, _ string) error { return nil }
+func RunKernelN(_ string, _ unsafe.Pointer, _ int, _ int, _ ...*Buffer) error { return nil }
+func SoftmaxBuffers(_ *SoftmaxParams, _ *Buffer, _ *Buffer, _ *Buffer) error { return nil }
+func MatMulStridedBuffers(_ *MatMulStridedParams, _ *Buffer, _ *Buffer, _ *Buffer) error { return nil }
+func CopyStridedBuffers(_ *CopyParams, _ *Buffer, _ *Buffer) error { return nil }
+func IndexCopyBuffers(_ *IndexParams, _ *Buffer, _ *Buffer, _ *Buffer) error { return nil }
+func CumSumBuffers(_ *RowOpParams, _ *Buffer, _ *Buffer) error           { return nil }
+func TopKBuffers(_ *RowOpParams, _ *Buffer, _ *Buffer, _ *Buffer) error { return nil }
+func ArgSortBuffers(_ *RowOpParams, _ *Buffer, _ *Buffer, _ *Buffer, _ *Buffer) error { return nil }
+func WhereBuffers(_ *ElementwiseParams, _ *Buffer, _ *Buffer, _ *Buffer) error { return nil }
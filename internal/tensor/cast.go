@@ -0,0 +1,92 @@
+package tensor
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"kylesmith19091/fastgo/internal/metal"
+)
+
+// To returns a new contiguous tensor holding t's values converted to dt.
+//
+// Values pass through float32, so each float cast rounds once:
+//   - Float16 and BFloat16 round to nearest even and overflow to ±Inf.
+//   - Float8E4M3, Float8E5M2 and Float4E2M1 round to nearest even and
+//     saturate to their largest finite value (see PackFP8E4M3); NaN stays
+//     NaN except for Float4E2M1, which has no NaN and maps it to 0.
+//   - Integer targets round to nearest (ties to even) and saturate to their
+//     range; NaN becomes 0. Integer-to-Int32/Int64 casts are exact apart from
+//     that saturation.
+//   - Bool stores every nonzero value, NaN included, as true.
+//   - Float8E8M0 holds MX block scales only and is not a valid target.
+//
+// Casts among the float, Int8 and Int4 dtypes run on Metal when a kernel
+// library is compiled and t is dense, Float8E8M0 included as a source; casts
+// from or to Int32, Int64 and Bool use the CPU path.
+func (t *Tensor) To(dt DType) (*Tensor, error) {
+	if err := t.live(); err != nil {
+		return nil, err
+	}
+	if dt == Float8E8M0 {
+		return nil, errors.New("cannot cast to float8_e8m0: scale-only dtype")
+	}
+	if dt.SizeOf() == 0 {
+		return nil, errors.New("unsupported target dtype")
+	}
+	out, err := NewOn(t.Device(), dt, t.Shape...)
+	if err != nil || out.Device() == Meta {
+		return out, err
+	}
+	if useMetal(t, out) && metalCastable(t.DT) && metalCastable(dt) && t.isDense() && t.Numel() > 0 {
+		err = metal.CastBuffers(t.buf, out.buf, t.Numel(), int(t.DT), int(dt), t.Offset, 0)
+	} else {
+		err = castCPU(t, out)
+	}
+	if err != nil {
+		_ = out.Close()
+		return nil, err
+	}
+	if err := checkOutput(out, t); err != nil {
+		_ = out.Close()
+		return nil, fmt.Errorf("To(%v): %w", dt, err)
+	}
+	return out, nil
+}
+
+// metalCastable reports whether cast_elementwise handles dt.
+func metalCastable(dt DType) bool {
+	switch dt {
+	case Float32, Float16, BFloat16, Int8, Int4, Float8E4M3, Float8E5M2, Float4E2M1, Float8E8M0:
+		return true
+	default:
+		return false
+	}
+}
+
+func castCPU(src, dst *Tensor) error {
+	if src.DT == dst.DT {
+		raw, err := src.packedBytes()
+		if err != nil {
+			return err
+		}
+		return dst.storePackedBytes(raw)
+	}
+	if isIntDType(src.DT) && (dst.DT == Int32 || dst.DT == Int64) {
+		vals, err := src.loadInt64()
+		if err != nil {
+			return err
+		}
+		if dst.DT == Int32 {
+			for i, v := range vals {
+				vals[i] = min(max(v, math.MinInt32), math.MaxInt32)
+			}
+		}
+		return dst.storeInt64(vals)
+	}
+	vals, err := src.loadFloat32()
+	if err != nil {
+		return err
+	}
+	return dst.storeFloat32(vals)
+}
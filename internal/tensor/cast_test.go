@@ -0,0 +1,182 @@
+package tensor
+
+import (
+	"math"
+	"testing"
+)
+
+var castDTypes = []DType{Float16, BFloat16, Float32, Int8, Int4, Int32, Int64, Float8E4M3, Float8E5M2, Float4E2M1}
+
+func TestToAllDTypePairs(t *testing.T) {
+	vals := []float32{0, 1, -1, 2.5, -3.5, 6, 7.75, -100, 300, 0.3, 1e-3, 65504}
+	for _, src := range castDTypes {
+		in, err := New(src, 3, 4)
+		if err != nil {
+			t.Fatalf("New(%v): %v", src, err)
+		}
+		if err := in.storeFloat32(vals); err != nil {
+			t.Fatalf("store %v: %v", src, err)
+		}
+		srcVals, err := in.loadFloat32()
+		if err != nil {
+			t.Fatalf("load %v: %v", src, err)
+		}
+		for _, dst := range castDTypes {
+			out, err := in.To(dst)
+			if err != nil {
+				t.Fatalf("%v.To(%v): %v", src, dst, err)
+			}
+			if out.DT != dst || !out.Contiguous() || out.Shape[0] != 3 || out.Shape[1] != 4 {
+				t.Fatalf("%v.To(%v): bad result %v", src, dst, out)
+			}
+			got, err := out.loadFloat32()
+			if err != nil {
+				t.Fatalf("load %v: %v", dst, err)
+			}
+			raw, _ := encodeFloat32(dst, srcVals)
+			want, _ := decodeFloat32(dst, raw, len(srcVals))
+			for i := range want {
+				if got[i] != want[i] && !(math.IsNaN(float64(got[i])) && math.IsNaN(float64(want[i]))) {
+					t.Fatalf("%v.To(%v) @%d: got %v want %v", src, dst, i, got[i], want[i])
+				}
+			}
+			_ = out.Close()
+		}
+		_ = in.Close()
+	}
+}
+
+func TestToIntegerRoundingAndSaturation(t *testing.T) {
+	vals := []float32{2.5, 3.5, -2.5, 0.49, -200, 200, float32(math.NaN()), 7.6}
+	in, err := FromFloat32(Float32, vals, len(vals))
+	if err != nil {
+		t.Fatalf("FromFloat32: %v", err)
+	}
+	defer in.Close()
+	cases := []struct {
+		dt   DType
+		want []float32
+	}{
+		{Int8, []float32{2, 4, -2, 0, -128, 127, 0, 8}},
+		{Int4, []float32{2, 4, -2, 0, -8, 7, 0, 7}},
+	}
+	for _, c := range cases {
+		out, err := in.To(c.dt)
+		if err != nil {
+			t.Fatalf("To(%v): %v", c.dt, err)
+		}
+		got, err := out.loadFloat32()
+		if err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		for i := range c.want {
+			if got[i] != c.want[i] {
+				t.Fatalf("To(%v) @%d: got %v want %v", c.dt, i, got[i], c.want[i])
+			}
+		}
+		_ = out.Close()
+	}
+}
+
+func TestToFloatOverflow(t *testing.T) {
+	in, err := FromFloat32(Float32, []float32{1e6, -1e6, float32(math.NaN())}, 3)
+	if err != nil {
+		t.Fatalf("FromFloat32: %v", err)
+	}
+	defer in.Close()
+	check := func(dt DType, want0 float32) {
+		out, err := in.To(dt)
+		if err != nil {
+			t.Fatalf("To(%v): %v", dt, err)
+		}
+		defer out.Close()
+		got, _ := out.loadFloat32()
+		if got[0] != want0 || got[1] != -want0 {
+			t.Fatalf("To(%v) overflow: got %v want ±%v", dt, got[:2], want0)
+		}
+		if dt != Float4E2M1 && !math.IsNaN(float64(got[2])) {
+			t.Fatalf("To(%v) NaN: got %v", dt, got[2])
+		}
+	}
+	check(Float16, float32(math.Inf(1)))
+	check(BFloat16, 999424) // 1e6 is finite in bfloat16
+	check(Float8E4M3, 448)
+	check(Float8E5M2, 57344)
+	check(Float4E2M1, 6)
+}
+
+func TestToStridedView(t *testing.T) {
+	data := []float32{0, 1, 2, 3, 4, 5}
+	in, err := FromFloat32(Float32, data, 2, 3)
+	if err != nil {
+		t.Fatalf("FromFloat32: %v", err)
+	}
+	defer in.Close()
+	// Transposed [3,2] view.
+	tr, err := in.View(0, []int{3, 2}, []int{4, 12})
+	if err != nil {
+		t.Fatalf("View: %v", err)
+	}
+	out, err := tr.To(Float16)
+	if err != nil {
+		t.Fatalf("To: %v", err)
+	}
+	defer out.Close()
+	got, _ := out.loadFloat32()
+	want := []float32{0, 3, 1, 4, 2, 5}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("@%d: got %v want %v", i, got[i], want[i])
+		}
+	}
+}
+
+// The packed targets write whole bytes, so an odd element count leaves the
+// last high nibble zero; Float8E8M0 scales cast like any other source.
+func TestToPackedOddLengthAndScaleSource(t *testing.T) {
+	in := mustFromFloat32(t, Float32, []float32{1, -2, 3.5, -6, 0.5}, 5)
+	for _, dt := range []DType{Int4, Float4E2M1} {
+		out, err := in.To(dt)
+		if err != nil {
+			t.Fatalf("To(%v): %v", dt, err)
+		}
+		raw, err := out.packedBytes()
+		if err != nil {
+			t.Fatalf("%v packedBytes: %v", dt, err)
+		}
+		want, _ := encodeFloat32(dt, []float32{1, -2, 3.5, -6, 0.5})
+		if len(raw) != 3 || raw[2]>>4 != 0 || string(raw) != string(want) {
+			t.Errorf("To(%v): bytes %x, want %x", dt, raw, want)
+		}
+		_ = out.Close()
+	}
+	scales, err := New(Float8E8M0, 4)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer scales.Close()
+	if err := scales.storePackedBytes([]byte{127, 130, 120, 0xFF}); err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	for _, dt := range []DType{Float32, BFloat16, Float8E4M3, Int4} {
+		out, err := scales.To(dt)
+		if err != nil {
+			t.Fatalf("To(%v): %v", dt, err)
+		}
+		raw, _ := encodeFloat32(dt, []float32{1, 8, 0.0078125, float32(math.NaN())})
+		want, _ := decodeFloat32(dt, raw, 4)
+		expectValues(t, "float8_e8m0 to "+dt.String(), mustLoad(t, out), want)
+		_ = out.Close()
+	}
+}
+
+func TestToRejectsScaleDType(t *testing.T) {
+	in, err := New(Float32, 2)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer in.Close()
+	if _, err := in.To(Float8E8M0); err == nil {
+		t.Fatalf("expected error casting to float8_e8m0")
+	}
+}
@@ -0,0 +1,281 @@
+package tensor
+
+import (
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// ---------- Comparison ----------
+//
+// AllClose and Compare check a tensor against a reference with NumPy's
+// tolerance rule |a - b| <= atol + rtol·|b|, where b is the reference. Both
+// operands are downloaded once and compared in float64 (integer pairs in
+// int64), so any dtypes and strided views may be mixed; b broadcasts
+// against a. A NaN
+// matches a NaN and an infinity matches only the same infinity.
+
+// AllClose reports whether every element of a is within atol + rtol·|b| of
+// the corresponding element of b.
+func AllClose(a, b *Tensor, rtol, atol float64) (bool, error) {
+	r, err := Compare(a, b, rtol, atol)
+	if err != nil {
+		return false, err
+	}
+	return r.Mismatches == 0, nil
+}
+
+// CompareReport describes how far a tensor is from a reference. Indices are
+// multi-dim positions in a's shape.
+type CompareReport struct {
+	DT            DType // a's dtype, the grid MaxULP is counted in
+	Numel         int
+	Mismatches    int   // elements outside tolerance, NaN and Inf mismatches included
+	FirstMismatch []int // nil when everything matches
+
+	MaxAbsErr float64 // over finite pairs
+	MaxAbsAt  []int
+	MaxRelErr float64 // |a-b|/|b| over finite pairs; +Inf where b is 0 and a is not
+	MaxRelAt  []int
+	// MaxULP is the largest number of representable a.DT values between a
+	// and b rounded to a.DT; for integer dtypes it is the absolute
+	// difference. It is -1 for Float8E8M0, which holds scales only.
+	MaxULP int64
+
+	NaNMismatches int // exactly one side is NaN
+	InfMismatches int // an infinity against a different value
+
+	// Histogram counts finite pairs by |a-b|; see ErrorBucket.
+	Histogram []ErrorBucket
+}
+
+// ErrorBucket counts errors up to Max (inclusive) and above the previous
+// bucket's Max. The first bucket holds exact matches (Max 0) and the last
+// has Max +Inf.
+type ErrorBucket struct {
+	Max   float64
+	Count int
+}
+
+// errorBucketMax lists the bucket bounds: exact, then decades up to 1.
+var errorBucketMax = []float64{0, 1e-7, 1e-6, 1e-5, 1e-4, 1e-3, 1e-2, 1e-1, 1, math.Inf(1)}
+
+// OK reports whether no element mismatched.
+func (r *CompareReport) OK() bool { return r.Mismatches == 0 }
+
+func (r *CompareReport) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d/%d mismatched", r.Mismatches, r.Numel)
+	if r.FirstMismatch != nil {
+		fmt.Fprintf(&sb, " (first at %v)", r.FirstMismatch)
+	}
+	fmt.Fprintf(&sb, "; max abs err %g at %v, max rel err %g at %v", r.MaxAbsErr, r.MaxAbsAt, r.MaxRelErr, r.MaxRelAt)
+	if r.MaxULP >= 0 {
+		fmt.Fprintf(&sb, ", max %d ulp (%v)", r.MaxULP, r.DT)
+	}
+	fmt.Fprintf(&sb, "; %d NaN and %d Inf mismatches\nabs err histogram:", r.NaNMismatches, r.InfMismatches)
+	for i, b := range r.Histogram {
+		switch {
+		case i == 0:
+			fmt.Fprintf(&sb, " exact:%d", b.Count)
+		case math.IsInf(b.Max, 1):
+			fmt.Fprintf(&sb, " >%g:%d", r.Histogram[i-1].Max, b.Count)
+		default:
+			fmt.Fprintf(&sb, " <=%g:%d", b.Max, b.Count)
+		}
+	}
+	return sb.String()
+}
+
+// Compare measures a against the reference b; see CompareReport.
+func Compare(a, b *Tensor, rtol, atol float64) (*CompareReport, error) {
+	if err := cmp.Or(a.live(), b.live()); err != nil {
+		return nil, fmt.Errorf("Compare: %w", err)
+	}
+	if !(rtol >= 0) || !(atol >= 0) {
+		return nil, fmt.Errorf("Compare: tolerances must be non-negative, got rtol=%g atol=%g", rtol, atol)
+	}
+	bb, err := b.broadcastTo(a.Shape)
+	if err != nil {
+		return nil, fmt.Errorf("Compare: reference shape %v does not broadcast to %v: %w", b.Shape, a.Shape, err)
+	}
+	x, err := a.loadFloat64()
+	if err != nil {
+		return nil, fmt.Errorf("Compare: %w", err)
+	}
+	y, err := bb.loadFloat64()
+	if err != nil {
+		return nil, fmt.Errorf("Compare: %w", err)
+	}
+	ulps, err := ulpDistances(a, bb, x, y)
+	if err != nil {
+		return nil, fmt.Errorf("Compare: %w", err)
+	}
+
+	r := &CompareReport{DT: a.DT, Numel: len(x), MaxULP: -1}
+	if ulps != nil {
+		r.MaxULP = 0
+	}
+	for _, m := range errorBucketMax {
+		r.Histogram = append(r.Histogram, ErrorBucket{Max: m})
+	}
+	exactInts := isIntDType(a.DT) && isIntDType(b.DT)
+	maxAbsAt, maxRelAt := -1, -1
+	for i := range x {
+		xi, yi := x[i], y[i]
+		xNaN, yNaN := math.IsNaN(xi), math.IsNaN(yi)
+		ok := true
+		switch {
+		case xNaN || yNaN:
+			if xNaN != yNaN {
+				r.NaNMismatches++
+				ok = false
+			}
+		case math.IsInf(xi, 0) || math.IsInf(yi, 0):
+			if xi != yi {
+				r.InfMismatches++
+				ok = false
+			}
+		default:
+			d := math.Abs(xi - yi)
+			if exactInts {
+				d = float64(ulps[i])
+			}
+			ok = d <= atol+rtol*math.Abs(yi)
+			if maxAbsAt < 0 || d > r.MaxAbsErr {
+				r.MaxAbsErr, maxAbsAt = d, i
+			}
+			rel := 0.0
+			if d != 0 {
+				rel = d / math.Abs(yi) // +Inf when yi is 0
+			}
+			if maxRelAt < 0 || rel > r.MaxRelErr {
+				r.MaxRelErr, maxRelAt = rel, i
+			}
+			for j, m := range errorBucketMax {
+				if d <= m {
+					r.Histogram[j].Count++
+					break
+				}
+			}
+			if ulps != nil {
+				r.MaxULP = max(r.MaxULP, ulps[i])
+			}
+		}
+		if !ok {
+			if r.Mismatches == 0 {
+				r.FirstMismatch = unflatten(a.Shape, i)
+			}
+			r.Mismatches++
+		}
+	}
+	if maxAbsAt >= 0 {
+		r.MaxAbsAt = unflatten(a.Shape, maxAbsAt)
+		r.MaxRelAt = unflatten(a.Shape, maxRelAt)
+	}
+	return r, nil
+}
+
+// unflatten converts a row-major flat position into a multi-dim index.
+func unflatten(shape []int, i int) []int {
+	idx := make([]int, len(shape))
+	for d := len(shape) - 1; d >= 0; d-- {
+		idx[d] = i % shape[d]
+		i /= shape[d]
+	}
+	return idx
+}
+
+// ulpDistances returns per-element distances on a.DT's grid between the
+// values x of a and y of b, rounding y to a.DT first. Non-finite pairs get 0.
+// It returns nil for dtypes without an ordered encoding.
+func ulpDistances(a, b *Tensor, x, y []float64) ([]int64, error) {
+	dt := a.DT
+	out := make([]int64, len(x))
+	switch {
+	case dt == Float8E8M0:
+		return nil, nil
+	case isIntDType(dt) || dt == Bool:
+		// Integers are compared exactly in int64 when both sides are ints.
+		if isIntDType(dt) && isIntDType(b.DT) {
+			xi, err := a.loadInt64()
+			if err != nil {
+				return nil, err
+			}
+			yi, err := b.loadInt64()
+			if err != nil {
+				return nil, err
+			}
+			for i := range out {
+				out[i] = absInt64(xi[i] - yi[i])
+			}
+			return out, nil
+		}
+		for i := range out {
+			if !math.IsNaN(y[i]) && !math.IsInf(y[i], 0) {
+				out[i] = int64(math.Abs(x[i] - math.RoundToEven(y[i])))
+			}
+		}
+		return out, nil
+	}
+	x32 := make([]float32, len(x))
+	y32 := make([]float32, len(y))
+	for i := range x {
+		x32[i], y32[i] = float32(x[i]), float32(y[i])
+	}
+	kx, err := orderedKeys(dt, x32)
+	if err != nil {
+		return nil, err
+	}
+	ky, err := orderedKeys(dt, y32)
+	if err != nil {
+		return nil, err
+	}
+	for i := range out {
+		if !math.IsNaN(x[i]) && !math.IsNaN(y[i]) && !math.IsInf(x[i], 0) && !math.IsInf(y[i], 0) {
+			out[i] = absInt64(kx[i] - ky[i])
+		}
+	}
+	return out, nil
+}
+
+// orderedKeys encodes vals in the sign-magnitude float dtype dt and maps each
+// bit pattern to an integer that increases by one per representable value,
+// with +0 and -0 both at 0.
+func orderedKeys(dt DType, vals []float32) ([]int64, error) {
+	raw, err := encodeFloat32(dt, vals)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]int64, len(vals))
+	for i := range keys {
+		var bits uint32
+		var width uint
+		switch dt {
+		case Float32:
+			bits, width = binary.LittleEndian.Uint32(raw[4*i:]), 32
+		case Float16, BFloat16:
+			bits, width = uint32(binary.LittleEndian.Uint16(raw[2*i:])), 16
+		case Float4E2M1:
+			bits, width = uint32(raw[i/2]>>(4*uint(i%2))&0x0F), 4
+		default:
+			bits, width = uint32(raw[i]), 8
+		}
+		sign := uint32(1) << (width - 1)
+		if bits&sign != 0 {
+			keys[i] = -int64(bits &^ sign)
+		} else {
+			keys[i] = int64(bits)
+		}
+	}
+	return keys, nil
+}
+
+func absInt64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,108 @@
+package tensor
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func mustCompare(t *testing.T, a, b *Tensor, rtol, atol float64) *CompareReport {
+	t.Helper()
+	r, err := Compare(a, b, rtol, atol)
+	if err != nil {
+		t.Fatalf("Compare: %v", err)
+	}
+	return r
+}
+
+func TestCompareReport(t *testing.T) {
+	nan, inf := float32(math.NaN()), float32(math.Inf(1))
+	got := mustFromFloat32(t, Float32, []float32{1, 2.001, 3, nan, inf, 0, 5, nan}, 2, 4)
+	want := mustFromFloat32(t, Float32, []float32{1, 2, 3.5, 4, inf, 1e-9, 5, nan}, 2, 4)
+	r := mustCompare(t, got, want, 1e-3, 1e-6)
+	if r.Numel != 8 || r.Mismatches != 2 || r.NaNMismatches != 1 || r.InfMismatches != 0 {
+		t.Fatalf("counts: %+v", r)
+	}
+	if !slices.Equal(r.FirstMismatch, []int{0, 2}) {
+		t.Fatalf("first mismatch %v", r.FirstMismatch)
+	}
+	if r.MaxAbsErr != 0.5 || !slices.Equal(r.MaxAbsAt, []int{0, 2}) {
+		t.Fatalf("max abs %v at %v", r.MaxAbsErr, r.MaxAbsAt)
+	}
+	// 0 against 1e-9 is within atol but its relative error is 1.
+	if r.MaxRelErr != 1 || !slices.Equal(r.MaxRelAt, []int{1, 1}) {
+		t.Fatalf("max rel %v at %v", r.MaxRelErr, r.MaxRelAt)
+	}
+	counts := make([]int, len(r.Histogram))
+	for i, b := range r.Histogram {
+		counts[i] = b.Count
+	}
+	// Exact: 1 and 5; 0 vs 1e-9 in <=1e-7; 2.001 vs 2 in <=1e-3; 0.5 in <=1.
+	if !slices.Equal(counts, []int{2, 1, 0, 0, 0, 1, 0, 0, 1, 0}) || !math.IsInf(r.Histogram[9].Max, 1) {
+		t.Fatalf("histogram %v", r.Histogram)
+	}
+	if ok, err := AllClose(got, want, 1e-3, 1e-6); ok || err != nil {
+		t.Fatalf("AllClose = %v, %v", ok, err)
+	}
+	s := r.String()
+	for _, part := range []string{"2/8 mismatched (first at [0 2])", "max abs err 0.5 at [0 2]", "1 NaN and 0 Inf mismatches", "exact:2"} {
+		if !strings.Contains(s, part) {
+			t.Fatalf("report %q missing %q", s, part)
+		}
+	}
+
+	infs := mustFromFloat32(t, Float32, []float32{inf, -inf}, 2)
+	big := mustFromFloat32(t, Float32, []float32{inf, 1e38}, 2)
+	if r := mustCompare(t, infs, big, 1, 1); r.InfMismatches != 1 || r.Mismatches != 1 {
+		t.Fatalf("inf: %+v", r)
+	}
+}
+
+func TestCompareULPAndDTypes(t *testing.T) {
+	// bf16 output against a float32 reference: 1 + 2^-7 is the next bf16
+	// after 1, and 1 + 3·2^-8 rounds to 1 + 2^-6, two steps away.
+	ref := mustFromFloat32(t, Float32, []float32{1, 1 + 1.0/256, -2}, 3)
+	out := mustFromFloat32(t, BFloat16, []float32{1.0078125, 1.0078125, -2}, 3)
+	if r := mustCompare(t, out, ref, 0, 0); r.MaxULP != 1 || r.DT != BFloat16 {
+		t.Fatalf("bf16 ulp %d (%v)", r.MaxULP, r.DT)
+	}
+	h := mustFromFloat32(t, Float16, []float32{-1, 1}, 2)
+	hz := mustFromFloat32(t, Float32, []float32{1, -1}, 2)
+	// -1 to +1 crosses 2·(0x3C00) half-precision values.
+	if r := mustCompare(t, h, hz, 0, 0); r.MaxULP != 2*0x3C00 {
+		t.Fatalf("f16 sign ulp %d", r.MaxULP)
+	}
+
+	// Strided view against a broadcast reference.
+	x := mustFromFloat32(t, Float16, []float32{1, 5, 2, 5, 3, 5}, 3, 2)
+	col, err := x.Transpose(0, 1)
+	if err != nil {
+		t.Fatalf("Transpose: %v", err)
+	}
+	row := mustFromFloat32(t, Int32, []float32{5}, 1, 1)
+	r := mustCompare(t, col, row, 0, 0)
+	if r.Mismatches != 3 || !slices.Equal(r.FirstMismatch, []int{0, 0}) || r.MaxAbsErr != 4 {
+		t.Fatalf("strided: %v", r)
+	}
+
+	// Int64 compares exactly beyond 2^53.
+	a := mustFromFloat32(t, Int64, []float32{0}, 1)
+	b := mustFromFloat32(t, Int64, []float32{0}, 1)
+	if err := a.storeInt64([]int64{1<<60 + 1}); err != nil {
+		t.Fatalf("storeInt64: %v", err)
+	}
+	if err := b.storeInt64([]int64{1 << 60}); err != nil {
+		t.Fatalf("storeInt64: %v", err)
+	}
+	if r := mustCompare(t, a, b, 0, 0); r.MaxULP != 1 || r.Mismatches != 1 || r.MaxAbsErr != 1 {
+		t.Fatalf("int64: %v", r)
+	}
+
+	if _, err := Compare(x, mustFromFloat32(t, Float32, make([]float32, 4), 4), 0, 0); err == nil || !strings.Contains(err.Error(), "does not broadcast") {
+		t.Fatalf("shape error: %v", err)
+	}
+	if _, err := AllClose(x, x, -1, 0); err == nil {
+		t.Fatalf("expected tolerance error")
+	}
+}
@@ -0,0 +1,248 @@
+package tensor
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"kylesmith19091/fastgo/internal/metal"
+)
+
+// ---------- Joining and splitting ----------
+//
+// Concat and Stack copy their inputs into a new contiguous tensor; Split and
+// Chunk return views that share the input's storage. 4-bit dtypes pack two
+// elements per byte and cannot be addressed per element, so they are
+// rejected; cast them first.
+
+// Concat joins tensors along dim. All inputs must share a dtype and rank and
+// match in every dim except dim.
+func Concat(dim int, ts ...*Tensor) (*Tensor, error) {
+	if len(ts) == 0 {
+		return nil, errors.New("Concat: no tensors")
+	}
+	for i, t := range ts {
+		if err := t.live(); err != nil {
+			return nil, fmt.Errorf("Concat: tensor %d: %w", i, err)
+		}
+	}
+	if _, err := sameDevice(ts...); err != nil {
+		return nil, fmt.Errorf("Concat: %w", err)
+	}
+	first := ts[0]
+	rank := len(first.Shape)
+	d, err := normalizeDim("Concat", dim, rank)
+	if err != nil {
+		return nil, err
+	}
+	if is4Bit(first.DT) {
+		return nil, fmt.Errorf("Concat: %v tensors are not supported; cast first", first.DT)
+	}
+	shape := append([]int(nil), first.Shape...)
+	for i, t := range ts[1:] {
+		if t.DT != first.DT {
+			return nil, fmt.Errorf("Concat: tensor %d has dtype %v, tensor 0 has %v", i+1, t.DT, first.DT)
+		}
+		if len(t.Shape) != rank {
+			return nil, fmt.Errorf("Concat: tensor %d has rank %d (shape %v), tensor 0 has rank %d (shape %v)", i+1, len(t.Shape), t.Shape, rank, first.Shape)
+		}
+		for j := range t.Shape {
+			if j != d && t.Shape[j] != first.Shape[j] {
+				return nil, fmt.Errorf("Concat: tensor %d has shape %v, tensor 0 has %v; only dim %d may differ", i+1, t.Shape, first.Shape, d)
+			}
+		}
+		shape[d] += t.Shape[d]
+	}
+	out, err := NewOn(first.Device(), first.DT, shape...)
+	if err != nil || out.Device() == Meta {
+		return out, err
+	}
+	start := 0
+	for i, t := range ts {
+		dst, err := out.narrow(d, start, t.Shape[d])
+		if err == nil {
+			err = copyView(dst, t)
+		}
+		if err != nil {
+			_ = out.Close()
+			return nil, fmt.Errorf("Concat: tensor %d: %w", i, err)
+		}
+		start += t.Shape[d]
+	}
+	if err := checkOutput(out, ts...); err != nil {
+		_ = out.Close()
+		return nil, fmt.Errorf("Concat: %w", err)
+	}
+	return out, nil
+}
+
+// Stack joins same-shaped tensors along a new dim inserted at dim, which may
+// range over [-(rank+1), rank].
+func Stack(dim int, ts ...*Tensor) (*Tensor, error) {
+	if len(ts) == 0 {
+		return nil, errors.New("Stack: no tensors")
+	}
+	for i, t := range ts {
+		if err := t.live(); err != nil {
+			return nil, fmt.Errorf("Stack: tensor %d: %w", i, err)
+		}
+		if !equalShapes(t.Shape, ts[0].Shape) {
+			return nil, fmt.Errorf("Stack: tensor %d has shape %v, tensor 0 has %v", i, t.Shape, ts[0].Shape)
+		}
+	}
+	d, err := normalizeDim("Stack", dim, len(ts[0].Shape)+1)
+	if err != nil {
+		return nil, err
+	}
+	views := make([]*Tensor, len(ts))
+	for i, t := range ts {
+		shape := append(append(append([]int(nil), t.Shape[:d]...), 1), t.Shape[d:]...)
+		strides := append(append(append([]int(nil), t.Strides[:d]...), 0), t.Strides[d:]...)
+		views[i] = &Tensor{DT: t.DT, Shape: shape, Strides: strides, Offset: t.Offset, buf: t.buf}
+	}
+	out, err := Concat(d, views...)
+	if err != nil {
+		return nil, fmt.Errorf("Stack: %w", err)
+	}
+	return out, nil
+}
+
+// Split returns views of t along dim with the given sizes, which must sum to
+// t.Shape[dim]; a zero size gives an empty view.
+func (t *Tensor) Split(dim int, sizes ...int) ([]*Tensor, error) {
+	if err := t.live(); err != nil {
+		return nil, fmt.Errorf("Split: %w", err)
+	}
+	d, err := normalizeDim("Split", dim, len(t.Shape))
+	if err != nil {
+		return nil, err
+	}
+	if is4Bit(t.DT) {
+		return nil, fmt.Errorf("Split: %v tensors are not supported; cast first", t.DT)
+	}
+	total := 0
+	for _, s := range sizes {
+		if s < 0 {
+			return nil, fmt.Errorf("Split: sizes must be non-negative, got %v", sizes)
+		}
+		total += s
+	}
+	if total != t.Shape[d] {
+		return nil, fmt.Errorf("Split: sizes %v sum to %d, dim %d has size %d", sizes, total, d, t.Shape[d])
+	}
+	parts := make([]*Tensor, len(sizes))
+	start := 0
+	for i, s := range sizes {
+		if parts[i], err = t.narrow(d, start, s); err != nil {
+			return nil, fmt.Errorf("Split: %w", err)
+		}
+		start += s
+	}
+	for _, p := range parts {
+		t.share(p)
+	}
+	return parts, nil
+}
+
+// Chunk splits t along dim into n views of size ceil(Shape[dim]/n); the last
+// chunk may be smaller and fewer than n chunks are returned when the dim is
+// too small to fill them all.
+func (t *Tensor) Chunk(dim, n int) ([]*Tensor, error) {
+	if err := t.live(); err != nil {
+		return nil, fmt.Errorf("Chunk: %w", err)
+	}
+	if n <= 0 {
+		return nil, fmt.Errorf("Chunk: chunk count must be positive, got %d", n)
+	}
+	d, err := normalizeDim("Chunk", dim, len(t.Shape))
+	if err != nil {
+		return nil, err
+	}
+	size := t.Shape[d]
+	step := (size + n - 1) / n
+	var sizes []int
+	for start := 0; start < size; start += step {
+		sizes = append(sizes, min(step, size-start))
+	}
+	return t.Split(d, sizes...)
+}
+
+// narrow returns the borrowed view (see view) of t covering
+// [start, start+length) along dim.
+func (t *Tensor) narrow(dim, start, length int) (*Tensor, error) {
+	shape := append([]int(nil), t.Shape...)
+	shape[dim] = length
+	return t.view(t.Offset+start*t.Strides[dim], shape, t.Strides)
+}
+
+// normalizeDim resolves a possibly negative dim against rank.
+func normalizeDim(op string, dim, rank int) (int, error) {
+	if dim < -rank || dim >= rank {
+		return 0, fmt.Errorf("%s: dim %d out of range for rank %d", op, dim, rank)
+	}
+	if dim < 0 {
+		dim += rank
+	}
+	return dim, nil
+}
+
+// scalarAlongDim views a rank-0 t as a one-element vector so ops along a dim
+// can run on it; like PyTorch they accept dim 0 or -1 on scalars. Pass the
+// results to dropScalarDim.
+func scalarAlongDim(op string, t *Tensor, dim int) (*Tensor, error) {
+	if dim != 0 && dim != -1 {
+		return nil, fmt.Errorf("%s: dim %d out of range for rank 0", op, dim)
+	}
+	return &Tensor{DT: t.DT, Shape: []int{1}, Strides: []int{t.DT.SizeOf()}, Offset: t.Offset, buf: t.buf}, nil
+}
+
+// dropScalarDim turns fresh [1] results of an op run through scalarAlongDim
+// back into scalars.
+func dropScalarDim(ts ...*Tensor) {
+	for _, t := range ts {
+		if t != nil && equalShapes(t.Shape, []int{1}) {
+			t.Shape, t.Strides = nil, nil
+		}
+	}
+}
+
+// copyView copies src into dst element by element; both must have the same
+// shape and dtype. It uses copy_strided on Metal and packed bytes otherwise.
+func copyView(dst, src *Tensor) error {
+	if dst.DT != src.DT || !equalShapes(dst.Shape, src.Shape) {
+		return fmt.Errorf("copy %v%v into %v%v", src.DT, src.Shape, dst.DT, dst.Shape)
+	}
+	if dst.Device() == Meta {
+		return nil
+	}
+	if p, ok := copyParams(dst, src); ok && useMetal(dst, src) {
+		return metal.CopyStridedBuffers(p, src.buf, dst.buf)
+	}
+	raw, err := src.packedBytes()
+	if err != nil {
+		return err
+	}
+	return dst.storePackedBytes(raw)
+}
+
+func copyParams(dst, src *Tensor) (*metal.CopyParams, bool) {
+	if is4Bit(src.DT) || len(src.Shape) > metal.MaxDims || src.Numel() > math.MaxInt32 {
+		return nil, false
+	}
+	if dst.buf.Size() > math.MaxInt32 || src.buf.Size() > math.MaxInt32 {
+		return nil, false
+	}
+	p := &metal.CopyParams{
+		N:        int32(src.Numel()),
+		ElemSize: int32(src.DT.SizeOf()),
+		Rank:     int32(len(src.Shape)),
+		OffSrc:   int32(src.Offset),
+		OffDst:   int32(dst.Offset),
+	}
+	for d := range src.Shape {
+		p.Shape[d] = int32(src.Shape[d])
+		p.SrcStride[d] = int32(src.Strides[d])
+		p.DstStride[d] = int32(dst.Strides[d])
+	}
+	return p, true
+}
@@ -0,0 +1,125 @@
+package tensor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConcatAlongDims(t *testing.T) {
+	a := mustFromFloat32(t, Float16, []float32{1, 2, 3, 4, 5, 6}, 2, 3)
+	b := mustFromFloat32(t, Float16, []float32{7, 8}, 2, 1)
+	c, err := Concat(-1, a, b)
+	if err != nil {
+		t.Fatalf("Concat: %v", err)
+	}
+	defer c.Close()
+	if c.DT != Float16 || !c.Contiguous() || !equalShapes(c.Shape, []int{2, 4}) {
+		t.Fatalf("Concat: got %v %v contiguous=%v", c.DT, c.Shape, c.Contiguous())
+	}
+	expectValues(t, "Concat dim 1", mustLoad(t, c), []float32{1, 2, 3, 7, 4, 5, 6, 8})
+
+	// A transposed input is gathered through its strides.
+	tr, err := a.Transpose(0, 1) // [3,2]
+	if err != nil {
+		t.Fatalf("Transpose: %v", err)
+	}
+	row := mustFromFloat32(t, Float16, []float32{9, 10}, 1, 2)
+	r, err := Concat(0, tr, row)
+	if err != nil {
+		t.Fatalf("Concat: %v", err)
+	}
+	defer r.Close()
+	expectValues(t, "Concat dim 0", mustLoad(t, r), []float32{1, 4, 2, 5, 3, 6, 9, 10})
+}
+
+func TestStack(t *testing.T) {
+	a := mustFromFloat32(t, Int8, []float32{1, 2, 3}, 3)
+	b := mustFromFloat32(t, Int8, []float32{4, 5, 6}, 3)
+	for _, c := range []struct {
+		dim   int
+		shape []int
+		want  []float32
+	}{
+		{0, []int{2, 3}, []float32{1, 2, 3, 4, 5, 6}},
+		{1, []int{3, 2}, []float32{1, 4, 2, 5, 3, 6}},
+		{-1, []int{3, 2}, []float32{1, 4, 2, 5, 3, 6}},
+	} {
+		s, err := Stack(c.dim, a, b)
+		if err != nil {
+			t.Fatalf("Stack(%d): %v", c.dim, err)
+		}
+		if !equalShapes(s.Shape, c.shape) {
+			t.Fatalf("Stack(%d) shape %v want %v", c.dim, s.Shape, c.shape)
+		}
+		expectValues(t, "Stack", mustLoad(t, s), c.want)
+		_ = s.Close()
+	}
+	if _, err := Stack(2, a, b); err == nil {
+		t.Fatalf("expected dim error")
+	}
+}
+
+func TestSplitAndChunkAreViews(t *testing.T) {
+	x := make([]float32, 10)
+	for i := range x {
+		x[i] = float32(i)
+	}
+	in := mustFromFloat32(t, Float32, x, 2, 5)
+	parts, err := in.Split(1, 2, 3)
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+	if len(parts) != 2 || !equalShapes(parts[1].Shape, []int{2, 3}) || parts[1].Buffer() != in.Buffer() {
+		t.Fatalf("Split: bad parts %v", parts)
+	}
+	expectValues(t, "Split[1]", mustLoad(t, parts[1]), []float32{2, 3, 4, 7, 8, 9})
+
+	// Writing through a split view updates the parent.
+	if err := MulScalarInto(parts[0], parts[0], 10); err != nil {
+		t.Fatalf("MulScalarInto: %v", err)
+	}
+	expectValues(t, "parent", mustLoad(t, in), []float32{0, 10, 2, 3, 4, 50, 60, 7, 8, 9})
+
+	chunks, err := in.Chunk(-1, 2)
+	if err != nil {
+		t.Fatalf("Chunk: %v", err)
+	}
+	if len(chunks) != 2 || chunks[0].Shape[1] != 3 || chunks[1].Shape[1] != 2 {
+		t.Fatalf("Chunk sizes: %v, %v", chunks[0].Shape, chunks[1].Shape)
+	}
+	few, err := in.Chunk(0, 4) // only 2 rows to spread
+	if err != nil || len(few) != 2 {
+		t.Fatalf("Chunk(0, 4): %d chunks, %v", len(few), err)
+	}
+
+	// Splitting then concatenating round-trips.
+	back, err := Concat(1, chunks...)
+	if err != nil {
+		t.Fatalf("Concat: %v", err)
+	}
+	defer back.Close()
+	expectValues(t, "round trip", mustLoad(t, back), mustLoad(t, in))
+}
+
+func TestJoinSplitErrors(t *testing.T) {
+	f32 := mustFromFloat32(t, Float32, make([]float32, 6), 2, 3)
+	f16 := mustFromFloat32(t, Float16, make([]float32, 6), 2, 3)
+	other := mustFromFloat32(t, Float32, make([]float32, 8), 2, 4)
+	cases := []struct {
+		name, want string
+		fn         func() error
+	}{
+		{"dtype", "dtype float16", func() error { _, err := Concat(0, f32, f16); return err }},
+		{"shape", "only dim 0 may differ", func() error { _, err := Concat(0, f32, other); return err }},
+		{"stack shape", "tensor 1 has shape [2 4]", func() error { _, err := Stack(0, f32, other); return err }},
+		{"split sum", "sum to 4", func() error { _, err := f32.Split(1, 2, 2); return err }},
+		{"chunk count", "must be positive", func() error { _, err := f32.Chunk(0, 0); return err }},
+		{"dim", "out of range", func() error { _, err := Concat(2, f32, f32); return err }},
+	}
+	for _, c := range cases {
+		err := c.fn()
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Fatalf("%s: got %v, want error containing %q", c.name, err, c.want)
+		}
+	}
+}
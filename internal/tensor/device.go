@@ -0,0 +1,335 @@
+package tensor
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync/atomic"
+	"unsafe"
+
+	"kylesmith19091/fastgo/internal/metal"
+)
+
+// ---------- Devices ----------
+//
+// Every tensor lives on one device. New allocates on the default device,
+// Metal unless SetDefaultDevice changes it; there ops run kernels when
+// metal.Ready(). Host tensors hold Go memory, which Data exposes as a typed
+// slice for loading, preprocessing or sampling without a copy. Meta tensors
+// have a shape, dtype and strides but no storage: ops on them only check
+// shapes and return meta results, so a forward pass traced on Meta finds
+// shape bugs and, through Memory, its peak activation size without
+// allocating. Ops on host or meta tensors return tensors on the same device
+// and refuse inputs from different devices with an error wrapping
+// ErrDeviceMismatch; ToDevice moves a tensor explicitly, and Materialize
+// gives a meta tensor storage in place.
+//
+// A model is planned on Meta like this:
+//
+//	prev := tensor.SetDefaultDevice(tensor.Meta)
+//	m, err := buildModel(cfg) // weights from New, Random, LoadNPY, ...
+//	tensor.SetDefaultDevice(prev)
+//	weights, _ := tensor.Memory(tensor.Meta)
+//	tensor.ResetPeakMemory(tensor.Meta)
+//	out, err := m.Forward(x) // x on Meta too
+//	_, peak := tensor.Memory(tensor.Meta) // peak-weights is the activation peak
+//
+// and loaded by materializing each weight, e.g. w.MaterializeFrom(loaded).
+
+// Device identifies where a tensor's storage lives.
+type Device int
+
+const (
+	Metal Device = iota // a Metal buffer that kernels bind directly
+	Host                // Go memory
+	Meta                // no storage, for shape and memory planning
+
+	numDevices = 3
+)
+
+func (d Device) String() string {
+	switch d {
+	case Metal:
+		return "metal"
+	case Host:
+		return "host"
+	case Meta:
+		return "meta"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrDeviceMismatch is wrapped by the errors ops return for tensors on
+// different devices.
+var ErrDeviceMismatch = errors.New("tensors on different devices")
+
+var defaultDevice atomic.Int32 // Metal
+
+// SetDefaultDevice makes New and the constructors built on it (FromFloat32,
+// Random, LoadNPY, the mask builders, ...) allocate on dev, and returns the
+// previous default. The default is process-wide.
+func SetDefaultDevice(dev Device) Device {
+	return Device(defaultDevice.Swap(int32(dev)))
+}
+
+// DefaultDevice returns the device New allocates on.
+func DefaultDevice() Device { return Device(defaultDevice.Load()) }
+
+// Device returns the device t's storage lives on.
+func (t *Tensor) Device() Device {
+	if t == nil {
+		return Metal
+	}
+	return bufferDevice(t.buf)
+}
+
+func bufferDevice(b *metal.Buffer) Device {
+	switch {
+	case b.Host():
+		return Host
+	case b.Meta():
+		return Meta
+	default:
+		return Metal
+	}
+}
+
+// NewOn is New for a tensor on dev.
+func NewOn(dev Device, dt DType, shape ...int) (*Tensor, error) {
+	if !IsValidShape(shape) {
+		return nil, errors.New("invalid shape")
+	}
+	st, err := newStorage(dev, BytesFor(dt, Numel(shape)))
+	if err != nil {
+		return nil, err
+	}
+	t := &Tensor{
+		DT:      dt,
+		Shape:   append([]int(nil), shape...),
+		Strides: DefaultStridesBytes(dt, shape),
+		Offset:  0,
+		buf:     st.buf,
+		st:      st,
+	}
+	return t, nil
+}
+
+// newStorage allocates nbytes on dev, holding one reference.
+func newStorage(dev Device, nbytes int) (*storage, error) {
+	// Metal cannot allocate an empty buffer, so empty tensors get one byte.
+	nbytes = max(nbytes, 1)
+	var mbuf *metal.Buffer
+	var err error
+	switch dev {
+	case Metal:
+		mbuf, err = metal.NewBuffer(nbytes)
+	case Host:
+		mbuf, err = metal.NewHostBuffer(nbytes)
+	case Meta:
+		mbuf, err = metal.NewMetaBuffer(nbytes)
+	default:
+		return nil, fmt.Errorf("unknown device %d", dev)
+	}
+	if err != nil {
+		return nil, err
+	}
+	buffersAllocated.Add(1)
+	memoryAllocated(dev, nbytes)
+	st := &storage{buf: mbuf}
+	st.refs.Store(1)
+	return st, nil
+}
+
+// release frees the storage once its last reference is gone.
+func (st *storage) release() error {
+	memoryAllocated(bufferDevice(st.buf), -st.buf.Size())
+	return st.buf.Close()
+}
+
+// ---------- Memory accounting ----------
+
+var memLive, memPeak [numDevices]atomic.Int64
+
+func memoryAllocated(dev Device, n int) {
+	live := memLive[dev].Add(int64(n))
+	for {
+		peak := memPeak[dev].Load()
+		if live <= peak || memPeak[dev].CompareAndSwap(peak, live) {
+			return
+		}
+	}
+}
+
+// Memory returns the bytes of storage live on dev and the most live at once
+// since the last ResetPeakMemory. Views share their tensor's storage and add
+// nothing; an empty tensor counts one byte.
+func Memory(dev Device) (live, peak int64) {
+	if dev < 0 || dev >= numDevices {
+		return 0, 0
+	}
+	return memLive[dev].Load(), memPeak[dev].Load()
+}
+
+// ResetPeakMemory starts a new peak measurement for dev at its live bytes.
+func ResetPeakMemory(dev Device) {
+	if dev >= 0 && dev < numDevices {
+		memPeak[dev].Store(memLive[dev].Load())
+	}
+}
+
+// Materialize gives the meta tensor t zeroed contiguous storage on dev in
+// place, so a model built on Meta keeps its tensor pointers. t must not
+// share its storage with views.
+func (t *Tensor) Materialize(dev Device) error {
+	if err := t.checkMaterialize(); err != nil {
+		return err
+	}
+	if dev == Meta {
+		return errors.New("Materialize: target device is meta")
+	}
+	st, err := newStorage(dev, t.ByteSize())
+	if err != nil {
+		return fmt.Errorf("Materialize: %w", err)
+	}
+	t.adopt(st, 0, DefaultStridesBytes(t.DT, t.Shape))
+	return nil
+}
+
+// MaterializeFrom makes the meta tensor t share src's storage in place, as a
+// loader does with weights read from a file. src must match t's dtype and
+// shape; t then holds its own reference, so src is closed as usual. t must
+// not share its storage with views.
+func (t *Tensor) MaterializeFrom(src *Tensor) error {
+	if err := t.checkMaterialize(); err != nil {
+		return err
+	}
+	if err := src.live(); err != nil {
+		return fmt.Errorf("MaterializeFrom: source: %w", err)
+	}
+	switch {
+	case src.Device() == Meta:
+		return errors.New("MaterializeFrom: source is a meta tensor")
+	case src.DT != t.DT:
+		return fmt.Errorf("MaterializeFrom: source dtype %v, want %v", src.DT, t.DT)
+	case !equalShapes(src.Shape, t.Shape):
+		return fmt.Errorf("MaterializeFrom: source shape %v, want %v", src.Shape, t.Shape)
+	}
+	src.st.refs.Add(1)
+	t.adopt(src.st, src.Offset, slices.Clone(src.Strides))
+	return nil
+}
+
+func (t *Tensor) checkMaterialize() error {
+	if err := t.live(); err != nil {
+		return fmt.Errorf("Materialize: %w", err)
+	}
+	if t.Device() != Meta {
+		return fmt.Errorf("Materialize: tensor on %v, want meta", t.Device())
+	}
+	if t.st == nil || t.st.refs.Load() != 1 {
+		return errors.New("Materialize: tensor shares its storage with views")
+	}
+	return nil
+}
+
+// adopt replaces t's meta storage with st.
+func (t *Tensor) adopt(st *storage, offset int, strides []int) {
+	_ = t.st.release()
+	t.st, t.buf, t.Offset, t.Strides = st, st.buf, offset, strides
+}
+
+// ToDevice copies t to dev as a new contiguous tensor. If t is already on
+// dev it returns a view sharing t's storage instead, which must be closed
+// all the same. Moving to Meta copies no data; a meta tensor has none to
+// move, so use Materialize instead.
+func (t *Tensor) ToDevice(dev Device) (*Tensor, error) {
+	if err := t.live(); err != nil {
+		return nil, fmt.Errorf("ToDevice: %w", err)
+	}
+	switch {
+	case t.Device() == dev:
+		return t.View(t.Offset, t.Shape, t.Strides)
+	case dev == Meta:
+		return NewOn(Meta, t.DT, t.Shape...)
+	case t.Device() == Meta:
+		return nil, fmt.Errorf("ToDevice: meta tensor has no data to move to %v; use Materialize", dev)
+	}
+	raw, err := t.packedBytes()
+	if err != nil {
+		return nil, fmt.Errorf("ToDevice: %w", err)
+	}
+	out, err := NewOn(dev, t.DT, t.Shape...)
+	if err != nil {
+		return nil, fmt.Errorf("ToDevice: %w", err)
+	}
+	if err := out.storePackedBytes(raw); err != nil {
+		_ = out.Close()
+		return nil, fmt.Errorf("ToDevice: %w", err)
+	}
+	return out, nil
+}
+
+// sameDevice returns the device shared by ts, or an error wrapping
+// ErrDeviceMismatch naming the first two that differ.
+func sameDevice(ts ...*Tensor) (Device, error) {
+	dev := ts[0].Device()
+	for _, t := range ts[1:] {
+		if d := t.Device(); d != dev {
+			return dev, fmt.Errorf("%w: %v and %v", ErrDeviceMismatch, dev, d)
+		}
+	}
+	return dev, nil
+}
+
+// Element lists the Go types Data can view host memory as. uint8 views any
+// dtype as its raw bytes, two 4-bit values per byte; uint16 views float16
+// and bfloat16 as their bit patterns.
+type Element interface {
+	float32 | int8 | int32 | int64 | uint8 | uint16
+}
+
+// Data returns the elements of a dense host tensor as a slice aliasing its
+// storage, so writes to the slice change the tensor. The slice is valid
+// until the last tensor sharing the storage is closed.
+func Data[T Element](t *Tensor) ([]T, error) {
+	if err := t.live(); err != nil {
+		return nil, fmt.Errorf("Data: %w", err)
+	}
+	if t.Device() != Host {
+		return nil, fmt.Errorf("Data: tensor on %v, want host", t.Device())
+	}
+	var zero T
+	size := int(unsafe.Sizeof(zero))
+	var ok bool
+	switch any(zero).(type) {
+	case float32:
+		ok = t.DT == Float32
+	case int8:
+		ok = t.DT == Int8
+	case int32:
+		ok = t.DT == Int32
+	case int64:
+		ok = t.DT == Int64
+	case uint16:
+		ok = t.DT == Float16 || t.DT == BFloat16
+	case uint8:
+		ok = true
+	}
+	if !ok {
+		return nil, fmt.Errorf("Data: cannot view %v as %T", t.DT, zero)
+	}
+	if !t.isDense() {
+		return nil, fmt.Errorf("Data: tensor is not contiguous (strides %v)", t.Strides)
+	}
+	n := t.ByteSize() / size
+	if n == 0 {
+		return []T{}, nil
+	}
+	mem := t.buf.Bytes()[t.Offset : t.Offset+t.ByteSize()]
+	p := unsafe.Pointer(&mem[0])
+	if uintptr(p)%unsafe.Alignof(zero) != 0 {
+		return nil, fmt.Errorf("Data: offset %d is not aligned for %T", t.Offset, zero)
+	}
+	return unsafe.Slice((*T)(p), n), nil
+}
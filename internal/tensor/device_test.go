@@ -0,0 +1,227 @@
+package tensor
+
+import (
+	"errors"
+	"testing"
+)
+
+// mustHost uploads data and moves it to a new host tensor.
+func mustHost(t *testing.T, dt DType, data []float32, shape ...int) *Tensor {
+	t.Helper()
+	x := mustFromFloat32(t, dt, data, shape...)
+	defer x.Close()
+	h, err := x.ToDevice(Host)
+	if err != nil {
+		t.Fatalf("ToDevice(host): %v", err)
+	}
+	if h.Device() != Host {
+		t.Fatalf("ToDevice(host) gave a tensor on %v", h.Device())
+	}
+	return h
+}
+
+func TestHostData(t *testing.T) {
+	x, err := NewOn(Host, Float32, 2, 3)
+	if err != nil {
+		t.Fatalf("NewOn: %v", err)
+	}
+	defer x.Close()
+	data, err := Data[float32](x)
+	if err != nil {
+		t.Fatalf("Data: %v", err)
+	}
+	copy(data, []float32{1, 2, 3, 4, 5, 6})
+	if v, err := x.At(1, 0); err != nil || v != 4 {
+		t.Fatalf("At(1, 0) after writing the slice = %v, %v; want 4", v, err)
+	}
+
+	// A row of a host tensor aliases the same memory; writes go both ways.
+	row, err := x.Row(1)
+	if err != nil {
+		t.Fatalf("Row: %v", err)
+	}
+	defer row.Close()
+	rd, err := Data[float32](row)
+	if err != nil {
+		t.Fatalf("Data(row): %v", err)
+	}
+	rd[2] = 60
+	expectValues(t, "after writing the row", mustLoad(t, x), []float32{1, 2, 3, 4, 5, 60})
+
+	h := mustHost(t, Float16, []float32{1.5, -2}, 2)
+	defer h.Close()
+	if bits, err := Data[uint16](h); err != nil || bits[0] != 0x3e00 || bits[1] != 0xc000 {
+		t.Fatalf("Data[uint16](float16) = %#x, %v", bits, err)
+	}
+	i4 := mustHost(t, Int4, []float32{1, -1, 7}, 3)
+	defer i4.Close()
+	if raw, err := Data[uint8](i4); err != nil || len(raw) != 2 || raw[0] != 0xf1 || raw[1] != 0x07 {
+		t.Fatalf("Data[uint8](int4) = %#x, %v", raw, err)
+	}
+	empty, err := NewOn(Host, Int64, 0, 4)
+	if err != nil {
+		t.Fatalf("NewOn: %v", err)
+	}
+	defer empty.Close()
+	if d, err := Data[int64](empty); err != nil || d == nil || len(d) != 0 {
+		t.Fatalf("Data(empty) = %v, %v", d, err)
+	}
+
+	tr, err := x.Transpose(0, 1)
+	if err != nil {
+		t.Fatalf("Transpose: %v", err)
+	}
+	defer tr.Close()
+	m := mustFromFloat32(t, Float32, []float32{1}, 1)
+	defer m.Close()
+	for _, tc := range []struct {
+		name string
+		fn   func() error
+	}{
+		{"wrong element type", func() error { _, err := Data[int32](x); return err }},
+		{"non-contiguous", func() error { _, err := Data[float32](tr); return err }},
+		{"metal tensor", func() error { _, err := Data[float32](m); return err }},
+	} {
+		if err := tc.fn(); err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+	}
+}
+
+func TestToDevice(t *testing.T) {
+	x := mustFromFloat32(t, Float32, []float32{1, 2, 3, 4, 5, 6}, 2, 3)
+	defer x.Close()
+	if x.Device() != Metal {
+		t.Fatalf("New gave a tensor on %v, want metal", x.Device())
+	}
+	tr, err := x.Transpose(0, 1)
+	if err != nil {
+		t.Fatalf("Transpose: %v", err)
+	}
+	defer tr.Close()
+	h, err := tr.ToDevice(Host)
+	if err != nil {
+		t.Fatalf("ToDevice(host): %v", err)
+	}
+	defer h.Close()
+	expectShape(t, "host copy", h, 3, 2)
+	if d, err := Data[float32](h); err != nil {
+		t.Fatalf("Data: %v", err)
+	} else {
+		expectValues(t, "host copy", d, []float32{1, 4, 2, 5, 3, 6})
+		d[0] = 10 // a copy: x is unchanged
+	}
+	expectValues(t, "source after writing the copy", mustLoad(t, x), []float32{1, 2, 3, 4, 5, 6})
+
+	back, err := h.ToDevice(Metal)
+	if err != nil {
+		t.Fatalf("ToDevice(metal): %v", err)
+	}
+	defer back.Close()
+	if back.Device() != Metal {
+		t.Fatalf("ToDevice(metal) gave a tensor on %v", back.Device())
+	}
+	expectValues(t, "round trip", mustLoad(t, back), []float32{10, 4, 2, 5, 3, 6})
+
+	same, err := h.ToDevice(Host)
+	if err != nil {
+		t.Fatalf("ToDevice(host) of a host tensor: %v", err)
+	}
+	if same.Buffer() != h.Buffer() {
+		t.Fatal("ToDevice to the same device copied")
+	}
+	buf := h.Buffer()
+	_ = h.Close()
+	expectFreed(t, "after closing the source", buf, false)
+	_ = same.Close()
+	expectFreed(t, "after closing both", buf, true)
+}
+
+func TestHostOps(t *testing.T) {
+	a := mustHost(t, Float32, []float32{1, 2, 3, 4, 5, 6}, 2, 3)
+	defer a.Close()
+	b := mustHost(t, Float32, []float32{10, 20, 30}, 3)
+	defer b.Close()
+	w := mustHost(t, Float32, []float32{1, 0, 0, 1, 1, 1}, 3, 2)
+	defer w.Close()
+	ids := mustHost(t, Int32, []float32{1, 0}, 2)
+	defer ids.Close()
+	mask := mustHost(t, Bool, []float32{0, 1, 0}, 3)
+	defer mask.Close()
+	zeros := mustHost(t, Float32, []float32{0, 0}, 2)
+	defer zeros.Close()
+
+	s := NewScope()
+	defer s.Close()
+	for _, tc := range []struct {
+		name string
+		fn   func() (*Tensor, error)
+		want []float32
+	}{
+		{"Add", func() (*Tensor, error) { return Add(a, b) }, []float32{11, 22, 33, 14, 25, 36}},
+		{"Exp", func() (*Tensor, error) { return Exp(zeros) }, []float32{1, 1}},
+		{"MatMul", func() (*Tensor, error) { return MatMul(a, w) }, []float32{4, 5, 10, 11}},
+		{"Sum", func() (*Tensor, error) { return Sum(a, false, 1) }, []float32{6, 15}},
+		{"Softmax", func() (*Tensor, error) { return Softmax(zeros, -1) }, []float32{0.5, 0.5}},
+		{"Where", func() (*Tensor, error) { return Where(mask, a, b) }, []float32{10, 2, 30, 10, 5, 30}},
+		{"MaskedFill", func() (*Tensor, error) { return MaskedFill(b, mask, -1) }, []float32{10, -1, 30}},
+		{"IndexSelect", func() (*Tensor, error) { return IndexSelect(a, 0, ids) }, []float32{4, 5, 6, 1, 2, 3}},
+		{"Concat", func() (*Tensor, error) { return Concat(0, b, b) }, []float32{10, 20, 30, 10, 20, 30}},
+		{"CumSum", func() (*Tensor, error) { return CumSum(b, 0) }, []float32{10, 30, 60}},
+		{"To", func() (*Tensor, error) { return b.To(Float16) }, []float32{10, 20, 30}},
+		{"Realize", func() (*Tensor, error) { return Lazy(a).Mul(Lazy(b)).AddScalar(1).Realize() }, []float32{11, 41, 91, 41, 101, 181}},
+	} {
+		out, err := s.Op(tc.fn())
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if out.Device() != Host {
+			t.Errorf("%s: result on %v, want host", tc.name, out.Device())
+		}
+		expectValues(t, tc.name, mustLoad(t, out), tc.want)
+	}
+}
+
+func TestMixedDevices(t *testing.T) {
+	h := mustHost(t, Float32, []float32{1, 2, 3, 4}, 2, 2)
+	defer h.Close()
+	m := mustFromFloat32(t, Float32, []float32{1, 2, 3, 4}, 2, 2)
+	defer m.Close()
+	hc := mustHost(t, Bool, []float32{1, 0, 0, 1}, 2, 2)
+	defer hc.Close()
+	mi := mustFromFloat32(t, Int32, []float32{0}, 1)
+	defer mi.Close()
+
+	s := NewScope()
+	defer s.Close()
+	for _, tc := range []struct {
+		name string
+		fn   func() error
+	}{
+		{"Add", func() error { _, err := Add(h, m); return err }},
+		{"AddInto metal output", func() error { return AddInto(m, h, h) }},
+		{"ExpInto host output", func() error { return ExpInto(h, m) }},
+		{"SumInto", func() error { return SumInto(h, m, true) }},
+		{"MatMul", func() error { _, err := MatMul(m, h); return err }},
+		{"Where", func() error { _, err := Where(hc, m, h); return err }},
+		{"IndexSelect", func() error { _, err := IndexSelect(h, 0, mi); return err }},
+		{"IndexCopy", func() error {
+			hi := mustHost(t, Int32, []float32{0}, 1)
+			s.Track(hi)
+			return IndexCopy(h, 0, hi, m)
+		}},
+		{"Concat", func() error { _, err := Concat(0, h, m); return err }},
+		{"Softmax mask", func() error { _, err := SoftmaxWith(m, -1, SoftmaxOptions{Mask: hc}); return err }},
+		{"Realize", func() error { _, err := Lazy(h).Add(Lazy(m)).Realize(); return err }},
+	} {
+		err := tc.fn()
+		if !errors.Is(err, ErrDeviceMismatch) {
+			t.Errorf("%s: got %v, want ErrDeviceMismatch", tc.name, err)
+		}
+	}
+	_, err := Add(h, m)
+	if want := "Add: tensors on different devices: host and metal"; err == nil || err.Error() != want {
+		t.Errorf("Add error = %v, want %q", err, want)
+	}
+}
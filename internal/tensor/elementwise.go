@@ -0,0 +1,541 @@
+package tensor
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+
+	"kylesmith19091/fastgo/internal/metal"
+)
+
+// ---------- Broadcasting ----------
+
+// BroadcastShapes returns the NumPy broadcast of the given shapes: shapes are
+// right-aligned and each dimension must match or be 1.
+func BroadcastShapes(shapes ...[]int) ([]int, error) {
+	rank := 0
+	for _, s := range shapes {
+		rank = max(rank, len(s))
+	}
+	out := make([]int, rank)
+	for i := range out {
+		out[i] = 1
+	}
+	for _, s := range shapes {
+		for i, d := range s {
+			j := rank - len(s) + i
+			switch {
+			case out[j] == d || d == 1:
+			case out[j] == 1:
+				out[j] = d
+			default:
+				return nil, fmt.Errorf("shapes %v are not broadcastable", shapes)
+			}
+		}
+	}
+	return out, nil
+}
+
+// BroadcastTo returns a view of t expanded to shape following NumPy rules.
+// Broadcast dimensions get stride 0, so no data is copied.
+func (t *Tensor) BroadcastTo(shape ...int) (*Tensor, error) {
+	if err := t.live(); err != nil {
+		return nil, err
+	}
+	v, err := t.broadcastTo(shape)
+	if err != nil {
+		return nil, err
+	}
+	return t.share(v), nil
+}
+
+// broadcastTo is BroadcastTo returning a borrowed view (see view).
+func (t *Tensor) broadcastTo(shape []int) (*Tensor, error) {
+	if len(shape) < len(t.Shape) {
+		return nil, fmt.Errorf("cannot broadcast %v to %v", t.Shape, shape)
+	}
+	if is4Bit(t.DT) {
+		return nil, errors.New("4-bit tensors cannot be broadcast")
+	}
+	strides := make([]int, len(shape))
+	lead := len(shape) - len(t.Shape)
+	for i := range shape {
+		if i < lead {
+			continue
+		}
+		d := t.Shape[i-lead]
+		switch {
+		case d == shape[i]:
+			strides[i] = t.Strides[i-lead]
+		case d == 1:
+			strides[i] = 0
+		default:
+			return nil, fmt.Errorf("cannot broadcast %v to %v", t.Shape, shape)
+		}
+	}
+	return t.view(t.Offset, shape, strides)
+}
+
+// ---------- Elementwise ops ----------
+//
+// All elementwise ops compute in float32 and convert the result to the output
+// dtype with the rounding rules of Tensor.To. Binary ops promote mismatched
+// dtypes: an integer and a float operand give the float dtype, two integer
+// dtypes give the wider one, and any other mix gives Float32. Exp, Log, Sqrt, Rsqrt, Div and
+// Pow of integer inputs produce Float32.
+//
+// Each op has an Into variant that writes into a caller-provided tensor whose
+// shape must equal the broadcast shape; its dtype may differ from the inputs.
+// out may be an input itself, and the InPlace forms write into their first
+// operand. An output that partly overlaps an input is also handled, on the
+// CPU, since the kernels read and write elements in parallel. An output
+// whose elements share memory, such as a broadcast view, is rejected.
+
+type binaryOp int
+
+// Op codes are shared with the Metal kernels; keep the order in sync.
+const (
+	opAdd binaryOp = iota
+	opSub
+	opMul
+	opDiv
+	opMaximum
+	opMinimum
+	opPow
+)
+
+func (op binaryOp) String() string {
+	return [...]string{"Add", "Sub", "Mul", "Div", "Maximum", "Minimum", "Pow"}[op]
+}
+
+func (op binaryOp) apply(x, y float32) float32 {
+	switch op {
+	case opAdd:
+		return x + y
+	case opSub:
+		return x - y
+	case opMul:
+		return x * y
+	case opDiv:
+		return x / y
+	case opMaximum:
+		if x != x || y != y {
+			return x + y
+		}
+		return max(x, y)
+	case opMinimum:
+		if x != x || y != y {
+			return x + y
+		}
+		return min(x, y)
+	default:
+		return float32(math.Pow(float64(x), float64(y)))
+	}
+}
+
+// floatResult reports whether the op always yields a float for integer inputs.
+func (op binaryOp) floatResult() bool { return op == opDiv || op == opPow }
+
+type unaryOp int
+
+// Unary op codes continue with the scalar forms of the binary ops, matching
+// apply_unary in mm.metal.
+const (
+	opNeg unaryOp = iota
+	opExp
+	opLog
+	opSqrt
+	opRsqrt
+	opAddScalar // opAddScalar + binaryOp applies that binary op with a scalar rhs
+	opSubScalar
+	opMulScalar
+	opDivScalar
+	opMaximumScalar
+	opMinimumScalar
+	opPowScalar
+)
+
+func (op unaryOp) String() string {
+	if op >= opAddScalar {
+		return binaryOp(op-opAddScalar).String() + "Scalar"
+	}
+	return [...]string{"Neg", "Exp", "Log", "Sqrt", "Rsqrt"}[op]
+}
+
+func (op unaryOp) apply(x, s float32) float32 {
+	switch op {
+	case opNeg:
+		return -x
+	case opExp:
+		return float32(math.Exp(float64(x)))
+	case opLog:
+		return float32(math.Log(float64(x)))
+	case opSqrt:
+		return float32(math.Sqrt(float64(x)))
+	case opRsqrt:
+		return float32(1 / math.Sqrt(float64(x)))
+	default:
+		return binaryOp(op-opAddScalar).apply(x, s)
+	}
+}
+
+func (op unaryOp) floatResult() bool {
+	switch op {
+	case opExp, opLog, opSqrt, opRsqrt:
+		return true
+	case opNeg:
+		return false
+	default:
+		return binaryOp(op - opAddScalar).floatResult()
+	}
+}
+
+func isIntDType(dt DType) bool { return dt == Int8 || dt == Int4 || dt == Int32 || dt == Int64 }
+
+// promoteTypes returns the result dtype of a binary op on a and b.
+func promoteTypes(a, b DType) DType {
+	switch {
+	case a == b:
+		return a
+	case a == Bool:
+		return b
+	case b == Bool:
+		return a
+	case isIntDType(a) && isIntDType(b):
+		if a.SizeOf() >= b.SizeOf() && a != Int4 {
+			return a
+		}
+		return b
+	case isIntDType(a):
+		return b
+	case isIntDType(b):
+		return a
+	default:
+		return Float32
+	}
+}
+
+func Add(a, b *Tensor) (*Tensor, error)     { return applyBinary(opAdd, nil, a, b) }
+func Sub(a, b *Tensor) (*Tensor, error)     { return applyBinary(opSub, nil, a, b) }
+func Mul(a, b *Tensor) (*Tensor, error)     { return applyBinary(opMul, nil, a, b) }
+func Div(a, b *Tensor) (*Tensor, error)     { return applyBinary(opDiv, nil, a, b) }
+func Maximum(a, b *Tensor) (*Tensor, error) { return applyBinary(opMaximum, nil, a, b) }
+func Minimum(a, b *Tensor) (*Tensor, error) { return applyBinary(opMinimum, nil, a, b) }
+func Pow(a, b *Tensor) (*Tensor, error)     { return applyBinary(opPow, nil, a, b) }
+
+func AddInto(out, a, b *Tensor) error     { return binaryInto(opAdd, out, a, b) }
+func SubInto(out, a, b *Tensor) error     { return binaryInto(opSub, out, a, b) }
+func MulInto(out, a, b *Tensor) error     { return binaryInto(opMul, out, a, b) }
+func DivInto(out, a, b *Tensor) error     { return binaryInto(opDiv, out, a, b) }
+func MaximumInto(out, a, b *Tensor) error { return binaryInto(opMaximum, out, a, b) }
+func MinimumInto(out, a, b *Tensor) error { return binaryInto(opMinimum, out, a, b) }
+func PowInto(out, a, b *Tensor) error     { return binaryInto(opPow, out, a, b) }
+
+func AddInPlace(a, b *Tensor) error     { return AddInto(a, a, b) }
+func SubInPlace(a, b *Tensor) error     { return SubInto(a, a, b) }
+func MulInPlace(a, b *Tensor) error     { return MulInto(a, a, b) }
+func DivInPlace(a, b *Tensor) error     { return DivInto(a, a, b) }
+func MaximumInPlace(a, b *Tensor) error { return MaximumInto(a, a, b) }
+func MinimumInPlace(a, b *Tensor) error { return MinimumInto(a, a, b) }
+func PowInPlace(a, b *Tensor) error     { return PowInto(a, a, b) }
+
+func Neg(a *Tensor) (*Tensor, error)   { return applyUnary(opNeg, nil, a, 0) }
+func Exp(a *Tensor) (*Tensor, error)   { return applyUnary(opExp, nil, a, 0) }
+func Log(a *Tensor) (*Tensor, error)   { return applyUnary(opLog, nil, a, 0) }
+func Sqrt(a *Tensor) (*Tensor, error)  { return applyUnary(opSqrt, nil, a, 0) }
+func Rsqrt(a *Tensor) (*Tensor, error) { return applyUnary(opRsqrt, nil, a, 0) }
+
+func NegInto(out, a *Tensor) error   { return unaryInto(opNeg, out, a, 0) }
+func ExpInto(out, a *Tensor) error   { return unaryInto(opExp, out, a, 0) }
+func LogInto(out, a *Tensor) error   { return unaryInto(opLog, out, a, 0) }
+func SqrtInto(out, a *Tensor) error  { return unaryInto(opSqrt, out, a, 0) }
+func RsqrtInto(out, a *Tensor) error { return unaryInto(opRsqrt, out, a, 0) }
+
+func NegInPlace(a *Tensor) error   { return NegInto(a, a) }
+func ExpInPlace(a *Tensor) error   { return ExpInto(a, a) }
+func LogInPlace(a *Tensor) error   { return LogInto(a, a) }
+func SqrtInPlace(a *Tensor) error  { return SqrtInto(a, a) }
+func RsqrtInPlace(a *Tensor) error { return RsqrtInto(a, a) }
+
+// Scalar variants apply the binary op with s as the right-hand operand.
+
+func AddScalar(a *Tensor, s float32) (*Tensor, error) {
+	return applyUnary(opAddScalar, nil, a, s)
+}
+
+func SubScalar(a *Tensor, s float32) (*Tensor, error) {
+	return applyUnary(opSubScalar, nil, a, s)
+}
+
+func MulScalar(a *Tensor, s float32) (*Tensor, error) {
+	return applyUnary(opMulScalar, nil, a, s)
+}
+
+func DivScalar(a *Tensor, s float32) (*Tensor, error) {
+	return applyUnary(opDivScalar, nil, a, s)
+}
+
+func MaximumScalar(a *Tensor, s float32) (*Tensor, error) {
+	return applyUnary(opMaximumScalar, nil, a, s)
+}
+
+func MinimumScalar(a *Tensor, s float32) (*Tensor, error) {
+	return applyUnary(opMinimumScalar, nil, a, s)
+}
+
+func PowScalar(a *Tensor, s float32) (*Tensor, error) {
+	return applyUnary(opPowScalar, nil, a, s)
+}
+
+func AddScalarInto(out, a *Tensor, s float32) error {
+	return unaryInto(opAddScalar, out, a, s)
+}
+
+func SubScalarInto(out, a *Tensor, s float32) error {
+	return unaryInto(opSubScalar, out, a, s)
+}
+
+func MulScalarInto(out, a *Tensor, s float32) error {
+	return unaryInto(opMulScalar, out, a, s)
+}
+
+func DivScalarInto(out, a *Tensor, s float32) error {
+	return unaryInto(opDivScalar, out, a, s)
+}
+
+func MaximumScalarInto(out, a *Tensor, s float32) error {
+	return unaryInto(opMaximumScalar, out, a, s)
+}
+
+func MinimumScalarInto(out, a *Tensor, s float32) error {
+	return unaryInto(opMinimumScalar, out, a, s)
+}
+
+func PowScalarInto(out, a *Tensor, s float32) error {
+	return unaryInto(opPowScalar, out, a, s)
+}
+
+func AddScalarInPlace(a *Tensor, s float32) error     { return AddScalarInto(a, a, s) }
+func SubScalarInPlace(a *Tensor, s float32) error     { return SubScalarInto(a, a, s) }
+func MulScalarInPlace(a *Tensor, s float32) error     { return MulScalarInto(a, a, s) }
+func DivScalarInPlace(a *Tensor, s float32) error     { return DivScalarInto(a, a, s) }
+func MaximumScalarInPlace(a *Tensor, s float32) error { return MaximumScalarInto(a, a, s) }
+func MinimumScalarInPlace(a *Tensor, s float32) error { return MinimumScalarInto(a, a, s) }
+func PowScalarInPlace(a *Tensor, s float32) error     { return PowScalarInto(a, a, s) }
+
+// binaryInto runs a binary op into a caller-provided out.
+func binaryInto(op binaryOp, out, a, b *Tensor) error {
+	if out == nil {
+		return fmt.Errorf("%v: nil output tensor", op)
+	}
+	_, err := applyBinary(op, out, a, b)
+	return err
+}
+
+// unaryInto runs a unary op into a caller-provided out.
+func unaryInto(op unaryOp, out, a *Tensor, s float32) error {
+	if out == nil {
+		return fmt.Errorf("%v: nil output tensor", op)
+	}
+	_, err := applyUnary(op, out, a, s)
+	return err
+}
+
+// prepareOut validates a caller-provided output or allocates a new one on
+// dev, the inputs' device.
+func prepareOut(op fmt.Stringer, out *Tensor, dev Device, dt DType, shape []int) (*Tensor, bool, error) {
+	if out == nil {
+		t, err := NewOn(dev, dt, shape...)
+		return t, true, err
+	}
+	if err := out.live(); err != nil {
+		return nil, false, fmt.Errorf("%v: output: %w", op, err)
+	}
+	if out.Device() != dev {
+		return nil, false, fmt.Errorf("%v: output: %w: %v and %v", op, ErrDeviceMismatch, out.Device(), dev)
+	}
+	if !equalShapes(out.Shape, shape) {
+		return nil, false, fmt.Errorf("%v: output shape %v, want %v", op, out.Shape, shape)
+	}
+	if out.selfOverlapping() {
+		return nil, false, fmt.Errorf("%v: output elements share memory (strides %v)", op, out.Strides)
+	}
+	return out, false, nil
+}
+
+// prepareOutExact is prepareOut for ops whose output dtype is fixed by the
+// inputs rather than converted on store.
+func prepareOutExact(op fmt.Stringer, out *Tensor, dev Device, dt DType, shape []int) (*Tensor, bool, error) {
+	if out != nil && out.live() == nil && out.DT != dt {
+		return nil, false, fmt.Errorf("%v: output dtype %v, want %v", op, out.DT, dt)
+	}
+	return prepareOut(op, out, dev, dt, shape)
+}
+
+// aliasSafe reports whether an elementwise kernel may write out while
+// reading ins: each input is either out itself or disjoint from it.
+func aliasSafe(out *Tensor, ins ...*Tensor) bool {
+	for _, in := range ins {
+		if overlaps(out, in) && !sameView(out, in) {
+			return false
+		}
+	}
+	return true
+}
+
+// disjoint reports whether out shares no memory with any of ins, as kernels
+// that read many inputs per output element require.
+func disjoint(out *Tensor, ins ...*Tensor) bool {
+	for _, in := range ins {
+		if overlaps(out, in) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalShapes(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func applyBinary(op binaryOp, out, a, b *Tensor) (*Tensor, error) {
+	if err := cmp.Or(a.live(), b.live()); err != nil {
+		return nil, fmt.Errorf("%v: %w", op, err)
+	}
+	dev, err := sameDevice(a, b)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", op, err)
+	}
+	shape, err := BroadcastShapes(a.Shape, b.Shape)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", op, err)
+	}
+	dt := promoteTypes(a.DT, b.DT)
+	if op.floatResult() && isIntDType(dt) {
+		dt = Float32
+	}
+	out, owned, err := prepareOut(op, out, dev, dt, shape)
+	if err != nil || dev == Meta {
+		return out, err
+	}
+	fail := func(err error) (*Tensor, error) {
+		if owned {
+			_ = out.Close()
+		}
+		return nil, fmt.Errorf("%v: %w", op, err)
+	}
+	ba, err := a.broadcastTo(shape)
+	if err != nil {
+		return fail(err)
+	}
+	bb, err := b.broadcastTo(shape)
+	if err != nil {
+		return fail(err)
+	}
+	if p, ok := elementwiseParams(out, ba, bb); ok && useMetal(out, ba, bb) && aliasSafe(out, ba, bb) {
+		p.Op = int32(op)
+		err = metal.ElementwiseBinaryBuffers(p, ba.buf, bb.buf, out.buf)
+	} else {
+		err = binaryCPU(op, out, ba, bb)
+	}
+	if err == nil {
+		err = checkOutput(out, a, b)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	return out, nil
+}
+
+func binaryCPU(op binaryOp, out, a, b *Tensor) error {
+	x, err := a.loadFloat32()
+	if err != nil {
+		return err
+	}
+	y, err := b.loadFloat32()
+	if err != nil {
+		return err
+	}
+	for i := range x {
+		x[i] = op.apply(x[i], y[i])
+	}
+	return out.storeFloat32(x)
+}
+
+func applyUnary(op unaryOp, out, a *Tensor, s float32) (*Tensor, error) {
+	if err := a.live(); err != nil {
+		return nil, fmt.Errorf("%v: %w", op, err)
+	}
+	dt := a.DT
+	if op.floatResult() && isIntDType(dt) {
+		dt = Float32
+	}
+	out, owned, err := prepareOut(op, out, a.Device(), dt, a.Shape)
+	if err != nil || out.Device() == Meta {
+		return out, err
+	}
+	if p, ok := elementwiseParams(out, a, a); ok && useMetal(out, a) && aliasSafe(out, a) {
+		p.Op = int32(op)
+		p.Scalar = s
+		err = metal.ElementwiseUnaryBuffers(p, a.buf, out.buf)
+	} else {
+		err = unaryCPU(op, out, a, s)
+	}
+	if err == nil {
+		err = checkOutputWith(out, []*Tensor{a}, nil, s)
+	}
+	if err != nil {
+		if owned {
+			_ = out.Close()
+		}
+		return nil, fmt.Errorf("%v: %w", op, err)
+	}
+	return out, nil
+}
+
+func unaryCPU(op unaryOp, out, a *Tensor, s float32) error {
+	x, err := a.loadFloat32()
+	if err != nil {
+		return err
+	}
+	for i := range x {
+		x[i] = op.apply(x[i], s)
+	}
+	return out.storeFloat32(x)
+}
+
+// elementwiseParams builds kernel params when out, a and b are float32, out is
+// dense and every stride/offset is element-aligned; ok is false otherwise.
+func elementwiseParams(out, a, b *Tensor) (*metal.ElementwiseParams, bool) {
+	if out.DT != Float32 || a.DT != Float32 || b.DT != Float32 || !out.isDense() {
+		return nil, false
+	}
+	if len(out.Shape) > metal.MaxDims || out.Numel() > math.MaxInt32 {
+		return nil, false
+	}
+	p := &metal.ElementwiseParams{Rank: int32(len(out.Shape)), N: int32(out.Numel())}
+	for _, off := range []int{out.Offset, a.Offset, b.Offset} {
+		if off%4 != 0 {
+			return nil, false
+		}
+	}
+	p.OffOut, p.OffA, p.OffB = int32(out.Offset/4), int32(a.Offset/4), int32(b.Offset/4)
+	for d := range out.Shape {
+		if a.Strides[d]%4 != 0 || b.Strides[d]%4 != 0 {
+			return nil, false
+		}
+		p.Shape[d] = int32(out.Shape[d])
+		p.StrideA[d] = int32(a.Strides[d] / 4)
+		p.StrideB[d] = int32(b.Strides[d] / 4)
+	}
+	return p, true
+}
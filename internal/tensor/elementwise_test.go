@@ -0,0 +1,209 @@
+package tensor
+
+import (
+	"math"
+	"testing"
+)
+
+func mustFromFloat32(t *testing.T, dt DType, data []float32, shape ...int) *Tensor {
+	t.Helper()
+	tt, err := New(dt, shape...)
+	if err != nil {
+		t.Fatalf("New(%v, %v): %v", dt, shape, err)
+	}
+	if err := tt.storeFloat32(data); err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	t.Cleanup(func() { _ = tt.Close() })
+	return tt
+}
+
+func mustLoad(t *testing.T, tt *Tensor) []float32 {
+	t.Helper()
+	vals, err := tt.loadFloat32()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	return vals
+}
+
+func expectValues(t *testing.T, name string, got, want []float32) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: len %d want %d", name, len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] && !(math.IsNaN(float64(got[i])) && math.IsNaN(float64(want[i]))) {
+			t.Fatalf("%s @%d: got %v want %v", name, i, got[i], want[i])
+		}
+	}
+}
+
+func TestBroadcastShapes(t *testing.T) {
+	got, err := BroadcastShapes([]int{2, 1, 4}, []int{3, 1}, []int{4})
+	if err != nil || !equalShapes(got, []int{2, 3, 4}) {
+		t.Fatalf("BroadcastShapes=%v,%v", got, err)
+	}
+	if _, err := BroadcastShapes([]int{2, 3}, []int{4}); err == nil {
+		t.Fatalf("expected incompatible shapes error")
+	}
+}
+
+func TestBinaryBroadcast(t *testing.T) {
+	a := mustFromFloat32(t, Float32, []float32{1, 2, 3, 4, 5, 6}, 2, 3)
+	bias := mustFromFloat32(t, Float32, []float32{10, 20, 30}, 3)
+	col := mustFromFloat32(t, Float32, []float32{2, -1}, 2, 1)
+
+	out, err := Add(a, bias)
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	defer out.Close()
+	expectValues(t, "Add", mustLoad(t, out), []float32{11, 22, 33, 14, 25, 36})
+
+	out2, err := Mul(col, bias) // [2,1] x [3] -> [2,3]
+	if err != nil {
+		t.Fatalf("Mul: %v", err)
+	}
+	defer out2.Close()
+	if !equalShapes(out2.Shape, []int{2, 3}) {
+		t.Fatalf("Mul shape %v", out2.Shape)
+	}
+	expectValues(t, "Mul", mustLoad(t, out2), []float32{20, 40, 60, -10, -20, -30})
+
+	if _, err := Add(a, mustView(t, col, 0, []int{2}, []int{4})); err == nil {
+		t.Fatalf("expected broadcast error for [2,3]+[2]")
+	}
+}
+
+func TestBinaryOpsValues(t *testing.T) {
+	nan := float32(math.NaN())
+	a := mustFromFloat32(t, Float32, []float32{4, -2, 9, nan}, 4)
+	b := mustFromFloat32(t, Float32, []float32{2, 3, 0.5, 1}, 4)
+	cases := []struct {
+		name string
+		fn   func(a, b *Tensor) (*Tensor, error)
+		want []float32
+	}{
+		{"Sub", Sub, []float32{2, -5, 8.5, nan}},
+		{"Div", Div, []float32{2, -2.0 / 3, 18, nan}},
+		{"Maximum", Maximum, []float32{4, 3, 9, nan}},
+		{"Minimum", Minimum, []float32{2, -2, 0.5, nan}},
+		{"Pow", Pow, []float32{16, -8, 3, nan}},
+	}
+	for _, c := range cases {
+		out, err := c.fn(a, b)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		expectValues(t, c.name, mustLoad(t, out), c.want)
+		_ = out.Close()
+	}
+}
+
+func TestUnaryAndScalarOps(t *testing.T) {
+	x := []float32{0.25, 1, 4, 9}
+	a := mustFromFloat32(t, Float32, x, 2, 2)
+	ref := func(f func(float64) float64) []float32 {
+		out := make([]float32, len(x))
+		for i, v := range x {
+			out[i] = float32(f(float64(v)))
+		}
+		return out
+	}
+	cases := []struct {
+		name string
+		fn   func(*Tensor) (*Tensor, error)
+		want []float32
+	}{
+		{"Neg", Neg, []float32{-0.25, -1, -4, -9}},
+		{"Exp", Exp, ref(math.Exp)},
+		{"Log", Log, ref(math.Log)},
+		{"Sqrt", Sqrt, []float32{0.5, 1, 2, 3}},
+		{"Rsqrt", Rsqrt, []float32{2, 1, 0.5, float32(1.0 / 3)}},
+		{"AddScalar", func(a *Tensor) (*Tensor, error) { return AddScalar(a, 1) }, []float32{1.25, 2, 5, 10}},
+		{"SubScalar", func(a *Tensor) (*Tensor, error) { return SubScalar(a, 1) }, []float32{-0.75, 0, 3, 8}},
+		{"MulScalar", func(a *Tensor) (*Tensor, error) { return MulScalar(a, -2) }, []float32{-0.5, -2, -8, -18}},
+		{"DivScalar", func(a *Tensor) (*Tensor, error) { return DivScalar(a, 4) }, []float32{0.0625, 0.25, 1, 2.25}},
+		{"MaximumScalar", func(a *Tensor) (*Tensor, error) { return MaximumScalar(a, 2) }, []float32{2, 2, 4, 9}},
+		{"MinimumScalar", func(a *Tensor) (*Tensor, error) { return MinimumScalar(a, 2) }, []float32{0.25, 1, 2, 2}},
+		{"PowScalar", func(a *Tensor) (*Tensor, error) { return PowScalar(a, 2) }, []float32{0.0625, 1, 16, 81}},
+	}
+	for _, c := range cases {
+		out, err := c.fn(a)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		expectValues(t, c.name, mustLoad(t, out), c.want)
+		_ = out.Close()
+	}
+}
+
+func TestElementwiseDTypePromotion(t *testing.T) {
+	i8 := mustFromFloat32(t, Int8, []float32{1, 2, 3}, 3)
+	f16 := mustFromFloat32(t, Float16, []float32{0.5, 0.5, 0.5}, 3)
+	bf16 := mustFromFloat32(t, BFloat16, []float32{1, 1, 1}, 3)
+	cases := []struct {
+		a, b *Tensor
+		fn   func(a, b *Tensor) (*Tensor, error)
+		want DType
+	}{
+		{i8, f16, Add, Float16},
+		{f16, bf16, Add, Float32},
+		{i8, i8, Add, Int8},
+		{i8, i8, Div, Float32},
+	}
+	for _, c := range cases {
+		out, err := c.fn(c.a, c.b)
+		if err != nil {
+			t.Fatalf("%v op %v: %v", c.a.DT, c.b.DT, err)
+		}
+		if out.DT != c.want {
+			t.Fatalf("%v op %v -> %v want %v", c.a.DT, c.b.DT, out.DT, c.want)
+		}
+		_ = out.Close()
+	}
+	out, err := Exp(i8)
+	if err != nil || out.DT != Float32 {
+		t.Fatalf("Exp(int8) dtype %v err %v", out.DT, err)
+	}
+	_ = out.Close()
+}
+
+func TestElementwiseStridedAndInto(t *testing.T) {
+	a := mustFromFloat32(t, Float32, []float32{0, 1, 2, 3, 4, 5}, 2, 3)
+	tr := mustView(t, a, 0, []int{3, 2}, []int{4, 12}) // transpose -> [[0,3],[1,4],[2,5]]
+	ones := mustFromFloat32(t, Float32, []float32{1, 1, 1, 1, 1, 1}, 3, 2)
+	out, err := Add(tr, ones)
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	defer out.Close()
+	expectValues(t, "Add transposed", mustLoad(t, out), []float32{1, 4, 2, 5, 3, 6})
+
+	// Into with aliasing: a = a * 2, written in place.
+	if err := MulScalarInto(a, a, 2); err != nil {
+		t.Fatalf("MulScalarInto: %v", err)
+	}
+	expectValues(t, "MulScalarInto", mustLoad(t, a), []float32{0, 2, 4, 6, 8, 10})
+
+	// Into a float16 output of the right shape converts on store.
+	h := mustFromFloat32(t, Float16, make([]float32, 6), 3, 2)
+	if err := SubInto(h, tr, ones); err != nil {
+		t.Fatalf("SubInto: %v", err)
+	}
+	expectValues(t, "SubInto", mustLoad(t, h), []float32{-1, 5, 1, 7, 3, 9})
+
+	if err := AddInto(ones, a, a); err == nil {
+		t.Fatalf("expected output shape mismatch error")
+	}
+}
+
+func mustView(t *testing.T, tt *Tensor, off int, shape, strides []int) *Tensor {
+	t.Helper()
+	v, err := tt.View(off, shape, strides)
+	if err != nil {
+		t.Fatalf("View: %v", err)
+	}
+	return v
+}
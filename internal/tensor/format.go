@@ -0,0 +1,277 @@
+package tensor
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ---------- Formatting ----------
+//
+// Tensors print like NumPy arrays: nested brackets, one innermost row per
+// line, and large tensors summarized with "..." so only the edges of each
+// dim are shown. A scalar prints as its bare value and any empty tensor as
+// "[]". The values come from one bulk download of the view.
+//
+// Tensor implements fmt.Formatter: %v and %s print the values, %+v prefixes
+// a metadata line (dtype, shape, strides, offset), and a precision such as
+// %.2v overrides PrintOptions.Precision.
+
+// PrintOptions controls tensor formatting, mirroring numpy.set_printoptions.
+// Zero fields take the defaults noted below.
+type PrintOptions struct {
+	Precision int // digits after the decimal point for floats (4; negative for none)
+	Threshold int // summarize tensors with more elements than this (1000)
+	EdgeItems int // entries kept at each end of a summarized dim (3)
+	LineWidth int // wrap innermost rows longer than this many bytes (75)
+}
+
+func (o PrintOptions) withDefaults() PrintOptions {
+	switch {
+	case o.Precision == 0:
+		o.Precision = 4
+	case o.Precision < 0:
+		o.Precision = 0
+	}
+	if o.Threshold <= 0 {
+		o.Threshold = 1000
+	}
+	if o.EdgeItems <= 0 {
+		o.EdgeItems = 3
+	}
+	if o.LineWidth <= 0 {
+		o.LineWidth = 75
+	}
+	return o
+}
+
+// String formats t's values with the default PrintOptions.
+func (t *Tensor) String() string { return t.StringWith(PrintOptions{}) }
+
+// StringWith formats t's values with opts.
+func (t *Tensor) StringWith(opts PrintOptions) string {
+	if t == nil {
+		return "<nil Tensor>"
+	}
+	if t.closed || t.buf == nil {
+		return "<closed Tensor>"
+	}
+	if t.Device() == Meta {
+		return fmt.Sprintf("<Tensor %v%v on meta>", t.DT, t.Shape)
+	}
+	s, err := formatValues(t, opts.withDefaults())
+	if err != nil {
+		return fmt.Sprintf("<Tensor %v%v: %v>", t.DT, t.Shape, err)
+	}
+	return s
+}
+
+// Format implements fmt.Formatter.
+func (t *Tensor) Format(f fmt.State, verb rune) {
+	switch verb {
+	case 'v', 's':
+	default:
+		fmt.Fprintf(f, "%%!%c(*tensor.Tensor)", verb)
+		return
+	}
+	var opts PrintOptions
+	if p, ok := f.Precision(); ok {
+		opts.Precision = p
+		if p == 0 {
+			opts.Precision = -1
+		}
+	}
+	if f.Flag('+') && t != nil {
+		fmt.Fprintf(f, "Tensor(%v, shape=%v, strides=%v, offset=%d, contiguous=%t)\n", t.DT, t.Shape, t.Strides, t.Offset, t.Contiguous())
+	}
+	_, _ = f.Write([]byte(t.StringWith(opts)))
+}
+
+// formatValues renders the summarized nested-bracket form of t.
+func formatValues(t *Tensor, opts PrintOptions) (string, error) {
+	if t.Numel() == 0 {
+		return "[]", nil
+	}
+	summarize := t.Numel() > opts.Threshold
+	// shown[d] lists the indices printed along dim d; -1 marks the "...".
+	shown := make([][]int, len(t.Shape))
+	for d, n := range t.Shape {
+		if summarize && n > 2*opts.EdgeItems {
+			for i := 0; i < opts.EdgeItems; i++ {
+				shown[d] = append(shown[d], i)
+			}
+			shown[d] = append(shown[d], -1)
+			for i := n - opts.EdgeItems; i < n; i++ {
+				shown[d] = append(shown[d], i)
+			}
+		} else {
+			for i := 0; i < n; i++ {
+				shown[d] = append(shown[d], i)
+			}
+		}
+	}
+	// Flat row-major positions of the shown entries, in print order.
+	var flat []int
+	var collect func(d, base int)
+	collect = func(d, base int) {
+		if d == len(t.Shape) {
+			flat = append(flat, base)
+			return
+		}
+		for _, i := range shown[d] {
+			if i >= 0 {
+				collect(d+1, base*t.Shape[d]+i)
+			}
+		}
+	}
+	collect(0, 0)
+
+	cells, err := formatCells(t, flat, opts.Precision)
+	if err != nil {
+		return "", err
+	}
+	width := 0
+	for _, c := range cells {
+		width = max(width, len(c))
+	}
+
+	var sb strings.Builder
+	next := 0
+	var write func(d int)
+	write = func(d int) {
+		if d == len(t.Shape) {
+			sb.WriteString(cells[0])
+			return
+		}
+		sb.WriteByte('[')
+		if d == len(t.Shape)-1 {
+			// Innermost row: space-separated cells wrapped at LineWidth, with
+			// continuation lines aligned under the first cell.
+			indent := strings.Repeat(" ", d+1)
+			col := d + 1
+			for k, i := range shown[d] {
+				cell := "..."
+				if i >= 0 {
+					cell = fmt.Sprintf("%*s", width, cells[next])
+					next++
+				}
+				if k > 0 {
+					// Leave room for the d+1 closing brackets.
+					if col+1+len(cell)+d+1 > opts.LineWidth {
+						sb.WriteString("\n" + indent)
+						col = d + 1
+					} else {
+						sb.WriteByte(' ')
+						col++
+					}
+				}
+				sb.WriteString(cell)
+				col += len(cell)
+			}
+		} else {
+			sep := strings.Repeat("\n", len(t.Shape)-d-1) + strings.Repeat(" ", d+1)
+			for k, i := range shown[d] {
+				if k > 0 {
+					sb.WriteString(sep)
+				}
+				if i < 0 {
+					sb.WriteString("...")
+					continue
+				}
+				write(d + 1)
+			}
+		}
+		sb.WriteByte(']')
+	}
+	write(0)
+	return sb.String(), nil
+}
+
+// formatCells downloads t once and formats the entries at the given flat
+// row-major positions.
+func formatCells(t *Tensor, flat []int, precision int) ([]string, error) {
+	cells := make([]string, len(flat))
+	switch {
+	case t.DT == Bool:
+		raw, err := t.packedBytes()
+		if err != nil {
+			return nil, err
+		}
+		for k, i := range flat {
+			cells[k] = strconv.FormatBool(raw[i] != 0)
+		}
+	case isIntDType(t.DT):
+		vals, err := t.loadInt64()
+		if err != nil {
+			return nil, err
+		}
+		for k, i := range flat {
+			cells[k] = strconv.FormatInt(vals[i], 10)
+		}
+	default:
+		vals, err := t.loadFloat32()
+		if err != nil {
+			return nil, err
+		}
+		xs := make([]float64, len(flat))
+		for k, i := range flat {
+			xs[k] = float64(vals[i])
+		}
+		formatFloats(cells, xs, precision)
+	}
+	return cells, nil
+}
+
+// formatFloats follows NumPy's default "maxprec" mode: every value is
+// printed with the same number of fraction digits, the fewest (at most
+// precision) that show each value exactly at that precision. Scientific
+// notation is used when the finite magnitudes reach 1e8, drop below 1e-4, or
+// span more than three orders of magnitude.
+func formatFloats(cells []string, xs []float64, precision int) {
+	var maxAbs, minAbs float64 = 0, math.Inf(1)
+	for _, x := range xs {
+		if a := math.Abs(x); !math.IsInf(a, 0) && !math.IsNaN(a) && a != 0 {
+			maxAbs, minAbs = max(maxAbs, a), min(minAbs, a)
+		}
+	}
+	verb := byte('f')
+	if maxAbs >= 1e8 || (maxAbs > 0 && (minAbs < 1e-4 || maxAbs/minAbs > 1e3)) {
+		verb = 'e'
+	}
+	digits := 0
+	for _, x := range xs {
+		if math.IsInf(x, 0) || math.IsNaN(x) {
+			continue
+		}
+		s := strconv.FormatFloat(x, verb, precision, 64)
+		mant := s
+		if e := strings.IndexByte(s, 'e'); e >= 0 {
+			mant = s[:e]
+		}
+		if dot := strings.IndexByte(mant, '.'); dot >= 0 {
+			digits = max(digits, len(strings.TrimRight(mant[dot+1:], "0")))
+		}
+	}
+	for k, x := range xs {
+		switch {
+		case math.IsNaN(x):
+			cells[k] = "nan"
+		case math.IsInf(x, 1):
+			cells[k] = "inf"
+		case math.IsInf(x, -1):
+			cells[k] = "-inf"
+		default:
+			s := strconv.FormatFloat(x, verb, digits, 64)
+			if digits == 0 {
+				// NumPy keeps the point: "1." and "1.e+08".
+				if e := strings.IndexByte(s, 'e'); e >= 0 {
+					s = s[:e] + "." + s[e:]
+				} else {
+					s += "."
+				}
+			}
+			cells[k] = s
+		}
+	}
+}
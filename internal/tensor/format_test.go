@@ -0,0 +1,113 @@
+package tensor
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestFormatNested(t *testing.T) {
+	x := mustFromFloat32(t, Float32, []float32{1, 2.5, -3, 4, 5, 6}, 2, 3)
+	want := "[[ 1.0  2.5 -3.0]\n [ 4.0  5.0  6.0]]"
+	if got := x.String(); got != want {
+		t.Fatalf("String:\n%s\nwant\n%s", got, want)
+	}
+	if got := fmt.Sprintf("%v", x); got != want {
+		t.Fatalf("%%v:\n%s", got)
+	}
+	wantMeta := "Tensor(float32, shape=[2 3], strides=[12 4], offset=0, contiguous=true)\n" + want
+	if got := fmt.Sprintf("%+v", x); got != wantMeta {
+		t.Fatalf("%%+v:\n%s", got)
+	}
+
+	// A transposed view prints in logical order; 3-D blocks are separated by
+	// a blank line.
+	tr, err := x.Transpose(0, 1)
+	if err != nil {
+		t.Fatalf("Transpose: %v", err)
+	}
+	if got := fmt.Sprintf("%.0v", tr); got != "[[ 1.  4.]\n [ 2.  5.]\n [-3.  6.]]" {
+		t.Fatalf("transposed:\n%s", got)
+	}
+	cube := mustFromFloat32(t, Int32, []float32{1, 2, 3, 4, 5, 6, 7, 8}, 2, 2, 2)
+	if got := cube.String(); got != "[[[1 2]\n  [3 4]]\n\n [[5 6]\n  [7 8]]]" {
+		t.Fatalf("cube:\n%s", got)
+	}
+}
+
+func TestFormatValues(t *testing.T) {
+	nan, inf := float32(math.NaN()), float32(math.Inf(1))
+	cases := []struct {
+		name string
+		x    *Tensor
+		want string
+	}{
+		{"precision", mustFromFloat32(t, Float32, []float32{1.0 / 3, 2}, 2), "[0.3333 2.0000]"},
+		{"short", mustFromFloat32(t, Float16, []float32{0.5, 0.25}, 2), "[0.50 0.25]"},
+		{"whole", mustFromFloat32(t, BFloat16, []float32{1, -2}, 2), "[ 1. -2.]"},
+		{"scientific", mustFromFloat32(t, Float32, []float32{1e-5, 1, nan, -inf}, 4), "[1.e-05 1.e+00    nan   -inf]"},
+		{"large", mustFromFloat32(t, Float32, []float32{1.5e9, 2e9}, 2), "[1.5e+09 2.0e+09]"},
+		{"non-finite", mustFromFloat32(t, Float32, []float32{nan, inf}, 2), "[nan inf]"},
+		{"int4", mustFromFloat32(t, Int4, []float32{-8, 7, 0}, 3), "[-8  7  0]"},
+		{"bool", mustFromFloat32(t, Bool, []float32{1, 0}, 2), "[ true false]"},
+	}
+	for _, c := range cases {
+		if got := c.x.String(); got != c.want {
+			t.Fatalf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+
+	// Int64 values print exactly, beyond float32 precision.
+	big := mustFromFloat32(t, Int64, []float32{0}, 1)
+	if err := big.storeInt64([]int64{1<<53 + 1}); err != nil {
+		t.Fatalf("storeInt64: %v", err)
+	}
+	if got := big.String(); got != "[9007199254740993]" {
+		t.Fatalf("int64: %s", got)
+	}
+	var nilT *Tensor
+	if got := fmt.Sprintf("%+v", nilT); got != "<nil Tensor>" {
+		t.Fatalf("nil: %s", got)
+	}
+	if got := fmt.Sprintf("%d", big); got != "%!d(*tensor.Tensor)" {
+		t.Fatalf("bad verb: %s", got)
+	}
+}
+
+func TestFormatSummarizeAndWrap(t *testing.T) {
+	// [vocab=2000, dim=8]: only three rows and columns at each edge print.
+	data := make([]float32, 2000*8)
+	for i := range data {
+		data[i] = float32(i % 10)
+	}
+	w := mustFromFloat32(t, Int8, data, 2000, 8)
+	want := strings.Join([]string{
+		"[[0 1 2 ... 5 6 7]",
+		" [8 9 0 ... 3 4 5]",
+		" [6 7 8 ... 1 2 3]",
+		" ...",
+		" [6 7 8 ... 1 2 3]",
+		" [4 5 6 ... 9 0 1]",
+		" [2 3 4 ... 7 8 9]]",
+	}, "\n")
+	if got := w.String(); got != want {
+		t.Fatalf("summarized:\n%s\nwant\n%s", got, want)
+	}
+	if got := w.StringWith(PrintOptions{EdgeItems: 1}); got != "[[0 ... 7]\n ...\n [2 ... 9]]" {
+		t.Fatalf("EdgeItems 1:\n%s", got)
+	}
+
+	row := make([]float32, 12)
+	for i := range row {
+		row[i] = float32(i * 100)
+	}
+	r := mustFromFloat32(t, Int32, row, 12)
+	wantWrap := "[   0  100  200  300  400\n  500  600  700  800  900\n 1000 1100]"
+	if got := r.StringWith(PrintOptions{LineWidth: 26}); got != wantWrap {
+		t.Fatalf("wrapped:\n%s\nwant\n%s", got, wantWrap)
+	}
+	if got := r.StringWith(PrintOptions{Threshold: 100}); strings.Contains(got, "...") || strings.Count(got, "\n") != 0 {
+		t.Fatalf("unwrapped: %s", got)
+	}
+}
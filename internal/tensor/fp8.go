@@ -0,0 +1,193 @@
+package tensor
+
+import "math"
+
+// ---------- FP8 (OCP OFP8 E4M3 / E5M2) conversion ----------
+//
+// Both formats round to nearest even. Out-of-range handling follows the OCP
+// 8-bit floating point specification:
+//   - saturate=true clamps finite overflow and ±Inf to ±max normal
+//     (448 for E4M3, 57344 for E5M2).
+//   - saturate=false maps overflow to NaN for E4M3 (which has no Inf)
+//     and to ±Inf for E5M2.
+// NaN inputs always produce NaN (0x7F/0xFF) and signed zeros are preserved.
+
+const (
+	fp8E4M3MaxCode = 0x7E // 448
+	fp8E4M3NaN     = 0x7F
+	fp8E5M2MaxCode = 0x7B // 57344
+	fp8E5M2Inf     = 0x7C
+	fp8E5M2NaN     = 0x7F
+)
+
+var (
+	fp8E4M3Table [256]float32
+	fp8E5M2Table [256]float32
+)
+
+func init() {
+	for i := 0; i < 256; i++ {
+		fp8E4M3Table[i] = decodeFP8E4M3(uint8(i))
+		fp8E5M2Table[i] = decodeFP8E5M2(uint8(i))
+	}
+}
+
+// PackFP8E4M3 converts float32 values to E4M3 codes (round-to-nearest-even).
+func PackFP8E4M3(src []float32, saturate bool) []uint8 {
+	out := make([]uint8, len(src))
+	for i, f := range src {
+		out[i] = float32ToFP8E4M3Bits(f, saturate)
+	}
+	return out
+}
+
+// UnpackFP8E4M3 converts E4M3 codes to float32.
+func UnpackFP8E4M3(src []uint8) []float32 {
+	out := make([]float32, len(src))
+	for i, b := range src {
+		out[i] = fp8E4M3Table[b]
+	}
+	return out
+}
+
+// PackFP8E5M2 converts float32 values to E5M2 codes (round-to-nearest-even).
+func PackFP8E5M2(src []float32, saturate bool) []uint8 {
+	out := make([]uint8, len(src))
+	for i, f := range src {
+		out[i] = float32ToFP8E5M2Bits(f, saturate)
+	}
+	return out
+}
+
+// UnpackFP8E5M2 converts E5M2 codes to float32.
+func UnpackFP8E5M2(src []uint8) []float32 {
+	out := make([]float32, len(src))
+	for i, b := range src {
+		out[i] = fp8E5M2Table[b]
+	}
+	return out
+}
+
+func float32ToFP8E4M3Bits(f float32, saturate bool) uint8 {
+	x := math.Float32bits(f)
+	sign := uint8(x>>24) & 0x80
+	if f != f {
+		return sign | fp8E4M3NaN
+	}
+	if x&0x7fffffff == 0x7f800000 {
+		if saturate {
+			return sign | fp8E4M3MaxCode
+		}
+		return sign | fp8E4M3NaN
+	}
+	q := roundMinifloat(x&0x7fffffff, 3, 7)
+	if q > fp8E4M3MaxCode {
+		if saturate {
+			return sign | fp8E4M3MaxCode
+		}
+		return sign | fp8E4M3NaN
+	}
+	return sign | uint8(q)
+}
+
+func float32ToFP8E5M2Bits(f float32, saturate bool) uint8 {
+	x := math.Float32bits(f)
+	sign := uint8(x>>24) & 0x80
+	if f != f {
+		return sign | fp8E5M2NaN
+	}
+	if x&0x7fffffff == 0x7f800000 {
+		if saturate {
+			return sign | fp8E5M2MaxCode
+		}
+		return sign | fp8E5M2Inf
+	}
+	q := roundMinifloat(x&0x7fffffff, 2, 15)
+	if q > fp8E5M2MaxCode {
+		if saturate {
+			return sign | fp8E5M2MaxCode
+		}
+		return sign | fp8E5M2Inf
+	}
+	return sign | uint8(q)
+}
+
+// roundMinifloat rounds the finite, non-negative float32 with bits x to a
+// small float with mBits mantissa bits and the given exponent bias, returning
+// the magnitude code (exponent<<mBits | mantissa) with round-to-nearest-even.
+// Carries propagate from the mantissa into the exponent, so a result past the
+// format's largest finite code signals overflow to the caller.
+func roundMinifloat(x uint32, mBits uint, bias int) uint32 {
+	e := int(x>>23) - 127
+	mant := x & 0x007fffff
+	if x>>23 == 0 {
+		// float32 subnormals are far below every small-float subnormal.
+		return 0
+	}
+	emin := 1 - bias
+	if e >= emin {
+		combined := uint32(e+bias)<<23 | mant
+		return roundShiftRNE(combined, 23-mBits)
+	}
+	// Target subnormal: align the full significand to the fixed 2^emin scale.
+	shift := 23 - mBits + uint(emin-e)
+	return roundShiftRNE(mant|0x00800000, shift)
+}
+
+// roundShiftRNE returns v >> s rounded to nearest, ties to even.
+func roundShiftRNE(v uint32, s uint) uint32 {
+	if s == 0 {
+		return v
+	}
+	if s > 25 {
+		return 0 // v < 2^24, strictly below half an ulp
+	}
+	q := v >> s
+	rem := v & (1<<s - 1)
+	half := uint32(1) << (s - 1)
+	if rem > half || (rem == half && q&1 == 1) {
+		q++
+	}
+	return q
+}
+
+// decodeMinifloat expands a finite magnitude code into float32.
+func decodeMinifloat(code uint32, mBits uint, bias int) float32 {
+	exp := int(code >> mBits)
+	mant := code & (1<<mBits - 1)
+	if exp == 0 {
+		return float32(math.Ldexp(float64(mant), 1-bias-int(mBits)))
+	}
+	return float32(math.Ldexp(float64(mant|1<<mBits), exp-bias-int(mBits)))
+}
+
+func decodeFP8E4M3(b uint8) float32 {
+	mag := uint32(b & 0x7f)
+	var f float32
+	if mag == fp8E4M3NaN {
+		f = float32(math.NaN())
+	} else {
+		f = decodeMinifloat(mag, 3, 7)
+	}
+	if b&0x80 != 0 {
+		return -f
+	}
+	return f
+}
+
+func decodeFP8E5M2(b uint8) float32 {
+	mag := uint32(b & 0x7f)
+	var f float32
+	switch {
+	case mag == fp8E5M2Inf:
+		f = float32(math.Inf(1))
+	case mag > fp8E5M2Inf:
+		f = float32(math.NaN())
+	default:
+		f = decodeMinifloat(mag, 2, 15)
+	}
+	if b&0x80 != 0 {
+		return -f
+	}
+	return f
+}
@@ -0,0 +1,146 @@
+package tensor
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFP8SizeOf(t *testing.T) {
+	if SizeOf(Float8E4M3) != 1 || SizeOf(Float8E5M2) != 1 {
+		t.Fatalf("unexpected FP8 SizeOf")
+	}
+	if BytesFor(Float8E4M3, 7) != 7 || BytesFor(Float8E5M2, 3) != 3 {
+		t.Fatalf("unexpected FP8 BytesFor")
+	}
+	if Float8E4M3.String() != "float8_e4m3" || Float8E5M2.String() != "float8_e5m2" {
+		t.Fatalf("unexpected FP8 names")
+	}
+}
+
+func TestFP8E4M3RoundTripAllCodes(t *testing.T) {
+	for c := 0; c < 256; c++ {
+		code := uint8(c)
+		f := UnpackFP8E4M3([]uint8{code})[0]
+		isNaN := code&0x7f == 0x7f
+		if isNaN != math.IsNaN(float64(f)) {
+			t.Fatalf("code %#02x: NaN mismatch (decoded %v)", code, f)
+		}
+		if math.IsInf(float64(f), 0) {
+			t.Fatalf("code %#02x: E4M3 must not decode to Inf", code)
+		}
+		for _, sat := range []bool{true, false} {
+			got := PackFP8E4M3([]float32{f}, sat)[0]
+			if isNaN {
+				if got&0x7f != 0x7f {
+					t.Fatalf("code %#02x sat=%v: NaN re-encoded as %#02x", code, sat, got)
+				}
+				continue
+			}
+			if got != code {
+				t.Fatalf("code %#02x sat=%v: %v re-encoded as %#02x", code, sat, f, got)
+			}
+		}
+	}
+}
+
+func TestFP8E5M2RoundTripAllCodes(t *testing.T) {
+	for c := 0; c < 256; c++ {
+		code := uint8(c)
+		f := UnpackFP8E5M2([]uint8{code})[0]
+		mag := code & 0x7f
+		isNaN := mag > 0x7c
+		isInf := mag == 0x7c
+		if isNaN != math.IsNaN(float64(f)) || isInf != math.IsInf(float64(f), 0) {
+			t.Fatalf("code %#02x: special mismatch (decoded %v)", code, f)
+		}
+		got := PackFP8E5M2([]float32{f}, false)[0]
+		switch {
+		case isNaN:
+			if got&0x7f <= 0x7c {
+				t.Fatalf("code %#02x: NaN re-encoded as %#02x", code, got)
+			}
+		case got != code:
+			t.Fatalf("code %#02x: %v re-encoded as %#02x", code, f, got)
+		}
+		sat := PackFP8E5M2([]float32{f}, true)[0]
+		switch {
+		case isNaN:
+			if sat&0x7f <= 0x7c {
+				t.Fatalf("code %#02x: NaN re-encoded as %#02x with saturation", code, sat)
+			}
+		case isInf:
+			if sat != code&0x80|0x7b {
+				t.Fatalf("code %#02x: Inf saturated to %#02x", code, sat)
+			}
+		case sat != code:
+			t.Fatalf("code %#02x: %v re-encoded as %#02x with saturation", code, f, sat)
+		}
+	}
+}
+
+func TestFP8KnownValues(t *testing.T) {
+	cases := []struct {
+		in       float32
+		e4m3Sat  float32
+		e4m3Wrap float32 // non-saturating
+		e5m2Sat  float32
+		e5m2Wrap float32
+	}{
+		{0, 0, 0, 0, 0},
+		{1, 1, 1, 1, 1},
+		{-1.75, -1.75, -1.75, -1.75, -1.75},
+		{448, 448, 448, 448, 448},
+		{464, 448, 448, 448, 448}, // E4M3 tie rounds to even mantissa (448)
+		{465, 448, nan32(), 448, 448},
+		{1e6, 448, nan32(), 57344, inf32(1)},
+		{61440, 448, nan32(), 57344, inf32(1)}, // E5M2 tie rounds up to Inf
+		{-inf32(1), -448, nan32(), -57344, -inf32(1)},
+		{0.001953125, 0.001953125, 0.001953125, 0.001953125, 0.001953125}, // E4M3 min subnormal 2^-9
+		{0.0009765625, 0, 0, 0.0009765625, 0.0009765625},                  // 2^-10 ties to zero in E4M3
+	}
+	for _, c := range cases {
+		check := func(name string, got, want float32) {
+			if math.IsNaN(float64(want)) {
+				if !math.IsNaN(float64(got)) {
+					t.Fatalf("%s(%v)=%v want NaN", name, c.in, got)
+				}
+				return
+			}
+			if got != want {
+				t.Fatalf("%s(%v)=%v want %v", name, c.in, got, want)
+			}
+		}
+		check("e4m3 sat", UnpackFP8E4M3(PackFP8E4M3([]float32{c.in}, true))[0], c.e4m3Sat)
+		check("e4m3", UnpackFP8E4M3(PackFP8E4M3([]float32{c.in}, false))[0], c.e4m3Wrap)
+		check("e5m2 sat", UnpackFP8E5M2(PackFP8E5M2([]float32{c.in}, true))[0], c.e5m2Sat)
+		check("e5m2", UnpackFP8E5M2(PackFP8E5M2([]float32{c.in}, false))[0], c.e5m2Wrap)
+	}
+}
+
+func TestFP8RoundToNearestEven(t *testing.T) {
+	// Between every pair of adjacent finite codes, the midpoint must round to the
+	// even code and points just either side must round to the nearer code.
+	for c := 0; c < 0x7e; c++ {
+		lo := float64(UnpackFP8E4M3([]uint8{uint8(c)})[0])
+		hi := float64(UnpackFP8E4M3([]uint8{uint8(c + 1)})[0])
+		mid := (lo + hi) / 2
+		want := uint8(c)
+		if c&1 == 1 {
+			want = uint8(c + 1)
+		}
+		if got := PackFP8E4M3([]float32{float32(mid)}, true)[0]; got != want {
+			t.Fatalf("e4m3 midpoint %v between %#02x/%#02x -> %#02x", mid, c, c+1, got)
+		}
+		below := math.Nextafter32(float32(mid), float32(lo))
+		if got := PackFP8E4M3([]float32{below}, true)[0]; got != uint8(c) {
+			t.Fatalf("e4m3 %v should round down to %#02x, got %#02x", below, c, got)
+		}
+		above := math.Nextafter32(float32(mid), float32(hi))
+		if got := PackFP8E4M3([]float32{above}, true)[0]; got != uint8(c+1) {
+			t.Fatalf("e4m3 %v should round up to %#02x, got %#02x", above, c+1, got)
+		}
+	}
+}
+
+func nan32() float32         { return float32(math.NaN()) }
+func inf32(sign int) float32 { return float32(math.Inf(sign)) }
@@ -0,0 +1,370 @@
+package tensor
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ---------- GGML block quantization formats ----------
+//
+// GGUF checkpoints store quantized weights as runs of fixed-size blocks, each
+// holding the scales and packed integers of a block of consecutive elements
+// along the last dimension. The layouts below follow ggml's block_q4_0,
+// block_q8_0, block_q4_K and block_q6_K structs, half-precision fields
+// little-endian, so tensor data read from a GGUF file can be used unchanged:
+//
+//   - Q4_0: 32 elements; d fp16, qs[16]. Element j is (qs[j] & 0xF) - 8 and
+//     element j+16 is (qs[j] >> 4) - 8, times d.
+//   - Q8_0: 32 elements; d fp16, qs[32] int8. Element j is qs[j] * d.
+//   - Q4_K: 256 elements in 8 sub-blocks of 32; d fp16, dmin fp16,
+//     scales[12] holding eight 6-bit scales and mins, qs[128]. Element i of
+//     sub-block s is d*scale[s]*q - dmin*min[s] for its 4-bit q.
+//   - Q6_K: 256 elements in 16 sub-blocks of 16; ql[128] low and qh[64] high
+//     bits of 6-bit values, scales[16] int8, d fp16. Element i of sub-block s
+//     is d*scales[s]*(q-32).
+//
+// Decoding follows ggml's dequantize_row_* and encoding, offered for Q4_0 and
+// Q8_0, its quantize_row_*_ref, in float32 without fused multiply-adds. The
+// tests check them against testdata/ggml/gen.py, a port of those routines,
+// not against output of ggml itself.
+
+// GGMLType identifies a GGML block format. The values are ggml_type's, as
+// stored in GGUF tensor infos.
+type GGMLType int
+
+const (
+	GGMLQ4_0 GGMLType = 2
+	GGMLQ8_0 GGMLType = 8
+	GGMLQ4_K GGMLType = 12
+	GGMLQ6_K GGMLType = 14
+)
+
+func (g GGMLType) String() string {
+	switch g {
+	case GGMLQ4_0:
+		return "q4_0"
+	case GGMLQ8_0:
+		return "q8_0"
+	case GGMLQ4_K:
+		return "q4_K"
+	case GGMLQ6_K:
+		return "q6_K"
+	default:
+		return "unknown"
+	}
+}
+
+// BlockSize returns the number of elements per block, or 0 for an unknown type.
+func (g GGMLType) BlockSize() int {
+	switch g {
+	case GGMLQ4_0, GGMLQ8_0:
+		return 32
+	case GGMLQ4_K, GGMLQ6_K:
+		return 256
+	default:
+		return 0
+	}
+}
+
+// BlockBytes returns the size of one block in bytes, or 0 for an unknown type.
+func (g GGMLType) BlockBytes() int {
+	switch g {
+	case GGMLQ4_0:
+		return 2 + 16
+	case GGMLQ8_0:
+		return 2 + 32
+	case GGMLQ4_K:
+		return 2 + 2 + 12 + 128
+	case GGMLQ6_K:
+		return 128 + 64 + 16 + 2
+	default:
+		return 0
+	}
+}
+
+// GGMLBytesFor returns the bytes needed for numel elements, counting a
+// partial trailing block as a whole one.
+func GGMLBytesFor(g GGMLType, numel int) int {
+	bs := g.BlockSize()
+	if bs == 0 {
+		return 0
+	}
+	return (numel + bs - 1) / bs * g.BlockBytes()
+}
+
+// DecodeGGML dequantizes the blocks in src into dst. len(dst) must be a
+// multiple of the block size.
+func DecodeGGML(g GGMLType, src []byte, dst []float32) error {
+	bs := g.BlockSize()
+	if bs == 0 {
+		return fmt.Errorf("unknown GGML type %d", int(g))
+	}
+	if len(dst)%bs != 0 {
+		return fmt.Errorf("%v decode needs a multiple of %d elements, got %d", g, bs, len(dst))
+	}
+	if len(src) < GGMLBytesFor(g, len(dst)) {
+		return fmt.Errorf("%v decode: %d bytes for %d elements, want %d", g, len(src), len(dst), GGMLBytesFor(g, len(dst)))
+	}
+	bb := g.BlockBytes()
+	for b := 0; b < len(dst)/bs; b++ {
+		block, y := src[b*bb:(b+1)*bb], dst[b*bs:(b+1)*bs]
+		switch g {
+		case GGMLQ4_0:
+			decodeQ4_0(block, y)
+		case GGMLQ8_0:
+			decodeQ8_0(block, y)
+		case GGMLQ4_K:
+			decodeQ4_K(block, y)
+		case GGMLQ6_K:
+			decodeQ6_K(block, y)
+		}
+	}
+	return nil
+}
+
+func fp16At(b []byte) float32 { return float16BitsToFloat32(binary.LittleEndian.Uint16(b)) }
+
+func decodeQ4_0(block []byte, y []float32) {
+	d, qs := fp16At(block), block[2:18]
+	for j, q := range qs {
+		y[j] = float32(int(q&0xF)-8) * d
+		y[j+16] = float32(int(q>>4)-8) * d
+	}
+}
+
+func decodeQ8_0(block []byte, y []float32) {
+	d, qs := fp16At(block), block[2:34]
+	for j, q := range qs {
+		y[j] = float32(int8(q)) * d
+	}
+}
+
+// q4KScaleMin unpacks the 6-bit scale and min of sub-block j from the
+// 12-byte scales field of a Q4_K block (ggml's get_scale_min_k4).
+func q4KScaleMin(j int, q []byte) (sc, m uint8) {
+	if j < 4 {
+		return q[j] & 63, q[j+4] & 63
+	}
+	return q[j+4]&0xF | q[j-4]>>6<<4, q[j+4]>>4 | q[j]>>6<<4
+}
+
+func decodeQ4_K(block []byte, y []float32) {
+	d, dmin := fp16At(block), fp16At(block[2:])
+	scales, qs := block[4:16], block[16:144]
+	for j := 0; j < 4; j++ {
+		sc1, m1 := q4KScaleMin(2*j, scales)
+		sc2, m2 := q4KScaleMin(2*j+1, scales)
+		d1, min1 := d*float32(sc1), dmin*float32(m1)
+		d2, min2 := d*float32(sc2), dmin*float32(m2)
+		q, out := qs[32*j:32*j+32], y[64*j:64*j+64]
+		for l, v := range q {
+			out[l] = float32(d1*float32(v&0xF)) - min1
+			out[l+32] = float32(d2*float32(v>>4)) - min2
+		}
+	}
+}
+
+func decodeQ6_K(block []byte, y []float32) {
+	d := fp16At(block[208:])
+	for n := 0; n < 2; n++ {
+		ql, qh := block[64*n:64*n+64], block[128+32*n:128+32*n+32]
+		sc, out := block[192+8*n:192+8*n+8], y[128*n:128*n+128]
+		for l := 0; l < 32; l++ {
+			is := l / 16
+			q1 := int(ql[l]&0xF|qh[l]&3<<4) - 32
+			q2 := int(ql[l+32]&0xF|qh[l]>>2&3<<4) - 32
+			q3 := int(ql[l]>>4|qh[l]>>4&3<<4) - 32
+			q4 := int(ql[l+32]>>4|qh[l]>>6&3<<4) - 32
+			out[l] = d * float32(int8(sc[is])) * float32(q1)
+			out[l+32] = d * float32(int8(sc[is+2])) * float32(q2)
+			out[l+64] = d * float32(int8(sc[is+4])) * float32(q3)
+			out[l+96] = d * float32(int8(sc[is+6])) * float32(q4)
+		}
+	}
+}
+
+// EncodeGGML quantizes src into blocks of type g, which must be Q4_0 or
+// Q8_0. len(src) must be a multiple of the block size and src finite.
+func EncodeGGML(g GGMLType, src []float32) ([]byte, error) {
+	if g != GGMLQ4_0 && g != GGMLQ8_0 {
+		return nil, fmt.Errorf("%v encoding is not supported", g)
+	}
+	bs, bb := g.BlockSize(), g.BlockBytes()
+	if len(src)%bs != 0 {
+		return nil, fmt.Errorf("%v encode needs a multiple of %d elements, got %d", g, bs, len(src))
+	}
+	for i, v := range src {
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return nil, fmt.Errorf("%v encode: non-finite value %v at element %d", g, v, i)
+		}
+	}
+	out := make([]byte, len(src)/bs*bb)
+	for b := 0; b < len(src)/bs; b++ {
+		block, x := out[b*bb:(b+1)*bb], src[b*bs:(b+1)*bs]
+		if g == GGMLQ4_0 {
+			encodeQ4_0(block, x)
+		} else {
+			encodeQ8_0(block, x)
+		}
+	}
+	return out, nil
+}
+
+// encodeQ4_0 follows quantize_row_q4_0_ref: the element of largest magnitude
+// maps to -8, and the rest are truncated after adding 8.5. The explicit
+// float32 conversions keep the arithmetic from being fused.
+func encodeQ4_0(block []byte, x []float32) {
+	var amax, vmax float32
+	for _, v := range x {
+		if a := float32(math.Abs(float64(v))); a > amax {
+			amax, vmax = a, v
+		}
+	}
+	d := vmax / -8
+	var id float32
+	if d != 0 {
+		id = 1 / d
+	}
+	binary.LittleEndian.PutUint16(block, float32ToFloat16Bits(d))
+	for j := 0; j < 16; j++ {
+		x0 := min(15, int8(float32(x[j]*id)+8.5))
+		x1 := min(15, int8(float32(x[j+16]*id)+8.5))
+		block[2+j] = uint8(x0) | uint8(x1)<<4
+	}
+}
+
+// encodeQ8_0 follows quantize_row_q8_0_ref: d = amax/127 and each element is
+// rounded half away from zero.
+func encodeQ8_0(block []byte, x []float32) {
+	var amax float32
+	for _, v := range x {
+		amax = max(amax, float32(math.Abs(float64(v))))
+	}
+	d := amax / 127
+	var id float32
+	if d != 0 {
+		id = 1 / d
+	}
+	binary.LittleEndian.PutUint16(block, float32ToFloat16Bits(d))
+	for j, v := range x {
+		block[2+j] = uint8(int8(math.Round(float64(float32(v * id)))))
+	}
+}
+
+// GGMLTensor is a tensor in a GGML block format. Blocks holds the raw blocks
+// as Int8 bytes, one row of blocks per row of the logical shape: it is shaped
+// like Shape with the last dimension replaced by that row's byte count.
+type GGMLTensor struct {
+	Type   GGMLType
+	Shape  []int
+	Blocks *Tensor
+}
+
+// ggmlBlockShape validates a GGML shape and returns the matching block shape.
+func ggmlBlockShape(g GGMLType, shape []int) ([]int, error) {
+	if g.BlockSize() == 0 {
+		return nil, fmt.Errorf("unknown GGML type %d", int(g))
+	}
+	if !IsValidShape(shape) || len(shape) == 0 {
+		return nil, errors.New("invalid shape")
+	}
+	last := shape[len(shape)-1]
+	if last%g.BlockSize() != 0 {
+		return nil, fmt.Errorf("%v last dim %d is not a multiple of %d", g, last, g.BlockSize())
+	}
+	blockShape := append([]int(nil), shape...)
+	blockShape[len(blockShape)-1] = GGMLBytesFor(g, last)
+	return blockShape, nil
+}
+
+// NewGGML allocates block storage for a GGML tensor.
+func NewGGML(g GGMLType, shape ...int) (*GGMLTensor, error) {
+	blockShape, err := ggmlBlockShape(g, shape)
+	if err != nil {
+		return nil, err
+	}
+	blocks, err := New(Int8, blockShape...)
+	if err != nil {
+		return nil, err
+	}
+	return &GGMLTensor{Type: g, Shape: append([]int(nil), shape...), Blocks: blocks}, nil
+}
+
+// GGMLFromBytes uploads raw blocks, such as a GGUF tensor's data, as a GGML
+// tensor.
+func GGMLFromBytes(g GGMLType, raw []byte, shape ...int) (*GGMLTensor, error) {
+	m, err := NewGGML(g, shape...)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) != m.ByteSize() {
+		_ = m.Close()
+		return nil, fmt.Errorf("%v data is %d bytes, want %d", g, len(raw), m.ByteSize())
+	}
+	if err := m.Blocks.buf.Write(raw); err != nil {
+		_ = m.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+// GGMLFromFloat32 encodes row-major float32 data, which EncodeGGML supports
+// for Q4_0 and Q8_0, and uploads it as a GGML tensor.
+func GGMLFromFloat32(g GGMLType, data []float32, shape ...int) (*GGMLTensor, error) {
+	if _, err := ggmlBlockShape(g, shape); err != nil {
+		return nil, err
+	}
+	if len(data) != Numel(shape) {
+		return nil, errors.New("len(data) mismatch")
+	}
+	raw, err := EncodeGGML(g, data)
+	if err != nil {
+		return nil, err
+	}
+	return GGMLFromBytes(g, raw, shape...)
+}
+
+func (m *GGMLTensor) Numel() int { return Numel(m.Shape) }
+
+// ByteSize returns the bytes of the blocks.
+func (m *GGMLTensor) ByteSize() int { return GGMLBytesFor(m.Type, m.Numel()) }
+
+// DownloadFloat32 dequantizes the tensor into dst.
+func (m *GGMLTensor) DownloadFloat32(dst []float32) error {
+	if m == nil || m.Blocks == nil {
+		return errors.New("nil tensor")
+	}
+	if err := m.Blocks.live(); err != nil {
+		return err
+	}
+	if len(dst) != m.Numel() {
+		return errors.New("len(dst) mismatch")
+	}
+	raw := make([]byte, m.ByteSize())
+	if err := m.Blocks.buf.Read(raw); err != nil {
+		return err
+	}
+	return DecodeGGML(m.Type, raw, dst)
+}
+
+// Dequantize decodes the tensor into a new Float32 tensor.
+func (m *GGMLTensor) Dequantize() (*Tensor, error) {
+	if m == nil {
+		return nil, errors.New("nil tensor")
+	}
+	dst := make([]float32, m.Numel())
+	if err := m.DownloadFloat32(dst); err != nil {
+		return nil, err
+	}
+	return FromFloat32(Float32, dst, m.Shape...)
+}
+
+// Close releases the block buffer.
+func (m *GGMLTensor) Close() error {
+	if m == nil {
+		return nil
+	}
+	return m.Blocks.Close()
+}
@@ -0,0 +1,184 @@
+package tensor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// readGGMLFixture reads a file from testdata/ggml; see gen.py there.
+func readGGMLFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", "ggml", name))
+	if err != nil {
+		t.Fatalf("reading fixture: %v", err)
+	}
+	return b
+}
+
+func readGGMLFloats(t *testing.T, name string) []float32 {
+	t.Helper()
+	b := readGGMLFixture(t, name)
+	out := make([]float32, len(b)/4)
+	for i := range out {
+		out[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return out
+}
+
+func TestGGMLBytesFor(t *testing.T) {
+	for _, tc := range []struct {
+		g                 GGMLType
+		blockSize, blockB int
+		numel, bytes      int
+	}{
+		{GGMLQ4_0, 32, 18, 64, 36},
+		{GGMLQ8_0, 32, 34, 33, 68},
+		{GGMLQ4_K, 256, 144, 256, 144},
+		{GGMLQ6_K, 256, 210, 512, 420},
+		{GGMLType(99), 0, 0, 32, 0},
+	} {
+		if tc.g.BlockSize() != tc.blockSize || tc.g.BlockBytes() != tc.blockB {
+			t.Errorf("%v: block of %d elements in %d bytes, want %d in %d", tc.g, tc.g.BlockSize(), tc.g.BlockBytes(), tc.blockSize, tc.blockB)
+		}
+		if got := GGMLBytesFor(tc.g, tc.numel); got != tc.bytes {
+			t.Errorf("GGMLBytesFor(%v, %d) = %d, want %d", tc.g, tc.numel, got, tc.bytes)
+		}
+	}
+}
+
+func TestDecodeGGMLKnownBlocks(t *testing.T) {
+	q8 := []byte{0x00, 0x38} // d = 0.5
+	q4 := []byte{0x00, 0x40} // d = 2
+	for j := 0; j < 32; j++ {
+		q8 = append(q8, uint8(int8(j-16)))
+	}
+	for j := 0; j < 16; j++ {
+		q4 = append(q4, uint8(j)|uint8(15-j)<<4)
+	}
+	got8 := make([]float32, 32)
+	if err := DecodeGGML(GGMLQ8_0, q8, got8); err != nil {
+		t.Fatalf("DecodeGGML(q8_0): %v", err)
+	}
+	got4 := make([]float32, 32)
+	if err := DecodeGGML(GGMLQ4_0, q4, got4); err != nil {
+		t.Fatalf("DecodeGGML(q4_0): %v", err)
+	}
+	for j := 0; j < 32; j++ {
+		if want := float32(j-16) * 0.5; got8[j] != want {
+			t.Fatalf("q8_0 @%d got %v want %v", j, got8[j], want)
+		}
+	}
+	for j := 0; j < 16; j++ {
+		if got4[j] != float32(j-8)*2 || got4[j+16] != float32(7-j)*2 {
+			t.Fatalf("q4_0 @%d got %v, %v want %v, %v", j, got4[j], got4[j+16], (j-8)*2, (7-j)*2)
+		}
+	}
+}
+
+func TestDecodeGGMLFixtures(t *testing.T) {
+	for _, g := range []GGMLType{GGMLQ4_0, GGMLQ8_0, GGMLQ4_K, GGMLQ6_K} {
+		raw := readGGMLFixture(t, g.String()+".bin")
+		want := readGGMLFloats(t, g.String()+".f32")
+		if len(raw) != GGMLBytesFor(g, len(want)) {
+			t.Fatalf("%v fixture: %d bytes for %d values", g, len(raw), len(want))
+		}
+		got := make([]float32, len(want))
+		if err := DecodeGGML(g, raw, got); err != nil {
+			t.Fatalf("DecodeGGML(%v): %v", g, err)
+		}
+		expectBits(t, g.String(), got, want)
+	}
+}
+
+func TestEncodeGGMLFixtures(t *testing.T) {
+	src := readGGMLFloats(t, "src.f32")
+	for _, g := range []GGMLType{GGMLQ4_0, GGMLQ8_0} {
+		got, err := EncodeGGML(g, src)
+		if err != nil {
+			t.Fatalf("EncodeGGML(%v): %v", g, err)
+		}
+		want := readGGMLFixture(t, g.String()+".bin")
+		if !bytes.Equal(got, want) {
+			for i := range got {
+				if got[i] != want[i] {
+					t.Fatalf("%v: byte %d (block %d) = %#x, want %#x", g, i, i/g.BlockBytes(), got[i], want[i])
+				}
+			}
+		}
+	}
+	for _, tc := range []struct {
+		name string
+		g    GGMLType
+		src  []float32
+	}{
+		{"k-quant", GGMLQ4_K, make([]float32, 256)},
+		{"partial block", GGMLQ8_0, make([]float32, 31)},
+		{"non-finite", GGMLQ4_0, append(make([]float32, 31), float32(math.Inf(-1)))},
+	} {
+		if _, err := EncodeGGML(tc.g, tc.src); err == nil {
+			t.Errorf("EncodeGGML %s: expected an error", tc.name)
+		}
+	}
+	if err := DecodeGGML(GGMLQ6_K, make([]byte, 209), make([]float32, 256)); err == nil {
+		t.Error("DecodeGGML with a short block: expected an error")
+	}
+}
+
+func TestGGMLTensor(t *testing.T) {
+	raw := readGGMLFixture(t, "q4_K.bin")
+	m, err := GGMLFromBytes(GGMLQ4_K, raw, 2, 256)
+	if err != nil {
+		t.Fatalf("GGMLFromBytes: %v", err)
+	}
+	defer m.Close()
+	expectShape(t, "q4_K blocks", m.Blocks, 2, 144)
+	if m.ByteSize() != len(raw) {
+		t.Errorf("ByteSize = %d, want %d", m.ByteSize(), len(raw))
+	}
+	d, err := m.Dequantize()
+	if err != nil {
+		t.Fatalf("Dequantize: %v", err)
+	}
+	defer d.Close()
+	expectShape(t, "dequantized", d, 2, 256)
+	expectBits(t, "dequantized q4_K", mustLoad(t, d), readGGMLFloats(t, "q4_K.f32"))
+
+	// Q8_0 keeps every element within half a step of its block's scale.
+	src := readGGMLFloats(t, "src.f32")
+	q, err := GGMLFromFloat32(GGMLQ8_0, src, 3, 32)
+	if err != nil {
+		t.Fatalf("GGMLFromFloat32: %v", err)
+	}
+	defer q.Close()
+	got := make([]float32, len(src))
+	if err := q.DownloadFloat32(got); err != nil {
+		t.Fatalf("DownloadFloat32: %v", err)
+	}
+	blocks := readGGMLFixture(t, "q8_0.bin")
+	for i, v := range src {
+		scale := float64(fp16At(blocks[i/32*34:]))
+		if diff := math.Abs(float64(got[i] - v)); diff > scale/2*1.01 {
+			t.Fatalf("q8_0 round trip @%d: %v decoded as %v", i, v, got[i])
+		}
+	}
+
+	for _, tc := range []struct {
+		name string
+		fn   func() (*GGMLTensor, error)
+	}{
+		{"partial row block", func() (*GGMLTensor, error) { return NewGGML(GGMLQ4_0, 2, 48) }},
+		{"unknown type", func() (*GGMLTensor, error) { return NewGGML(GGMLType(3), 32) }},
+		{"scalar", func() (*GGMLTensor, error) { return NewGGML(GGMLQ8_0) }},
+		{"wrong byte count", func() (*GGMLTensor, error) { return GGMLFromBytes(GGMLQ4_K, raw[:144], 2, 256) }},
+		{"len(data) mismatch", func() (*GGMLTensor, error) { return GGMLFromFloat32(GGMLQ4_0, src, 2, 32) }},
+	} {
+		if m, err := tc.fn(); err == nil {
+			_ = m.Close()
+			t.Errorf("%s: expected an error", tc.name)
+		}
+	}
+}
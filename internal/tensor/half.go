@@ -0,0 +1,131 @@
+package tensor
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// ---------- FP16/BF16 conversion ----------
+//
+// Packing rounds to nearest even, FP16 subnormals included, with bit tricks
+// instead of per-case branches. Overflow gives ±Inf (FP16 from 65520 up),
+// NaN stays a quiet NaN of the same sign and signed zeros are preserved.
+// Unpacking is exact. The Bytes forms convert whole slices straight to or
+// from little-endian bytes, as tensor buffers store them.
+
+// PackFP16 converts float32 values to IEEE 754 half precision.
+func PackFP16(src []float32) []uint16 {
+	out := make([]uint16, len(src))
+	for i, f := range src {
+		out[i] = float32ToFloat16Bits(f)
+	}
+	return out
+}
+
+// UnpackFP16 converts IEEE 754 half precision values to float32.
+func UnpackFP16(src []uint16) []float32 {
+	out := make([]float32, len(src))
+	for i, h := range src {
+		out[i] = float16BitsToFloat32(h)
+	}
+	return out
+}
+
+// PackFP16Bytes writes src to dst as little-endian half precision; dst must
+// hold 2*len(src) bytes.
+func PackFP16Bytes(dst []byte, src []float32) {
+	dst = dst[:2*len(src)]
+	for i, f := range src {
+		binary.LittleEndian.PutUint16(dst[2*i:], float32ToFloat16Bits(f))
+	}
+}
+
+// UnpackFP16Bytes fills dst from little-endian half precision values in src,
+// which must hold 2*len(dst) bytes.
+func UnpackFP16Bytes(dst []float32, src []byte) {
+	src = src[:2*len(dst)]
+	for i := range dst {
+		dst[i] = float16BitsToFloat32(binary.LittleEndian.Uint16(src[2*i:]))
+	}
+}
+
+// PackBF16 converts float32 values to bfloat16.
+func PackBF16(src []float32) []uint16 {
+	out := make([]uint16, len(src))
+	for i, f := range src {
+		out[i] = float32ToBFloat16Bits(f)
+	}
+	return out
+}
+
+// UnpackBF16 converts bfloat16 values to float32.
+func UnpackBF16(src []uint16) []float32 {
+	out := make([]float32, len(src))
+	for i, b := range src {
+		out[i] = math.Float32frombits(uint32(b) << 16)
+	}
+	return out
+}
+
+// PackBF16Bytes writes src to dst as little-endian bfloat16; dst must hold
+// 2*len(src) bytes.
+func PackBF16Bytes(dst []byte, src []float32) {
+	dst = dst[:2*len(src)]
+	for i, f := range src {
+		binary.LittleEndian.PutUint16(dst[2*i:], float32ToBFloat16Bits(f))
+	}
+}
+
+// UnpackBF16Bytes fills dst from little-endian bfloat16 values in src, which
+// must hold 2*len(dst) bytes.
+func UnpackBF16Bytes(dst []float32, src []byte) {
+	src = src[:2*len(dst)]
+	for i := range dst {
+		dst[i] = math.Float32frombits(uint32(binary.LittleEndian.Uint16(src[2*i:])) << 16)
+	}
+}
+
+func float32ToFloat16Bits(f float32) uint16 {
+	x := math.Float32bits(f)
+	sign := uint16(x>>16) & 0x8000
+	x &= 0x7fffffff
+	switch {
+	case x >= 0x47800000: // 2^16 and up, Inf or NaN
+		if x > 0x7f800000 {
+			return sign | 0x7e00
+		}
+		return sign | 0x7c00
+	case x < 0x38800000: // below 2^-14: a subnormal or zero half
+		// In [0.5, 1) a float's ulp is 2^-24, the subnormal half's ulp, so
+		// adding 0.5 lets the FPU round the mantissa to nearest even. A
+		// carry to 0x400 is the smallest normal half.
+		return sign | uint16(math.Float32bits(math.Float32frombits(x)+0.5)-0x3f000000)
+	default:
+		// Rebias the exponent by (15-127)<<23 and round the 13 dropped bits
+		// to even; a mantissa carry bumps the exponent, up to Inf at 65520.
+		x += 0xc8000fff + (x>>13)&1
+		return sign | uint16(x>>13)
+	}
+}
+
+func float16BitsToFloat32(h uint16) float32 {
+	x := uint32(h&0x7fff) << 13
+	switch x & 0x0f800000 {
+	case 0x0f800000: // Inf or NaN; the payload is kept
+		x += (255 - 31) << 23
+	case 0: // zero or subnormal: normalize through a float subtraction
+		x = math.Float32bits(math.Float32frombits(x+113<<23) - math.Float32frombits(113<<23))
+	default:
+		x += (127 - 15) << 23
+	}
+	return math.Float32frombits(uint32(h&0x8000)<<16 | x)
+}
+
+func float32ToBFloat16Bits(f float32) uint16 {
+	x := math.Float32bits(f)
+	if x&0x7fffffff > 0x7f800000 {
+		// Truncate NaN payloads but keep them quiet; rounding could carry into Inf.
+		return uint16(x>>16) | 0x0040
+	}
+	return uint16((x + 0x7fff + (x>>16)&1) >> 16)
+}
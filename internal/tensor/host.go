@@ -0,0 +1,470 @@
+package tensor
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"slices"
+	"unsafe"
+
+	"kylesmith19091/fastgo/internal/metal"
+)
+
+// ---------- Host (CPU backend) helpers ----------
+//
+// The CPU backend moves a view's elements to the host as packed row-major
+// bytes, computes there and writes results back through the buffer. Metal
+// buffers use shared storage and the non-Metal stub keeps buffers in host
+// memory, so this path works everywhere; ops switch to a kernel when
+// metal.Ready() and the kernel supports the dtype/layout.
+
+// is4Bit reports whether dt packs two elements per byte.
+func is4Bit(dt DType) bool { return dt == Int4 || dt == Float4E2M1 }
+
+// useMetal reports whether ops should dispatch to Metal kernels, which bind
+// ts and so need them all on Metal.
+func useMetal(ts ...*Tensor) bool {
+	if !metal.Ready() {
+		return false
+	}
+	for _, t := range ts {
+		if t.Device() != Metal {
+			return false
+		}
+	}
+	return true
+}
+
+// span returns the [lo, hi) byte range of the buffer touched by the view.
+func (t *Tensor) span() (int, int) {
+	if is4Bit(t.DT) {
+		return t.Offset, t.Offset + BytesFor(t.DT, t.Numel())
+	}
+	lo, hi := t.Offset, t.Offset
+	for d, n := range t.Shape {
+		if n <= 0 {
+			return t.Offset, t.Offset
+		}
+		ext := (n - 1) * t.Strides[d]
+		if ext < 0 {
+			lo += ext
+		} else {
+			hi += ext
+		}
+	}
+	return lo, hi + t.DT.SizeOf()
+}
+
+// forEachOffset calls fn with the flat row-major index and the byte offset
+// (relative to the view's offset) of every element of shape/strides.
+func forEachOffset(shape, strides []int, fn func(i, off int)) {
+	n := Numel(shape)
+	if n == 0 {
+		return
+	}
+	rank := len(shape)
+	idx := make([]int, rank)
+	off := 0
+	for i := 0; i < n; i++ {
+		fn(i, off)
+		for d := rank - 1; d >= 0; d-- {
+			idx[d]++
+			off += strides[d]
+			if idx[d] < shape[d] {
+				break
+			}
+			off -= idx[d] * strides[d]
+			idx[d] = 0
+		}
+	}
+}
+
+// elemStridesFor returns row-major strides of shape in elements, addressing
+// the packed copies returned by packedBytes and loadFloat32.
+func elemStridesFor(shape []int) []int {
+	strides := make([]int, len(shape))
+	for d, acc := len(shape)-1, 1; d >= 0; d-- {
+		strides[d] = acc
+		acc *= shape[d]
+	}
+	return strides
+}
+
+// forEachOffset2 is forEachOffset over two stride sets that share a shape.
+func forEachOffset2(shape, stridesA, stridesB []int, fn func(i, offA, offB int)) {
+	n := Numel(shape)
+	if n == 0 {
+		return
+	}
+	rank := len(shape)
+	idx := make([]int, rank)
+	offA, offB := 0, 0
+	for i := 0; i < n; i++ {
+		fn(i, offA, offB)
+		for d := rank - 1; d >= 0; d-- {
+			idx[d]++
+			offA += stridesA[d]
+			offB += stridesB[d]
+			if idx[d] < shape[d] {
+				break
+			}
+			offA -= idx[d] * stridesA[d]
+			offB -= idx[d] * stridesB[d]
+			idx[d] = 0
+		}
+	}
+}
+
+// packedBytes returns the view's elements as packed row-major bytes.
+func (t *Tensor) packedBytes() ([]byte, error) {
+	if err := t.live(); err != nil {
+		return nil, err
+	}
+	if t.Numel() == 0 {
+		return []byte{}, nil // an empty view's offset may lie past the buffer
+	}
+	lo, hi := t.span()
+	raw, err := t.buf.ReadN(lo, hi-lo)
+	if err != nil {
+		return nil, err
+	}
+	if t.isDense() {
+		return raw, nil
+	}
+	if is4Bit(t.DT) {
+		return nil, errors.New("4-bit tensors must be contiguous")
+	}
+	elem := t.DT.SizeOf()
+	out := make([]byte, t.Numel()*elem)
+	base := t.Offset - lo
+	forEachOffset(t.Shape, t.Strides, func(i, off int) {
+		copy(out[i*elem:(i+1)*elem], raw[base+off:base+off+elem])
+	})
+	return out, nil
+}
+
+// storePackedBytes writes packed row-major bytes into the view.
+func (t *Tensor) storePackedBytes(src []byte) error {
+	if err := t.live(); err != nil {
+		return err
+	}
+	if len(src) != t.ByteSize() {
+		return errors.New("packed size mismatch")
+	}
+	if len(src) == 0 {
+		return nil
+	}
+	if t.isDense() {
+		return t.buf.WriteAt(t.Offset, src)
+	}
+	if is4Bit(t.DT) {
+		return errors.New("4-bit tensors must be contiguous")
+	}
+	lo, hi := t.span()
+	raw, err := t.buf.ReadN(lo, hi-lo)
+	if err != nil {
+		return err
+	}
+	elem := t.DT.SizeOf()
+	base := t.Offset - lo
+	forEachOffset(t.Shape, t.Strides, func(i, off int) {
+		copy(raw[base+off:base+off+elem], src[i*elem:(i+1)*elem])
+	})
+	return t.buf.WriteAt(lo, raw)
+}
+
+// isDense reports whether the view has row-major strides, ignoring its offset.
+func (t *Tensor) isDense() bool {
+	want := DefaultStridesBytes(t.DT, t.Shape)
+	if len(want) != len(t.Strides) {
+		return false
+	}
+	for i := range want {
+		if t.Shape[i] != 1 && want[i] != t.Strides[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// overlaps reports whether a and b share storage and touch a common byte.
+func overlaps(a, b *Tensor) bool {
+	if a.buf != b.buf || a.Numel() == 0 || b.Numel() == 0 {
+		return false
+	}
+	loA, hiA := a.span()
+	loB, hiB := b.span()
+	return loA < hiB && loB < hiA
+}
+
+// sameView reports whether a and b address the same elements in the same
+// order, so an elementwise kernel reading one while writing the other only
+// ever reads an element before writing it.
+func sameView(a, b *Tensor) bool {
+	return a.buf == b.buf && a.DT.SizeOf() == b.DT.SizeOf() && a.Offset == b.Offset &&
+		equalShapes(a.Shape, b.Shape) && equalShapes(a.Strides, b.Strides)
+}
+
+// selfOverlapping reports whether two elements of t may share memory, as in
+// a broadcast view. The test is conservative: it sorts dims by stride and
+// requires each to step past everything the smaller ones span.
+func (t *Tensor) selfOverlapping() bool {
+	if t.Numel() == 0 || is4Bit(t.DT) {
+		return false
+	}
+	type dim struct{ n, stride int }
+	var dims []dim
+	for d, n := range t.Shape {
+		if n > 1 {
+			dims = append(dims, dim{n, abs(t.Strides[d])})
+		}
+	}
+	slices.SortFunc(dims, func(a, b dim) int { return a.stride - b.stride })
+	extent := t.DT.SizeOf()
+	for _, d := range dims {
+		if d.stride < extent {
+			return true
+		}
+		extent += (d.n - 1) * d.stride
+	}
+	return false
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// loadFloat32 returns the view's values in row-major order as float32.
+func (t *Tensor) loadFloat32() ([]float32, error) {
+	raw, err := t.packedBytes()
+	if err != nil {
+		return nil, err
+	}
+	return decodeFloat32(t.DT, raw, t.Numel())
+}
+
+// loadFloat64 returns the view's values in row-major order as float64, exact
+// for every float dtype and for integers up to 2^53.
+func (t *Tensor) loadFloat64() ([]float64, error) {
+	out := make([]float64, t.Numel())
+	if isIntDType(t.DT) {
+		vals, err := t.loadInt64()
+		for i, v := range vals {
+			out[i] = float64(v)
+		}
+		return out, err
+	}
+	vals, err := t.loadFloat32()
+	for i, v := range vals {
+		out[i] = float64(v)
+	}
+	return out, err
+}
+
+// storeFloat32 converts vals to the view's dtype and writes them row-major.
+func (t *Tensor) storeFloat32(vals []float32) error {
+	if len(vals) != t.Numel() {
+		return errors.New("len(vals) mismatch")
+	}
+	raw, err := encodeFloat32(t.DT, vals)
+	if err != nil {
+		return err
+	}
+	return t.storePackedBytes(raw)
+}
+
+// decodeFloat32 expands n packed elements of dtype dt into float32.
+func decodeFloat32(dt DType, raw []byte, n int) ([]float32, error) {
+	out := make([]float32, n)
+	switch dt {
+	case Float32:
+		for i := range out {
+			out[i] = math.Float32frombits(binary.LittleEndian.Uint32(raw[4*i:]))
+		}
+	case Float16:
+		UnpackFP16Bytes(out, raw)
+	case BFloat16:
+		UnpackBF16Bytes(out, raw)
+	case Int8:
+		for i := range out {
+			out[i] = float32(int8(raw[i]))
+		}
+	case Int4:
+		for i := range out {
+			nibble := raw[i/2] >> (4 * uint(i%2)) & 0x0F
+			out[i] = float32(int8(nibble<<4) >> 4)
+		}
+	case Float8E4M3:
+		for i := range out {
+			out[i] = fp8E4M3Table[raw[i]]
+		}
+	case Float8E5M2:
+		for i := range out {
+			out[i] = fp8E5M2Table[raw[i]]
+		}
+	case Float4E2M1:
+		copy(out, UnpackFP4E2M1(raw, n))
+	case Float8E8M0:
+		for i := range out {
+			out[i] = e8m0ToFloat32(raw[i])
+		}
+	case Int32:
+		for i := range out {
+			out[i] = float32(int32(binary.LittleEndian.Uint32(raw[4*i:])))
+		}
+	case Int64:
+		for i := range out {
+			out[i] = float32(int64(binary.LittleEndian.Uint64(raw[8*i:])))
+		}
+	case Bool:
+		for i := range out {
+			if raw[i] != 0 {
+				out[i] = 1
+			}
+		}
+	default:
+		return nil, errors.New("unsupported dtype " + dt.String())
+	}
+	return out, nil
+}
+
+// encodeFloat32 packs float32 values into dtype dt using the rounding rules
+// documented on Tensor.To.
+func encodeFloat32(dt DType, vals []float32) ([]byte, error) {
+	switch dt {
+	case Float32:
+		out := make([]byte, 4*len(vals))
+		if len(vals) > 0 {
+			copy(out, unsafe.Slice((*byte)(unsafe.Pointer(&vals[0])), len(vals)*4))
+		}
+		return out, nil
+	case Float16:
+		out := make([]byte, 2*len(vals))
+		PackFP16Bytes(out, vals)
+		return out, nil
+	case BFloat16:
+		out := make([]byte, 2*len(vals))
+		PackBF16Bytes(out, vals)
+		return out, nil
+	case Int8:
+		out := make([]byte, len(vals))
+		for i, v := range vals {
+			out[i] = byte(roundSaturate(v, math.MinInt8, math.MaxInt8))
+		}
+		return out, nil
+	case Int4:
+		out := make([]byte, BytesFor(Int4, len(vals)))
+		for i, v := range vals {
+			out[i/2] |= (byte(roundSaturate(v, -8, 7)) & 0x0F) << (4 * uint(i%2))
+		}
+		return out, nil
+	case Int32:
+		out := make([]byte, 4*len(vals))
+		for i, v := range vals {
+			binary.LittleEndian.PutUint32(out[4*i:], uint32(roundSaturate(v, math.MinInt32, math.MaxInt32)))
+		}
+		return out, nil
+	case Int64:
+		out := make([]byte, 8*len(vals))
+		for i, v := range vals {
+			binary.LittleEndian.PutUint64(out[8*i:], uint64(roundSaturate(v, math.MinInt64, math.MaxInt64)))
+		}
+		return out, nil
+	case Bool:
+		out := make([]byte, len(vals))
+		for i, v := range vals {
+			if v != 0 {
+				out[i] = 1
+			}
+		}
+		return out, nil
+	case Float8E4M3:
+		return PackFP8E4M3(vals, true), nil
+	case Float8E5M2:
+		return PackFP8E5M2(vals, true), nil
+	case Float4E2M1:
+		return PackFP4E2M1(vals), nil
+	default:
+		return nil, errors.New("unsupported dtype " + dt.String())
+	}
+}
+
+// roundSaturate rounds f to the nearest integer (ties to even) and clamps it
+// to [lo, hi]. NaN maps to 0.
+func roundSaturate(f float32, lo, hi int64) int64 {
+	if f != f {
+		return 0
+	}
+	r := math.RoundToEven(float64(f))
+	if r <= float64(lo) {
+		return lo
+	}
+	if r >= float64(hi) {
+		return hi
+	}
+	return int64(r)
+}
+
+// loadInt64 returns the values of an integer view exactly, in row-major order.
+func (t *Tensor) loadInt64() ([]int64, error) {
+	raw, err := t.packedBytes()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]int64, t.Numel())
+	switch t.DT {
+	case Int8:
+		for i := range out {
+			out[i] = int64(int8(raw[i]))
+		}
+	case Int4:
+		for i := range out {
+			nibble := raw[i/2] >> (4 * uint(i%2)) & 0x0F
+			out[i] = int64(int8(nibble<<4) >> 4)
+		}
+	case Int32:
+		for i := range out {
+			out[i] = int64(int32(binary.LittleEndian.Uint32(raw[4*i:])))
+		}
+	case Int64:
+		for i := range out {
+			out[i] = int64(binary.LittleEndian.Uint64(raw[8*i:]))
+		}
+	default:
+		return nil, errors.New("expected an integer tensor, got " + t.DT.String())
+	}
+	return out, nil
+}
+
+// storeInt64 writes integer values into an integer view. Values must fit the
+// view's dtype; wider values are truncated.
+func (t *Tensor) storeInt64(vals []int64) error {
+	if len(vals) != t.Numel() {
+		return errors.New("len(vals) mismatch")
+	}
+	raw := make([]byte, BytesFor(t.DT, len(vals)))
+	switch t.DT {
+	case Int8:
+		for i, v := range vals {
+			raw[i] = byte(v)
+		}
+	case Int4:
+		for i, v := range vals {
+			raw[i/2] |= (byte(v) & 0x0F) << (4 * uint(i%2))
+		}
+	case Int32:
+		for i, v := range vals {
+			binary.LittleEndian.PutUint32(raw[4*i:], uint32(int32(v)))
+		}
+	case Int64:
+		for i, v := range vals {
+			binary.LittleEndian.PutUint64(raw[8*i:], uint64(v))
+		}
+	default:
+		return errors.New("expected an integer tensor, got " + t.DT.String())
+	}
+	return t.storePackedBytes(raw)
+}
@@ -0,0 +1,389 @@
+package tensor
+
+import (
+	"fmt"
+	"math"
+
+	"kylesmith19091/fastgo/internal/metal"
+)
+
+// ---------- Indexing ----------
+//
+// Index tensors hold Int8, Int32 or Int64 values and are bounds-checked on the
+// host before any copy; negative indices are rejected. The data dtype is
+// never converted and 4-bit dtypes are not supported. When an index repeats
+// in ScatterInto or IndexCopy the CPU path keeps the last write; on Metal the
+// winner is unspecified.
+
+// IndexSelect picks entries of t along dim. indices may have any rank: the
+// result has shape t.Shape[:dim] + indices.Shape + t.Shape[dim+1:], so
+// IndexSelect(weights, 0, ids) with ids [B, S] looks up a [B, S, D] batch of
+// embedding rows.
+func IndexSelect(t *Tensor, dim int, indices *Tensor) (*Tensor, error) {
+	const op = "IndexSelect"
+	if err := checkIndexOperands(op, t, indices); err != nil {
+		return nil, err
+	}
+	d, err := normalizeDim(op, dim, len(t.Shape))
+	if err != nil {
+		return nil, err
+	}
+	flat, tmp, err := flattenIndices(op, indices)
+	if err != nil {
+		return nil, err
+	}
+	if tmp != nil {
+		defer tmp.Close()
+	}
+	if err := checkIndices(op, flat, d, t.Shape[d]); err != nil {
+		return nil, err
+	}
+	n := flat.Shape[0]
+	outShape := append(append(append([]int(nil), t.Shape[:d]...), indices.Shape...), t.Shape[d+1:]...)
+	out, err := NewOn(t.Device(), t.DT, outShape...)
+	if err != nil {
+		return nil, err
+	}
+	// View the output with the indices flattened so it lines up with t.
+	iter := append([]int(nil), t.Shape...)
+	iter[d] = n
+	dst, err := out.view(out.Offset, iter, DefaultStridesBytes(out.DT, iter))
+	if err == nil {
+		err = indexCopy(op, t, dst, alongDim(flat, iter, d), d, false)
+	}
+	if err != nil {
+		_ = out.Close()
+		return nil, err
+	}
+	if err := checkOutput(out, t, indices); err != nil {
+		_ = out.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return out, nil
+}
+
+// Gather returns out with out[i][j][k] = t[index[i][j][k]][j][k] for dim 0
+// (and likewise for other dims). index must have t's rank and may not exceed
+// t's shape outside dim; the result has index's shape.
+func Gather(t *Tensor, dim int, index *Tensor) (*Tensor, error) {
+	const op = "Gather"
+	if err := checkIndexOperands(op, t, index); err != nil {
+		return nil, err
+	}
+	d, err := normalizeDim(op, dim, len(t.Shape))
+	if err != nil {
+		return nil, err
+	}
+	src, err := fitIndexShape(op, "input", t, index, d)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkIndices(op, index, d, t.Shape[d]); err != nil {
+		return nil, err
+	}
+	out, err := NewOn(t.Device(), t.DT, index.Shape...)
+	if err != nil {
+		return nil, err
+	}
+	if err := indexCopy(op, src, out, index, d, false); err != nil {
+		_ = out.Close()
+		return nil, err
+	}
+	if err := checkOutput(out, t, index); err != nil {
+		_ = out.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return out, nil
+}
+
+// ScatterInto writes src into dst in place, with dst[index[i][j]][j] =
+// src[i][j] for dim 0 (and likewise for other dims). index, src and dst share
+// a rank; index may not exceed src's shape, nor dst's outside dim.
+func ScatterInto(dst *Tensor, dim int, index, src *Tensor) error {
+	const op = "ScatterInto"
+	if err := checkIndexOperands(op, dst, index); err != nil {
+		return err
+	}
+	if err := src.live(); err != nil {
+		return fmt.Errorf("%s: source: %w", op, err)
+	}
+	if _, err := sameDevice(dst, index, src); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if src.DT != dst.DT {
+		return fmt.Errorf("%s: source dtype %v, destination dtype %v", op, src.DT, dst.DT)
+	}
+	d, err := normalizeDim(op, dim, len(dst.Shape))
+	if err != nil {
+		return err
+	}
+	if len(src.Shape) != len(index.Shape) {
+		return fmt.Errorf("%s: source shape %v and index shape %v differ in rank", op, src.Shape, index.Shape)
+	}
+	for i, n := range index.Shape {
+		if n > src.Shape[i] {
+			return fmt.Errorf("%s: index shape %v exceeds source shape %v", op, index.Shape, src.Shape)
+		}
+	}
+	sv, err := src.view(src.Offset, index.Shape, src.Strides)
+	if err != nil {
+		return err
+	}
+	dv, err := fitIndexShape(op, "destination", dst, index, d)
+	if err != nil {
+		return err
+	}
+	if err := checkIndices(op, index, d, dst.Shape[d]); err != nil {
+		return err
+	}
+	if err := indexCopy(op, sv, dv, index, d, true); err != nil {
+		return err
+	}
+	if err := checkOutput(dst, dst, index, src); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// IndexCopy writes the slices of src along dim into dst at the 1-D indices,
+// i.e. dst.Select(dim, indices[i]) = src.Select(dim, i). src must match dst
+// except that its dim has len(indices) entries. Writing new KV entries into
+// the slots of a paged cache is IndexCopy(cache, 0, slots, kv).
+func IndexCopy(dst *Tensor, dim int, indices, src *Tensor) error {
+	const op = "IndexCopy"
+	if err := checkIndexOperands(op, dst, indices); err != nil {
+		return err
+	}
+	if err := src.live(); err != nil {
+		return fmt.Errorf("%s: source: %w", op, err)
+	}
+	if _, err := sameDevice(dst, indices, src); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if src.DT != dst.DT {
+		return fmt.Errorf("%s: source dtype %v, destination dtype %v", op, src.DT, dst.DT)
+	}
+	d, err := normalizeDim(op, dim, len(dst.Shape))
+	if err != nil {
+		return err
+	}
+	if len(indices.Shape) != 1 {
+		return fmt.Errorf("%s: indices must be 1-D, got shape %v", op, indices.Shape)
+	}
+	want := append([]int(nil), dst.Shape...)
+	want[d] = indices.Shape[0]
+	if !equalShapes(src.Shape, want) {
+		return fmt.Errorf("%s: source shape %v, want %v for %d indices into %v", op, src.Shape, want, indices.Shape[0], dst.Shape)
+	}
+	if err := checkIndices(op, indices, d, dst.Shape[d]); err != nil {
+		return err
+	}
+	if err := indexCopy(op, src, dst, alongDim(indices, src.Shape, d), d, true); err != nil {
+		return err
+	}
+	if err := checkOutput(dst, dst, indices, src); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func checkIndexOperands(op string, t, index *Tensor) error {
+	if err := t.live(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := index.live(); err != nil {
+		return fmt.Errorf("%s: index: %w", op, err)
+	}
+	if _, err := sameDevice(t, index); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if is4Bit(t.DT) {
+		return fmt.Errorf("%s: %v tensors are not supported; cast first", op, t.DT)
+	}
+	if index.DT != Int8 && index.DT != Int32 && index.DT != Int64 {
+		return fmt.Errorf("%s: index dtype must be int8, int32 or int64, got %v", op, index.DT)
+	}
+	return nil
+}
+
+// fitIndexShape checks index against t outside dim and returns the view of t
+// restricted to index's extent there.
+func fitIndexShape(op, what string, t, index *Tensor, dim int) (*Tensor, error) {
+	if len(index.Shape) != len(t.Shape) {
+		return nil, fmt.Errorf("%s: index rank %d (shape %v) differs from %s rank %d (shape %v)", op, len(index.Shape), index.Shape, what, len(t.Shape), t.Shape)
+	}
+	shape := append([]int(nil), index.Shape...)
+	shape[dim] = t.Shape[dim]
+	for i, n := range shape {
+		if n > t.Shape[i] {
+			return nil, fmt.Errorf("%s: index shape %v exceeds %s shape %v outside dim %d", op, index.Shape, what, t.Shape, dim)
+		}
+	}
+	return t.view(t.Offset, shape, t.Strides)
+}
+
+// flattenIndices returns indices as a 1-D view. When the layout needs a
+// copy, the copy is returned as tmp for the caller to close.
+func flattenIndices(op string, indices *Tensor) (flat, tmp *Tensor, err error) {
+	if len(indices.Shape) == 1 {
+		return indices, nil, nil
+	}
+	src := indices
+	if !indices.isDense() {
+		if tmp, err = indices.To(indices.DT); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", op, err)
+		}
+		src = tmp
+	}
+	flat, err = src.view(src.Offset, []int{src.Numel()}, []int{src.DT.SizeOf()})
+	if err != nil && tmp != nil {
+		_ = tmp.Close()
+		tmp = nil
+	}
+	return flat, tmp, err
+}
+
+// alongDim broadcasts 1-D indices over shape so that element i of shape uses
+// indices[i_dim].
+func alongDim(indices *Tensor, shape []int, dim int) *Tensor {
+	strides := make([]int, len(shape))
+	strides[dim] = indices.Strides[0]
+	return &Tensor{DT: indices.DT, Shape: append([]int(nil), shape...), Strides: strides, Offset: indices.Offset, buf: indices.buf}
+}
+
+// indexCopy copies one element per position of idx.Shape from src to dst.
+// Outside dim both sides are addressed by position; along dim the indexed
+// side (src for gathers, dst for scatters) uses the index value and the other
+// side the position. Callers validate the indices with checkIndices first.
+func indexCopy(op string, src, dst, idx *Tensor, dim int, scatter bool) error {
+	if dst.Device() == Meta {
+		return nil
+	}
+	var err error
+	if p, ok := indexParams(src, dst, idx, dim, scatter); ok && useMetal(src, idx, dst) {
+		p.Limit = int32(src.Shape[dim])
+		if scatter {
+			p.Limit = int32(dst.Shape[dim])
+		}
+		err = metal.IndexCopyBuffers(p, src.buf, idx.buf, dst.buf)
+	} else {
+		err = indexCopyCPU(src, dst, idx, dim, scatter)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// checkIndices reports the first index outside [0, limit).
+func checkIndices(op string, indices *Tensor, dim, limit int) error {
+	if indices.Device() == Meta {
+		return nil // no values to check
+	}
+	vals, err := indices.loadInt64()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	for i, v := range vals {
+		if v < 0 || v >= int64(limit) {
+			return fmt.Errorf("%s: index %d at position %d out of range for dim %d of size %d", op, v, i, dim, limit)
+		}
+	}
+	return nil
+}
+
+func indexCopyCPU(src, dst, idx *Tensor, dim int, scatter bool) error {
+	vals, err := idx.loadInt64()
+	if err != nil {
+		return err
+	}
+	shape := idx.Shape
+	srcStrides := append([]int(nil), src.Strides...)
+	dstStrides := append([]int(nil), dst.Strides...)
+	// The indexed side's dim stride is applied per index value instead.
+	step := srcStrides[dim]
+	if scatter {
+		step = dstStrides[dim]
+		dstStrides[dim] = 0
+	} else {
+		srcStrides[dim] = 0
+	}
+	srcOffs := make([]int, len(vals))
+	dstOffs := make([]int, len(vals))
+	forEachOffset2(shape, srcStrides, dstStrides, func(i, s, d int) {
+		if scatter {
+			d += int(vals[i]) * step
+		} else {
+			s += int(vals[i]) * step
+		}
+		srcOffs[i], dstOffs[i] = src.Offset+s, dst.Offset+d
+	})
+	if len(vals) == 0 {
+		return nil
+	}
+	elem := src.DT.SizeOf()
+	srcLo, srcHi := offsetRange(srcOffs, elem)
+	dstLo, dstHi := offsetRange(dstOffs, elem)
+	in, err := src.buf.ReadN(srcLo, srcHi-srcLo)
+	if err != nil {
+		return err
+	}
+	out, err := dst.buf.ReadN(dstLo, dstHi-dstLo)
+	if err != nil {
+		return err
+	}
+	for i := range vals {
+		s, d := srcOffs[i]-srcLo, dstOffs[i]-dstLo
+		copy(out[d:d+elem], in[s:s+elem])
+	}
+	return dst.buf.WriteAt(dstLo, out)
+}
+
+// offsetRange returns the byte range covering elements at offs.
+func offsetRange(offs []int, elem int) (int, int) {
+	lo, hi := offs[0], offs[0]
+	for _, o := range offs[1:] {
+		lo, hi = min(lo, o), max(hi, o)
+	}
+	return lo, hi + elem
+}
+
+// indexParams builds kernel params when the index dtype is Int32/Int64 and
+// every offset fits the kernel's 32-bit arithmetic; ok is false otherwise.
+func indexParams(src, dst, idx *Tensor, dim int, scatter bool) (*metal.IndexParams, bool) {
+	if idx.DT != Int32 && idx.DT != Int64 {
+		return nil, false
+	}
+	if len(idx.Shape) > metal.MaxDims || src.buf.Size() > math.MaxInt32 || dst.buf.Size() > math.MaxInt32 || idx.buf.Size() > math.MaxInt32 {
+		return nil, false
+	}
+	ie := idx.DT.SizeOf()
+	if idx.Offset%ie != 0 {
+		return nil, false
+	}
+	p := &metal.IndexParams{
+		N:        int32(idx.Numel()),
+		ElemSize: int32(src.DT.SizeOf()),
+		Rank:     int32(len(idx.Shape)),
+		Dim:      int32(dim),
+		OffSrc:   int32(src.Offset),
+		OffDst:   int32(dst.Offset),
+		OffIdx:   int32(idx.Offset / ie),
+	}
+	if scatter {
+		p.Scatter = 1
+	}
+	if idx.DT == Int64 {
+		p.Idx64 = 1
+	}
+	for d := range idx.Shape {
+		if idx.Strides[d]%ie != 0 {
+			return nil, false
+		}
+		p.Shape[d] = int32(idx.Shape[d])
+		p.SrcStride[d] = int32(src.Strides[d])
+		p.DstStride[d] = int32(dst.Strides[d])
+		p.IdxStride[d] = int32(idx.Strides[d] / ie)
+	}
+	return p, true
+}
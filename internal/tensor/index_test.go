@@ -0,0 +1,120 @@
+package tensor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIndexSelectEmbeddingBatch(t *testing.T) {
+	// [vocab=4, dim=3] with row r = [r, r+0.5, -r].
+	var w []float32
+	for r := 0; r < 4; r++ {
+		w = append(w, float32(r), float32(r)+0.5, -float32(r))
+	}
+	weights := mustFromFloat32(t, BFloat16, w, 4, 3)
+	for _, dt := range []DType{Int32, Int64, Int8} {
+		ids := mustFromFloat32(t, dt, []float32{3, 0, 1, 3}, 2, 2)
+		out, err := IndexSelect(weights, 0, ids)
+		if err != nil {
+			t.Fatalf("IndexSelect(%v): %v", dt, err)
+		}
+		if out.DT != BFloat16 || !equalShapes(out.Shape, []int{2, 2, 3}) {
+			t.Fatalf("IndexSelect(%v): got %v %v", dt, out.DT, out.Shape)
+		}
+		expectValues(t, "IndexSelect", mustLoad(t, out), []float32{
+			3, 3.5, -3, 0, 0.5, 0,
+			1, 1.5, -1, 3, 3.5, -3,
+		})
+		_ = out.Close()
+	}
+
+	// Selecting columns of a transposed view.
+	tr, err := weights.Transpose(0, 1) // [3,4]
+	if err != nil {
+		t.Fatalf("Transpose: %v", err)
+	}
+	cols := mustFromFloat32(t, Int32, []float32{2, 2}, 2)
+	out, err := IndexSelect(tr, -1, cols)
+	if err != nil {
+		t.Fatalf("IndexSelect cols: %v", err)
+	}
+	defer out.Close()
+	expectValues(t, "IndexSelect cols", mustLoad(t, out), []float32{2, 2, 2.5, 2.5, -2, -2})
+}
+
+func TestGatherAndScatter(t *testing.T) {
+	src := mustFromFloat32(t, Float32, []float32{1, 2, 3, 4, 5, 6}, 2, 3)
+	index := mustFromFloat32(t, Int64, []float32{2, 0, 1, 1}, 2, 2)
+	g, err := Gather(src, 1, index)
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	defer g.Close()
+	if !equalShapes(g.Shape, []int{2, 2}) {
+		t.Fatalf("Gather shape %v", g.Shape)
+	}
+	expectValues(t, "Gather", mustLoad(t, g), []float32{3, 1, 5, 5})
+
+	// ScatterInto along dim 0: dst[index[i][j]][j] = src[i][j].
+	dst := mustFromFloat32(t, Float32, make([]float32, 9), 3, 3)
+	idx := mustFromFloat32(t, Int32, []float32{2, 0, 1, 0, 1, 2}, 2, 3)
+	if err := ScatterInto(dst, 0, idx, src); err != nil {
+		t.Fatalf("ScatterInto: %v", err)
+	}
+	expectValues(t, "ScatterInto", mustLoad(t, dst), []float32{
+		4, 2, 0,
+		0, 5, 3,
+		1, 0, 6,
+	})
+}
+
+func TestIndexCopyPagedKV(t *testing.T) {
+	// Cache of 6 slots x [heads=2, headDim=2]; write 2 new tokens into
+	// slots 4 and 1.
+	cache := mustFromFloat32(t, Float16, make([]float32, 6*2*2), 6, 2, 2)
+	kv := mustFromFloat32(t, Float16, []float32{1, 2, 3, 4, 5, 6, 7, 8}, 2, 2, 2)
+	slots := mustFromFloat32(t, Int32, []float32{4, 1}, 2)
+	if err := IndexCopy(cache, 0, slots, kv); err != nil {
+		t.Fatalf("IndexCopy: %v", err)
+	}
+	want := make([]float32, 24)
+	copy(want[4*4:], []float32{1, 2, 3, 4})
+	copy(want[1*4:], []float32{5, 6, 7, 8})
+	expectValues(t, "IndexCopy", mustLoad(t, cache), want)
+
+	// Reading the slots back with IndexSelect returns the written entries.
+	back, err := IndexSelect(cache, 0, slots)
+	if err != nil {
+		t.Fatalf("IndexSelect: %v", err)
+	}
+	defer back.Close()
+	expectValues(t, "round trip", mustLoad(t, back), mustLoad(t, kv))
+}
+
+func TestIndexBoundsAndErrors(t *testing.T) {
+	x := mustFromFloat32(t, Float32, make([]float32, 6), 2, 3)
+	bad := mustFromFloat32(t, Int32, []float32{0, 2}, 2)
+	neg := mustFromFloat32(t, Int64, []float32{-1}, 1)
+	f := mustFromFloat32(t, Float32, []float32{0}, 1)
+	row := mustFromFloat32(t, Float32, make([]float32, 3), 1, 3)
+	cases := []struct {
+		name, want string
+		fn         func() error
+	}{
+		{"select oob", "index 2 at position 1 out of range for dim 0 of size 2", func() error { _, err := IndexSelect(x, 0, bad); return err }},
+		{"select negative", "index -1", func() error { _, err := IndexSelect(x, 1, neg); return err }},
+		{"float index", "index dtype", func() error { _, err := IndexSelect(x, 0, f); return err }},
+		{"gather rank", "index rank 1", func() error { _, err := Gather(x, 0, bad); return err }},
+		{"copy shape", "source shape", func() error { return IndexCopy(x, 0, bad, row) }},
+		{"copy dtype", "source dtype", func() error { return IndexCopy(x, 0, bad, neg) }},
+		{"copy oob", "index 2 at position 1", func() error { return IndexCopy(x, 0, bad, x) }},
+	}
+	for _, c := range cases {
+		err := c.fn()
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Fatalf("%s: got %v, want error containing %q", c.name, err, c.want)
+		}
+	}
+	// A failed bounds check leaves the destination untouched.
+	expectValues(t, "unchanged", mustLoad(t, x), make([]float32, 6))
+}
@@ -0,0 +1,259 @@
+package tensor
+
+import (
+	"math"
+	"testing"
+)
+
+func TestInPlaceOps(t *testing.T) {
+	x := mustFromFloat32(t, Float32, []float32{1, 2, 3, 4, 5, 6}, 2, 3)
+	bias := mustFromFloat32(t, Float32, []float32{10, 20, 30}, 3)
+	mask := mustFromFloat32(t, Bool, []float32{0, 1, 0}, 3)
+	defer x.Close()
+	defer bias.Close()
+	defer mask.Close()
+
+	steps := []struct {
+		name string
+		run  func() error
+		want []float32
+	}{
+		{"AddInPlace", func() error { return AddInPlace(x, bias) }, []float32{11, 22, 33, 14, 25, 36}},
+		{"SubScalarInPlace", func() error { return SubScalarInPlace(x, 10) }, []float32{1, 12, 23, 4, 15, 26}},
+		{"MaskedFillInPlace", func() error { return MaskedFillInPlace(x, mask, 0) }, []float32{1, 0, 23, 4, 0, 26}},
+		{"MaximumScalarInPlace", func() error { return MaximumScalarInPlace(x, 4) }, []float32{4, 4, 23, 4, 4, 26}},
+		{"SqrtInPlace", func() error { return SqrtInPlace(x) }, []float32{2, 2, float32(math.Sqrt(23)), 2, 2, float32(math.Sqrt(26))}},
+		{"MulInPlace", func() error { return MulInPlace(x, x) }, []float32{4, 4, 23, 4, 4, 26}},
+		{"NegInPlace", func() error { return NegInPlace(x) }, []float32{-4, -4, -23, -4, -4, -26}},
+	}
+	for _, s := range steps {
+		if err := s.run(); err != nil {
+			t.Fatalf("%s: %v", s.name, err)
+		}
+		expectClose(t, s.name, mustLoad(t, x), s.want, 1e-5)
+	}
+
+	// In place on a strided view only touches the view's elements.
+	col, err := x.Select(1, 2)
+	if err != nil {
+		t.Fatalf("Select: %v", err)
+	}
+	defer col.Close()
+	if err := AddScalarInPlace(col, 100); err != nil {
+		t.Fatalf("AddScalarInPlace on a column: %v", err)
+	}
+	expectClose(t, "column", mustLoad(t, x), []float32{-4, -4, 77, -4, -4, 74}, 1e-5)
+}
+
+func TestReduceAndMatMulInto(t *testing.T) {
+	x := mustFromFloat32(t, Float32, []float32{1, 5, 3, 4, 2, 6}, 2, 3)
+	w := mustFromFloat32(t, Float32, []float32{1, 0, 0, 1, 1, 1}, 3, 2)
+	defer x.Close()
+	defer w.Close()
+	f := func(shape ...int) *Tensor {
+		out, err := New(Float32, shape...)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		t.Cleanup(func() { _ = out.Close() })
+		return out
+	}
+	row, scalar, kept := f(2), f(), f(2, 1)
+	idx, err := New(Int32, 2)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer idx.Close()
+	mm := f(2, 2)
+
+	cases := []struct {
+		name string
+		run  func() error
+		out  *Tensor
+		want []float32
+	}{
+		{"SumInto", func() error { return SumInto(row, x, false, 1) }, row, []float32{9, 12}},
+		{"MeanInto", func() error { return MeanInto(scalar, x, false) }, scalar, []float32{3.5}},
+		{"MaxInto keepdim", func() error { return MaxInto(kept, x, true, -1) }, kept, []float32{5, 6}},
+		{"MinInto", func() error { return MinInto(row, x, false, 1) }, row, []float32{1, 2}},
+		{"VarInto", func() error { return VarInto(row, x, 1, false, 1) }, row, []float32{4, 4}},
+		{"ArgMaxInto", func() error { return ArgMaxInto(idx, x, 1, false) }, idx, []float32{1, 2}},
+		{"ArgMinInto", func() error { return ArgMinInto(idx, x, 1, false) }, idx, []float32{0, 1}},
+		{"MatMulInto", func() error { return MatMulInto(mm, x, w) }, mm, []float32{4, 8, 10, 8}},
+	}
+	for _, c := range cases {
+		if err := c.run(); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		expectValues(t, c.name, mustLoad(t, c.out), c.want)
+	}
+}
+
+func TestIntoValidation(t *testing.T) {
+	x := mustFromFloat32(t, Float32, []float32{1, 2, 3, 4}, 2, 2)
+	v := mustFromFloat32(t, Float32, []float32{1, 2}, 2)
+	f16 := mustFromFloat32(t, Float16, []float32{0, 0}, 2)
+	i32 := mustFromFloat32(t, Int32, []float32{0, 0}, 2)
+	closed := mustFromFloat32(t, Float32, []float32{0, 0}, 2)
+	_ = closed.Close()
+	bc, err := v.BroadcastTo(2, 2)
+	if err != nil {
+		t.Fatalf("BroadcastTo: %v", err)
+	}
+	row, err := x.Row(0)
+	if err != nil {
+		t.Fatalf("Row: %v", err)
+	}
+	for _, tt := range []*Tensor{x, v, f16, i32, bc, row} {
+		defer tt.Close()
+	}
+
+	cases := map[string]func() error{
+		"nil out":             func() error { return AddInto(nil, x, x) },
+		"nil unary out":       func() error { return ExpInto(nil, x) },
+		"nil reduce out":      func() error { return SumInto(nil, x, false) },
+		"nil arg out":         func() error { return ArgMaxInto(nil, x, 0, false) },
+		"nil matmul out":      func() error { return MatMulInto(nil, x, x) },
+		"nil where out":       func() error { return WhereInto(nil, x, x, x) },
+		"nil masked fill out": func() error { return MaskedFillInto(nil, x, x, 0) },
+		"closed out":          func() error { return AddInto(closed, v, v) },
+		"shape":               func() error { return AddInto(v, x, x) },
+		"in place broadcasts": func() error { return AddInPlace(v, x) },
+		"broadcast out":       func() error { return AddInto(bc, x, x) },
+		"reduce dtype":        func() error { return SumInto(f16, x, false, 1) },
+		"arg dtype":           func() error { return ArgMaxInto(f16, x, 1, false) },
+		"matmul dtype":        func() error { return MatMulInto(i32, x, v) },
+		"reduce overlaps":     func() error { return SumInto(row, x, false, 0) },
+		"matmul overlaps":     func() error { return MatMulInto(row, x, v) },
+		"reduce into input":   func() error { return SumInto(x, x, false) },
+	}
+	for name, run := range cases {
+		if err := run(); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+// An output that partly overlaps an input must see the input as it was
+// before the op, as if the inputs were copied first.
+func TestIntoOverlappingViews(t *testing.T) {
+	x := mustFromFloat32(t, Float32, []float32{1, 2, 3, 4, 5}, 5)
+	defer x.Close()
+	head, err := x.View(0, []int{4}, []int{4})
+	if err != nil {
+		t.Fatalf("View: %v", err)
+	}
+	tail, err := x.View(4, []int{4}, []int{4})
+	if err != nil {
+		t.Fatalf("View: %v", err)
+	}
+	defer head.Close()
+	defer tail.Close()
+	if err := AddInto(tail, head, head); err != nil {
+		t.Fatalf("AddInto shifted right: %v", err)
+	}
+	expectValues(t, "shifted right", mustLoad(t, x), []float32{1, 2, 4, 6, 8})
+	if err := SubInto(head, tail, head); err != nil {
+		t.Fatalf("SubInto shifted left: %v", err)
+	}
+	expectValues(t, "shifted left", mustLoad(t, x), []float32{1, 2, 2, 2, 8})
+
+	// x + x^T written over x: every output element reads one that is
+	// overwritten elsewhere.
+	m := mustFromFloat32(t, Float32, []float32{1, 2, 3, 4, 5, 6, 7, 8, 9}, 3, 3)
+	defer m.Close()
+	mt, err := m.Transpose(0, 1)
+	if err != nil {
+		t.Fatalf("Transpose: %v", err)
+	}
+	defer mt.Close()
+	if err := AddInPlace(m, mt); err != nil {
+		t.Fatalf("AddInPlace with its transpose: %v", err)
+	}
+	expectValues(t, "x + x^T", mustLoad(t, m), []float32{2, 6, 10, 6, 10, 14, 10, 14, 18})
+
+	// Where reading the output it copies into.
+	cond := mustFromFloat32(t, Bool, []float32{1, 0, 1}, 3)
+	a := mustFromFloat32(t, Float32, []float32{1, 2, 3}, 3)
+	b := mustFromFloat32(t, Float32, []float32{7, 8, 9}, 3)
+	defer cond.Close()
+	defer a.Close()
+	defer b.Close()
+	if err := WhereInto(a, cond, a, b); err != nil {
+		t.Fatalf("WhereInto a: %v", err)
+	}
+	expectValues(t, "WhereInto a", mustLoad(t, a), []float32{1, 8, 3})
+}
+
+// A decode step built from Into and InPlace ops reuses its buffers: after
+// warm-up it allocates no tensors and fewer Go objects than the same step
+// built from allocating ops.
+func TestIntoAllocations(t *testing.T) {
+	x := mustFromFloat32(t, Float32, []float32{1, 2, 3, 4, 5, 6}, 2, 3)
+	w := mustFromFloat32(t, Float32, []float32{1, 0, 0, 1, 1, 1}, 3, 2)
+	bias := mustFromFloat32(t, Float32, []float32{0.5, -0.5}, 2)
+	h := mustFromFloat32(t, Float32, make([]float32, 4), 2, 2)
+	sum := mustFromFloat32(t, Float32, make([]float32, 2), 2)
+	for _, tt := range []*Tensor{x, w, bias, h, sum} {
+		defer tt.Close()
+	}
+	stepInto := func() {
+		if err := MatMulInto(h, x, w); err != nil {
+			t.Fatalf("MatMulInto: %v", err)
+		}
+		if err := AddInPlace(h, bias); err != nil {
+			t.Fatalf("AddInPlace: %v", err)
+		}
+		if err := MulScalarInPlace(h, 0.5); err != nil {
+			t.Fatalf("MulScalarInPlace: %v", err)
+		}
+		if err := ExpInPlace(h); err != nil {
+			t.Fatalf("ExpInPlace: %v", err)
+		}
+		if err := SumInto(sum, h, false, -1); err != nil {
+			t.Fatalf("SumInto: %v", err)
+		}
+	}
+	stepAlloc := func() {
+		var ts []*Tensor
+		keep := func(out *Tensor, err error) *Tensor {
+			if err != nil {
+				t.Fatalf("step: %v", err)
+			}
+			ts = append(ts, out)
+			return out
+		}
+		y := keep(MatMul(x, w))
+		y = keep(Add(y, bias))
+		y = keep(MulScalar(y, 0.5))
+		y = keep(Exp(y))
+		keep(Sum(y, false, -1))
+		for _, tt := range ts {
+			_ = tt.Close()
+		}
+	}
+
+	stepInto()
+	want := mustLoad(t, sum)
+	stepAlloc() // warm up both paths
+
+	before := buffersAllocated.Load()
+	for range 10 {
+		stepInto()
+	}
+	if n := buffersAllocated.Load() - before; n != 0 {
+		t.Fatalf("Into step allocated %d buffers over 10 steps, want 0", n)
+	}
+	before = buffersAllocated.Load()
+	stepAlloc()
+	if n := buffersAllocated.Load() - before; n != 5 {
+		t.Fatalf("allocating step allocated %d buffers, want 5", n)
+	}
+	expectValues(t, "steady state", mustLoad(t, sum), want)
+
+	into := testing.AllocsPerRun(20, stepInto)
+	alloc := testing.AllocsPerRun(20, stepAlloc)
+	if into >= alloc {
+		t.Fatalf("Into step makes %v Go allocations, allocating step %v", into, alloc)
+	}
+}
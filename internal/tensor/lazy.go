@@ -0,0 +1,567 @@
+package tensor
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"slices"
+	"strings"
+	"unsafe"
+
+	"kylesmith19091/fastgo/internal/metal"
+)
+
+// ---------- Lazy expressions ----------
+//
+// An Expr records elementwise and broadcast ops instead of running them, and
+// Realize evaluates the whole tree in one pass over the output, without the
+// temporary each eager op allocates. RMSNorm, for example:
+//
+//	x := tensor.Lazy(h)
+//	inv := x.Mul(x).Mean(true, -1).AddScalar(eps).Rsqrt()
+//	y, err := x.Mul(inv).Mul(tensor.Lazy(w)).Realize()
+//
+// The ops follow their eager forms exactly: operands broadcast, dtypes
+// promote as in Add and every intermediate is rounded to its dtype, so the
+// result is bit-identical to the eager chain. Reductions are barriers: their
+// input is realized and reduced first, then read like a tensor.
+//
+// On the CPU the tree runs as one loop over the output in chunks, each node
+// evaluated over a chunk before the next. With Metal ready and float32
+// operands it runs as one kernel generated from the tree, using the math of
+// the eager kernels. Kernels are cached by the tree's structure; scalars are
+// passed as params, so changing one does not compile a new kernel.
+//
+// An Expr borrows its tensors, which must stay open until Realize returns,
+// and may be realized any number of times, reading their current values.
+// Errors in building it, such as shapes that do not broadcast, are reported
+// by Realize.
+
+// Expr is a lazily evaluated elementwise expression; see Lazy.
+type Expr struct {
+	kind    exprKind
+	t       *Tensor // exprLeaf
+	bin     binaryOp
+	un      unaryOp
+	s       float32 // scalar operand of the scalar unary ops
+	red     reduceOp
+	keepdim bool
+	dims    []int
+	a, b    *Expr
+	shape   []int
+	dt      DType
+	err     error
+}
+
+type exprKind uint8
+
+const (
+	exprLeaf exprKind = iota
+	exprUnary
+	exprBinary
+	exprReduce
+)
+
+// Lazy starts an expression that reads t.
+func Lazy(t *Tensor) *Expr {
+	if err := t.live(); err != nil {
+		return &Expr{err: fmt.Errorf("Lazy: %w", err)}
+	}
+	return &Expr{kind: exprLeaf, t: t, shape: t.Shape, dt: t.DT}
+}
+
+// Shape returns the shape the expression realizes to.
+func (e *Expr) Shape() []int { return e.shape }
+
+// DType returns the dtype the expression realizes to.
+func (e *Expr) DType() DType { return e.dt }
+
+func (e *Expr) Add(o *Expr) *Expr     { return e.binary(opAdd, o) }
+func (e *Expr) Sub(o *Expr) *Expr     { return e.binary(opSub, o) }
+func (e *Expr) Mul(o *Expr) *Expr     { return e.binary(opMul, o) }
+func (e *Expr) Div(o *Expr) *Expr     { return e.binary(opDiv, o) }
+func (e *Expr) Maximum(o *Expr) *Expr { return e.binary(opMaximum, o) }
+func (e *Expr) Minimum(o *Expr) *Expr { return e.binary(opMinimum, o) }
+func (e *Expr) Pow(o *Expr) *Expr     { return e.binary(opPow, o) }
+
+func (e *Expr) Neg() *Expr   { return e.unary(opNeg, 0) }
+func (e *Expr) Exp() *Expr   { return e.unary(opExp, 0) }
+func (e *Expr) Log() *Expr   { return e.unary(opLog, 0) }
+func (e *Expr) Sqrt() *Expr  { return e.unary(opSqrt, 0) }
+func (e *Expr) Rsqrt() *Expr { return e.unary(opRsqrt, 0) }
+
+func (e *Expr) AddScalar(s float32) *Expr     { return e.unary(opAddScalar, s) }
+func (e *Expr) SubScalar(s float32) *Expr     { return e.unary(opSubScalar, s) }
+func (e *Expr) MulScalar(s float32) *Expr     { return e.unary(opMulScalar, s) }
+func (e *Expr) DivScalar(s float32) *Expr     { return e.unary(opDivScalar, s) }
+func (e *Expr) MaximumScalar(s float32) *Expr { return e.unary(opMaximumScalar, s) }
+func (e *Expr) MinimumScalar(s float32) *Expr { return e.unary(opMinimumScalar, s) }
+func (e *Expr) PowScalar(s float32) *Expr     { return e.unary(opPowScalar, s) }
+
+// Sum, Mean, Max and Min reduce as their eager forms do; see the package
+// reductions. They end a fused pass: the input is realized on its own.
+func (e *Expr) Sum(keepdim bool, dims ...int) *Expr  { return e.reduce(opSum, keepdim, dims) }
+func (e *Expr) Mean(keepdim bool, dims ...int) *Expr { return e.reduce(opMean, keepdim, dims) }
+func (e *Expr) Max(keepdim bool, dims ...int) *Expr  { return e.reduce(opMax, keepdim, dims) }
+func (e *Expr) Min(keepdim bool, dims ...int) *Expr  { return e.reduce(opMin, keepdim, dims) }
+
+func (e *Expr) binary(op binaryOp, o *Expr) *Expr {
+	if o == nil {
+		return &Expr{err: fmt.Errorf("%v: nil operand", op)}
+	}
+	if err := cmp.Or(e.err, o.err); err != nil {
+		return &Expr{err: err}
+	}
+	shape, err := BroadcastShapes(e.shape, o.shape)
+	if err != nil {
+		return &Expr{err: fmt.Errorf("%v: %w", op, err)}
+	}
+	dt := promoteTypes(e.dt, o.dt)
+	if op.floatResult() && isIntDType(dt) {
+		dt = Float32
+	}
+	return &Expr{kind: exprBinary, bin: op, a: e, b: o, shape: shape, dt: dt}
+}
+
+func (e *Expr) unary(op unaryOp, s float32) *Expr {
+	if e.err != nil {
+		return e
+	}
+	dt := e.dt
+	if op.floatResult() && isIntDType(dt) {
+		dt = Float32
+	}
+	return &Expr{kind: exprUnary, un: op, s: s, a: e, shape: e.shape, dt: dt}
+}
+
+func (e *Expr) reduce(op reduceOp, keepdim bool, dims []int) *Expr {
+	if e.err != nil {
+		return e
+	}
+	mask, err := reduceDims(op, len(e.shape), dims)
+	if err != nil {
+		return &Expr{err: err}
+	}
+	dt, err := reduceResultDType(op, e.dt, 0)
+	if err != nil {
+		return &Expr{err: err}
+	}
+	shape := newReduceLayout(e.shape, make([]int, len(e.shape)), mask, keepdim).outShape
+	return &Expr{kind: exprReduce, red: op, keepdim: keepdim, dims: slices.Clone(dims), a: e, shape: shape, dt: dt}
+}
+
+// Realize evaluates the expression into a new tensor.
+func (e *Expr) Realize() (*Tensor, error) { return e.realize(nil) }
+
+// RealizeInto evaluates the expression into out, which must have its shape;
+// values are converted to out's dtype. out may be one of the expression's
+// tensors, as with an InPlace op.
+func (e *Expr) RealizeInto(out *Tensor) error {
+	if out == nil {
+		return errors.New("Realize: nil output tensor")
+	}
+	_, err := e.realize(out)
+	return err
+}
+
+func (e *Expr) realize(out *Tensor) (*Tensor, error) {
+	if e.err != nil {
+		return nil, fmt.Errorf("Realize: %w", e.err)
+	}
+	p := compileExpr(e)
+	// Barriers are realized first and released once the pass has read them.
+	ins := make([]*Tensor, len(p.leaves))
+	defer func() {
+		for i, l := range p.leaves {
+			if l.kind == exprReduce && ins[i] != nil {
+				_ = ins[i].Close()
+			}
+		}
+	}()
+	for i, l := range p.leaves {
+		if l.kind == exprLeaf {
+			if err := l.t.live(); err != nil {
+				return nil, fmt.Errorf("Realize: %w", err)
+			}
+			ins[i] = l.t
+			continue
+		}
+		x, err := l.a.t, error(nil)
+		if l.a.kind != exprLeaf {
+			if x, err = l.a.realize(nil); err != nil {
+				return nil, err
+			}
+		}
+		ins[i], err = reduce(l.red, nil, x, l.dims, l.keepdim, 0, 0)
+		if l.a.kind != exprLeaf {
+			_ = x.Close()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Realize: %w", err)
+		}
+	}
+	dev, err := sameDevice(ins...)
+	if err != nil {
+		return nil, fmt.Errorf("Realize: %w", err)
+	}
+	out, owned, err := prepareOut(opName("Realize"), out, dev, e.dt, e.shape)
+	if err != nil || dev == Meta {
+		return out, err
+	}
+	views := make([]*Tensor, len(ins))
+	for i, t := range ins {
+		if views[i], err = t.broadcastTo(e.shape); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		if fp, ok := p.metalParams(out, views); ok && useMetal(out) && dev == Metal {
+			err = p.runMetal(fp, out, ins, views)
+		} else {
+			err = p.runCPU(out, ins)
+		}
+	}
+	if err == nil && DebugChecks() {
+		// The leaves are the caller's operands; reduction results are not.
+		var leaves, reduced []*Tensor
+		for i, l := range p.leaves {
+			if l.kind == exprLeaf {
+				leaves = append(leaves, ins[i])
+			} else {
+				reduced = append(reduced, ins[i])
+			}
+		}
+		err = checkOutputWith(out, leaves, reduced, p.scalars...)
+	}
+	if err != nil {
+		if owned {
+			_ = out.Close()
+		}
+		return nil, fmt.Errorf("Realize: %w", err)
+	}
+	return out, nil
+}
+
+// fusedProgram is an expression flattened for evaluation. Registers
+// 0..len(leaves)-1 hold the leaves, tensors or reductions, and register
+// len(leaves)+i the result of insts[i].
+type fusedProgram struct {
+	leaves  []*Expr
+	insts   []fusedInst
+	scalars []float32
+	root    int
+}
+
+type fusedInst struct {
+	node   *Expr
+	a, b   int // operand registers; b is unused by unary ops
+	scalar int // index into scalars of a scalar op's operand
+}
+
+// compileExpr flattens e in evaluation order. Subtrees shared by pointer are
+// evaluated once, and leaves reading the same view share a register.
+func compileExpr(e *Expr) *fusedProgram {
+	p := &fusedProgram{}
+	regs := map[*Expr]int{}
+	seen := map[*Expr]bool{}
+	var collect func(n *Expr)
+	collect = func(n *Expr) {
+		if seen[n] {
+			return
+		}
+		seen[n] = true
+		switch n.kind {
+		case exprLeaf:
+			for i, l := range p.leaves {
+				if l.kind == exprLeaf && l.t.DT == n.t.DT && sameView(l.t, n.t) {
+					regs[n] = i
+					return
+				}
+			}
+			fallthrough
+		case exprReduce:
+			regs[n] = len(p.leaves)
+			p.leaves = append(p.leaves, n)
+		case exprBinary:
+			collect(n.a)
+			collect(n.b)
+		default:
+			collect(n.a)
+		}
+	}
+	collect(e)
+	var emit func(n *Expr) int
+	emit = func(n *Expr) int {
+		if r, ok := regs[n]; ok {
+			return r
+		}
+		in := fusedInst{node: n, a: emit(n.a)}
+		if n.kind == exprBinary {
+			in.b = emit(n.b)
+		} else if n.un >= opAddScalar {
+			in.scalar = len(p.scalars)
+			p.scalars = append(p.scalars, n.s)
+		}
+		r := len(p.leaves) + len(p.insts)
+		p.insts = append(p.insts, in)
+		regs[n] = r
+		return r
+	}
+	p.root = emit(e)
+	return p
+}
+
+// fusedChunk is the number of output elements the CPU evaluates a node over
+// before moving on to the next.
+const fusedChunk = 1024
+
+// runCPU evaluates p over out a chunk at a time. Each node runs over the
+// whole chunk as its own loop, so its float32 results are rounded exactly as
+// the eager op stores them. Every input is loaded before out is written.
+func (p *fusedProgram) runCPU(out *Tensor, ins []*Tensor) error {
+	n, nl := out.Numel(), len(ins)
+	vals := make([][]float32, nl)
+	strides := make([][]int, nl)
+	regs := make([][]float32, nl+len(p.insts))
+	var gather []int // leaves read through broadcast strides
+	for i, t := range ins {
+		v, err := t.loadFloat32()
+		if err != nil {
+			return err
+		}
+		vals[i] = v
+		if !equalShapes(t.Shape, out.Shape) {
+			strides[i] = broadcastElemStrides(t.Shape, out.Shape)
+			regs[i] = make([]float32, fusedChunk)
+			gather = append(gather, i)
+		}
+	}
+	for r := nl; r < len(regs); r++ {
+		regs[r] = make([]float32, fusedChunk)
+	}
+	res := make([]float32, n)
+	shape := out.Shape
+	idx := make([]int, len(shape))
+	offs := make([]int, nl)
+	for start := 0; start < n; start += fusedChunk {
+		m := min(fusedChunk, n-start)
+		for i := range ins {
+			if strides[i] == nil {
+				regs[i] = vals[i][start : start+m]
+			}
+		}
+		for j := 0; len(gather) > 0 && j < m; j++ {
+			for _, i := range gather {
+				regs[i][j] = vals[i][offs[i]]
+			}
+			for d := len(shape) - 1; d >= 0; d-- {
+				idx[d]++
+				for _, i := range gather {
+					offs[i] += strides[i][d]
+				}
+				if idx[d] < shape[d] {
+					break
+				}
+				for _, i := range gather {
+					offs[i] -= idx[d] * strides[i][d]
+				}
+				idx[d] = 0
+			}
+		}
+		for k, in := range p.insts {
+			node, dst, x := in.node, regs[nl+k][:m], regs[in.a]
+			if node.kind == exprBinary {
+				y := regs[in.b]
+				for j := range dst {
+					dst[j] = node.bin.apply(x[j], y[j])
+				}
+			} else {
+				for j := range dst {
+					dst[j] = node.un.apply(x[j], node.s)
+				}
+			}
+			// The eager ops store intermediates in their dtype; the result is
+			// converted once, to out's.
+			if node.dt != Float32 && nl+k != p.root {
+				if err := roundTo(node.dt, dst); err != nil {
+					return err
+				}
+			}
+		}
+		copy(res[start:start+m], regs[p.root])
+	}
+	return out.storeFloat32(res)
+}
+
+// broadcastElemStrides returns strides that address the packed values of a
+// tensor of shape from at each index of the broadcast shape to, with 0 along
+// broadcast dims.
+func broadcastElemStrides(from, to []int) []int {
+	packed := elemStridesFor(from)
+	strides := make([]int, len(to))
+	lead := len(to) - len(from)
+	for d, n := range from {
+		if n != 1 {
+			strides[lead+d] = packed[d]
+		}
+	}
+	return strides
+}
+
+// roundTo rounds vals in place to dt, as storing and reloading them would.
+func roundTo(dt DType, vals []float32) error {
+	raw, err := encodeFloat32(dt, vals)
+	if err != nil {
+		return err
+	}
+	back, err := decodeFloat32(dt, raw, len(vals))
+	if err != nil {
+		return err
+	}
+	copy(vals, back)
+	return nil
+}
+
+// metalParams builds fused kernel params when every operand and intermediate
+// is float32, out is dense and every stride/offset is element-aligned; ok is
+// false otherwise. views are the leaves broadcast to out's shape.
+func (p *fusedProgram) metalParams(out *Tensor, views []*Tensor) (*metal.FusedParams, bool) {
+	if out.DT != Float32 || !out.isDense() || out.Offset%4 != 0 {
+		return nil, false
+	}
+	if len(out.Shape) > metal.MaxDims || out.Numel() > math.MaxInt32 {
+		return nil, false
+	}
+	if len(views) > metal.MaxFusedInputs || len(p.scalars) > metal.MaxFusedScalars || !aliasSafe(out, views...) {
+		return nil, false
+	}
+	for _, in := range p.insts {
+		if in.node.dt != Float32 {
+			return nil, false
+		}
+	}
+	fp := &metal.FusedParams{N: int32(out.Numel()), Rank: int32(len(out.Shape)), OffOut: int32(out.Offset / 4)}
+	for d, n := range out.Shape {
+		fp.Shape[d] = int32(n)
+	}
+	for i, v := range views {
+		if v.DT != Float32 || v.Offset%4 != 0 {
+			return nil, false
+		}
+		fp.Off[i] = int32(v.Offset / 4)
+		for d, s := range v.Strides {
+			if s%4 != 0 {
+				return nil, false
+			}
+			fp.Stride[i][d] = int32(s / 4)
+		}
+	}
+	copy(fp.Scalar[:], p.scalars)
+	return fp, true
+}
+
+// runMetal compiles p's kernel, once per structure, and runs it. A kernel
+// that fails to compile leaves the work to the CPU.
+func (p *fusedProgram) runMetal(fp *metal.FusedParams, out *Tensor, ins, views []*Tensor) error {
+	name, src := p.metalSource()
+	if err := metal.CompileKernel(name, src); err != nil {
+		return p.runCPU(out, ins)
+	}
+	bufs := []*metal.Buffer{out.buf}
+	for _, v := range views {
+		bufs = append(bufs, v.buf)
+	}
+	return metal.RunKernelN(name, unsafe.Pointer(fp), int(unsafe.Sizeof(*fp)), int(fp.N), bufs...)
+}
+
+// metalSource returns the name and source of a kernel evaluating p over
+// float32 operands, for params laid out as metal.FusedParams. The name is a
+// hash of the source, which depends only on the tree's structure.
+func (p *fusedProgram) metalSource() (name, src string) {
+	var b strings.Builder
+	b.WriteString("(\n")
+	b.WriteString("  device const FusedParams *params [[buffer(0)]],\n")
+	b.WriteString("  device float *out [[buffer(1)]],\n")
+	for i := range p.leaves {
+		fmt.Fprintf(&b, "  device const float *in%d [[buffer(%d)]],\n", i, i+2)
+	}
+	b.WriteString("  uint gid [[thread_position_in_grid]]\n) {\n")
+	b.WriteString("  if (gid >= (uint)params->n) {\n    return;\n  }\n")
+	for i := range p.leaves {
+		fmt.Fprintf(&b, "  int o%d = params->off[%d];\n", i, i)
+	}
+	b.WriteString("  uint idx = gid;\n")
+	b.WriteString("  for (int d = params->rank - 1; d >= 0; --d) {\n")
+	b.WriteString("    uint dim = (uint)params->shape[d];\n")
+	b.WriteString("    int i = (int)(idx % dim);\n")
+	b.WriteString("    idx /= dim;\n")
+	for i := range p.leaves {
+		fmt.Fprintf(&b, "    o%d += i * params->stride[%d][d];\n", i, i)
+	}
+	b.WriteString("  }\n")
+	for i := range p.leaves {
+		fmt.Fprintf(&b, "  float r%d = in%d[o%d];\n", i, i, i)
+	}
+	for k, in := range p.insts {
+		fmt.Fprintf(&b, "  float r%d = %s;\n", len(p.leaves)+k, p.metalExpr(in))
+	}
+	fmt.Fprintf(&b, "  out[params->off_out + gid] = r%d;\n}\n", p.root)
+
+	h := fnv.New64a()
+	h.Write([]byte(b.String()))
+	name = fmt.Sprintf("fused_%016x", h.Sum64())
+	src = fmt.Sprintf(`#include <metal_stdlib>
+using namespace metal;
+
+// One statement per node, never contracted, so every intermediate is rounded
+// to float as the stores between the eager kernels round it.
+#pragma clang fp contract(off)
+
+typedef struct FusedParams {
+  int n;
+  int rank;
+  int off_out;
+  int shape[%d];
+  int off[%d];
+  int stride[%d][%d];
+  float scalar[%d];
+} FusedParams;
+
+kernel void %s%s`, metal.MaxDims, metal.MaxFusedInputs, metal.MaxFusedInputs, metal.MaxDims, metal.MaxFusedScalars, name, b.String())
+	return name, src
+}
+
+// metalExpr returns the Metal expression computing in, mirroring
+// apply_unary in mm.metal.
+func (p *fusedProgram) metalExpr(in fusedInst) string {
+	x, n := fmt.Sprintf("r%d", in.a), in.node
+	if n.kind == exprBinary {
+		return metalBinary(n.bin, x, fmt.Sprintf("r%d", in.b))
+	}
+	switch n.un {
+	case opNeg:
+		return "-" + x
+	case opExp, opLog, opSqrt, opRsqrt:
+		return fmt.Sprintf("%s(%s)", strings.ToLower(n.un.String()), x)
+	default:
+		return metalBinary(binaryOp(n.un-opAddScalar), x, fmt.Sprintf("params->scalar[%d]", in.scalar))
+	}
+}
+
+// metalBinary mirrors apply_binary in mm.metal.
+func metalBinary(op binaryOp, x, y string) string {
+	switch op {
+	case opAdd, opSub, opMul, opDiv:
+		return fmt.Sprintf("%s %c %s", x, "+-*/"[op], y)
+	case opMaximum, opMinimum:
+		fn := "max"
+		if op == opMinimum {
+			fn = "min"
+		}
+		return fmt.Sprintf("(isnan(%s) || isnan(%s)) ? %s + %s : %s(%s, %s)", x, y, x, y, fn, x, y)
+	default:
+		return fmt.Sprintf("pow(%s, %s)", x, y)
+	}
+}
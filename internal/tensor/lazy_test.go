@@ -0,0 +1,309 @@
+package tensor
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// expectBits compares float32 values bit for bit, NaN payloads included.
+func expectBits(t *testing.T, name string, got, want []float32) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %d values, want %d", name, len(got), len(want))
+	}
+	for i := range want {
+		if math.Float32bits(got[i]) != math.Float32bits(want[i]) {
+			t.Fatalf("%s: [%d] = %v (%#08x), want %v (%#08x)", name, i,
+				got[i], math.Float32bits(got[i]), want[i], math.Float32bits(want[i]))
+		}
+	}
+}
+
+func TestLazyMatchesEager(t *testing.T) {
+	x := mustFromFloat32(t, Float32, []float32{
+		1.5, -2.25, 0, 3e-39, 7,
+		-0.1, 1e10, nan32(), inf32(-1), 0.333,
+		4, 2.5, -1, 1e-3, 65504,
+	}, 3, 5)
+	w := mustFromFloat32(t, Float32, []float32{0.5, -1, 2, 3.75, 1e-4}, 5)
+	col := mustFromFloat32(t, Float32, []float32{2, -3, nan32()}, 3, 1)
+	xb := mustFromFloat32(t, BFloat16, []float32{1.01, 2.02, 3.03, -4.04, 5.05}, 5)
+	xh := mustFromFloat32(t, Float16, []float32{0.1, 0.2, 0.3, 0.4, 0.5}, 5)
+	xi := mustFromFloat32(t, Int32, []float32{7, -3, 0, 100, 9}, 5)
+	rng := rand.New(rand.NewSource(3))
+	big := mustFromFloat32(t, Float32, randomFloats(rng, 40*50), 40, 50)
+	bigRow := mustFromFloat32(t, Float32, randomFloats(rng, 50), 50)
+	bigCol := mustFromFloat32(t, Float32, randomFloats(rng, 40), 40, 1)
+	for _, tt := range []*Tensor{x, w, col, xb, xh, xi, big, bigRow, bigCol} {
+		defer tt.Close()
+	}
+	xt, err := x.Transpose(0, 1)
+	if err != nil {
+		t.Fatalf("Transpose: %v", err)
+	}
+	defer xt.Close()
+
+	// ev unwraps an eager result, closing it when the test ends.
+	ev := func(out *Tensor, err error) *Tensor {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("eager: %v", err)
+		}
+		t.Cleanup(func() { _ = out.Close() })
+		return out
+	}
+	const eps = 1e-6
+	cases := []struct {
+		name  string
+		eager func() *Tensor
+		lazy  func() *Expr
+	}{
+		{
+			"rmsnorm",
+			func() *Tensor {
+				ms := ev(Mean(ev(Mul(x, x)), true, -1))
+				inv := ev(Rsqrt(ev(AddScalar(ms, eps))))
+				return ev(Mul(ev(Mul(x, inv)), w))
+			},
+			func() *Expr {
+				lx := Lazy(x)
+				inv := lx.Mul(lx).Mean(true, -1).AddScalar(eps).Rsqrt()
+				return lx.Mul(inv).Mul(Lazy(w))
+			},
+		},
+		{
+			"binary broadcast",
+			func() *Tensor {
+				y := ev(Sub(ev(Add(x, w)), col))
+				y = ev(Div(ev(Mul(y, x)), w))
+				return ev(Minimum(ev(Maximum(y, col)), x))
+			},
+			func() *Expr {
+				lx, lw, lc := Lazy(x), Lazy(w), Lazy(col)
+				return lx.Add(lw).Sub(lc).Mul(lx).Div(lw).Maximum(lc).Minimum(lx)
+			},
+		},
+		{
+			"unary and pow",
+			func() *Tensor {
+				y := ev(Add(ev(Exp(ev(Neg(x)))), ev(Log(x))))
+				y = ev(Sub(y, ev(Sqrt(x))))
+				return ev(Pow(ev(Rsqrt(y)), w))
+			},
+			func() *Expr {
+				lx := Lazy(x)
+				return lx.Neg().Exp().Add(lx.Log()).Sub(lx.Sqrt()).Rsqrt().Pow(Lazy(w))
+			},
+		},
+		{
+			"scalars",
+			func() *Tensor {
+				y := ev(MulScalar(ev(AddScalar(x, 0.1)), 3))
+				y = ev(DivScalar(ev(SubScalar(y, 7)), 0.7))
+				y = ev(MinimumScalar(ev(MaximumScalar(y, -5)), 1e9))
+				return ev(PowScalar(y, 1.5))
+			},
+			func() *Expr {
+				return Lazy(x).AddScalar(0.1).MulScalar(3).SubScalar(7).DivScalar(0.7).
+					MaximumScalar(-5).MinimumScalar(1e9).PowScalar(1.5)
+			},
+		},
+		{
+			"transposed view",
+			func() *Tensor { return ev(Sum(ev(Mul(ev(Add(xt, xt)), xt)), false, 1)) },
+			func() *Expr { l := Lazy(xt); return l.Add(l).Mul(l).Sum(false, 1) },
+		},
+		{
+			"reduced dtypes",
+			func() *Tensor {
+				y := ev(Mul(ev(Add(xb, xb)), xb)) // rounded to bfloat16 twice
+				y = ev(Add(y, ev(Mul(xh, xh))))
+				return ev(Add(ev(DivScalar(ev(AddScalar(xi, 1)), 3)), y))
+			},
+			func() *Expr {
+				lb, lh, li := Lazy(xb), Lazy(xh), Lazy(xi)
+				y := lb.Add(lb).Mul(lb).Add(lh.Mul(lh))
+				return li.AddScalar(1).DivScalar(3).Add(y)
+			},
+		},
+		{
+			"many chunks",
+			func() *Tensor {
+				y := ev(Mul(ev(Add(big, bigRow)), bigCol))
+				return ev(Sub(ev(Exp(y)), ev(Max(big, true, 0))))
+			},
+			func() *Expr {
+				lx := Lazy(big)
+				return lx.Add(Lazy(bigRow)).Mul(Lazy(bigCol)).Exp().Sub(lx.Max(true, 0))
+			},
+		},
+	}
+	for _, c := range cases {
+		want := c.eager()
+		e := c.lazy()
+		got, err := e.Realize()
+		if err != nil {
+			t.Fatalf("%s: Realize: %v", c.name, err)
+		}
+		expectShape(t, c.name, got, want.Shape...)
+		if got.DT != want.DT || e.DType() != want.DT {
+			t.Fatalf("%s: dtype %v (expr %v), want %v", c.name, got.DT, e.DType(), want.DT)
+		}
+		expectBits(t, c.name, mustLoad(t, got), mustLoad(t, want))
+		_ = got.Close()
+	}
+}
+
+// A chain of elementwise ops realizes with its output as the only buffer,
+// where the eager chain allocates one per op.
+func TestLazyAllocatesOnlyTheOutput(t *testing.T) {
+	x := mustFromFloat32(t, Float32, []float32{1, 2, 3, 4, 5, 6}, 2, 3)
+	b := mustFromFloat32(t, Float32, []float32{1, -1, 0.5}, 3)
+	defer x.Close()
+	defer b.Close()
+	e := Lazy(x).Add(Lazy(b)).MulScalar(0.5).Exp().Mul(Lazy(x)).Neg()
+	before := buffersAllocated.Load()
+	y, err := e.Realize()
+	if err != nil {
+		t.Fatalf("Realize: %v", err)
+	}
+	defer y.Close()
+	if n := buffersAllocated.Load() - before; n != 1 {
+		t.Fatalf("Realize allocated %d buffers, want 1", n)
+	}
+
+	// Realizing again reads the tensors' current values.
+	if err := MulScalarInPlace(b, 0); err != nil {
+		t.Fatalf("MulScalarInPlace: %v", err)
+	}
+	before = buffersAllocated.Load()
+	if err := e.RealizeInto(y); err != nil {
+		t.Fatalf("RealizeInto: %v", err)
+	}
+	if n := buffersAllocated.Load() - before; n != 0 {
+		t.Fatalf("RealizeInto allocated %d buffers, want 0", n)
+	}
+	want := make([]float32, 6)
+	for i := range want {
+		v := float32(i + 1)
+		want[i] = -(float32(math.Exp(float64(v*0.5))) * v)
+	}
+	expectBits(t, "after update", mustLoad(t, y), want)
+}
+
+func TestLazyRealizeInto(t *testing.T) {
+	m := mustFromFloat32(t, Float32, []float32{1, 2, 3, 4, 5, 6, 7, 8, 9}, 3, 3)
+	defer m.Close()
+	mt, err := m.Transpose(0, 1)
+	if err != nil {
+		t.Fatalf("Transpose: %v", err)
+	}
+	defer mt.Close()
+	// Written over m while reading its transpose, as AddInPlace allows.
+	if err := Lazy(m).Add(Lazy(mt)).MulScalar(2).RealizeInto(m); err != nil {
+		t.Fatalf("RealizeInto m: %v", err)
+	}
+	expectValues(t, "in place", mustLoad(t, m), []float32{4, 12, 20, 12, 20, 28, 20, 28, 36})
+
+	// The result is converted to out's dtype once, like AddInto's store.
+	h := mustFromFloat32(t, Float16, make([]float32, 3), 3)
+	defer h.Close()
+	row, err := m.Row(0)
+	if err != nil {
+		t.Fatalf("Row: %v", err)
+	}
+	defer row.Close()
+	if err := Lazy(row).DivScalar(3).RealizeInto(h); err != nil {
+		t.Fatalf("RealizeInto float16: %v", err)
+	}
+	want := mustFromFloat32(t, Float16, []float32{4.0 / 3, 4, 20.0 / 3}, 3)
+	defer want.Close()
+	expectBits(t, "float16 out", mustLoad(t, h), mustLoad(t, want))
+}
+
+func TestLazyErrors(t *testing.T) {
+	x := mustFromFloat32(t, Float32, []float32{1, 2, 3, 4}, 2, 2)
+	v := mustFromFloat32(t, Float32, []float32{1, 2, 3}, 3)
+	empty := mustFromFloat32(t, Float32, nil, 0, 2)
+	closed := mustFromFloat32(t, Float32, []float32{1, 2}, 2)
+	defer x.Close()
+	defer v.Close()
+	defer empty.Close()
+	_ = closed.Close()
+	later := mustFromFloat32(t, Float32, []float32{1, 2}, 2)
+	e := Lazy(x).Add(Lazy(later))
+	_ = later.Close()
+
+	cases := map[string]func() error{
+		"broadcast":         func() error { _, err := Lazy(x).Mul(Lazy(v)).Exp().Realize(); return err },
+		"reduce dim":        func() error { _, err := Lazy(x).Sum(false, 2).Realize(); return err },
+		"nil operand":       func() error { _, err := Lazy(x).Add(nil).Realize(); return err },
+		"nil out":           func() error { return Lazy(x).RealizeInto(nil) },
+		"out shape":         func() error { return Lazy(x).Neg().RealizeInto(v) },
+		"closed out":        func() error { return Lazy(v).RealizeInto(closed) },
+		"closed leaf":       func() error { _, err := Lazy(closed).Neg().Realize(); return err },
+		"closed after Lazy": func() error { _, err := e.Realize(); return err },
+		"closed under Mean": func() error { _, err := Lazy(closed).Mean(false).Realize(); return err },
+		"empty Max":         func() error { _, err := Lazy(x).MulScalar(2).Add(Lazy(empty).Max(false, 0)).Realize(); return err },
+	}
+	for name, run := range cases {
+		if err := run(); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if _, err := e.Realize(); !errors.Is(err, ErrClosed) {
+		t.Errorf("closed leaf: got %v, want ErrClosed", err)
+	}
+	if s := Lazy(x).Add(Lazy(v)); s.Shape() != nil {
+		t.Errorf("failed expression has shape %v", s.Shape())
+	}
+}
+
+func TestLazyMetalSource(t *testing.T) {
+	x := mustFromFloat32(t, Float32, []float32{1, 2, 3, 4, 5, 6}, 2, 3)
+	w := mustFromFloat32(t, Float32, []float32{1, 2, 3}, 3)
+	defer x.Close()
+	defer w.Close()
+	rmsnorm := func(eps float32) *fusedProgram {
+		inv := Lazy(x).Mul(Lazy(x)).Mean(true, -1).AddScalar(eps).Rsqrt()
+		return compileExpr(Lazy(x).Mul(inv).Mul(Lazy(w)))
+	}
+	p := rmsnorm(1e-6)
+	// x (read once), the mean and w; eps is a param.
+	if len(p.leaves) != 3 || len(p.scalars) != 1 {
+		t.Fatalf("%d leaves and %d scalars, want 3 and 1", len(p.leaves), len(p.scalars))
+	}
+	name, src := p.metalSource()
+	for _, want := range []string{
+		"kernel void " + name + "(",
+		"device const float *in2 [[buffer(4)]]",
+		"float r3 = r1 + params->scalar[0];",
+		"float r4 = rsqrt(r3);",
+		"float r5 = r0 * r4;",
+		"out[params->off_out + gid] = r6;",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("source lacks %q:\n%s", want, src)
+		}
+	}
+	if strings.Contains(src, "in3") {
+		t.Errorf("source binds more than 3 inputs:\n%s", src)
+	}
+	if other, _ := rmsnorm(1e-5).metalSource(); other != name {
+		t.Errorf("kernel name depends on eps: %s and %s", name, other)
+	}
+	if name == mustName(compileExpr(Lazy(x).Maximum(Lazy(w)))) {
+		t.Errorf("different trees share kernel %s", name)
+	}
+	if mustName(compileExpr(Lazy(x).Maximum(Lazy(w)))) == mustName(compileExpr(Lazy(x).Minimum(Lazy(w)))) {
+		t.Errorf("Maximum and Minimum share a kernel")
+	}
+}
+
+func mustName(p *fusedProgram) string {
+	name, _ := p.metalSource()
+	return name
+}
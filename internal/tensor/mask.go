@@ -0,0 +1,262 @@
+package tensor
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"kylesmith19091/fastgo/internal/metal"
+)
+
+// ---------- Masking ----------
+//
+// Masks are Bool tensors. The builders below mark positions that must NOT be
+// attended with true, so MaskedFill(scores, mask, -Inf) applies them directly
+// before Softmax. Masks combine with Where: Where(m1, m1, m2) is m1 OR m2.
+
+// Where returns cond ? a : b elementwise. cond must be Bool; the three
+// operands broadcast together and the result dtype is the promotion of a and
+// b (see Add). Integer operands are selected exactly.
+func Where(cond, a, b *Tensor) (*Tensor, error) {
+	return where(opName("Where"), nil, cond, a, b, nil)
+}
+
+// WhereInto writes Where(cond, a, b) into out. out may be b itself.
+func WhereInto(out, cond, a, b *Tensor) error {
+	if out == nil {
+		return errors.New("Where: nil output tensor")
+	}
+	_, err := where(opName("Where"), out, cond, a, b, nil)
+	return err
+}
+
+// MaskedFill returns a copy of t with value written wherever mask (Bool,
+// broadcast against t) is true. value is converted to t's dtype.
+func MaskedFill(t, mask *Tensor, value float32) (*Tensor, error) {
+	return maskedFill(nil, t, mask, value)
+}
+
+// MaskedFillInto writes MaskedFill(t, mask, value) into out; pass out == t to
+// fill in place. value is staged in a one-element tensor, the only buffer it
+// allocates.
+func MaskedFillInto(out, t, mask *Tensor, value float32) error {
+	if out == nil {
+		return errors.New("MaskedFill: nil output tensor")
+	}
+	_, err := maskedFill(out, t, mask, value)
+	return err
+}
+
+// MaskedFillInPlace writes value into t wherever mask is true.
+func MaskedFillInPlace(t, mask *Tensor, value float32) error {
+	return MaskedFillInto(t, t, mask, value)
+}
+
+// CausalMask returns a [qLen, kLen] mask for queries at absolute positions
+// offset, ..., offset+qLen-1 over keys at positions 0, ..., kLen-1. Entry
+// [i, j] is true when key j comes after query i. During decoding with a KV
+// cache offset is the number of cached tokens, kLen-qLen.
+func CausalMask(qLen, kLen, offset int) (*Tensor, error) {
+	return buildMask("CausalMask", qLen, kLen, offset, 0)
+}
+
+// SlidingWindowMask is CausalMask that also hides keys more than window-1
+// positions before the query, so each query sees at most window keys
+// including its own position.
+func SlidingWindowMask(qLen, kLen, offset, window int) (*Tensor, error) {
+	if window <= 0 {
+		return nil, fmt.Errorf("SlidingWindowMask: window must be positive, got %d", window)
+	}
+	return buildMask("SlidingWindowMask", qLen, kLen, offset, window)
+}
+
+// PaddingMask returns a [batch, 1, kLen] mask for a padded batch, which
+// broadcasts against [batch, qLen, kLen] scores (Reshape it to
+// [batch, 1, 1, kLen] for per-head scores). Sequence b occupies key positions
+// starts[b], ..., starts[b]+lengths[b]-1 and everything else is true. starts
+// may be nil for right padding; left padding uses starts[b] = kLen-lengths[b].
+func PaddingMask(kLen int, starts, lengths []int) (*Tensor, error) {
+	if starts != nil && len(starts) != len(lengths) {
+		return nil, fmt.Errorf("PaddingMask: %d starts for %d lengths", len(starts), len(lengths))
+	}
+	if kLen < 0 {
+		return nil, fmt.Errorf("PaddingMask: negative kLen %d", kLen)
+	}
+	raw := make([]byte, len(lengths)*kLen)
+	for b, n := range lengths {
+		start := 0
+		if starts != nil {
+			start = starts[b]
+		}
+		if start < 0 || n < 0 || start+n > kLen {
+			return nil, fmt.Errorf("PaddingMask: sequence %d spans [%d, %d), outside [0, %d)", b, start, start+n, kLen)
+		}
+		row := raw[b*kLen : (b+1)*kLen]
+		for j := range row {
+			if j < start || j >= start+n {
+				row[j] = 1
+			}
+		}
+	}
+	return fromBoolBytes(raw, len(lengths), 1, kLen)
+}
+
+func buildMask(op string, qLen, kLen, offset, window int) (*Tensor, error) {
+	if qLen < 0 || kLen < 0 || offset < 0 {
+		return nil, fmt.Errorf("%s: invalid sizes qLen=%d kLen=%d offset=%d", op, qLen, kLen, offset)
+	}
+	raw := make([]byte, qLen*kLen)
+	for i := 0; i < qLen; i++ {
+		pos := offset + i
+		for j := 0; j < kLen; j++ {
+			if j > pos || (window > 0 && j <= pos-window) {
+				raw[i*kLen+j] = 1
+			}
+		}
+	}
+	return fromBoolBytes(raw, qLen, kLen)
+}
+
+// fromBoolBytes uploads 0/1 bytes into a new Bool tensor.
+func fromBoolBytes(raw []byte, shape ...int) (*Tensor, error) {
+	t, err := New(Bool, shape...)
+	if err != nil || t.Device() == Meta {
+		return t, err
+	}
+	if err := t.buf.Write(raw); err != nil {
+		_ = t.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+func maskedFill(out, t, mask *Tensor, value float32) (*Tensor, error) {
+	if err := t.live(); err != nil {
+		return nil, fmt.Errorf("MaskedFill: %w", err)
+	}
+	fill, err := NewOn(t.Device(), t.DT)
+	if err != nil {
+		return nil, fmt.Errorf("MaskedFill: %w", err)
+	}
+	defer fill.Close()
+	if fill.Device() != Meta {
+		if err := fill.storeFloat32([]float32{value}); err != nil {
+			return nil, fmt.Errorf("MaskedFill: %w", err)
+		}
+	}
+	return where(opName("MaskedFill"), out, mask, fill, t, func(out *Tensor) error {
+		return checkOutputWith(out, []*Tensor{t, mask}, []*Tensor{fill})
+	})
+}
+
+// where runs Where for op. check, if set, replaces the debug check of the
+// result against cond, a and b, for callers whose operands differ.
+func where(op opName, out, cond, a, b *Tensor, check func(out *Tensor) error) (*Tensor, error) {
+	for _, x := range []*Tensor{cond, a, b} {
+		if err := x.live(); err != nil {
+			return nil, fmt.Errorf("%v: %w", op, err)
+		}
+	}
+	if cond.DT != Bool {
+		return nil, fmt.Errorf("%v: condition dtype %v, want bool", op, cond.DT)
+	}
+	dev, err := sameDevice(cond, a, b)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", op, err)
+	}
+	shape, err := BroadcastShapes(cond.Shape, a.Shape, b.Shape)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", op, err)
+	}
+	out, owned, err := prepareOut(op, out, dev, promoteTypes(a.DT, b.DT), shape)
+	if err != nil || dev == Meta {
+		return out, err
+	}
+	fail := func(err error) (*Tensor, error) {
+		if owned {
+			_ = out.Close()
+		}
+		return nil, fmt.Errorf("%v: %w", op, err)
+	}
+	var views [3]*Tensor
+	for i, x := range []*Tensor{cond, a, b} {
+		if views[i], err = x.broadcastTo(shape); err != nil {
+			return fail(err)
+		}
+	}
+	bc, ba, bb := views[0], views[1], views[2]
+	if p, ok := whereParams(out, bc, ba, bb); ok && useMetal(out, bc, ba, bb) {
+		if err = copyView(out, bb); err == nil {
+			err = metal.WhereBuffers(p, bc.buf, ba.buf, out.buf)
+		}
+	} else {
+		err = whereCPU(out, bc, ba, bb)
+	}
+	if err == nil {
+		if check != nil {
+			err = check(out)
+		} else {
+			err = checkOutput(out, cond, a, b)
+		}
+	}
+	if err != nil {
+		return fail(err)
+	}
+	return out, nil
+}
+
+func whereCPU(out, cond, a, b *Tensor) error {
+	c, err := cond.packedBytes()
+	if err != nil {
+		return err
+	}
+	if isIntDType(a.DT) && isIntDType(b.DT) && isIntDType(out.DT) {
+		x, err := a.loadInt64()
+		if err != nil {
+			return err
+		}
+		y, err := b.loadInt64()
+		if err != nil {
+			return err
+		}
+		for i := range y {
+			if c[i] != 0 {
+				y[i] = x[i]
+			}
+		}
+		return out.storeInt64(y)
+	}
+	x, err := a.loadFloat32()
+	if err != nil {
+		return err
+	}
+	y, err := b.loadFloat32()
+	if err != nil {
+		return err
+	}
+	for i := range y {
+		if c[i] != 0 {
+			y[i] = x[i]
+		}
+	}
+	return out.storeFloat32(y)
+}
+
+// whereParams builds where_f32 params for float32 operands. out is filled
+// from b before the kernel reads cond and a, so out must not overlap them and
+// may overlap b only as the very same view.
+func whereParams(out, cond, a, b *Tensor) (*metal.ElementwiseParams, bool) {
+	if b.DT != Float32 || cond.buf.Size() > math.MaxInt32 || !disjoint(out, cond, a) || !aliasSafe(out, b) {
+		return nil, false
+	}
+	p, ok := elementwiseParams(out, a, a)
+	if !ok {
+		return nil, false
+	}
+	p.OffB, p.StrideB = p.OffA, p.StrideA
+	p.OffA = int32(cond.Offset)
+	for d := range cond.Shape {
+		p.StrideA[d] = int32(cond.Strides[d])
+	}
+	return p, true
+}
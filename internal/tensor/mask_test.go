@@ -0,0 +1,204 @@
+package tensor
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// maskRows renders a Bool mask as rows of '1' (masked) and '.' (visible).
+func maskRows(t *testing.T, m *Tensor) []string {
+	t.Helper()
+	if m.DT != Bool {
+		t.Fatalf("mask dtype %v, want bool", m.DT)
+	}
+	vals := mustLoad(t, m)
+	n := m.Shape[len(m.Shape)-1]
+	var rows []string
+	for i := 0; i < len(vals); i += n {
+		var sb strings.Builder
+		for _, v := range vals[i : i+n] {
+			if v != 0 {
+				sb.WriteByte('1')
+			} else {
+				sb.WriteByte('.')
+			}
+		}
+		rows = append(rows, sb.String())
+	}
+	return rows
+}
+
+func expectMask(t *testing.T, name string, m *Tensor, want ...string) {
+	t.Helper()
+	got := maskRows(t, m)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("%s:\ngot\n%s\nwant\n%s", name, strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestMaskBuilders(t *testing.T) {
+	prefill, err := CausalMask(4, 4, 0)
+	if err != nil {
+		t.Fatalf("CausalMask: %v", err)
+	}
+	defer prefill.Close()
+	expectMask(t, "causal prefill", prefill,
+		".111",
+		"..11",
+		"...1",
+		"....",
+	)
+
+	// Two new tokens after three cached ones.
+	decode, err := CausalMask(2, 5, 3)
+	if err != nil {
+		t.Fatalf("CausalMask: %v", err)
+	}
+	defer decode.Close()
+	expectMask(t, "causal with cache", decode,
+		"....1",
+		".....",
+	)
+
+	sw, err := SlidingWindowMask(5, 5, 0, 2)
+	if err != nil {
+		t.Fatalf("SlidingWindowMask: %v", err)
+	}
+	defer sw.Close()
+	expectMask(t, "sliding window", sw,
+		".1111",
+		"..111",
+		"1..11",
+		"11..1",
+		"111..",
+	)
+
+	pad, err := PaddingMask(4, []int{0, 1, 2}, []int{4, 3, 1})
+	if err != nil {
+		t.Fatalf("PaddingMask: %v", err)
+	}
+	defer pad.Close()
+	if !equalShapes(pad.Shape, []int{3, 1, 4}) {
+		t.Fatalf("PaddingMask shape %v", pad.Shape)
+	}
+	expectMask(t, "padding", pad,
+		"....",
+		"1...",
+		"11.1",
+	)
+
+	right, err := PaddingMask(3, nil, []int{2, 3})
+	if err != nil {
+		t.Fatalf("PaddingMask: %v", err)
+	}
+	defer right.Close()
+	expectMask(t, "right padding", right, "..1", "...")
+}
+
+func TestWhereAndMaskedFill(t *testing.T) {
+	cond := mustFromFloat32(t, Bool, []float32{1, 0, 0, 1}, 2, 2)
+	a := mustFromFloat32(t, Float32, []float32{1, 2, 3, 4}, 2, 2)
+	b := mustFromFloat32(t, Float16, []float32{-1}, 1)
+	w, err := Where(cond, a, b)
+	if err != nil {
+		t.Fatalf("Where: %v", err)
+	}
+	defer w.Close()
+	if w.DT != Float32 {
+		t.Fatalf("Where dtype %v", w.DT)
+	}
+	expectValues(t, "Where", mustLoad(t, w), []float32{1, -1, -1, 4})
+
+	// Int64 values beyond float32 precision are selected exactly.
+	big := mustFromFloat32(t, Int64, []float32{0, 0}, 2)
+	if err := big.storeInt64([]int64{1<<53 + 1, 7}); err != nil {
+		t.Fatalf("storeInt64: %v", err)
+	}
+	zero := mustFromFloat32(t, Int32, []float32{0}, 1)
+	row := mustFromFloat32(t, Bool, []float32{0, 1}, 2)
+	wi, err := Where(row, zero, big)
+	if err != nil {
+		t.Fatalf("Where int: %v", err)
+	}
+	defer wi.Close()
+	if got, _ := wi.loadInt64(); wi.DT != Int64 || got[0] != 1<<53+1 || got[1] != 0 {
+		t.Fatalf("Where int: %v %v", wi.DT, got)
+	}
+
+	// Causal masking of [heads=2, 3, 3] scores, then softmax.
+	scores := mustFromFloat32(t, Float32, []float32{
+		1, 2, 3, 4, 5, 6, 7, 8, 9,
+		9, 8, 7, 6, 5, 4, 3, 2, 1,
+	}, 2, 3, 3)
+	causal, err := CausalMask(3, 3, 0)
+	if err != nil {
+		t.Fatalf("CausalMask: %v", err)
+	}
+	defer causal.Close()
+	inf := float32(math.Inf(-1))
+	masked, err := MaskedFill(scores, causal, inf)
+	if err != nil {
+		t.Fatalf("MaskedFill: %v", err)
+	}
+	defer masked.Close()
+	expectValues(t, "MaskedFill", mustLoad(t, masked), []float32{
+		1, inf, inf, 4, 5, inf, 7, 8, 9,
+		9, inf, inf, 6, 5, inf, 3, 2, 1,
+	})
+	probs, err := Softmax(masked, -1)
+	if err != nil {
+		t.Fatalf("Softmax: %v", err)
+	}
+	defer probs.Close()
+	if p, _ := probs.At(0, 0, 1); p != 0 {
+		t.Fatalf("masked probability %v", p)
+	}
+	if p, _ := probs.At(1, 0, 0); p != 1 {
+		t.Fatalf("first-row probability %v", p)
+	}
+
+	// In place on bf16 through a padding mask.
+	h := mustFromFloat32(t, BFloat16, []float32{1, 2, 3, 4, 5, 6}, 2, 1, 3)
+	pad, err := PaddingMask(3, nil, []int{3, 1})
+	if err != nil {
+		t.Fatalf("PaddingMask: %v", err)
+	}
+	defer pad.Close()
+	if err := MaskedFillInto(h, h, pad, 0); err != nil {
+		t.Fatalf("MaskedFillInto: %v", err)
+	}
+	expectValues(t, "MaskedFillInto", mustLoad(t, h), []float32{1, 2, 3, 4, 0, 0})
+
+	// OR of two masks.
+	m1 := mustFromFloat32(t, Bool, []float32{1, 0, 0}, 3)
+	m2 := mustFromFloat32(t, Bool, []float32{0, 0, 1}, 3)
+	or, err := Where(m1, m1, m2)
+	if err != nil {
+		t.Fatalf("Where bool: %v", err)
+	}
+	defer or.Close()
+	expectMask(t, "or", or, "1.1")
+}
+
+func TestMaskErrors(t *testing.T) {
+	x := mustFromFloat32(t, Float32, make([]float32, 6), 2, 3)
+	notBool := mustFromFloat32(t, Int8, []float32{1, 0, 1}, 3)
+	wide := mustFromFloat32(t, Bool, make([]float32, 4), 4)
+	cases := []struct {
+		name, want string
+		fn         func() error
+	}{
+		{"cond dtype", "condition dtype int8", func() error { _, err := Where(notBool, x, x); return err }},
+		{"broadcast", "MaskedFill", func() error { _, err := MaskedFill(x, wide, 0); return err }},
+		{"window", "window must be positive", func() error { _, err := SlidingWindowMask(2, 2, 0, 0); return err }},
+		{"padding span", "sequence 0 spans [2, 5)", func() error { _, err := PaddingMask(4, []int{2}, []int{3}); return err }},
+		{"causal sizes", "invalid sizes", func() error { _, err := CausalMask(-1, 2, 0); return err }},
+	}
+	for _, c := range cases {
+		err := c.fn()
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Fatalf("%s: got %v, want error containing %q", c.name, err, c.want)
+		}
+	}
+}
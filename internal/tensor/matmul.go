@@ -0,0 +1,269 @@
+package tensor
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+
+	"kylesmith19091/fastgo/internal/metal"
+)
+
+// ---------- Matrix multiplication ----------
+
+// MatMul multiplies a [..., M, K] by b [..., K, N] into [..., M, N] with
+// NumPy semantics: batch dims broadcast, and a 1-D a (or b) is treated as a
+// [1, K] row (or [K, 1] column) whose unit dim is dropped from the result, so
+// two vectors give a scalar. An empty contraction (K = 0) yields zeros.
+//
+// Operands may be arbitrary strided views, so a transposed weight such as
+// w.Transpose(-2, -1) of an HF [out, in] matrix is used without copying.
+// Products accumulate in float32 and are stored in the promoted dtype of a
+// and b (see elementwise ops); two integer operands accumulate exactly and
+// produce Int32, saturating on overflow. Float32 operands run on Metal when a
+// library is ready: contiguous same-batch inputs use
+// matrix_multiply_batched_naive, everything else matmul_strided_f32.
+func MatMul(a, b *Tensor) (*Tensor, error) {
+	return matmul(nil, a, b)
+}
+
+// MatMulInto writes MatMul(a, b) into out, which must have the result's shape
+// and dtype and must not overlap a or b.
+func MatMulInto(out, a, b *Tensor) error {
+	if out == nil {
+		return errors.New("MatMul: nil output tensor")
+	}
+	_, err := matmul(out, a, b)
+	return err
+}
+
+// matmulShapes holds the broadcast problem description for MatMul.
+type matmulShapes struct {
+	batch    []int // broadcast batch shape
+	m, k, n  int
+	outShape []int
+}
+
+func matmul(out, a, b *Tensor) (*Tensor, error) {
+	if err := cmp.Or(a.live(), b.live()); err != nil {
+		return nil, fmt.Errorf("MatMul: %w", err)
+	}
+	for _, t := range []*Tensor{a, b} {
+		if is4Bit(t.DT) || t.DT == Float8E8M0 {
+			return nil, fmt.Errorf("MatMul: unsupported dtype %v; cast with To first", t.DT)
+		}
+		if len(t.Shape) == 0 {
+			return nil, errors.New("MatMul: operands must have rank >= 1")
+		}
+	}
+	dev, err := sameDevice(a, b)
+	if err != nil {
+		return nil, fmt.Errorf("MatMul: %w", err)
+	}
+	av, bv, sh, err := matmulOperands(a, b)
+	if err != nil {
+		return nil, err
+	}
+	dt := promoteTypes(a.DT, b.DT)
+	if isIntDType(dt) {
+		dt = Int32
+	}
+	out, owned, err := prepareOutExact(opName("MatMul"), out, dev, dt, sh.outShape)
+	if err != nil {
+		return nil, err
+	}
+	if !owned && !disjoint(out, a, b) {
+		return nil, errors.New("MatMul: output overlaps an operand")
+	}
+	if dev == Meta {
+		return out, nil
+	}
+	// out differs from [batch..., M, N] only by dropped unit dims, so both
+	// paths can fill it in row-major order.
+	if useMetal(out, av, bv) && out.DT == Float32 && av.DT == Float32 && bv.DT == Float32 && out.isDense() && sh.k > 0 && out.Numel() > 0 {
+		err = matmulMetal(out, av, bv, sh)
+	} else {
+		err = matmulCPU(out, av, bv, sh)
+	}
+	if err == nil {
+		err = checkOutput(out, a, b)
+	}
+	if err != nil {
+		if owned {
+			_ = out.Close()
+		}
+		return nil, fmt.Errorf("MatMul: %w", err)
+	}
+	return out, nil
+}
+
+// opName adapts a fixed op name to prepareOut.
+type opName string
+
+func (s opName) String() string { return string(s) }
+
+// matmulOperands promotes 1-D operands to matrices, checks the contraction
+// dim and broadcasts both operands' batch dims to a common shape.
+func matmulOperands(a, b *Tensor) (*Tensor, *Tensor, matmulShapes, error) {
+	var sh matmulShapes
+	av, bv := a, b
+	var err error
+	if len(a.Shape) == 1 {
+		if av, err = a.view(a.Offset, []int{1, a.Shape[0]}, []int{0, a.Strides[0]}); err != nil {
+			return nil, nil, sh, err
+		}
+	}
+	if len(b.Shape) == 1 {
+		if bv, err = b.view(b.Offset, []int{b.Shape[0], 1}, []int{b.Strides[0], 0}); err != nil {
+			return nil, nil, sh, err
+		}
+	}
+	ra, rb := len(av.Shape), len(bv.Shape)
+	sh.m, sh.k, sh.n = av.Shape[ra-2], av.Shape[ra-1], bv.Shape[rb-1]
+	if bv.Shape[rb-2] != sh.k {
+		return nil, nil, sh, fmt.Errorf("MatMul: inner dims differ: %v x %v", a.Shape, b.Shape)
+	}
+	sh.batch, err = BroadcastShapes(av.Shape[:ra-2], bv.Shape[:rb-2])
+	if err != nil {
+		return nil, nil, sh, fmt.Errorf("MatMul: batch dims of %v and %v: %w", a.Shape, b.Shape, err)
+	}
+	if av, err = av.broadcastTo(append(append([]int(nil), sh.batch...), sh.m, sh.k)); err != nil {
+		return nil, nil, sh, err
+	}
+	if bv, err = bv.broadcastTo(append(append([]int(nil), sh.batch...), sh.k, sh.n)); err != nil {
+		return nil, nil, sh, err
+	}
+	sh.outShape = append([]int(nil), sh.batch...)
+	if len(a.Shape) > 1 {
+		sh.outShape = append(sh.outShape, sh.m)
+	}
+	if len(b.Shape) > 1 {
+		sh.outShape = append(sh.outShape, sh.n)
+	}
+	return av, bv, sh, nil
+}
+
+func matmulCPU(out, a, b *Tensor, sh matmulShapes) error {
+	m, k, n := sh.m, sh.k, sh.n
+	batch := Numel(sh.batch)
+	// Batch offsets (in elements) of each operand's packed matrices; broadcast
+	// batch dims have stride 0 and so reuse the same matrix.
+	aOffs := make([]int, batch)
+	bOffs := make([]int, batch)
+	nb := len(sh.batch)
+	forEachOffset(sh.batch, packedBatchStrides(a, nb), func(i, off int) { aOffs[i] = off })
+	forEachOffset(sh.batch, packedBatchStrides(b, nb), func(i, off int) { bOffs[i] = off })
+
+	if isIntDType(a.DT) && isIntDType(b.DT) {
+		x, err := unbroadcast(a, nb).loadInt64()
+		if err != nil {
+			return err
+		}
+		y, err := unbroadcast(b, nb).loadInt64()
+		if err != nil {
+			return err
+		}
+		res := make([]int64, batch*m*n)
+		for bi := 0; bi < batch; bi++ {
+			matmulKernel(res[bi*m*n:(bi+1)*m*n], x[aOffs[bi]:], y[bOffs[bi]:], m, k, n)
+		}
+		for i, v := range res {
+			res[i] = max(min(v, math.MaxInt32), math.MinInt32)
+		}
+		return out.storeInt64(res)
+	}
+	x, err := unbroadcast(a, nb).loadFloat32()
+	if err != nil {
+		return err
+	}
+	y, err := unbroadcast(b, nb).loadFloat32()
+	if err != nil {
+		return err
+	}
+	res := make([]float32, batch*m*n)
+	for bi := 0; bi < batch; bi++ {
+		matmulKernel(res[bi*m*n:(bi+1)*m*n], x[aOffs[bi]:], y[bOffs[bi]:], m, k, n)
+	}
+	return out.storeFloat32(res)
+}
+
+// matmulKernel accumulates row-major x[m,k] . y[k,n] into c[m,n], walking y
+// row by row so the inner loop is contiguous.
+func matmulKernel[T float32 | int64](c, x, y []T, m, k, n int) {
+	for i := 0; i < m; i++ {
+		row := c[i*n : (i+1)*n]
+		for kk := 0; kk < k; kk++ {
+			av := x[i*k+kk]
+			yr := y[kk*n : (kk+1)*n]
+			for j := range row {
+				row[j] += av * yr[j]
+			}
+		}
+	}
+}
+
+// unbroadcast undoes the stride-0 batch dims added by BroadcastTo so the
+// operand's data is loaded once; size-1 dims take their place.
+func unbroadcast(t *Tensor, nb int) *Tensor {
+	shape := append([]int(nil), t.Shape...)
+	for d := 0; d < nb; d++ {
+		if t.Strides[d] == 0 {
+			shape[d] = 1
+		}
+	}
+	return &Tensor{DT: t.DT, Shape: shape, Strides: t.Strides, Offset: t.Offset, buf: t.buf}
+}
+
+// packedBatchStrides returns the element strides of the leading nb batch dims
+// of the packed (unbroadcast) operand, with 0 for broadcast dims.
+func packedBatchStrides(t *Tensor, nb int) []int {
+	u := unbroadcast(t, nb)
+	strides := elemStridesFor(u.Shape)[:nb]
+	for d := range strides {
+		if t.Strides[d] == 0 {
+			strides[d] = 0
+		}
+	}
+	return strides
+}
+
+func matmulMetal(out, a, b *Tensor, sh matmulShapes) error {
+	batch := Numel(sh.batch)
+	if a.Contiguous() && b.Contiguous() && out.Contiguous() {
+		return metal.MatMulBatchedBuffers(a.buf, b.buf, out.buf, batch, sh.m, sh.k, sh.n)
+	}
+	if len(sh.batch) > metal.MaxDims || out.Numel() > math.MaxInt32 {
+		return matmulCPU(out, a, b, sh)
+	}
+	for _, t := range []*Tensor{a, b, out} {
+		if t.Offset%4 != 0 {
+			return matmulCPU(out, a, b, sh)
+		}
+		for _, s := range t.Strides {
+			if s%4 != 0 {
+				return matmulCPU(out, a, b, sh)
+			}
+		}
+	}
+	nb := len(sh.batch)
+	p := &metal.MatMulStridedParams{
+		Batch:     int32(batch),
+		M:         int32(sh.m),
+		K:         int32(sh.k),
+		N:         int32(sh.n),
+		OffA:      int32(a.Offset / 4),
+		OffB:      int32(b.Offset / 4),
+		OffC:      int32(out.Offset / 4),
+		ARow:      int32(a.Strides[nb] / 4),
+		ACol:      int32(a.Strides[nb+1] / 4),
+		BRow:      int32(b.Strides[nb] / 4),
+		BCol:      int32(b.Strides[nb+1] / 4),
+		BatchRank: int32(nb),
+	}
+	for d := 0; d < nb; d++ {
+		p.BatchShape[d] = int32(sh.batch[d])
+		p.ABatchStride[d] = int32(a.Strides[d] / 4)
+		p.BBatchStride[d] = int32(b.Strides[d] / 4)
+	}
+	return metal.MatMulStridedBuffers(p, a.buf, b.buf, out.buf)
+}
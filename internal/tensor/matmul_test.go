@@ -0,0 +1,203 @@
+package tensor
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// batchedMatMulCPU is the reference from examples/batched_mm: dense
+// [B,M,K] x [B,K,N] -> [B,M,N] in float32.
+func batchedMatMulCPU(batches, rows, inner, cols int, a, b []float32) []float32 {
+	c := make([]float32, batches*rows*cols)
+	batchStrideA := rows * inner
+	batchStrideB := inner * cols
+	batchStrideC := rows * cols
+
+	for batch := 0; batch < batches; batch++ {
+		aBatch := a[batch*batchStrideA : (batch+1)*batchStrideA]
+		bBatch := b[batch*batchStrideB : (batch+1)*batchStrideB]
+		cBatch := c[batch*batchStrideC : (batch+1)*batchStrideC]
+
+		for r := 0; r < rows; r++ {
+			rowOffset := r * inner
+			for cIdx := 0; cIdx < cols; cIdx++ {
+				sum := float32(0)
+				for k := 0; k < inner; k++ {
+					sum += aBatch[rowOffset+k] * bBatch[k*cols+cIdx]
+				}
+				cBatch[r*cols+cIdx] = sum
+			}
+		}
+	}
+
+	return c
+}
+
+func randomFloats(rng *rand.Rand, n int) []float32 {
+	out := make([]float32, n)
+	for i := range out {
+		out[i] = rng.Float32()*2 - 1
+	}
+	return out
+}
+
+func TestMatMulBatchedMatchesReference(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	const batch, m, k, n = 2, 3, 4, 5
+	ha, hb := randomFloats(rng, batch*m*k), randomFloats(rng, batch*k*n)
+	a := mustFromFloat32(t, Float32, ha, batch, m, k)
+	b := mustFromFloat32(t, Float32, hb, batch, k, n)
+	c, err := MatMul(a, b)
+	if err != nil {
+		t.Fatalf("MatMul: %v", err)
+	}
+	defer c.Close()
+	if c.DT != Float32 || !equalShapes(c.Shape, []int{batch, m, n}) {
+		t.Fatalf("MatMul: got %v %v", c.DT, c.Shape)
+	}
+	expectClose(t, "MatMul", mustLoad(t, c), batchedMatMulCPU(batch, m, k, n, ha, hb), 1e-6)
+}
+
+func TestMatMulBroadcastAndTransposedWeight(t *testing.T) {
+	rng := rand.New(rand.NewSource(11))
+	const batch, m, k, n = 3, 2, 6, 4
+	hx := randomFloats(rng, batch*m*k)
+	hw := randomFloats(rng, n*k) // HF layout [out=n, in=k]
+	x := mustFromFloat32(t, Float32, hx, batch, m, k)
+	w := mustFromFloat32(t, Float32, hw, n, k)
+	wt, err := w.Transpose(-2, -1)
+	if err != nil {
+		t.Fatalf("Transpose: %v", err)
+	}
+	if wt.Buffer() != w.Buffer() || wt.Contiguous() {
+		t.Fatalf("Transpose should be a strided view of the same buffer")
+	}
+	y, err := MatMul(x, wt)
+	if err != nil {
+		t.Fatalf("MatMul: %v", err)
+	}
+	defer y.Close()
+	if !equalShapes(y.Shape, []int{batch, m, n}) {
+		t.Fatalf("MatMul shape %v", y.Shape)
+	}
+	// Oracle: materialise w^T once per batch.
+	hwt := make([]float32, 0, batch*k*n)
+	for bi := 0; bi < batch; bi++ {
+		for kk := 0; kk < k; kk++ {
+			for j := 0; j < n; j++ {
+				hwt = append(hwt, hw[j*k+kk])
+			}
+		}
+	}
+	expectClose(t, "MatMul x.W^T", mustLoad(t, y), batchedMatMulCPU(batch, m, k, n, hx, hwt), 1e-6)
+
+	// [2,1,M,K] x [3,K,N] broadcasts to [2,3,M,N].
+	a4 := mustFromFloat32(t, Float32, randomFloats(rng, 2*m*k), 2, 1, m, k)
+	b3 := mustFromFloat32(t, Float32, randomFloats(rng, 3*k*n), 3, k, n)
+	c, err := MatMul(a4, b3)
+	if err != nil {
+		t.Fatalf("MatMul broadcast: %v", err)
+	}
+	defer c.Close()
+	if !equalShapes(c.Shape, []int{2, 3, m, n}) {
+		t.Fatalf("MatMul broadcast shape %v", c.Shape)
+	}
+	ha, hb := mustLoad(t, a4), mustLoad(t, b3)
+	var rep, repB []float32
+	for i := 0; i < 2; i++ {
+		for j := 0; j < 3; j++ {
+			rep = append(rep, ha[i*m*k:(i+1)*m*k]...)
+			repB = append(repB, hb[j*k*n:(j+1)*k*n]...)
+		}
+	}
+	expectClose(t, "MatMul broadcast", mustLoad(t, c), batchedMatMulCPU(6, m, k, n, rep, repB), 1e-6)
+}
+
+// Strided operands reach the caller through DownloadFloat32, so it must read
+// views, not the buffer from byte 0.
+func TestDownloadFloat32Views(t *testing.T) {
+	for _, dt := range []DType{Float32, Float16, Int8} {
+		x := mustFromFloat32(t, dt, []float32{1, 2, 3, 4, 5, 6}, 2, 3)
+		xt, err := x.Transpose(0, 1)
+		if err != nil {
+			t.Fatalf("Transpose: %v", err)
+		}
+		row, err := x.Select(0, 1)
+		if err != nil {
+			t.Fatalf("Select: %v", err)
+		}
+		for _, tc := range []struct {
+			name string
+			v    *Tensor
+			want []float32
+		}{
+			{"transposed", xt, []float32{1, 4, 2, 5, 3, 6}},
+			{"offset", row, []float32{4, 5, 6}},
+		} {
+			got := make([]float32, len(tc.want))
+			if err := tc.v.DownloadFloat32(got); err != nil {
+				t.Fatalf("%v %s DownloadFloat32: %v", dt, tc.name, err)
+			}
+			expectValues(t, dt.String()+" "+tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestMatMulVectorsAndMixedDTypes(t *testing.T) {
+	v := mustFromFloat32(t, Float16, []float32{1, 2, 3}, 3)
+	mat := mustFromFloat32(t, Float32, []float32{1, 0, 0, 1, 1, 1}, 3, 2)
+	vm, err := MatMul(v, mat) // [3] x [3,2] -> [2]
+	if err != nil {
+		t.Fatalf("MatMul vec-mat: %v", err)
+	}
+	defer vm.Close()
+	if vm.DT != Float32 || !equalShapes(vm.Shape, []int{2}) {
+		t.Fatalf("vec-mat: got %v %v", vm.DT, vm.Shape)
+	}
+	expectValues(t, "vec-mat", mustLoad(t, vm), []float32{4, 5})
+
+	dot, err := MatMul(v, v)
+	if err != nil {
+		t.Fatalf("MatMul dot: %v", err)
+	}
+	defer dot.Close()
+	if dot.DT != Float16 {
+		t.Fatalf("dot dtype %v", dot.DT)
+	}
+	expectValues(t, "dot", mustLoad(t, dot), []float32{14})
+
+	bf := mustFromFloat32(t, BFloat16, []float32{1, 2, 3, 4}, 2, 2)
+	i8 := mustFromFloat32(t, Int8, []float32{1, -1, 2, 0}, 2, 2)
+	mixed, err := MatMul(bf, i8)
+	if err != nil {
+		t.Fatalf("MatMul bf16 x int8: %v", err)
+	}
+	defer mixed.Close()
+	if mixed.DT != BFloat16 {
+		t.Fatalf("bf16 x int8 dtype %v", mixed.DT)
+	}
+	expectValues(t, "bf16 x int8", mustLoad(t, mixed), []float32{5, -1, 11, -3})
+
+	big := mustFromFloat32(t, Int8, []float32{127, 127, 127, 127}, 2, 2)
+	ii, err := MatMul(big, big)
+	if err != nil {
+		t.Fatalf("MatMul int8: %v", err)
+	}
+	defer ii.Close()
+	if got, _ := ii.loadInt64(); ii.DT != Int32 || got[0] != 2*127*127 {
+		t.Fatalf("int8 x int8: got %v %v", ii.DT, got)
+	}
+}
+
+func TestMatMulErrors(t *testing.T) {
+	a := mustFromFloat32(t, Float32, make([]float32, 6), 2, 3)
+	b := mustFromFloat32(t, Float32, make([]float32, 8), 2, 4)
+	if _, err := MatMul(a, b); err == nil {
+		t.Fatalf("expected inner dim error")
+	}
+	a3 := mustFromFloat32(t, Float32, make([]float32, 12), 2, 2, 3)
+	b3 := mustFromFloat32(t, Float32, make([]float32, 18), 3, 3, 2)
+	if _, err := MatMul(a3, b3); err == nil {
+		t.Fatalf("expected batch broadcast error")
+	}
+}
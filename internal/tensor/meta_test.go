@@ -0,0 +1,277 @@
+package tensor
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+
+	"kylesmith19091/fastgo/internal/metal"
+)
+
+// onMeta runs fn with Meta as the default device.
+func onMeta(t *testing.T, fn func()) {
+	t.Helper()
+	prev := SetDefaultDevice(Meta)
+	defer SetDefaultDevice(prev)
+	fn()
+}
+
+// metaBlock traces a small attention block on whatever device its inputs
+// are on: embedding lookup, projections, masked softmax and a residual.
+func metaBlock(ids, emb, wq, wk, wv *Tensor) (*Tensor, error) {
+	s := NewScope()
+	defer s.Close()
+	x, err := s.Op(IndexSelect(emb, 0, ids)) // [S, D]
+	if err != nil {
+		return nil, err
+	}
+	q, err := s.Op(MatMul(x, wq))
+	if err != nil {
+		return nil, err
+	}
+	k, err := s.Op(MatMul(x, wk))
+	if err != nil {
+		return nil, err
+	}
+	v, err := s.Op(MatMul(x, wv))
+	if err != nil {
+		return nil, err
+	}
+	kt, err := s.Op(k.Transpose(0, 1))
+	if err != nil {
+		return nil, err
+	}
+	scores, err := s.Op(MatMul(q, kt)) // [S, S]
+	if err != nil {
+		return nil, err
+	}
+	mask, err := s.Op(CausalMask(x.Shape[0], x.Shape[0], 0))
+	if err != nil {
+		return nil, err
+	}
+	if mask, err = s.Op(mask.ToDevice(x.Device())); err != nil {
+		return nil, err
+	}
+	if err := MaskedFillInPlace(scores, mask, -1e9); err != nil {
+		return nil, err
+	}
+	probs, err := s.Op(Softmax(scores, -1))
+	if err != nil {
+		return nil, err
+	}
+	attn, err := s.Op(MatMul(probs, v))
+	if err != nil {
+		return nil, err
+	}
+	return Lazy(attn).Add(Lazy(x)).MulScalar(0.5).Realize()
+}
+
+func TestMetaForward(t *testing.T) {
+	const vocab, dim, seq = 16, 8, 5
+	var emb, wq, wk, wv, ids *Tensor
+	onMeta(t, func() {
+		rng := rand.New(rand.NewSource(1))
+		var err error
+		if emb, err = Random(rng, Normal(0, 1), Float32, vocab, dim); err != nil {
+			t.Fatalf("Random: %v", err)
+		}
+		for _, w := range []**Tensor{&wq, &wk, &wv} {
+			if *w, err = New(Float32, dim, dim); err != nil {
+				t.Fatalf("New: %v", err)
+			}
+		}
+		if ids, err = FromFloat32(Int32, []float32{1, 2, 3, 4, 5}, seq); err != nil {
+			t.Fatalf("FromFloat32: %v", err)
+		}
+	})
+	defer func() {
+		for _, w := range []*Tensor{emb, wq, wk, wv, ids} {
+			_ = w.Close()
+		}
+	}()
+	for _, w := range []*Tensor{emb, wq, wk, wv, ids} {
+		if w.Device() != Meta {
+			t.Fatalf("tensor built under SetDefaultDevice(Meta) is on %v", w.Device())
+		}
+	}
+	if DefaultDevice() != Metal {
+		t.Fatalf("default device not restored: %v", DefaultDevice())
+	}
+
+	before := buffersAllocated.Load()
+	live0, _ := Memory(Meta)
+	ResetPeakMemory(Meta)
+	out, err := metaBlock(ids, emb, wq, wk, wv)
+	if err != nil {
+		t.Fatalf("forward on meta: %v", err)
+	}
+	defer out.Close()
+	if out.Device() != Meta {
+		t.Fatalf("forward result on %v, want meta", out.Device())
+	}
+	expectShape(t, "forward", out, seq, dim)
+	live, peak := Memory(Meta)
+	if want := live0 + seq*dim*4; live != want {
+		t.Errorf("live meta bytes after the block = %d, want %d", live, want)
+	}
+	// x, q, k, v, attn and out are [S, D] float32, the scores and probs
+	// [S, S] float32 and the mask [S, S] bool, all alive at the last op.
+	act := 6*seq*dim*4 + 2*seq*seq*4 + seq*seq
+	if peak-live0 != int64(act) {
+		t.Errorf("peak activation bytes = %d, want %d", peak-live0, act)
+	}
+	if buffersAllocated.Load() == before {
+		t.Error("meta ops allocated no tensors")
+	}
+
+	// The same trace catches shape bugs before anything is allocated.
+	var bad *Tensor
+	onMeta(t, func() { bad, err = New(Float32, dim+1, dim) })
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer bad.Close()
+	if _, err := metaBlock(ids, emb, bad, wk, wv); err == nil {
+		t.Error("mismatched projection traced without error")
+	}
+
+	if _, err := out.At(0, 0); !errors.Is(err, metal.ErrNoStorage) {
+		t.Errorf("At on meta: got %v, want ErrNoStorage", err)
+	}
+	if err := out.DownloadFloat32(make([]float32, seq*dim)); !errors.Is(err, metal.ErrNoStorage) {
+		t.Errorf("DownloadFloat32 on meta: got %v, want ErrNoStorage", err)
+	}
+	if got, want := out.String(), "<Tensor float32[5 8] on meta>"; got != want {
+		t.Errorf("String = %q, want %q", got, want)
+	}
+	h := mustFromFloat32(t, Float32, make([]float32, seq*dim), seq, dim)
+	defer h.Close()
+	if _, err := Add(out, h); !errors.Is(err, ErrDeviceMismatch) {
+		t.Errorf("Add(meta, metal): got %v, want ErrDeviceMismatch", err)
+	}
+}
+
+func TestMetaOps(t *testing.T) {
+	var x, idx *Tensor
+	onMeta(t, func() {
+		var err error
+		if x, err = New(Float16, 2, 3, 4); err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		if idx, err = New(Int64, 2, 3, 1); err != nil {
+			t.Fatalf("New: %v", err)
+		}
+	})
+	defer x.Close()
+	defer idx.Close()
+	s := NewScope()
+	defer s.Close()
+	for _, tc := range []struct {
+		name  string
+		fn    func() (*Tensor, error)
+		shape []int
+		dt    DType
+	}{
+		{"To", func() (*Tensor, error) { return x.To(Int8) }, []int{2, 3, 4}, Int8},
+		{"Sum", func() (*Tensor, error) { return Sum(x, false, 1) }, []int{2, 4}, Float16},
+		{"ArgMax", func() (*Tensor, error) { return ArgMax(x, -1, true, Int32) }, []int{2, 3, 1}, Int32},
+		{"Concat", func() (*Tensor, error) { return Concat(2, x, x) }, []int{2, 3, 8}, Float16},
+		{"Stack", func() (*Tensor, error) { return Stack(0, x, x) }, []int{2, 2, 3, 4}, Float16},
+		{"Gather", func() (*Tensor, error) { return Gather(x, 2, idx) }, []int{2, 3, 1}, Float16},
+		{"Where", func() (*Tensor, error) {
+			c, err := s.Op(x.To(Bool))
+			if err != nil {
+				return nil, err
+			}
+			return Where(c, x, x)
+		}, []int{2, 3, 4}, Float16},
+		{"TopK", func() (*Tensor, error) { v, _, err := TopK(x, -1, 2); return v, err }, []int{2, 3, 2}, Float16},
+		{"ArgSort", func() (*Tensor, error) { return ArgSort(x, 0, false) }, []int{2, 3, 4}, Int64},
+		{"CumSum", func() (*Tensor, error) { return CumSum(x, 1) }, []int{2, 3, 4}, Float16},
+		{"LogSoftmax", func() (*Tensor, error) { return LogSoftmax(x, 1) }, []int{2, 3, 4}, Float16},
+		{"ToDevice", func() (*Tensor, error) {
+			m := mustFromFloat32(t, Float32, []float32{1, 2}, 2)
+			s.Track(m)
+			return m.ToDevice(Meta)
+		}, []int{2}, Float32},
+	} {
+		out, err := s.Op(tc.fn())
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if out.Device() != Meta || out.DT != tc.dt || !equalShapes(out.Shape, tc.shape) {
+			t.Errorf("%s: %v %v%v, want meta %v%v", tc.name, out.Device(), out.DT, out.Shape, tc.dt, tc.shape)
+		}
+	}
+	if err := ScatterInto(x, 2, idx, x); err != nil {
+		t.Errorf("ScatterInto on meta: %v", err)
+	}
+	if _, err := x.ToDevice(Host); err == nil {
+		t.Error("ToDevice(host) of a meta tensor: expected an error")
+	}
+	if _, err := Sum(x, false, 3); err == nil {
+		t.Error("Sum over a missing dim on meta: expected an error")
+	}
+}
+
+func TestMaterialize(t *testing.T) {
+	var w, b *Tensor
+	onMeta(t, func() {
+		var err error
+		if w, err = New(Float32, 2, 3); err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		if b, err = New(Float32, 3); err != nil {
+			t.Fatalf("New: %v", err)
+		}
+	})
+	defer w.Close()
+	defer b.Close()
+	meta0, _ := Memory(Meta)
+
+	loaded := mustFromFloat32(t, Float32, []float32{1, 2, 3, 4, 5, 6}, 3, 2)
+	if err := w.MaterializeFrom(loaded); err == nil {
+		t.Fatal("MaterializeFrom with a transposed shape: expected an error")
+	}
+	lt, err := loaded.Transpose(0, 1)
+	if err != nil {
+		t.Fatalf("Transpose: %v", err)
+	}
+	if err := w.MaterializeFrom(lt); err != nil {
+		t.Fatalf("MaterializeFrom: %v", err)
+	}
+	_ = lt.Close()
+	_ = loaded.Close()
+	if w.Device() != Metal {
+		t.Fatalf("materialized weight on %v", w.Device())
+	}
+	expectValues(t, "materialized weight", mustLoad(t, w), []float32{1, 3, 5, 2, 4, 6})
+	if live, _ := Memory(Meta); live != meta0-2*3*4 {
+		t.Errorf("meta bytes after MaterializeFrom = %d, want %d", live, meta0-2*3*4)
+	}
+
+	row, err := b.Reshape(1, 3)
+	if err != nil {
+		t.Fatalf("Reshape: %v", err)
+	}
+	if err := b.Materialize(Host); err == nil {
+		t.Error("Materialize with a live view: expected an error")
+	}
+	_ = row.Close()
+	if err := b.Materialize(Host); err != nil {
+		t.Fatalf("Materialize: %v", err)
+	}
+	if d, err := Data[float32](b); err != nil {
+		t.Fatalf("Data: %v", err)
+	} else {
+		expectValues(t, "materialized bias", d, []float32{0, 0, 0})
+	}
+	if err := b.Materialize(Host); err == nil {
+		t.Error("Materialize of a host tensor: expected an error")
+	}
+	y, err := Add(w, b)
+	if !errors.Is(err, ErrDeviceMismatch) {
+		t.Errorf("Add(metal, host) after materializing: got %v, %v", y, err)
+	}
+}
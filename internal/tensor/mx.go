@@ -0,0 +1,375 @@
+package tensor
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ---------- OCP Microscaling (MX) block formats ----------
+//
+// An MX tensor stores its elements in a narrow float format plus one shared
+// Float8E8M0 scale per block of MXBlockSize consecutive elements along the
+// last dimension. The decoded value of element i is scale[i/32] * elem[i].
+
+// MXBlockSize is the number of elements sharing one scale.
+const MXBlockSize = 32
+
+// MXFormat identifies the element encoding of an MX tensor.
+type MXFormat int
+
+const (
+	MXFP4     MXFormat = iota // Float4E2M1 elements
+	MXFP8E4M3                 // Float8E4M3 elements
+	MXFP8E5M2                 // Float8E5M2 elements
+)
+
+func (f MXFormat) String() string {
+	switch f {
+	case MXFP4:
+		return "mxfp4"
+	case MXFP8E4M3:
+		return "mxfp8_e4m3"
+	case MXFP8E5M2:
+		return "mxfp8_e5m2"
+	default:
+		return "unknown"
+	}
+}
+
+// ElemDType returns the dtype used for the packed elements.
+func (f MXFormat) ElemDType() DType {
+	switch f {
+	case MXFP4:
+		return Float4E2M1
+	case MXFP8E4M3:
+		return Float8E4M3
+	case MXFP8E5M2:
+		return Float8E5M2
+	default:
+		return -1
+	}
+}
+
+// emax is the exponent of the largest power of two representable by the
+// element format, used to derive the shared block exponent.
+func (f MXFormat) emax() int {
+	switch f {
+	case MXFP4:
+		return 2 // 6 = 1.5 * 2^2
+	case MXFP8E4M3:
+		return 8 // 448 = 1.75 * 2^8
+	default:
+		return 15 // 57344 = 1.75 * 2^15
+	}
+}
+
+// MXBytesFor returns the bytes needed for numel elements plus their block scales.
+func MXBytesFor(f MXFormat, numel int) int {
+	blocks := (numel + MXBlockSize - 1) / MXBlockSize
+	return BytesFor(f.ElemDType(), numel) + BytesFor(Float8E8M0, blocks)
+}
+
+// EncodeMX quantizes src into packed elements and per-block E8M0 scales.
+// len(src) must be a multiple of MXBlockSize. The shared exponent of a block is
+// floor(log2(amax)) - emax(elem), clamped to the E8M0 range, and elements are
+// converted with round-to-nearest-even and saturation. Blocks holding NaN or
+// Inf get a NaN scale, so every element of that block decodes to NaN.
+func EncodeMX(f MXFormat, src []float32) (elems []byte, scales []byte, err error) {
+	if f.ElemDType() < 0 {
+		return nil, nil, errors.New("unknown MX format")
+	}
+	if len(src)%MXBlockSize != 0 {
+		return nil, nil, fmt.Errorf("MX encode needs a multiple of %d elements, got %d", MXBlockSize, len(src))
+	}
+	nblocks := len(src) / MXBlockSize
+	scales = make([]byte, nblocks)
+	scaled := make([]float32, len(src))
+	for b := 0; b < nblocks; b++ {
+		block := src[b*MXBlockSize : (b+1)*MXBlockSize]
+		amax := 0.0
+		finite := true
+		for _, v := range block {
+			a := math.Abs(float64(v))
+			if math.IsNaN(a) || math.IsInf(a, 0) {
+				finite = false
+				break
+			}
+			if a > amax {
+				amax = a
+			}
+		}
+		if !finite {
+			scales[b] = 0xFF
+			continue
+		}
+		sharedExp := -127
+		if amax > 0 {
+			_, e := math.Frexp(amax) // amax = frac * 2^e, frac in [0.5, 1)
+			sharedExp = e - 1 - f.emax()
+		}
+		sharedExp = min(max(sharedExp, -127), 127)
+		scales[b] = uint8(sharedExp + 127)
+		for i, v := range block {
+			scaled[b*MXBlockSize+i] = float32(math.Ldexp(float64(v), -sharedExp))
+		}
+	}
+	switch f {
+	case MXFP4:
+		elems = PackFP4E2M1(scaled)
+	case MXFP8E4M3:
+		elems = PackFP8E4M3(scaled, true)
+	case MXFP8E5M2:
+		elems = PackFP8E5M2(scaled, true)
+	}
+	return elems, scales, nil
+}
+
+// DecodeMX expands packed elements and block scales into dst.
+func DecodeMX(f MXFormat, elems []byte, scales []byte, dst []float32) error {
+	if len(dst)%MXBlockSize != 0 {
+		return fmt.Errorf("MX decode needs a multiple of %d elements, got %d", MXBlockSize, len(dst))
+	}
+	if len(scales) < len(dst)/MXBlockSize {
+		return errors.New("MX decode: too few scales")
+	}
+	if len(elems) < BytesFor(f.ElemDType(), len(dst)) {
+		return errors.New("MX decode: too few element bytes")
+	}
+	for i := range dst {
+		var v float32
+		switch f {
+		case MXFP4:
+			nibble := elems[i/2] >> (4 * uint(i%2)) & 0x0F
+			v = fp4E2M1Table[nibble]
+		case MXFP8E4M3:
+			v = fp8E4M3Table[elems[i]]
+		case MXFP8E5M2:
+			v = fp8E5M2Table[elems[i]]
+		default:
+			return errors.New("unknown MX format")
+		}
+		dst[i] = v * e8m0ToFloat32(scales[i/MXBlockSize])
+	}
+	return nil
+}
+
+// MXTensor is a block-scaled tensor: Elems holds the packed elements with the
+// logical shape and Scales holds one Float8E8M0 per block, shaped like Elems
+// with the last dimension divided by MXBlockSize.
+type MXTensor struct {
+	Format MXFormat
+	Shape  []int
+	Elems  *Tensor
+	Scales *Tensor
+}
+
+// mxScaleShape validates an MX shape and returns the matching scale shape.
+func mxScaleShape(shape []int) ([]int, error) {
+	if !IsValidShape(shape) || len(shape) == 0 {
+		return nil, errors.New("invalid shape")
+	}
+	last := shape[len(shape)-1]
+	if last%MXBlockSize != 0 {
+		return nil, fmt.Errorf("MX last dim %d is not a multiple of %d", last, MXBlockSize)
+	}
+	scaleShape := append([]int(nil), shape...)
+	scaleShape[len(scaleShape)-1] = last / MXBlockSize
+	return scaleShape, nil
+}
+
+// NewMX allocates element and scale buffers for an MX tensor.
+func NewMX(f MXFormat, shape ...int) (*MXTensor, error) {
+	if f.ElemDType() < 0 {
+		return nil, errors.New("unknown MX format")
+	}
+	scaleShape, err := mxScaleShape(shape)
+	if err != nil {
+		return nil, err
+	}
+	elems, err := New(f.ElemDType(), shape...)
+	if err != nil {
+		return nil, err
+	}
+	scales, err := New(Float8E8M0, scaleShape...)
+	if err != nil {
+		_ = elems.Close()
+		return nil, err
+	}
+	return &MXTensor{Format: f, Shape: append([]int(nil), shape...), Elems: elems, Scales: scales}, nil
+}
+
+// MXFromFloat32 encodes row-major float32 data and uploads it as an MX tensor.
+func MXFromFloat32(f MXFormat, data []float32, shape ...int) (*MXTensor, error) {
+	m, err := NewMX(f, shape...)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != m.Numel() {
+		_ = m.Close()
+		return nil, errors.New("len(data) mismatch")
+	}
+	elems, scales, err := EncodeMX(f, data)
+	if err != nil {
+		_ = m.Close()
+		return nil, err
+	}
+	if err := m.Elems.buf.Write(elems); err != nil {
+		_ = m.Close()
+		return nil, err
+	}
+	if err := m.Scales.buf.Write(scales); err != nil {
+		_ = m.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *MXTensor) Numel() int { return Numel(m.Shape) }
+
+// ByteSize returns the combined bytes of elements and scales.
+func (m *MXTensor) ByteSize() int { return MXBytesFor(m.Format, m.Numel()) }
+
+// DownloadFloat32 dequantizes the tensor into dst.
+func (m *MXTensor) DownloadFloat32(dst []float32) error {
+	if m == nil || m.Elems == nil || m.Scales == nil {
+		return errors.New("nil tensor")
+	}
+	if len(dst) != m.Numel() {
+		return errors.New("len(dst) mismatch")
+	}
+	elems := make([]byte, m.Elems.ByteSize())
+	if err := m.Elems.buf.Read(elems); err != nil {
+		return err
+	}
+	scales := make([]byte, m.Scales.ByteSize())
+	if err := m.Scales.buf.Read(scales); err != nil {
+		return err
+	}
+	return DecodeMX(m.Format, elems, scales, dst)
+}
+
+// MatMulRef is a reference dequantizing matmul: for x shaped [M,K] and the MX
+// weight m shaped [N,K] (HF [out,in] layout) it returns x·mᵀ as a float32
+// [M,N] tensor. Weights are decoded one row at a time on the host.
+func (m *MXTensor) MatMulRef(x *Tensor) (*Tensor, error) {
+	if m == nil || x == nil {
+		return nil, errors.New("nil tensor")
+	}
+	if len(x.Shape) != 2 || len(m.Shape) != 2 {
+		return nil, errors.New("MX matmul expects 2D operands")
+	}
+	rows, inner, cols := x.Shape[0], x.Shape[1], m.Shape[0]
+	if m.Shape[1] != inner {
+		return nil, fmt.Errorf("incompatible shapes: %v x %vᵀ", x.Shape, m.Shape)
+	}
+	hx, err := x.loadFloat32()
+	if err != nil {
+		return nil, err
+	}
+	elems := make([]byte, m.Elems.ByteSize())
+	if err := m.Elems.buf.Read(elems); err != nil {
+		return nil, err
+	}
+	scales := make([]byte, m.Scales.ByteSize())
+	if err := m.Scales.buf.Read(scales); err != nil {
+		return nil, err
+	}
+	out, err := mxMatMulRef(m.Format, hx, elems, scales, rows, inner, cols)
+	if err != nil {
+		return nil, err
+	}
+	return FromFloat32(Float32, out, rows, cols)
+}
+
+// mxMatMulRef computes x[rows,inner] · w[cols,inner]ᵀ with w in MX encoding,
+// accumulating in float32.
+func mxMatMulRef(f MXFormat, x []float32, elems, scales []byte, rows, inner, cols int) ([]float32, error) {
+	out := make([]float32, rows*cols)
+	wrow := make([]float32, inner)
+	rowBytes := BytesFor(f.ElemDType(), inner)
+	rowScales := inner / MXBlockSize
+	for n := 0; n < cols; n++ {
+		if err := DecodeMX(f, elems[n*rowBytes:(n+1)*rowBytes], scales[n*rowScales:(n+1)*rowScales], wrow); err != nil {
+			return nil, err
+		}
+		for r := 0; r < rows; r++ {
+			xr := x[r*inner : (r+1)*inner]
+			sum := float32(0)
+			for k, w := range wrow {
+				sum += xr[k] * w
+			}
+			out[r*cols+n] = sum
+		}
+	}
+	return out, nil
+}
+
+// Close releases the element and scale buffers.
+func (m *MXTensor) Close() error {
+	if m == nil {
+		return nil
+	}
+	err := m.Elems.Close()
+	if serr := m.Scales.Close(); err == nil {
+		err = serr
+	}
+	return err
+}
+
+// ---------- FP4 (E2M1) and E8M0 conversion ----------
+
+// fp4E2M1Table holds the 16 E2M1 values: ±{0, 0.5, 1, 1.5, 2, 3, 4, 6}.
+var fp4E2M1Table [16]float32
+
+func init() {
+	for c := range fp4E2M1Table {
+		f := decodeMinifloat(uint32(c&0x7), 1, 1)
+		if c&0x8 != 0 {
+			f = -f
+		}
+		fp4E2M1Table[c] = f
+	}
+}
+
+// PackFP4E2M1 converts float32 values to E2M1 codes packed two per byte
+// (element 2i in the low nibble). Rounding is to nearest even and values
+// beyond ±6, including ±Inf, saturate; NaN encodes as zero since E2M1 has
+// no NaN.
+func PackFP4E2M1(src []float32) []uint8 {
+	out := make([]uint8, BytesFor(Float4E2M1, len(src)))
+	for i, f := range src {
+		out[i/2] |= float32ToFP4E2M1Bits(f) << (4 * uint(i%2))
+	}
+	return out
+}
+
+// UnpackFP4E2M1 expands n packed E2M1 values into float32.
+func UnpackFP4E2M1(src []uint8, n int) []float32 {
+	out := make([]float32, n)
+	for i := range out {
+		out[i] = fp4E2M1Table[src[i/2]>>(4*uint(i%2))&0x0F]
+	}
+	return out
+}
+
+func float32ToFP4E2M1Bits(f float32) uint8 {
+	x := math.Float32bits(f)
+	sign := uint8(x>>28) & 0x08
+	if f != f {
+		return 0
+	}
+	q := uint32(7)
+	if x&0x7fffffff != 0x7f800000 {
+		q = min(roundMinifloat(x&0x7fffffff, 1, 1), 7)
+	}
+	return sign | uint8(q)
+}
+
+// e8m0ToFloat32 decodes an E8M0 scale: 2^(b-127), with 0xFF meaning NaN.
+func e8m0ToFloat32(b uint8) float32 {
+	if b == 0xFF {
+		return float32(math.NaN())
+	}
+	return float32(math.Ldexp(1, int(b)-127))
+}
@@ -0,0 +1,176 @@
+package tensor
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestFP4E2M1AllCodes(t *testing.T) {
+	for c := uint8(0); c < 16; c++ {
+		f := UnpackFP4E2M1([]uint8{c}, 1)[0]
+		got := PackFP4E2M1([]float32{f})[0]
+		if got != c {
+			t.Fatalf("code %#x: %v re-encoded as %#x", c, f, got)
+		}
+	}
+	cases := []struct{ in, want float32 }{
+		{0.25, 0}, {0.75, 1}, {1.25, 1}, {1.75, 2}, {2.5, 2}, {3.5, 4}, {5, 4}, {5.5, 6},
+		{7, 6}, {-100, -6}, {float32(math.Inf(1)), 6},
+	}
+	for _, c := range cases {
+		if got := UnpackFP4E2M1(PackFP4E2M1([]float32{c.in}), 1)[0]; got != c.want {
+			t.Fatalf("e2m1(%v)=%v want %v", c.in, got, c.want)
+		}
+	}
+}
+
+func TestMXBytesFor(t *testing.T) {
+	if got := MXBytesFor(MXFP4, 64); got != 32+2 {
+		t.Fatalf("MXBytesFor(mxfp4,64)=%d", got)
+	}
+	if got := MXBytesFor(MXFP8E4M3, 96); got != 96+3 {
+		t.Fatalf("MXBytesFor(mxfp8,96)=%d", got)
+	}
+	if BytesFor(Float4E2M1, 5) != 3 || BytesFor(Float8E8M0, 5) != 5 {
+		t.Fatalf("unexpected BytesFor for MX component dtypes")
+	}
+}
+
+func TestEncodeMXKnownBlock(t *testing.T) {
+	src := make([]float32, MXBlockSize)
+	for i := range src {
+		src[i] = fp4E2M1Table[i%16] * 0.25 // amax 1.5 -> floor(log2)=0
+	}
+	elems, scales, err := EncodeMX(MXFP4, src)
+	if err != nil {
+		t.Fatalf("EncodeMX: %v", err)
+	}
+	// shared exponent = 0 - emax(E2M1)=2 -> scale 2^-2
+	if scales[0] != 127-2 {
+		t.Fatalf("scale code %d want %d", scales[0], 125)
+	}
+	got := make([]float32, len(src))
+	if err := DecodeMX(MXFP4, elems, scales, got); err != nil {
+		t.Fatalf("DecodeMX: %v", err)
+	}
+	for i := range src {
+		if got[i] != src[i] {
+			t.Fatalf("@%d got %v want %v", i, got[i], src[i])
+		}
+	}
+}
+
+func TestEncodeMXErrors(t *testing.T) {
+	if _, _, err := EncodeMX(MXFP8E4M3, make([]float32, 31)); err == nil {
+		t.Fatalf("expected error for partial block")
+	}
+	src := make([]float32, 2*MXBlockSize)
+	src[40] = float32(math.NaN())
+	elems, scales, err := EncodeMX(MXFP8E4M3, src)
+	if err != nil {
+		t.Fatalf("EncodeMX: %v", err)
+	}
+	if scales[0] == 0xFF || scales[1] != 0xFF {
+		t.Fatalf("scales=%v, want only block 1 NaN", scales)
+	}
+	got := make([]float32, len(src))
+	if err := DecodeMX(MXFP8E4M3, elems, scales, got); err != nil {
+		t.Fatalf("DecodeMX: %v", err)
+	}
+	if got[0] != 0 || !math.IsNaN(float64(got[33])) {
+		t.Fatalf("unexpected decode of NaN block: %v %v", got[0], got[33])
+	}
+}
+
+func TestEncodeMXRelativeError(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	src := make([]float32, 8*MXBlockSize)
+	for i := range src {
+		src[i] = float32(rng.NormFloat64()) * float32(math.Pow(10, float64(i/MXBlockSize-4)))
+	}
+	// Worst-case error relative to block amax: the larger of half an element
+	// ulp in the top binade and the saturation gap above the element max.
+	tol := map[MXFormat]float64{MXFP4: 0.25, MXFP8E4M3: 0.125, MXFP8E5M2: 0.125}
+	for f, rel := range tol {
+		elems, scales, err := EncodeMX(f, src)
+		if err != nil {
+			t.Fatalf("%v EncodeMX: %v", f, err)
+		}
+		got := make([]float32, len(src))
+		if err := DecodeMX(f, elems, scales, got); err != nil {
+			t.Fatalf("%v DecodeMX: %v", f, err)
+		}
+		for b := 0; b < len(src)/MXBlockSize; b++ {
+			amax := 0.0
+			for _, v := range src[b*MXBlockSize : (b+1)*MXBlockSize] {
+				amax = math.Max(amax, math.Abs(float64(v)))
+			}
+			for i := b * MXBlockSize; i < (b+1)*MXBlockSize; i++ {
+				if d := math.Abs(float64(got[i] - src[i])); d > rel*amax {
+					t.Fatalf("%v @%d: got %v want %v (amax %v)", f, i, got[i], src[i], amax)
+				}
+			}
+		}
+	}
+}
+
+func TestMXMatMulRef(t *testing.T) {
+	rows, inner, cols := 3, 64, 5
+	rng := rand.New(rand.NewSource(2))
+	x := make([]float32, rows*inner)
+	w := make([]float32, cols*inner)
+	for i := range x {
+		x[i] = float32(rng.NormFloat64())
+	}
+	for i := range w {
+		w[i] = float32(rng.NormFloat64())
+	}
+	elems, scales, err := EncodeMX(MXFP8E4M3, w)
+	if err != nil {
+		t.Fatalf("EncodeMX: %v", err)
+	}
+	deq := make([]float32, len(w))
+	if err := DecodeMX(MXFP8E4M3, elems, scales, deq); err != nil {
+		t.Fatalf("DecodeMX: %v", err)
+	}
+	got, err := mxMatMulRef(MXFP8E4M3, x, elems, scales, rows, inner, cols)
+	if err != nil {
+		t.Fatalf("mxMatMulRef: %v", err)
+	}
+	for r := 0; r < rows; r++ {
+		for n := 0; n < cols; n++ {
+			want := float32(0)
+			for k := 0; k < inner; k++ {
+				want += x[r*inner+k] * deq[n*inner+k]
+			}
+			if got[r*cols+n] != want {
+				t.Fatalf("[%d,%d] got %v want %v", r, n, got[r*cols+n], want)
+			}
+		}
+	}
+
+	// MatMulRef reads x as a view: here the transpose of an [inner,rows]
+	// tensor.
+	xt := make([]float32, len(x))
+	for r := 0; r < rows; r++ {
+		for k := 0; k < inner; k++ {
+			xt[k*rows+r] = x[r*inner+k]
+		}
+	}
+	xv, err := mustFromFloat32(t, Float32, xt, inner, rows).Transpose(0, 1)
+	if err != nil {
+		t.Fatalf("Transpose: %v", err)
+	}
+	m, err := MXFromFloat32(MXFP8E4M3, w, cols, inner)
+	if err != nil {
+		t.Fatalf("MXFromFloat32: %v", err)
+	}
+	defer m.Close()
+	y, err := m.MatMulRef(xv)
+	if err != nil {
+		t.Fatalf("MatMulRef: %v", err)
+	}
+	defer y.Close()
+	expectValues(t, "MatMulRef of a transposed x", mustLoad(t, y), got)
+}
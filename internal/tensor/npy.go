@@ -0,0 +1,379 @@
+package tensor
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ---------- NPY / NPZ ----------
+//
+// LoadNPY and SaveNPY read and write NumPy's .npy format (versions 1.0-3.0
+// are read, 1.0 is written); LoadNPZ and SaveNPZ handle .npz archives of
+// named arrays, stored or deflated. Supported dtypes are float16 ('<f2'),
+// float32 ('<f4'), int8 ('|i1'), int32 ('<i4'), int64 ('<i8') and bool
+// ('|b1'); big-endian files are byte-swapped on load. Fortran-order arrays
+// are transposed into a contiguous row-major tensor on load. Files are
+// always written little-endian in C order, packing strided views.
+//
+// NumPy has no bfloat16. By convention SaveNPY widens BFloat16 tensors to
+// '<f4', which is exact, so `To(BFloat16)` after loading restores them bit
+// for bit. On load, 2-byte void arrays ('|V2'), which is what np.save writes
+// for ml_dtypes.bfloat16 arrays such as JAX dumps, become BFloat16. Other
+// dtypes must be cast before saving.
+
+var npyMagic = []byte("\x93NUMPY")
+
+// npyDescr maps dtypes to the little-endian descr written by SaveNPY.
+func npyDescr(dt DType) (string, error) {
+	switch dt {
+	case Float16:
+		return "<f2", nil
+	case Float32, BFloat16:
+		return "<f4", nil
+	case Int8:
+		return "|i1", nil
+	case Int32:
+		return "<i4", nil
+	case Int64:
+		return "<i8", nil
+	case Bool:
+		return "|b1", nil
+	default:
+		return "", fmt.Errorf("dtype %v has no .npy equivalent; cast first", dt)
+	}
+}
+
+// parseNPYDescr returns the dtype for a descr and whether its data is
+// big-endian.
+func parseNPYDescr(descr string) (DType, bool, error) {
+	if len(descr) < 2 {
+		return 0, false, fmt.Errorf("bad descr %q", descr)
+	}
+	order, kind := descr[0], descr[1:]
+	var big bool
+	switch order {
+	case '<', '|', '=':
+	case '>':
+		big = true
+	default:
+		return 0, false, fmt.Errorf("bad descr %q", descr)
+	}
+	switch kind {
+	case "f2":
+		return Float16, big, nil
+	case "f4":
+		return Float32, big, nil
+	case "i1":
+		return Int8, false, nil
+	case "i4":
+		return Int32, big, nil
+	case "i8":
+		return Int64, big, nil
+	case "b1":
+		return Bool, false, nil
+	case "V2":
+		return BFloat16, false, nil
+	default:
+		return 0, false, fmt.Errorf("unsupported .npy dtype %q", descr)
+	}
+}
+
+// LoadNPY reads a .npy file into a new contiguous tensor.
+func LoadNPY(path string) (*Tensor, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	t, err := ReadNPY(bufio.NewReader(f))
+	if err != nil {
+		return nil, fmt.Errorf("LoadNPY %s: %w", path, err)
+	}
+	return t, nil
+}
+
+// SaveNPY writes t to path in .npy format.
+func SaveNPY(path string, t *Tensor) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	err = WriteNPY(w, t)
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("SaveNPY %s: %w", path, err)
+	}
+	return nil
+}
+
+// ReadNPY decodes one .npy stream.
+func ReadNPY(r io.Reader) (*Tensor, error) {
+	var pre [8]byte
+	if _, err := io.ReadFull(r, pre[:]); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(pre[:6], npyMagic) {
+		return nil, errors.New("not a .npy file")
+	}
+	var hlen int
+	switch pre[6] {
+	case 1:
+		var n uint16
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return nil, err
+		}
+		hlen = int(n)
+	case 2, 3:
+		var n uint32
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return nil, err
+		}
+		hlen = int(n)
+	default:
+		return nil, fmt.Errorf("unsupported .npy version %d.%d", pre[6], pre[7])
+	}
+	header := make([]byte, hlen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	descr, fortran, shape, err := parseNPYHeader(string(header))
+	if err != nil {
+		return nil, err
+	}
+	dt, big, err := parseNPYDescr(descr)
+	if err != nil {
+		return nil, err
+	}
+	if !IsValidShape(shape) {
+		return nil, fmt.Errorf("unsupported shape %v", shape)
+	}
+	raw := make([]byte, BytesFor(dt, Numel(shape)))
+	if _, err := io.ReadFull(r, raw); err != nil {
+		return nil, fmt.Errorf("reading data: %w", err)
+	}
+	if big {
+		size := dt.SizeOf()
+		for i := 0; i < len(raw); i += size {
+			slices.Reverse(raw[i : i+size])
+		}
+	}
+	if DefaultDevice() == Meta {
+		return New(dt, shape...)
+	}
+	// Fortran data is the row-major layout of the reversed shape.
+	fileShape := shape
+	if fortran {
+		fileShape = slices.Clone(shape)
+		slices.Reverse(fileShape)
+	}
+	t, err := New(dt, fileShape...)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.buf.Write(raw); err != nil {
+		_ = t.Close()
+		return nil, err
+	}
+	if !fortran || len(shape) == 1 {
+		return t, nil
+	}
+	defer t.Close()
+	strides := slices.Clone(t.Strides)
+	slices.Reverse(strides)
+	view, err := t.view(0, shape, strides)
+	if err != nil {
+		return nil, err
+	}
+	out, err := New(dt, shape...)
+	if err != nil {
+		return nil, err
+	}
+	if err := copyView(out, view); err != nil {
+		_ = out.Close()
+		return nil, err
+	}
+	return out, nil
+}
+
+// WriteNPY encodes t as a version 1.0 .npy stream in C order.
+func WriteNPY(w io.Writer, t *Tensor) error {
+	if err := t.live(); err != nil {
+		return err
+	}
+	descr, err := npyDescr(t.DT)
+	if err != nil {
+		return err
+	}
+	var raw []byte
+	if t.DT == BFloat16 {
+		vals, err := t.loadFloat32()
+		if err == nil {
+			raw, err = encodeFloat32(Float32, vals)
+		}
+		if err != nil {
+			return err
+		}
+	} else if raw, err = t.packedBytes(); err != nil {
+		return err
+	}
+	dims := make([]string, len(t.Shape))
+	for i, d := range t.Shape {
+		dims[i] = strconv.Itoa(d)
+	}
+	shape := "(" + strings.Join(dims, ", ") + ")"
+	if len(dims) == 1 {
+		shape = "(" + dims[0] + ",)"
+	}
+	header := fmt.Sprintf("{'descr': '%s', 'fortran_order': False, 'shape': %s, }", descr, shape)
+	// Pad with spaces so the data starts on a 64-byte boundary, as NumPy does.
+	const prefix = 10
+	pad := 63 - (prefix+len(header))%64
+	header += strings.Repeat(" ", pad) + "\n"
+	if len(header) > 0xFFFF {
+		return errors.New("header too long for .npy version 1.0")
+	}
+	var pre [prefix]byte
+	copy(pre[:], npyMagic)
+	pre[6], pre[7] = 1, 0
+	binary.LittleEndian.PutUint16(pre[8:], uint16(len(header)))
+	for _, b := range [][]byte{pre[:], []byte(header), raw} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseNPYHeader extracts the fields of the Python dict literal in a .npy
+// header, e.g. {'descr': '<f4', 'fortran_order': False, 'shape': (2, 3), }.
+func parseNPYHeader(h string) (descr string, fortran bool, shape []int, err error) {
+	field := func(key string) (string, bool) {
+		i := strings.Index(h, "'"+key+"'")
+		if i < 0 {
+			return "", false
+		}
+		rest := strings.TrimLeft(h[i+len(key)+2:], " ")
+		if !strings.HasPrefix(rest, ":") {
+			return "", false
+		}
+		return strings.TrimLeft(rest[1:], " "), true
+	}
+	v, ok := field("descr")
+	if !ok || len(v) < 2 || v[0] != '\'' {
+		return "", false, nil, fmt.Errorf("bad .npy header %q: descr", h)
+	}
+	end := strings.IndexByte(v[1:], '\'')
+	if end < 0 {
+		return "", false, nil, fmt.Errorf("bad .npy header %q: descr", h)
+	}
+	descr = v[1 : end+1]
+
+	v, ok = field("fortran_order")
+	switch {
+	case ok && strings.HasPrefix(v, "True"):
+		fortran = true
+	case ok && strings.HasPrefix(v, "False"):
+	default:
+		return "", false, nil, fmt.Errorf("bad .npy header %q: fortran_order", h)
+	}
+
+	v, ok = field("shape")
+	end = strings.IndexByte(v, ')')
+	if !ok || !strings.HasPrefix(v, "(") || end < 0 {
+		return "", false, nil, fmt.Errorf("bad .npy header %q: shape", h)
+	}
+	shape = []int{}
+	for _, s := range strings.Split(v[1:end], ",") {
+		s = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "L"))
+		if s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return "", false, nil, fmt.Errorf("bad .npy header %q: shape", h)
+		}
+		shape = append(shape, n)
+	}
+	return descr, fortran, shape, nil
+}
+
+// LoadNPZ reads every array in a .npz archive, keyed by name without the
+// .npy suffix. On error no tensors are returned.
+func LoadNPZ(path string) (map[string]*Tensor, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	out := make(map[string]*Tensor, len(zr.File))
+	fail := func(err error) (map[string]*Tensor, error) {
+		for _, t := range out {
+			_ = t.Close()
+		}
+		return nil, fmt.Errorf("LoadNPZ %s: %w", path, err)
+	}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			return fail(err)
+		}
+		t, err := ReadNPY(bufio.NewReader(rc))
+		_ = rc.Close()
+		if err != nil {
+			return fail(fmt.Errorf("%s: %w", f.Name, err))
+		}
+		out[strings.TrimSuffix(f.Name, ".npy")] = t
+	}
+	return out, nil
+}
+
+// SaveNPZ writes the named tensors to an uncompressed .npz archive, like
+// np.savez, in sorted name order.
+func SaveNPZ(path string, tensors map[string]*Tensor) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	zw := zip.NewWriter(f)
+	names := make([]string, 0, len(tensors))
+	for name := range tensors {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		var w io.Writer
+		w, err = zw.CreateHeader(&zip.FileHeader{Name: name + ".npy", Method: zip.Store})
+		if err == nil {
+			err = WriteNPY(w, tensors[name])
+		}
+		if err != nil {
+			err = fmt.Errorf("%s: %w", name, err)
+			break
+		}
+	}
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("SaveNPZ %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package tensor
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var npyF32 = []float32{0, 1.5, -2, 3.25, -4, 1e-3}
+
+func mustLoadNPY(t *testing.T, name string) *Tensor {
+	t.Helper()
+	x, err := LoadNPY(filepath.Join("testdata", "npy", name))
+	if err != nil {
+		t.Fatalf("LoadNPY(%s): %v", name, err)
+	}
+	t.Cleanup(func() { _ = x.Close() })
+	return x
+}
+
+func TestLoadNPYFixtures(t *testing.T) {
+	f16 := make([]float32, len(npyF32))
+	copy(f16, UnpackFP16(PackFP16(npyF32)))
+	cases := []struct {
+		file  string
+		dt    DType
+		shape []int
+		want  []float32
+	}{
+		{"f32_2x3.npy", Float32, []int{2, 3}, npyF32},
+		{"f32_fortran_2x3.npy", Float32, []int{2, 3}, npyF32},
+		{"f16_2x3.npy", Float16, []int{2, 3}, f16},
+		{"i8_4.npy", Int8, []int{4}, []float32{-128, -1, 0, 127}},
+		{"i32_2x2.npy", Int32, []int{2, 2}, []float32{-1 << 31, -1, 0, 1<<31 - 1}},
+		{"i32_be_3.npy", Int32, []int{3}, []float32{1, -2, 300}},
+		{"bool_2x2.npy", Bool, []int{2, 2}, []float32{1, 0, 0, 1}},
+		{"bf16_void_3.npy", BFloat16, []int{3}, []float32{1, -2.5, 0.15625}},
+	}
+	for _, c := range cases {
+		x := mustLoadNPY(t, c.file)
+		if x.DT != c.dt || !equalShapes(x.Shape, c.shape) || !x.Contiguous() {
+			t.Fatalf("%s: got %v %v contiguous=%v", c.file, x.DT, x.Shape, x.Contiguous())
+		}
+		expectValues(t, c.file, mustLoad(t, x), c.want)
+	}
+	i64 := mustLoadNPY(t, "i64_3.npy")
+	if got, _ := i64.loadInt64(); !slices.Equal(got, []int64{-1 << 62, 1, 1<<53 + 1}) {
+		t.Fatalf("i64_3.npy: %v", got)
+	}
+}
+
+func TestSaveNPYMatchesNumPy(t *testing.T) {
+	dir := t.TempDir()
+	// Files NumPy writes in C order come back byte for byte.
+	for _, name := range []string{"f32_2x3.npy", "f16_2x3.npy", "i8_4.npy", "i32_2x2.npy", "i64_3.npy", "bool_2x2.npy"} {
+		x := mustLoadNPY(t, name)
+		out := filepath.Join(dir, name)
+		if err := SaveNPY(out, x); err != nil {
+			t.Fatalf("SaveNPY(%s): %v", name, err)
+		}
+		got, _ := os.ReadFile(out)
+		want, _ := os.ReadFile(filepath.Join("testdata", "npy", name))
+		if !bytes.Equal(got, want) {
+			t.Fatalf("%s: saved bytes differ from the NumPy fixture", name)
+		}
+	}
+	// Fortran-order and big-endian input saves as NumPy's C-order equivalent.
+	var buf bytes.Buffer
+	if err := WriteNPY(&buf, mustLoadNPY(t, "f32_fortran_2x3.npy")); err != nil {
+		t.Fatalf("WriteNPY: %v", err)
+	}
+	if want, _ := os.ReadFile(filepath.Join("testdata", "npy", "f32_2x3.npy")); !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("fortran fixture did not save as C order")
+	}
+
+	// A transposed view is packed; bf16 widens to '<f4' exactly.
+	x := mustFromFloat32(t, BFloat16, []float32{1, 2, 3, 4.5, 5, 6}, 2, 3)
+	tr, err := x.Transpose(0, 1)
+	if err != nil {
+		t.Fatalf("Transpose: %v", err)
+	}
+	buf.Reset()
+	if err := WriteNPY(&buf, tr); err != nil {
+		t.Fatalf("WriteNPY: %v", err)
+	}
+	if !strings.Contains(buf.String(), "'descr': '<f4', 'fortran_order': False, 'shape': (3, 2), }") || buf.Len()%64 != 24 {
+		t.Fatalf("bf16 header: %q", buf.String()[:128])
+	}
+	back, err := ReadNPY(&buf)
+	if err != nil {
+		t.Fatalf("ReadNPY: %v", err)
+	}
+	defer back.Close()
+	bf, err := back.To(BFloat16)
+	if err != nil {
+		t.Fatalf("To: %v", err)
+	}
+	defer bf.Close()
+	expectValues(t, "bf16 round trip", mustLoad(t, bf), []float32{1, 4.5, 2, 5, 3, 6})
+
+	fp8 := mustFromFloat32(t, Float8E4M3, []float32{1}, 1)
+	if err := WriteNPY(&buf, fp8); err == nil || !strings.Contains(err.Error(), "cast first") {
+		t.Fatalf("fp8: got %v", err)
+	}
+}
+
+func TestNPZRoundTrip(t *testing.T) {
+	for _, name := range []string{"arrays.npz", "arrays_compressed.npz"} {
+		arrays, err := LoadNPZ(filepath.Join("testdata", "npy", name))
+		if err != nil {
+			t.Fatalf("LoadNPZ(%s): %v", name, err)
+		}
+		if len(arrays) != 3 || arrays["logits"] == nil || arrays["ids"] == nil || arrays["mask"] == nil {
+			t.Fatalf("%s: keys %v", name, arrays)
+		}
+		expectValues(t, name+" logits", mustLoad(t, arrays["logits"]), npyF32)
+		expectValues(t, name+" mask", mustLoad(t, arrays["mask"]), []float32{1, 0, 0, 1})
+
+		out := filepath.Join(t.TempDir(), "out.npz")
+		if err := SaveNPZ(out, arrays); err != nil {
+			t.Fatalf("SaveNPZ: %v", err)
+		}
+		back, err := LoadNPZ(out)
+		if err != nil {
+			t.Fatalf("LoadNPZ(saved): %v", err)
+		}
+		for k, v := range arrays {
+			r := mustCompare(t, back[k], v, 0, 0)
+			if !r.OK() || back[k].DT != v.DT || !equalShapes(back[k].Shape, v.Shape) {
+				t.Fatalf("%s: %s changed: %v", name, k, r)
+			}
+			_ = v.Close()
+			_ = back[k].Close()
+		}
+	}
+}
+
+func TestNPYErrors(t *testing.T) {
+	cases := []struct {
+		name, data, want string
+	}{
+		{"magic", "PK\x03\x04\x14\x00\x00\x00", "not a .npy file"},
+		{"version", "\x93NUMPY\x04\x00\x00\x00", "unsupported .npy version 4.0"},
+		{"dtype", string(npyTestHeader("<u2", "(2,)")), "unsupported .npy dtype \"<u2\""},
+		{"short data", string(npyTestHeader("<f4", "(2,)")) + "\x00\x00", "reading data"},
+		{"shape", string(npyTestHeader("<f4", "(2, -1)")), "unsupported shape [2 -1]"},
+	}
+	for _, c := range cases {
+		_, err := ReadNPY(strings.NewReader(c.data))
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Fatalf("%s: got %v, want error containing %q", c.name, err, c.want)
+		}
+	}
+	if _, err := LoadNPZ(filepath.Join("testdata", "npy", "f32_2x3.npy")); err == nil {
+		t.Fatalf("LoadNPZ of a .npy: expected error")
+	}
+}
+
+// npyTestHeader returns the magic and a version 1.0 header.
+func npyTestHeader(descr, shape string) []byte {
+	h := "{'descr': '" + descr + "', 'fortran_order': False, 'shape': " + shape + ", }\n"
+	return append([]byte("\x93NUMPY\x01\x00"), append([]byte{byte(len(h)), 0}, h...)...)
+}
@@ -1,8 +1,11 @@
 package tensor
 
 import (
+	"encoding/binary"
 	"math"
+	"math/rand"
 	"testing"
+	"unsafe"
 )
 
 func TestBytesForAndSizeOf(t *testing.T) {
@@ -69,3 +72,291 @@ func TestFP16BF16Conversions(t *testing.T) {
 		}
 	}
 }
+
+// refFloat16 rounds f to the nearest half, ties to even, in float64.
+func refFloat16(f float32) uint16 {
+	var sign uint16
+	if math.Signbit(float64(f)) {
+		sign = 0x8000
+	}
+	a := math.Abs(float64(f))
+	switch {
+	case math.IsNaN(a):
+		return sign | 0x7e00
+	case a >= 65520: // halfway from 65504 to 2^16, which ties up to Inf
+		return sign | 0x7c00
+	case a < 0x1p-14:
+		return sign | uint16(math.RoundToEven(a*0x1p24))
+	}
+	_, e := math.Frexp(a) // a in [2^(e-1), 2^e)
+	m := uint16(math.RoundToEven(math.Ldexp(a, 11-e)))
+	return sign | (uint16(e+14)<<10 + m - 1024)
+}
+
+// refBFloat16 is refFloat16 for bfloat16.
+func refBFloat16(f float32) uint16 {
+	if f != f {
+		return uint16(math.Float32bits(f)>>16) | 0x0040
+	}
+	var sign uint16
+	if math.Signbit(float64(f)) {
+		sign = 0x8000
+	}
+	a := math.Abs(float64(f))
+	switch {
+	case a >= (2-0x1p-8)*0x1p127:
+		return sign | 0x7f80
+	case a < 0x1p-126:
+		return sign | uint16(math.RoundToEven(a*0x1p133))
+	}
+	_, e := math.Frexp(a)
+	m := uint16(math.RoundToEven(math.Ldexp(a, 8-e)))
+	return sign | (uint16(e+126)<<7 + m - 128)
+}
+
+// halfValue is the exact value of a finite half.
+func halfValue(h uint16) float64 {
+	exp, mant := int(h>>10&0x1f), float64(h&0x3ff)
+	v := math.Ldexp(mant, -24)
+	if exp > 0 {
+		v = math.Ldexp(1024+mant, exp-25)
+	}
+	if h&0x8000 != 0 {
+		v = -v
+	}
+	return v
+}
+
+// float32Samples returns n float32s with random bit patterns, so every
+// exponent and both signs show up, followed by NaN and Inf variants.
+func float32Samples(n int) []float32 {
+	rng := rand.New(rand.NewSource(11))
+	out := make([]float32, n, n+4)
+	for i := range out {
+		out[i] = math.Float32frombits(rng.Uint32())
+	}
+	return append(out, inf32(1), inf32(-1), math.Float32frombits(0x7f800001), math.Float32frombits(0xffc00001))
+}
+
+func TestFP16Exhaustive(t *testing.T) {
+	raw := make([]byte, 2*65536)
+	for h := range 65536 {
+		binary.LittleEndian.PutUint16(raw[2*h:], uint16(h))
+	}
+	vals := make([]float32, 65536)
+	UnpackFP16Bytes(vals, raw)
+	back := make([]byte, len(raw))
+	PackFP16Bytes(back, vals)
+	for h := range 65536 {
+		v, hb := vals[h], uint16(h)
+		got := binary.LittleEndian.Uint16(back[2*h:])
+		sign := uint32(h&0x8000) << 16
+		if h&0x7c00 == 0x7c00 && h&0x3ff != 0 {
+			if want := sign | 0x7f800000 | uint32(h&0x3ff)<<13; math.Float32bits(v) != want {
+				t.Fatalf("unpack NaN %#04x: got %#08x, want %#08x", h, math.Float32bits(v), want)
+			}
+			if want := uint16(sign>>16) | 0x7e00; got != want {
+				t.Fatalf("pack NaN from %#04x: got %#04x, want %#04x", h, got, want)
+			}
+			continue
+		}
+		want := math.Inf(1)
+		if h&0x7fff != 0x7c00 {
+			want = math.Abs(halfValue(hb))
+		}
+		if math.Abs(float64(v)) != want || math.Float32bits(v)&0x80000000 != sign {
+			t.Fatalf("unpack %#04x: got %v, want %v with sign %#x", h, v, want, sign)
+		}
+		if got != hb {
+			t.Fatalf("round trip %#04x: got %#04x", h, got)
+		}
+	}
+
+	// Halfway between neighbours, and one float32 ulp either side, including
+	// the subnormal range and the tie at 65520 that rounds to Inf.
+	var in []float32
+	for h := uint16(0); h < 0x7c00; h++ {
+		lo, hi := halfValue(h), halfValue(h+1)
+		if h == 0x7bff {
+			hi = 65536
+		}
+		mid := float32((lo + hi) / 2)
+		in = append(in, mid, math.Nextafter32(mid, 0), math.Nextafter32(mid, 1e9))
+		in = append(in, -mid, -math.Nextafter32(mid, 0), -math.Nextafter32(mid, 1e9))
+		if want := h + h&1; refFloat16(mid) != want {
+			t.Fatalf("reference rounds the tie above %#04x to %#04x, want %#04x", h, refFloat16(mid), want)
+		}
+	}
+	in = append(in, float32Samples(1<<20)...)
+	out := make([]byte, 2*len(in))
+	PackFP16Bytes(out, in)
+	for i, f := range in {
+		if got, want := binary.LittleEndian.Uint16(out[2*i:]), refFloat16(f); got != want {
+			t.Fatalf("pack %v (%#08x): got %#04x, want %#04x", f, math.Float32bits(f), got, want)
+		}
+	}
+	if got := PackFP16(in[:3]); got[0] != refFloat16(in[0]) || got[2] != refFloat16(in[2]) {
+		t.Fatalf("PackFP16 disagrees with PackFP16Bytes: %#04x", got)
+	}
+}
+
+func TestBF16Exhaustive(t *testing.T) {
+	raw := make([]byte, 2*65536)
+	for b := range 65536 {
+		binary.LittleEndian.PutUint16(raw[2*b:], uint16(b))
+	}
+	vals := make([]float32, 65536)
+	UnpackBF16Bytes(vals, raw)
+	back := make([]byte, len(raw))
+	PackBF16Bytes(back, vals)
+	for b := range 65536 {
+		if got := math.Float32bits(vals[b]); got != uint32(b)<<16 {
+			t.Fatalf("unpack %#04x: got %#08x", b, got)
+		}
+		want := uint16(b)
+		if b&0x7f80 == 0x7f80 && b&0x7f != 0 {
+			want |= 0x0040
+		}
+		if got := binary.LittleEndian.Uint16(back[2*b:]); got != want {
+			t.Fatalf("round trip %#04x: got %#04x, want %#04x", b, got, want)
+		}
+	}
+
+	in := float32Samples(1 << 20)
+	for b := uint32(0); b < 0x7f80; b++ {
+		mid := math.Float32frombits(b<<16 | 0x8000)
+		in = append(in, mid, math.Nextafter32(mid, 0), -mid)
+	}
+	out := make([]byte, 2*len(in))
+	PackBF16Bytes(out, in)
+	for i, f := range in {
+		if got, want := binary.LittleEndian.Uint16(out[2*i:]), refBFloat16(f); got != want {
+			t.Fatalf("pack %v (%#08x): got %#04x, want %#04x", f, math.Float32bits(f), got, want)
+		}
+	}
+}
+
+func benchmarkPack(b *testing.B, pack func([]byte, []float32)) {
+	src := randomFloats(rand.New(rand.NewSource(1)), 1<<16)
+	dst := make([]byte, 2*len(src))
+	b.SetBytes(int64(4 * len(src)))
+	for range b.N {
+		pack(dst, src)
+	}
+}
+
+func benchmarkUnpack(b *testing.B, pack func([]byte, []float32), unpack func([]float32, []byte)) {
+	src := randomFloats(rand.New(rand.NewSource(1)), 1<<16)
+	raw := make([]byte, 2*len(src))
+	pack(raw, src)
+	b.SetBytes(int64(len(raw)))
+	for range b.N {
+		unpack(src, raw)
+	}
+}
+
+func BenchmarkPackFP16Bytes(b *testing.B)   { benchmarkPack(b, PackFP16Bytes) }
+func BenchmarkUnpackFP16Bytes(b *testing.B) { benchmarkUnpack(b, PackFP16Bytes, UnpackFP16Bytes) }
+func BenchmarkPackBF16Bytes(b *testing.B)   { benchmarkPack(b, PackBF16Bytes) }
+func BenchmarkUnpackBF16Bytes(b *testing.B) { benchmarkUnpack(b, PackBF16Bytes, UnpackBF16Bytes) }
+
+// The Branchy benchmarks time the routines the byte converters replaced, for
+// comparison: a []uint16 per call, copied to bytes, and per-exponent-range
+// branches in the FP16 conversions.
+func BenchmarkPackFP16BytesBranchy(b *testing.B) { benchmarkPack(b, branchyPackFP16Bytes) }
+func BenchmarkUnpackFP16BytesBranchy(b *testing.B) {
+	benchmarkUnpack(b, PackFP16Bytes, branchyUnpackFP16Bytes)
+}
+func BenchmarkPackBF16BytesBranchy(b *testing.B) { benchmarkPack(b, branchyPackBF16Bytes) }
+
+func branchyPackFP16Bytes(dst []byte, src []float32) {
+	out := make([]uint16, len(src))
+	for i, f := range src {
+		out[i] = branchyFloat32ToFloat16Bits(f)
+	}
+	copy(dst, unsafe.Slice((*byte)(unsafe.Pointer(&out[0])), 2*len(out)))
+}
+
+func branchyUnpackFP16Bytes(dst []float32, src []byte) {
+	for i := range dst {
+		dst[i] = branchyFloat16BitsToFloat32(binary.LittleEndian.Uint16(src[2*i:]))
+	}
+}
+
+func branchyPackBF16Bytes(dst []byte, src []float32) {
+	out := make([]uint16, len(src))
+	for i, f := range src {
+		bits := math.Float32bits(f)
+		if f != f {
+			out[i] = uint16(bits>>16) | 0x0040
+			continue
+		}
+		out[i] = uint16((bits + 0x7FFF + (bits>>16)&1) >> 16)
+	}
+	copy(dst, unsafe.Slice((*byte)(unsafe.Pointer(&out[0])), 2*len(out)))
+}
+
+// branchyFloat32ToFloat16Bits is the previous encoder, which rounded ties up
+// rather than to even.
+func branchyFloat32ToFloat16Bits(f float32) uint16 {
+	x := math.Float32bits(f)
+	sign := uint16((x >> 16) & 0x8000)
+	mant := x & 0x007fffff
+	exp := (x >> 23) & 0xff
+	if exp == 0xff {
+		if mant != 0 {
+			return sign | 0x7e00
+		}
+		return sign | 0x7c00
+	}
+	exp16 := int(exp) - 127 + 15
+	if exp16 >= 0x1f {
+		return sign | 0x7c00
+	}
+	if exp16 <= 0 {
+		if exp16 < -10 {
+			return sign
+		}
+		mant |= 0x00800000
+		shift := uint32(14 - exp16)
+		out := mant >> shift
+		if (mant>>(shift-1))&1 == 1 {
+			out++
+		}
+		return sign | uint16(out&0x03ff)
+	}
+	outExp := uint16(exp16) & 0x1f
+	outMant := uint16(mant >> 13)
+	if (mant>>12)&1 == 1 {
+		outMant++
+	}
+	if outMant >= 0x0400 {
+		outMant = 0
+		outExp++
+		if outExp >= 0x1f {
+			return sign | 0x7c00
+		}
+	}
+	return sign | outExp<<10 | outMant&0x03ff
+}
+
+func branchyFloat16BitsToFloat32(h uint16) float32 {
+	sign := uint32(h&0x8000) << 16
+	exp := (h >> 10) & 0x1f
+	mant := uint32(h & 0x03ff)
+	switch {
+	case exp == 0 && mant == 0:
+		return math.Float32frombits(sign)
+	case exp == 0:
+		f := math.Ldexp(float64(float32(mant)/1024), -14)
+		if sign != 0 {
+			f = -f
+		}
+		return float32(f)
+	case exp == 0x1f && mant == 0:
+		return math.Float32frombits(sign | 0x7f800000)
+	case exp == 0x1f:
+		return math.Float32frombits(sign | 0x7fc00000)
+	}
+	return math.Float32frombits(sign | (uint32(exp)-15+127)<<23 | mant<<13)
+}
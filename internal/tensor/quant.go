@@ -0,0 +1,302 @@
+package tensor
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ---------- Integer quantization ----------
+//
+// A QuantizedTensor stores Int8 or Int4 values q with float32 scales and,
+// for asymmetric schemes, integer zero points; element x decodes as
+// (q - zero) * scale, where scale and zero belong to the element's group:
+//
+//   - PerTensor: one group for the whole tensor.
+//   - PerChannel: one group per index along Axis, as for the output rows of
+//     an HF [out, in] weight with Axis 0.
+//   - PerGroup: GroupSize consecutive elements along Axis per group, at each
+//     position of the other dims, as for GPTQ/AWQ-style weights grouped
+//     along the input dim with Axis -1.
+//
+// Symmetric schemes use q in [-qmax, qmax] (±127, ±7) with scale amax/qmax
+// and no zero points. Asymmetric ones span the full range ([-128, 127],
+// [-8, 7]) over [min(lo, 0), max(hi, 0)], so 0 stays exact. Either way
+// rounding is to nearest even and every in-range value decodes within
+// scale/2 of its input.
+
+// QuantGranularity selects how many scales a QuantScheme uses.
+type QuantGranularity int
+
+const (
+	PerTensor QuantGranularity = iota
+	PerChannel
+	PerGroup
+)
+
+func (g QuantGranularity) String() string {
+	switch g {
+	case PerTensor:
+		return "per-tensor"
+	case PerChannel:
+		return "per-channel"
+	case PerGroup:
+		return "per-group"
+	default:
+		return "unknown"
+	}
+}
+
+// QuantScheme describes how Quantize maps a tensor to integers.
+type QuantScheme struct {
+	DType       DType // Int8 or Int4
+	Granularity QuantGranularity
+	Axis        int  // the channel axis, or the axis groups run along; may be negative
+	GroupSize   int  // PerGroup only; must divide the Axis dim
+	Symmetric   bool // zero points fixed at 0
+}
+
+// qrange returns the integers a scheme quantizes to.
+func (s QuantScheme) qrange() (lo, hi float64) {
+	hi = 127
+	if s.DType == Int4 {
+		hi = 7
+	}
+	if s.Symmetric {
+		return -hi, hi
+	}
+	return -hi - 1, hi
+}
+
+// QuantizedTensor is a tensor quantized by Quantize. Data holds the packed
+// integers with the logical shape. Scales (Float32) and Zeros (Int8, also
+// for Int4 data; nil when symmetric) hold one entry per group: a scalar for
+// PerTensor, [Shape[Axis]] for PerChannel, and Shape with the Axis dim
+// divided by GroupSize for PerGroup. Axis is normalized to be non-negative.
+type QuantizedTensor struct {
+	Scheme QuantScheme
+	Shape  []int
+	Data   *Tensor
+	Scales *Tensor
+	Zeros  *Tensor
+}
+
+// quantGroups maps the row-major element indices of a quantized tensor to
+// its groups.
+type quantGroups struct {
+	n          int   // number of groups
+	shape      []int // shape of Scales and Zeros; nil for PerTensor
+	dim, inner int   // size of the axis dim and of the dims after it
+	group      int   // GroupSize, 0 unless PerGroup
+}
+
+// newQuantGroups returns the groups of shape under s, whose Axis is already
+// normalized.
+func newQuantGroups(s QuantScheme, shape []int) quantGroups {
+	q := quantGroups{n: 1}
+	switch s.Granularity {
+	case PerChannel:
+		q.shape = []int{shape[s.Axis]}
+	case PerGroup:
+		q.shape = append([]int(nil), shape...)
+		q.shape[s.Axis] /= s.GroupSize
+		q.group = s.GroupSize
+	default:
+		return q
+	}
+	q.n = Numel(q.shape)
+	q.dim, q.inner = shape[s.Axis], Numel(shape[s.Axis+1:])
+	return q
+}
+
+// of returns the group of the element at row-major index i.
+func (q quantGroups) of(i int) int {
+	switch {
+	case q.shape == nil:
+		return 0
+	case q.group == 0:
+		return i / q.inner % q.dim
+	}
+	outer, ax, in := i/(q.dim*q.inner), i/q.inner%q.dim, i%q.inner
+	return (outer*(q.dim/q.group)+ax/q.group)*q.inner + in
+}
+
+// normalize validates s against shape and returns it with Axis non-negative.
+func (s QuantScheme) normalize(shape []int) (QuantScheme, error) {
+	if s.DType != Int8 && s.DType != Int4 {
+		return s, fmt.Errorf("Quantize: dtype %v, want int8 or int4", s.DType)
+	}
+	switch s.Granularity {
+	case PerTensor:
+		s.Axis, s.GroupSize = 0, 0
+		return s, nil
+	case PerChannel, PerGroup:
+	default:
+		return s, fmt.Errorf("Quantize: unknown granularity %d", s.Granularity)
+	}
+	axis, err := normalizeDim("Quantize", s.Axis, len(shape))
+	if err != nil {
+		return s, err
+	}
+	s.Axis = axis
+	if s.Granularity == PerChannel {
+		s.GroupSize = 0
+		return s, nil
+	}
+	if s.GroupSize <= 0 || shape[axis]%s.GroupSize != 0 {
+		return s, fmt.Errorf("Quantize: group size %d does not divide dim %d of shape %v", s.GroupSize, axis, shape)
+	}
+	return s, nil
+}
+
+// Quantize quantizes t with scheme, computing on the host and placing the
+// result on t's device; on Meta it only allocates. t must be a float tensor
+// of finite values.
+func Quantize(t *Tensor, scheme QuantScheme) (*QuantizedTensor, error) {
+	if err := t.live(); err != nil {
+		return nil, fmt.Errorf("Quantize: %w", err)
+	}
+	if isIntDType(t.DT) || t.DT == Bool || t.DT == Float8E8M0 {
+		return nil, fmt.Errorf("Quantize: dtype %v, want a float tensor", t.DT)
+	}
+	s, err := scheme.normalize(t.Shape)
+	if err != nil {
+		return nil, err
+	}
+	groups := newQuantGroups(s, t.Shape)
+	q, err := newQuantized(t.Device(), s, t.Shape, groups.shape)
+	if err != nil || t.Device() == Meta {
+		return q, err
+	}
+	x, err := t.loadFloat32()
+	if err != nil {
+		_ = q.Close()
+		return nil, fmt.Errorf("Quantize: %w", err)
+	}
+	lo := make([]float64, groups.n)
+	hi := make([]float64, groups.n)
+	for i, v := range x {
+		f := float64(v)
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			_ = q.Close()
+			return nil, fmt.Errorf("Quantize: non-finite value %v at element %d", v, i)
+		}
+		g := groups.of(i)
+		lo[g], hi[g] = min(lo[g], f), max(hi[g], f)
+	}
+	qmin, qmax := s.qrange()
+	scales := make([]float32, groups.n)
+	zeros := make([]float32, groups.n)
+	for g := range scales {
+		if s.Symmetric {
+			scales[g] = float32(max(-lo[g], hi[g]) / qmax)
+		} else {
+			scales[g] = float32((hi[g] - lo[g]) / (qmax - qmin))
+		}
+		if scales[g] == 0 {
+			scales[g] = 1 // an all-zero group
+		}
+		if !s.Symmetric {
+			zeros[g] = float32(min(max(math.RoundToEven(qmin-lo[g]/float64(scales[g])), qmin), qmax))
+		}
+	}
+	for i, v := range x {
+		g := groups.of(i)
+		x[i] = float32(min(max(math.RoundToEven(float64(v)/float64(scales[g]))+float64(zeros[g]), qmin), qmax))
+	}
+	err = q.Data.storeFloat32(x)
+	if err == nil {
+		err = q.Scales.storeFloat32(scales)
+	}
+	if err == nil && q.Zeros != nil {
+		err = q.Zeros.storeFloat32(zeros)
+	}
+	if err != nil {
+		_ = q.Close()
+		return nil, fmt.Errorf("Quantize: %w", err)
+	}
+	return q, nil
+}
+
+// newQuantized allocates the tensors of a QuantizedTensor on dev.
+func newQuantized(dev Device, s QuantScheme, shape, groupShape []int) (*QuantizedTensor, error) {
+	q := &QuantizedTensor{Scheme: s, Shape: append([]int(nil), shape...)}
+	var err error
+	if q.Data, err = NewOn(dev, s.DType, shape...); err == nil {
+		if q.Scales, err = NewOn(dev, Float32, groupShape...); err == nil && !s.Symmetric {
+			q.Zeros, err = NewOn(dev, Int8, groupShape...)
+		}
+	}
+	if err != nil {
+		_ = q.Close()
+		return nil, err
+	}
+	return q, nil
+}
+
+// Dequantize decodes q into a new Float32 tensor on q's device.
+func (q *QuantizedTensor) Dequantize() (*Tensor, error) {
+	if q == nil {
+		return nil, errors.New("Dequantize: nil tensor")
+	}
+	for _, t := range []*Tensor{q.Data, q.Scales} {
+		if err := t.live(); err != nil {
+			return nil, fmt.Errorf("Dequantize: %w", err)
+		}
+	}
+	if q.Zeros != nil {
+		if err := q.Zeros.live(); err != nil {
+			return nil, fmt.Errorf("Dequantize: %w", err)
+		}
+	}
+	groups := newQuantGroups(q.Scheme, q.Shape)
+	if !equalShapes(q.Data.Shape, q.Shape) || q.Scales.Numel() != groups.n || q.Zeros != nil && q.Zeros.Numel() != groups.n {
+		return nil, fmt.Errorf("Dequantize: data %v, scales %v do not fit shape %v and %v %v", q.Data.Shape, q.Scales.Shape, q.Shape, q.Scheme.Granularity, groups.shape)
+	}
+	out, err := NewOn(q.Data.Device(), Float32, q.Shape...)
+	if err != nil || out.Device() == Meta {
+		return out, err
+	}
+	x, err := q.Data.loadFloat32()
+	var scales, zeros []float32
+	if err == nil {
+		scales, err = q.Scales.loadFloat32()
+	}
+	if err == nil && q.Zeros != nil {
+		zeros, err = q.Zeros.loadFloat32()
+	}
+	if err == nil {
+		for i, v := range x {
+			g := groups.of(i)
+			if zeros != nil {
+				v -= zeros[g]
+			}
+			x[i] = v * scales[g]
+		}
+		err = out.storeFloat32(x)
+	}
+	if err != nil {
+		_ = out.Close()
+		return nil, fmt.Errorf("Dequantize: %w", err)
+	}
+	return out, nil
+}
+
+func (q *QuantizedTensor) Numel() int { return Numel(q.Shape) }
+
+// ByteSize returns the combined bytes of data, scales and zero points.
+func (q *QuantizedTensor) ByteSize() int {
+	n := q.Data.ByteSize() + q.Scales.ByteSize()
+	if q.Zeros != nil {
+		n += q.Zeros.ByteSize()
+	}
+	return n
+}
+
+// Close releases the data, scale and zero point tensors.
+func (q *QuantizedTensor) Close() error {
+	if q == nil {
+		return nil
+	}
+	return errors.Join(q.Data.Close(), q.Scales.Close(), q.Zeros.Close())
+}
@@ -0,0 +1,222 @@
+package tensor
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestQuantizeKnownValues(t *testing.T) {
+	x := mustFromFloat32(t, Float32, []float32{0, 3, 7, 15}, 4)
+	defer x.Close()
+	q, err := Quantize(x, QuantScheme{DType: Int4})
+	if err != nil {
+		t.Fatalf("Quantize: %v", err)
+	}
+	defer q.Close()
+	// [0, 15] over 16 levels: scale 1 and zero point -8.
+	expectValues(t, "int4 data", mustLoad(t, q.Data), []float32{-8, -5, -1, 7})
+	expectValues(t, "int4 scale", mustLoad(t, q.Scales), []float32{1})
+	expectValues(t, "int4 zero", mustLoad(t, q.Zeros), []float32{-8})
+	if got := q.ByteSize(); got != 2+4+1 {
+		t.Errorf("ByteSize = %d, want 7", got)
+	}
+
+	// Rows scaled by 1 and 2; halves round to even.
+	w := mustFromFloat32(t, Float32, []float32{1, -127, 127, 254, -5, 3}, 2, 3)
+	defer w.Close()
+	qw, err := Quantize(w, QuantScheme{DType: Int8, Granularity: PerChannel, Symmetric: true})
+	if err != nil {
+		t.Fatalf("Quantize: %v", err)
+	}
+	defer qw.Close()
+	if qw.Zeros != nil {
+		t.Error("symmetric scheme stored zero points")
+	}
+	expectShape(t, "per-channel scales", qw.Scales, 2)
+	expectValues(t, "int8 data", mustLoad(t, qw.Data), []float32{1, -127, 127, 127, -2, 2})
+	expectValues(t, "int8 scales", mustLoad(t, qw.Scales), []float32{1, 2})
+	d, err := qw.Dequantize()
+	if err != nil {
+		t.Fatalf("Dequantize: %v", err)
+	}
+	defer d.Close()
+	expectValues(t, "dequantized", mustLoad(t, d), []float32{1, -127, 127, 254, -4, 4})
+}
+
+func TestQuantGroups(t *testing.T) {
+	shape := []int{2, 4, 3}
+	s, err := QuantScheme{DType: Int8, Granularity: PerGroup, Axis: -2, GroupSize: 2}.normalize(shape)
+	if err != nil {
+		t.Fatalf("normalize: %v", err)
+	}
+	g := newQuantGroups(s, shape)
+	if g.n != 12 || !equalShapes(g.shape, []int{2, 2, 3}) {
+		t.Fatalf("groups %d of shape %v, want 12 of [2 2 3]", g.n, g.shape)
+	}
+	i := 0
+	for o := 0; o < 2; o++ {
+		for a := 0; a < 4; a++ {
+			for in := 0; in < 3; in++ {
+				if got, want := g.of(i), (o*2+a/2)*3+in; got != want {
+					t.Errorf("element (%d, %d, %d) in group %d, want %d", o, a, in, got, want)
+				}
+				i++
+			}
+		}
+	}
+}
+
+func TestQuantizeRoundTrip(t *testing.T) {
+	shape := []int{4, 6, 8}
+	rng := rand.New(rand.NewSource(7))
+	src := make([]float32, Numel(shape))
+	for i := range src {
+		// Spread the magnitudes so groups get different scales.
+		src[i] = float32(rng.NormFloat64()) * float32(1+i%5)
+		if i%7 == 0 {
+			src[i] = float32(math.Abs(float64(src[i]))) + 2 // skew some groups positive
+		}
+	}
+	x := mustFromFloat32(t, Float32, src, shape...)
+	defer x.Close()
+
+	for _, dt := range []DType{Int8, Int4} {
+		for _, sym := range []bool{true, false} {
+			for _, tc := range []struct {
+				name   string
+				scheme QuantScheme
+				scales []int
+			}{
+				{"per-tensor", QuantScheme{Granularity: PerTensor}, []int{}},
+				{"per-channel axis 0", QuantScheme{Granularity: PerChannel}, []int{4}},
+				{"per-channel axis -2", QuantScheme{Granularity: PerChannel, Axis: -2}, []int{6}},
+				{"per-group last axis", QuantScheme{Granularity: PerGroup, Axis: -1, GroupSize: 4}, []int{4, 6, 2}},
+				{"per-group middle axis", QuantScheme{Granularity: PerGroup, Axis: 1, GroupSize: 3}, []int{4, 2, 8}},
+			} {
+				name := tc.name + " " + dt.String()
+				if sym {
+					name += " symmetric"
+				}
+				tc.scheme.DType, tc.scheme.Symmetric = dt, sym
+				q, err := Quantize(x, tc.scheme)
+				if err != nil {
+					t.Fatalf("%s: Quantize: %v", name, err)
+				}
+				expectShape(t, name+" scales", q.Scales, tc.scales...)
+				if (q.Zeros == nil) != sym {
+					t.Errorf("%s: zero points %v", name, q.Zeros)
+				}
+				d, err := q.Dequantize()
+				if err != nil {
+					t.Fatalf("%s: Dequantize: %v", name, err)
+				}
+				got := mustLoad(t, d)
+				scales := mustLoad(t, q.Scales)
+				groups := newQuantGroups(q.Scheme, shape)
+				worst := 0.0
+				for i, v := range src {
+					scale := float64(scales[groups.of(i)])
+					diff := math.Abs(float64(got[i] - v))
+					if diff > scale/2*(1+1e-5)+1e-6 {
+						t.Fatalf("%s: element %d: %v decoded as %v, off by more than scale/2 = %v", name, i, v, got[i], scale/2)
+					}
+					worst = max(worst, diff/scale)
+				}
+				if worst < 0.25 {
+					t.Errorf("%s: worst error %v scales suspiciously small", name, worst)
+				}
+				_ = d.Close()
+				_ = q.Close()
+			}
+		}
+	}
+}
+
+func TestQuantizeZeros(t *testing.T) {
+	x := mustFromFloat32(t, Float32, []float32{0, 0, 1, -1}, 2, 2)
+	defer x.Close()
+	q, err := Quantize(x, QuantScheme{DType: Int8, Granularity: PerChannel})
+	if err != nil {
+		t.Fatalf("Quantize: %v", err)
+	}
+	defer q.Close()
+	d, err := q.Dequantize()
+	if err != nil {
+		t.Fatalf("Dequantize: %v", err)
+	}
+	defer d.Close()
+	got := mustLoad(t, d)
+	if got[0] != 0 || got[1] != 0 {
+		t.Errorf("all-zero row decoded as %v", got[:2])
+	}
+	if s := mustLoad(t, q.Scales); s[0] != 1 {
+		t.Errorf("all-zero row scale = %v, want 1", s[0])
+	}
+}
+
+func TestQuantizeMeta(t *testing.T) {
+	var x *Tensor
+	onMeta(t, func() {
+		var err error
+		if x, err = New(Float16, 8, 32); err != nil {
+			t.Fatalf("New: %v", err)
+		}
+	})
+	defer x.Close()
+	q, err := Quantize(x, QuantScheme{DType: Int4, Granularity: PerGroup, Axis: -1, GroupSize: 16})
+	if err != nil {
+		t.Fatalf("Quantize: %v", err)
+	}
+	defer q.Close()
+	if q.Data.Device() != Meta || q.Scales.Device() != Meta || q.Zeros.Device() != Meta {
+		t.Fatal("quantized meta tensor has storage")
+	}
+	if got := q.ByteSize(); got != 8*32/2+8*2*4+8*2 {
+		t.Errorf("ByteSize = %d, want %d", got, 8*32/2+8*2*4+8*2)
+	}
+	d, err := q.Dequantize()
+	if err != nil {
+		t.Fatalf("Dequantize: %v", err)
+	}
+	defer d.Close()
+	if d.Device() != Meta || d.DT != Float32 {
+		t.Errorf("Dequantize on meta gave %v %v", d.Device(), d.DT)
+	}
+	expectShape(t, "dequantized", d, 8, 32)
+}
+
+func TestQuantizeErrors(t *testing.T) {
+	x := mustFromFloat32(t, Float32, []float32{1, 2, 3, 4, 5, 6}, 2, 3)
+	defer x.Close()
+	nan := mustFromFloat32(t, Float32, []float32{1, float32(math.NaN())}, 2)
+	defer nan.Close()
+	ints := mustFromFloat32(t, Int32, []float32{1, 2}, 2)
+	defer ints.Close()
+	for _, tc := range []struct {
+		name   string
+		x      *Tensor
+		scheme QuantScheme
+	}{
+		{"float target", x, QuantScheme{DType: Float16}},
+		{"integer input", ints, QuantScheme{DType: Int8}},
+		{"non-finite input", nan, QuantScheme{DType: Int8}},
+		{"axis out of range", x, QuantScheme{DType: Int8, Granularity: PerChannel, Axis: 2}},
+		{"group does not divide", x, QuantScheme{DType: Int4, Granularity: PerGroup, Axis: 1, GroupSize: 2}},
+		{"no group size", x, QuantScheme{DType: Int4, Granularity: PerGroup, Axis: 1}},
+		{"unknown granularity", x, QuantScheme{DType: Int8, Granularity: 7}},
+	} {
+		if q, err := Quantize(tc.x, tc.scheme); err == nil {
+			_ = q.Close()
+			t.Errorf("%s: expected an error", tc.name)
+		}
+	}
+	q, err := Quantize(x, QuantScheme{DType: Int8})
+	if err != nil {
+		t.Fatalf("Quantize: %v", err)
+	}
+	_ = q.Close()
+	if _, err := q.Dequantize(); err == nil {
+		t.Error("Dequantize after Close: expected an error")
+	}
+}
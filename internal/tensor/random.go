@@ -0,0 +1,255 @@
+package tensor
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// ---------- Random initialization ----------
+//
+// Random draws every element from a caller-seeded *rand.Rand in row-major
+// order, so a fixed seed reproduces the same tensor bit for bit. Samples are
+// drawn in float64 and converted like Tensor.To: float dtypes round to nearest
+// even (FP8/FP4 saturate), integer dtypes round to nearest and saturate.
+// Uniform on an integer dtype instead draws whole numbers uniformly from
+// [ceil(low), floor(high)].
+
+type distKind int
+
+const (
+	distUniform distKind = iota
+	distNormal
+	distTruncNormal
+	distKaimingUniform
+	distKaimingNormal
+	distXavierUniform
+	distXavierNormal
+)
+
+// Distribution describes how Random fills a tensor. Build one with Uniform,
+// Normal, TruncNormal, KaimingUniform, KaimingNormal, XavierUniform or
+// XavierNormal. The Kaiming and Xavier scales use PyTorch's fans: a weight
+// shaped [out, in, k...] has fanIn = in·k... and fanOut = out·k..., and a
+// rank-1 shape uses its length for both.
+type Distribution struct {
+	kind      distKind
+	a, b      float64 // uniform bounds, or normal mean and std
+	low, high float64 // truncation bounds
+	gain      float64
+}
+
+// Uniform samples from [low, high).
+func Uniform(low, high float64) Distribution {
+	return Distribution{kind: distUniform, a: low, b: high}
+}
+
+// Normal samples from N(mean, std²).
+func Normal(mean, std float64) Distribution {
+	return Distribution{kind: distNormal, a: mean, b: std}
+}
+
+// TruncNormal samples N(mean, std²) restricted to [low, high]; the bounds are
+// absolute values, as in PyTorch's trunc_normal_.
+func TruncNormal(mean, std, low, high float64) Distribution {
+	return Distribution{kind: distTruncNormal, a: mean, b: std, low: low, high: high}
+}
+
+// KaimingUniform samples from ±gain·sqrt(3/fanIn). A zero gain means sqrt(2),
+// the ReLU gain.
+func KaimingUniform(gain float64) Distribution {
+	return Distribution{kind: distKaimingUniform, gain: gain}
+}
+
+// KaimingNormal samples from N(0, (gain/sqrt(fanIn))²); a zero gain means
+// sqrt(2).
+func KaimingNormal(gain float64) Distribution {
+	return Distribution{kind: distKaimingNormal, gain: gain}
+}
+
+// XavierUniform samples from ±gain·sqrt(6/(fanIn+fanOut)); a zero gain means 1.
+func XavierUniform(gain float64) Distribution {
+	return Distribution{kind: distXavierUniform, gain: gain}
+}
+
+// XavierNormal samples from N(0, gain²·2/(fanIn+fanOut)); a zero gain means 1.
+func XavierNormal(gain float64) Distribution {
+	return Distribution{kind: distXavierNormal, gain: gain}
+}
+
+func (d Distribution) String() string {
+	switch d.kind {
+	case distUniform:
+		return fmt.Sprintf("Uniform(%g, %g)", d.a, d.b)
+	case distNormal:
+		return fmt.Sprintf("Normal(%g, %g)", d.a, d.b)
+	case distTruncNormal:
+		return fmt.Sprintf("TruncNormal(%g, %g, %g, %g)", d.a, d.b, d.low, d.high)
+	case distKaimingUniform:
+		return fmt.Sprintf("KaimingUniform(%g)", d.gain)
+	case distKaimingNormal:
+		return fmt.Sprintf("KaimingNormal(%g)", d.gain)
+	case distXavierUniform:
+		return fmt.Sprintf("XavierUniform(%g)", d.gain)
+	default:
+		return fmt.Sprintf("XavierNormal(%g)", d.gain)
+	}
+}
+
+// Random returns a new tensor of dtype dt filled from dist using rng. Every
+// float dtype except Float8E8M0 is supported, as are the integer dtypes. On
+// Meta, rng is left untouched.
+func Random(rng *rand.Rand, dist Distribution, dt DType, shape ...int) (*Tensor, error) {
+	if rng == nil {
+		return nil, errors.New("Random: nil rng")
+	}
+	if dt == Float8E8M0 || dt == Bool || dt.SizeOf() == 0 {
+		return nil, fmt.Errorf("Random: unsupported dtype %v", dt)
+	}
+	if !IsValidShape(shape) {
+		return nil, errors.New("invalid shape")
+	}
+	sample, err := dist.sampler(shape)
+	if err != nil {
+		return nil, fmt.Errorf("Random: %w", err)
+	}
+	out, err := New(dt, shape...)
+	if err != nil || out.Device() == Meta {
+		return out, err
+	}
+	n := out.Numel()
+	if isIntDType(dt) && dist.kind == distUniform {
+		lo, hi, ok := intBounds(dt, dist.a, dist.b)
+		if !ok {
+			_ = out.Close()
+			return nil, fmt.Errorf("Random: %v holds no %v values", dist, dt)
+		}
+		vals := make([]int64, n)
+		span := uint64(hi) - uint64(lo)
+		for i := range vals {
+			vals[i] = lo + int64(uniformUint64(rng, span))
+		}
+		err = out.storeInt64(vals)
+	} else {
+		vals := make([]float32, n)
+		for i := range vals {
+			vals[i] = float32(sample(rng))
+		}
+		err = out.storeFloat32(vals)
+	}
+	if err != nil {
+		_ = out.Close()
+		return nil, err
+	}
+	return out, nil
+}
+
+// sampler resolves dist against shape into a per-element draw.
+func (d Distribution) sampler(shape []int) (func(*rand.Rand) float64, error) {
+	var fanIn, fanOut int
+	if d.kind >= distKaimingUniform {
+		if len(shape) == 0 {
+			return nil, fmt.Errorf("%v: fans are undefined for a scalar", d)
+		}
+		fanIn, fanOut = fans(shape)
+	}
+	gain := d.gain
+	if gain == 0 {
+		gain = 1
+		if d.kind == distKaimingUniform || d.kind == distKaimingNormal {
+			gain = math.Sqrt2
+		}
+	}
+	uniform := func(low, high float64) func(*rand.Rand) float64 {
+		return func(r *rand.Rand) float64 { return low + (high-low)*r.Float64() }
+	}
+	normal := func(mean, std float64) func(*rand.Rand) float64 {
+		return func(r *rand.Rand) float64 { return mean + std*r.NormFloat64() }
+	}
+	switch d.kind {
+	case distUniform:
+		if !(d.a <= d.b) {
+			return nil, fmt.Errorf("%v: low exceeds high", d)
+		}
+		return uniform(d.a, d.b), nil
+	case distNormal:
+		if !(d.b >= 0) {
+			return nil, fmt.Errorf("%v: negative std", d)
+		}
+		return normal(d.a, d.b), nil
+	case distTruncNormal:
+		if !(d.b > 0) || !(d.low < d.high) {
+			return nil, fmt.Errorf("%v: need std > 0 and low < high", d)
+		}
+		// Inverse-CDF sampling: map a uniform draw between the CDF values of
+		// the bounds back through the normal quantile function.
+		cdf := func(x float64) float64 { return (1 + math.Erf((x-d.a)/(d.b*math.Sqrt2))) / 2 }
+		cLow, cHigh := cdf(d.low), cdf(d.high)
+		return func(r *rand.Rand) float64 {
+			u := cLow + (cHigh-cLow)*r.Float64()
+			x := d.a + d.b*math.Sqrt2*math.Erfinv(2*u-1)
+			return min(max(x, d.low), d.high)
+		}, nil
+	case distKaimingUniform:
+		bound := gain * math.Sqrt(3/float64(fanIn))
+		return uniform(-bound, bound), nil
+	case distKaimingNormal:
+		return normal(0, gain/math.Sqrt(float64(fanIn))), nil
+	case distXavierUniform:
+		bound := gain * math.Sqrt(6/float64(fanIn+fanOut))
+		return uniform(-bound, bound), nil
+	default:
+		return normal(0, gain*math.Sqrt(2/float64(fanIn+fanOut))), nil
+	}
+}
+
+// fans returns the fan-in and fan-out described on Distribution.
+func fans(shape []int) (fanIn, fanOut int) {
+	if len(shape) < 2 {
+		return shape[0], shape[0]
+	}
+	receptive := Numel(shape[2:])
+	return shape[1] * receptive, shape[0] * receptive
+}
+
+// intBounds intersects [ceil(low), floor(high)] with dt's range; ok is false
+// when the result is empty.
+func intBounds(dt DType, low, high float64) (lo, hi int64, ok bool) {
+	lo, hi = math.MinInt64, math.MaxInt64
+	switch dt {
+	case Int4:
+		lo, hi = -8, 7
+	case Int8:
+		lo, hi = math.MinInt8, math.MaxInt8
+	case Int32:
+		lo, hi = math.MinInt32, math.MaxInt32
+	}
+	l, h := math.Ceil(low), math.Floor(high)
+	if !(l <= h) || l > float64(hi) || h < float64(lo) {
+		return 0, 0, false
+	}
+	// float64(math.MaxInt64) rounds up to 2^63, so compare before converting.
+	if l > float64(lo) {
+		lo = int64(l)
+	}
+	if h < float64(hi) {
+		hi = int64(h)
+	}
+	return lo, hi, true
+}
+
+// uniformUint64 returns a uniform value in [0, span].
+func uniformUint64(rng *rand.Rand, span uint64) uint64 {
+	if span == math.MaxUint64 {
+		return rng.Uint64()
+	}
+	if span < math.MaxInt64 {
+		return uint64(rng.Int63n(int64(span) + 1))
+	}
+	for {
+		if v := rng.Uint64(); v <= span {
+			return v
+		}
+	}
+}
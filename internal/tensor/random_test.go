@@ -0,0 +1,152 @@
+package tensor
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func mustRandom(t *testing.T, seed int64, dist Distribution, dt DType, shape ...int) *Tensor {
+	t.Helper()
+	x, err := Random(rand.New(rand.NewSource(seed)), dist, dt, shape...)
+	if err != nil {
+		t.Fatalf("Random(%v, %v): %v", dist, dt, err)
+	}
+	t.Cleanup(func() { _ = x.Close() })
+	return x
+}
+
+func mustPacked(t *testing.T, x *Tensor) []byte {
+	t.Helper()
+	raw, err := x.packedBytes()
+	if err != nil {
+		t.Fatalf("packedBytes: %v", err)
+	}
+	return raw
+}
+
+func TestRandomSeededBitIdentical(t *testing.T) {
+	dists := []Distribution{
+		Uniform(-2, 3), Normal(1, 0.5), TruncNormal(0, 1, -2, 2),
+		KaimingUniform(0), KaimingNormal(0), XavierUniform(0), XavierNormal(1.5),
+	}
+	dts := []DType{Float32, Float16, BFloat16, Float8E4M3, Float8E5M2, Float4E2M1, Int8, Int4}
+	for _, d := range dists {
+		for _, dt := range dts {
+			a := mustPacked(t, mustRandom(t, 7, d, dt, 3, 5, 2))
+			b := mustPacked(t, mustRandom(t, 7, d, dt, 3, 5, 2))
+			if !bytes.Equal(a, b) {
+				t.Fatalf("%v %v: same seed gave different bytes", d, dt)
+			}
+		}
+		a := mustPacked(t, mustRandom(t, 7, d, Float32, 64))
+		b := mustPacked(t, mustRandom(t, 8, d, Float32, 64))
+		if bytes.Equal(a, b) {
+			t.Fatalf("%v: seeds 7 and 8 gave identical bytes", d)
+		}
+	}
+
+	// Goldens pin the sampling order and conversions for seed 42.
+	golden := []struct {
+		dist Distribution
+		dt   DType
+		want []byte
+	}{
+		{Normal(0, 1), BFloat16, []byte{0xc7, 0x3f, 0x0, 0x3e, 0xfd, 0xbe, 0x9f, 0x3f, 0x7, 0x3e, 0x9a, 0x3f, 0x20, 0xbf, 0x21, 0x3f}},
+		{Uniform(-8, 7), Int4, []byte{0x3b, 0x10, 0x51, 0x87}},
+		{TruncNormal(0, 1, -2, 2), Float16, []byte{0xf0, 0xb4, 0x78, 0xbd, 0x7, 0x34, 0x1f, 0xba, 0x12, 0xbe, 0x88, 0xb4, 0xb2, 0x3a, 0x7b, 0xb4}},
+	}
+	for _, g := range golden {
+		if got := mustPacked(t, mustRandom(t, 42, g.dist, g.dt, 2, 4)); !bytes.Equal(got, g.want) {
+			t.Fatalf("%v %v: got %#v, want %#v", g.dist, g.dt, got, g.want)
+		}
+	}
+
+	// Float32 Uniform is exactly low + (high-low)*Float64() in order.
+	got := mustLoad(t, mustRandom(t, 3, Uniform(-1, 1), Float32, 4, 4))
+	r := rand.New(rand.NewSource(3))
+	for i, v := range got {
+		if want := float32(-1 + 2*r.Float64()); v != want {
+			t.Fatalf("element %d: got %v, want %v", i, v, want)
+		}
+	}
+}
+
+func TestRandomDistributions(t *testing.T) {
+	moments := func(v []float32) (mean, std float64) {
+		for _, x := range v {
+			mean += float64(x)
+		}
+		mean /= float64(len(v))
+		for _, x := range v {
+			std += (float64(x) - mean) * (float64(x) - mean)
+		}
+		return mean, math.Sqrt(std / float64(len(v)))
+	}
+	const n = 1 << 16
+
+	mean, std := moments(mustLoad(t, mustRandom(t, 1, Normal(2, 3), Float32, n)))
+	if math.Abs(mean-2) > 0.05 || math.Abs(std-3) > 0.05 {
+		t.Fatalf("Normal(2, 3): mean %v std %v", mean, std)
+	}
+
+	for _, v := range mustLoad(t, mustRandom(t, 1, TruncNormal(1, 2, 0, 1.5), Float32, n)) {
+		if v < 0 || v > 1.5 {
+			t.Fatalf("TruncNormal sample %v outside [0, 1.5]", v)
+		}
+	}
+
+	// Linear weight [out=64, in=256]: Kaiming bound sqrt(2)*sqrt(3/256).
+	bound := float32(math.Sqrt2 * math.Sqrt(3.0/256))
+	ku := mustLoad(t, mustRandom(t, 1, KaimingUniform(0), Float32, 64, 256))
+	var maxAbs float32
+	for _, v := range ku {
+		maxAbs = max(maxAbs, float32(math.Abs(float64(v))))
+	}
+	if maxAbs > bound || maxAbs < 0.98*bound {
+		t.Fatalf("KaimingUniform max |w| = %v, bound %v", maxAbs, bound)
+	}
+	// Conv weight [out=32, in=16, 3, 3]: Xavier std sqrt(2/((16+32)*9)).
+	_, std = moments(mustLoad(t, mustRandom(t, 1, XavierNormal(0), Float32, 32, 16, 3, 3)))
+	if want := math.Sqrt(2.0 / (48 * 9)); math.Abs(std-want) > 0.05*want {
+		t.Fatalf("XavierNormal std %v, want %v", std, want)
+	}
+
+	// Integer Uniform covers both inclusive ends.
+	seen := map[float32]int{}
+	for _, v := range mustLoad(t, mustRandom(t, 1, Uniform(-100, 100), Int4, 4096)) {
+		seen[v]++
+	}
+	if len(seen) != 16 || seen[-8] == 0 || seen[7] == 0 {
+		t.Fatalf("Int4 Uniform values %v", seen)
+	}
+	for _, v := range mustLoad(t, mustRandom(t, 1, Uniform(-0.5, 2.5), Int8, 1000)) {
+		if v != 0 && v != 1 && v != 2 {
+			t.Fatalf("Int8 Uniform(-0.5, 2.5) gave %v", v)
+		}
+	}
+}
+
+func TestRandomErrors(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	cases := []struct {
+		name, want string
+		fn         func() error
+	}{
+		{"nil rng", "nil rng", func() error { _, err := Random(nil, Normal(0, 1), Float32, 2); return err }},
+		{"dtype", "unsupported dtype float8_e8m0", func() error { _, err := Random(rng, Normal(0, 1), Float8E8M0, 2); return err }},
+		{"bounds", "low exceeds high", func() error { _, err := Random(rng, Uniform(1, 0), Float32, 2); return err }},
+		{"std", "negative std", func() error { _, err := Random(rng, Normal(0, -1), Float32, 2); return err }},
+		{"trunc", "low < high", func() error { _, err := Random(rng, TruncNormal(0, 1, 1, 1), Float32, 2); return err }},
+		{"no ints", "holds no int8 values", func() error { _, err := Random(rng, Uniform(0.2, 0.8), Int8, 2); return err }},
+		{"shape", "invalid shape", func() error { _, err := Random(rng, Normal(0, 1), Float32, -1); return err }},
+	}
+	for _, c := range cases {
+		err := c.fn()
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Fatalf("%s: got %v, want error containing %q", c.name, err, c.want)
+		}
+	}
+}
@@ -0,0 +1,378 @@
+package tensor
+
+import (
+	"fmt"
+	"math"
+
+	"kylesmith19091/fastgo/internal/metal"
+)
+
+// ---------- Reductions ----------
+//
+// Reductions take the dims to reduce (negative dims count from the end; no
+// dims reduces everything) and a keepdim flag that keeps reduced dims as size
+// 1 so the result broadcasts against the input. Reducing every dim without
+// keepdim yields a scalar (rank-0) tensor; a scalar input accepts dim 0 or -1,
+// as in PyTorch, and reduces to itself.
+//
+// Reducing an empty dim gives 0 for Sum and NaN for Mean and Var; Max, Min,
+// ArgMax and ArgMin have no identity and fail unless the result is empty too.
+//
+// The CPU path accumulates in float64, so Float16/BFloat16 inputs never
+// accumulate in their own precision; the Metal kernels accumulate float32 in
+// float32. Floating-point inputs keep their dtype. Sum of an integer tensor is
+// exact and returns Int64; Mean and Var of integers return Float32; Max and
+// Min keep the input dtype. Max and Min propagate NaN.
+
+type reduceOp int
+
+// Op codes are shared with reduce_f32/reduce_arg_f32; keep the order in sync.
+const (
+	opSum reduceOp = iota
+	opMean
+	opMax
+	opMin
+	opVar
+	opArgMax
+	opArgMin
+)
+
+func (op reduceOp) String() string {
+	return [...]string{"Sum", "Mean", "Max", "Min", "Var", "ArgMax", "ArgMin"}[op]
+}
+
+func (op reduceOp) isArg() bool { return op == opArgMax || op == opArgMin }
+
+// Sum adds the elements of t over dims.
+func Sum(t *Tensor, keepdim bool, dims ...int) (*Tensor, error) {
+	return reduce(opSum, nil, t, dims, keepdim, 0, 0)
+}
+
+// Mean averages the elements of t over dims.
+func Mean(t *Tensor, keepdim bool, dims ...int) (*Tensor, error) {
+	return reduce(opMean, nil, t, dims, keepdim, 0, 0)
+}
+
+// Max returns the largest element of t over dims.
+func Max(t *Tensor, keepdim bool, dims ...int) (*Tensor, error) {
+	return reduce(opMax, nil, t, dims, keepdim, 0, 0)
+}
+
+// Min returns the smallest element of t over dims.
+func Min(t *Tensor, keepdim bool, dims ...int) (*Tensor, error) {
+	return reduce(opMin, nil, t, dims, keepdim, 0, 0)
+}
+
+// Var returns the variance of t over dims, dividing the squared deviations by
+// N-correction (0 for the population variance, 1 for Bessel's correction).
+func Var(t *Tensor, correction int, keepdim bool, dims ...int) (*Tensor, error) {
+	return reduce(opVar, nil, t, dims, keepdim, correction, 0)
+}
+
+// ArgMax returns the index of the largest element along dim as an Int32 or
+// Int64 tensor. Ties resolve to the first occurrence and NaN counts as the
+// largest value.
+func ArgMax(t *Tensor, dim int, keepdim bool, indexDT DType) (*Tensor, error) {
+	return reduce(opArgMax, nil, t, []int{dim}, keepdim, 0, indexDT)
+}
+
+// ArgMin returns the index of the smallest element along dim, with the same
+// tie and NaN rules as ArgMax.
+func ArgMin(t *Tensor, dim int, keepdim bool, indexDT DType) (*Tensor, error) {
+	return reduce(opArgMin, nil, t, []int{dim}, keepdim, 0, indexDT)
+}
+
+// The Into variants write into out, which must have the result's shape and
+// dtype (for ArgMaxInto and ArgMinInto, Int32 or Int64) and must not overlap t.
+
+func SumInto(out, t *Tensor, keepdim bool, dims ...int) error {
+	return reduceInto(opSum, out, t, dims, keepdim, 0)
+}
+
+func MeanInto(out, t *Tensor, keepdim bool, dims ...int) error {
+	return reduceInto(opMean, out, t, dims, keepdim, 0)
+}
+
+func MaxInto(out, t *Tensor, keepdim bool, dims ...int) error {
+	return reduceInto(opMax, out, t, dims, keepdim, 0)
+}
+
+func MinInto(out, t *Tensor, keepdim bool, dims ...int) error {
+	return reduceInto(opMin, out, t, dims, keepdim, 0)
+}
+
+func VarInto(out, t *Tensor, correction int, keepdim bool, dims ...int) error {
+	return reduceInto(opVar, out, t, dims, keepdim, correction)
+}
+
+func ArgMaxInto(out, t *Tensor, dim int, keepdim bool) error {
+	return reduceInto(opArgMax, out, t, []int{dim}, keepdim, 0)
+}
+
+func ArgMinInto(out, t *Tensor, dim int, keepdim bool) error {
+	return reduceInto(opArgMin, out, t, []int{dim}, keepdim, 0)
+}
+
+// reduceInto runs a reduction into a caller-provided out; arg ops take their
+// index dtype from out.
+func reduceInto(op reduceOp, out, t *Tensor, dims []int, keepdim bool, correction int) error {
+	if out == nil {
+		return fmt.Errorf("%v: nil output tensor", op)
+	}
+	_, err := reduce(op, out, t, dims, keepdim, correction, out.DT)
+	return err
+}
+
+// reduceDims resolves dims against rank and returns a per-dim reduce mask.
+func reduceDims(op reduceOp, rank int, dims []int) ([]bool, error) {
+	mask := make([]bool, rank)
+	if len(dims) == 0 {
+		for i := range mask {
+			mask[i] = true
+		}
+		return mask, nil
+	}
+	for _, d := range dims {
+		// A scalar is reduced along its one implicit dim.
+		if n := max(rank, 1); d < -n || d >= n {
+			return nil, fmt.Errorf("%v: dim %d out of range for rank %d", op, d, rank)
+		}
+		if rank == 0 {
+			continue
+		}
+		if d < 0 {
+			d += rank
+		}
+		if mask[d] {
+			return nil, fmt.Errorf("%v: dim %d repeated", op, d)
+		}
+		mask[d] = true
+	}
+	return mask, nil
+}
+
+// reduceResultDType returns the output dtype of op on dt.
+func reduceResultDType(op reduceOp, dt, indexDT DType) (DType, error) {
+	switch {
+	case op.isArg():
+		if indexDT != Int32 && indexDT != Int64 {
+			return 0, fmt.Errorf("%v: index dtype must be int32 or int64, got %v", op, indexDT)
+		}
+		return indexDT, nil
+	case dt == Float8E8M0:
+		return 0, fmt.Errorf("%v: unsupported dtype %v", op, dt)
+	case isIntDType(dt) && op == opSum:
+		return Int64, nil
+	case isIntDType(dt) && (op == opMean || op == opVar):
+		return Float32, nil
+	default:
+		return dt, nil
+	}
+}
+
+// reduceLayout splits a view into the kept dims (the output elements) and the
+// reduced dims, carrying the strides it was built from.
+type reduceLayout struct {
+	outShape               []int
+	keptShape, keptStrides []int
+	redShape, redStrides   []int
+}
+
+func newReduceLayout(shape, strides []int, mask []bool, keepdim bool) reduceLayout {
+	var l reduceLayout
+	for d, n := range shape {
+		if mask[d] {
+			l.redShape = append(l.redShape, n)
+			l.redStrides = append(l.redStrides, strides[d])
+			if keepdim {
+				l.outShape = append(l.outShape, 1)
+			}
+			continue
+		}
+		l.keptShape = append(l.keptShape, n)
+		l.keptStrides = append(l.keptStrides, strides[d])
+		l.outShape = append(l.outShape, n)
+	}
+	return l
+}
+
+func reduce(op reduceOp, out, t *Tensor, dims []int, keepdim bool, correction int, indexDT DType) (*Tensor, error) {
+	if err := t.live(); err != nil {
+		return nil, fmt.Errorf("%v: %w", op, err)
+	}
+	mask, err := reduceDims(op, len(t.Shape), dims)
+	if err != nil {
+		return nil, err
+	}
+	dt, err := reduceResultDType(op, t.DT, indexDT)
+	if err != nil {
+		return nil, err
+	}
+	layout := newReduceLayout(t.Shape, t.Strides, mask, keepdim)
+	if Numel(layout.redShape) == 0 && Numel(layout.keptShape) > 0 && op != opSum && op != opMean && op != opVar {
+		return nil, fmt.Errorf("%v: cannot reduce an empty dim of %v; the op has no identity", op, t.Shape)
+	}
+	out, owned, err := prepareOutExact(op, out, t.Device(), dt, layout.outShape)
+	if err != nil {
+		return nil, err
+	}
+	if !owned && !disjoint(out, t) {
+		return nil, fmt.Errorf("%v: output overlaps the input", op)
+	}
+	if out.Device() == Meta {
+		return out, nil
+	}
+	if p, ok := reduceParams(op, out, t, layout); ok && useMetal(out, t) {
+		p.Correction = int32(correction)
+		err = metal.ReduceBuffers(p, op.isArg(), t.buf, out.buf)
+	} else {
+		err = reduceCPU(op, out, t, mask, correction)
+	}
+	if err == nil {
+		err = checkOutput(out, t)
+	}
+	if err != nil {
+		if owned {
+			_ = out.Close()
+		}
+		return nil, fmt.Errorf("%v: %w", op, err)
+	}
+	return out, nil
+}
+
+func reduceCPU(op reduceOp, out, t *Tensor, mask []bool, correction int) error {
+	// Work on the packed row-major copy, addressed in elements.
+	elemStrides := elemStridesFor(t.Shape)
+	l := newReduceLayout(t.Shape, elemStrides, mask, false)
+	redOffs := make([]int, Numel(l.redShape))
+	forEachOffset(l.redShape, l.redStrides, func(j, off int) { redOffs[j] = off })
+
+	if isIntDType(t.DT) && (op == opSum || op == opMax || op == opMin) {
+		vals, err := t.loadInt64()
+		if err != nil {
+			return err
+		}
+		res := make([]int64, Numel(l.keptShape))
+		forEachOffset(l.keptShape, l.keptStrides, func(i, base int) {
+			if op == opSum {
+				var acc int64
+				for _, off := range redOffs {
+					acc += vals[base+off]
+				}
+				res[i] = acc
+				return
+			}
+			acc := vals[base+redOffs[0]]
+			for _, off := range redOffs[1:] {
+				if op == opMax {
+					acc = max(acc, vals[base+off])
+				} else {
+					acc = min(acc, vals[base+off])
+				}
+			}
+			res[i] = acc
+		})
+		return out.storeInt64(res)
+	}
+
+	vals, err := t.loadFloat32()
+	if err != nil {
+		return err
+	}
+	if op.isArg() {
+		res := make([]int64, Numel(l.keptShape))
+		forEachOffset(l.keptShape, l.keptStrides, func(i, base int) {
+			best, bestJ := vals[base+redOffs[0]], 0
+			for j := 1; j < len(redOffs) && best == best; j++ {
+				v := vals[base+redOffs[j]]
+				if v != v || (op == opArgMax && v > best) || (op == opArgMin && v < best) {
+					best, bestJ = v, j
+				}
+			}
+			res[i] = int64(bestJ)
+		})
+		return out.storeInt64(res)
+	}
+	res := make([]float32, Numel(l.keptShape))
+	n := float64(len(redOffs))
+	forEachOffset(l.keptShape, l.keptStrides, func(i, base int) {
+		switch op {
+		case opMax, opMin:
+			acc := vals[base+redOffs[0]]
+			for _, off := range redOffs[1:] {
+				v := vals[base+off]
+				if v != v || acc != acc {
+					acc = float32(math.NaN())
+				} else if op == opMax {
+					acc = max(acc, v)
+				} else {
+					acc = min(acc, v)
+				}
+			}
+			res[i] = acc
+		default:
+			var sum float64
+			for _, off := range redOffs {
+				sum += float64(vals[base+off])
+			}
+			switch op {
+			case opSum:
+				res[i] = float32(sum)
+			case opMean:
+				res[i] = float32(sum / n)
+			case opVar:
+				mean := sum / n
+				var ss float64
+				for _, off := range redOffs {
+					d := float64(vals[base+off]) - mean
+					ss += d * d
+				}
+				res[i] = float32(ss / math.Max(n-float64(correction), 0))
+			}
+		}
+	})
+	return out.storeFloat32(res)
+}
+
+// reduceParams builds kernel params when t is float32, out is dense float32
+// (or int32 for arg ops) and every stride/offset is element-aligned; ok is
+// false otherwise.
+func reduceParams(op reduceOp, out, t *Tensor, l reduceLayout) (*metal.ReduceParams, bool) {
+	wantOut := Float32
+	if op.isArg() {
+		wantOut = Int32
+	}
+	if t.DT != Float32 || out.DT != wantOut || !out.isDense() {
+		return nil, false
+	}
+	if len(t.Shape) > metal.MaxDims || t.Numel() > math.MaxInt32 || t.Offset%4 != 0 || out.Offset%4 != 0 {
+		return nil, false
+	}
+	if t.Numel() == 0 {
+		return nil, false // empty reductions fill identities on the CPU
+	}
+	p := &metal.ReduceParams{
+		Op:      int32(op),
+		NOut:    int32(Numel(l.keptShape)),
+		NRed:    int32(Numel(l.redShape)),
+		OffIn:   int32(t.Offset / 4),
+		OffOut:  int32(out.Offset / 4),
+		OutRank: int32(len(l.keptShape)),
+		RedRank: int32(len(l.redShape)),
+	}
+	for d := range l.keptShape {
+		if l.keptStrides[d]%4 != 0 {
+			return nil, false
+		}
+		p.OutShape[d] = int32(l.keptShape[d])
+		p.OutStride[d] = int32(l.keptStrides[d] / 4)
+	}
+	for d := range l.redShape {
+		if l.redStrides[d]%4 != 0 {
+			return nil, false
+		}
+		p.RedShape[d] = int32(l.redShape[d])
+		p.RedStride[d] = int32(l.redStrides[d] / 4)
+	}
+	return p, true
+}
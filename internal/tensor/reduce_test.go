@@ -0,0 +1,243 @@
+package tensor
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// refReduce reduces a row-major [rows, cols] slice along the last axis in
+// float64.
+func refReduce(x []float32, rows, cols int, f func(row []float64) float64) []float32 {
+	out := make([]float32, rows)
+	row := make([]float64, cols)
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			row[c] = float64(x[r*cols+c])
+		}
+		out[r] = float32(f(row))
+	}
+	return out
+}
+
+func TestReduceMatchesFloat64Reference(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	const rows, cols = 5, 257
+	x := make([]float32, rows*cols)
+	for i := range x {
+		x[i] = float32(rng.NormFloat64()*4 + 1)
+	}
+	sum := func(r []float64) float64 {
+		s := 0.0
+		for _, v := range r {
+			s += v
+		}
+		return s
+	}
+	mean := func(r []float64) float64 { return sum(r) / float64(len(r)) }
+	variance := func(r []float64) float64 {
+		m, ss := mean(r), 0.0
+		for _, v := range r {
+			ss += (v - m) * (v - m)
+		}
+		return ss / float64(len(r)-1)
+	}
+	for _, dt := range []DType{Float32, Float16, BFloat16} {
+		in := mustFromFloat32(t, dt, x, rows, cols)
+		xs := mustLoad(t, in) // values as rounded to dt
+		cases := []struct {
+			name string
+			fn   func() (*Tensor, error)
+			ref  func([]float64) float64
+		}{
+			{"Sum", func() (*Tensor, error) { return Sum(in, false, -1) }, sum},
+			{"Mean", func() (*Tensor, error) { return Mean(in, false, 1) }, mean},
+			{"Var", func() (*Tensor, error) { return Var(in, 1, false, 1) }, variance},
+		}
+		for _, c := range cases {
+			out, err := c.fn()
+			if err != nil {
+				t.Fatalf("%v %s: %v", dt, c.name, err)
+			}
+			if out.DT != dt || !equalShapes(out.Shape, []int{rows}) {
+				t.Fatalf("%v %s: got %v %v", dt, c.name, out.DT, out.Shape)
+			}
+			raw, _ := encodeFloat32(dt, refReduce(xs, rows, cols, c.ref))
+			want, _ := decodeFloat32(dt, raw, rows)
+			expectValues(t, dt.String()+" "+c.name, mustLoad(t, out), want)
+			_ = out.Close()
+		}
+	}
+}
+
+func TestReduceKeepdimAndDims(t *testing.T) {
+	// [2,3,2] = 0..11
+	x := make([]float32, 12)
+	for i := range x {
+		x[i] = float32(i)
+	}
+	in := mustFromFloat32(t, Float32, x, 2, 3, 2)
+	cases := []struct {
+		keepdim bool
+		dims    []int
+		shape   []int
+		want    []float32
+	}{
+		{false, []int{1}, []int{2, 2}, []float32{6, 9, 24, 27}},
+		{true, []int{1}, []int{2, 1, 2}, []float32{6, 9, 24, 27}},
+		{true, []int{0, -1}, []int{1, 3, 1}, []float32{14, 22, 30}},
+		{true, nil, []int{1, 1, 1}, []float32{66}},
+		{false, nil, []int{}, []float32{66}},
+	}
+	for _, c := range cases {
+		out, err := Sum(in, c.keepdim, c.dims...)
+		if err != nil {
+			t.Fatalf("Sum(%v, %v): %v", c.keepdim, c.dims, err)
+		}
+		if !equalShapes(out.Shape, c.shape) {
+			t.Fatalf("Sum(%v, %v) shape %v want %v", c.keepdim, c.dims, out.Shape, c.shape)
+		}
+		expectValues(t, "Sum", mustLoad(t, out), c.want)
+		_ = out.Close()
+	}
+	if _, err := Sum(in, false, 3); err == nil {
+		t.Fatalf("expected out-of-range dim error")
+	}
+	if _, err := Sum(in, false, 1, -2); err == nil {
+		t.Fatalf("expected repeated dim error")
+	}
+}
+
+func TestReduceStridedView(t *testing.T) {
+	a := mustFromFloat32(t, Float32, []float32{1, 5, 2, 4, 0, 9}, 2, 3)
+	tr := mustView(t, a, 0, []int{3, 2}, []int{4, 12}) // [[1,4],[5,0],[2,9]]
+	out, err := Max(tr, false, 1)
+	if err != nil {
+		t.Fatalf("Max: %v", err)
+	}
+	defer out.Close()
+	expectValues(t, "Max transposed", mustLoad(t, out), []float32{4, 5, 9})
+
+	idx, err := ArgMin(tr, 0, true, Int64)
+	if err != nil {
+		t.Fatalf("ArgMin: %v", err)
+	}
+	defer idx.Close()
+	if idx.DT != Int64 || !equalShapes(idx.Shape, []int{1, 2}) {
+		t.Fatalf("ArgMin: got %v %v", idx.DT, idx.Shape)
+	}
+	got, _ := idx.loadInt64()
+	if got[0] != 0 || got[1] != 1 {
+		t.Fatalf("ArgMin transposed: got %v want [0 1]", got)
+	}
+}
+
+func TestArgMaxTiesAndNaN(t *testing.T) {
+	nan := float32(math.NaN())
+	in := mustFromFloat32(t, Float32, []float32{
+		3, 7, 7, 1,
+		2, nan, 9, nan,
+		-1, -1, -1, -1,
+	}, 3, 4)
+	for _, dt := range []DType{Int32, Int64} {
+		out, err := ArgMax(in, 1, false, dt)
+		if err != nil {
+			t.Fatalf("ArgMax(%v): %v", dt, err)
+		}
+		got, _ := out.loadInt64()
+		if out.DT != dt || got[0] != 1 || got[1] != 1 || got[2] != 0 {
+			t.Fatalf("ArgMax(%v): got %v %v want [1 1 0]", dt, out.DT, got)
+		}
+		_ = out.Close()
+	}
+	mx, err := Max(in, false, 1)
+	if err != nil {
+		t.Fatalf("Max: %v", err)
+	}
+	defer mx.Close()
+	expectValues(t, "Max NaN", mustLoad(t, mx), []float32{7, nan, -1})
+	if _, err := ArgMax(in, 1, false, Float32); err == nil {
+		t.Fatalf("expected index dtype error")
+	}
+}
+
+func TestArgMaxLargeVocab(t *testing.T) {
+	const vocab = 151936
+	x := make([]float32, 2*vocab)
+	rng := rand.New(rand.NewSource(5))
+	for i := range x {
+		x[i] = rng.Float32()
+	}
+	x[123456] = 2
+	x[vocab+151935] = 3
+	in := mustFromFloat32(t, BFloat16, x, 2, vocab)
+	out, err := ArgMax(in, -1, false, Int32)
+	if err != nil {
+		t.Fatalf("ArgMax: %v", err)
+	}
+	defer out.Close()
+	got, _ := out.loadInt64()
+	if got[0] != 123456 || got[1] != 151935 {
+		t.Fatalf("ArgMax: got %v", got)
+	}
+}
+
+func TestReduceIntegers(t *testing.T) {
+	in := mustFromFloat32(t, Int8, []float32{127, 127, 127, -128, 5, 6}, 2, 3)
+	sum, err := Sum(in, false, 1)
+	if err != nil {
+		t.Fatalf("Sum: %v", err)
+	}
+	defer sum.Close()
+	got, _ := sum.loadInt64()
+	if sum.DT != Int64 || got[0] != 381 || got[1] != -117 {
+		t.Fatalf("Sum int8: got %v %v", sum.DT, got)
+	}
+
+	big := mustFromFloat32(t, Int64, make([]float32, 2), 2)
+	if err := big.storeInt64([]int64{1 << 60, 3}); err != nil {
+		t.Fatalf("storeInt64: %v", err)
+	}
+	total, err := Sum(big, false)
+	if err != nil {
+		t.Fatalf("Sum int64: %v", err)
+	}
+	defer total.Close()
+	if g, _ := total.loadInt64(); g[0] != 1<<60+3 {
+		t.Fatalf("Sum int64: got %d", g[0])
+	}
+
+	mn, err := Min(in, false, 1)
+	if err != nil {
+		t.Fatalf("Min: %v", err)
+	}
+	defer mn.Close()
+	if g, _ := mn.loadInt64(); mn.DT != Int8 || g[0] != 127 || g[1] != -128 {
+		t.Fatalf("Min int8: got %v %v", mn.DT, g)
+	}
+
+	mean, err := Mean(in, false, 1)
+	if err != nil {
+		t.Fatalf("Mean: %v", err)
+	}
+	defer mean.Close()
+	if mean.DT != Float32 {
+		t.Fatalf("Mean int8 dtype %v", mean.DT)
+	}
+	expectValues(t, "Mean int8", mustLoad(t, mean), []float32{127, -39})
+}
+
+func TestVarCorrection(t *testing.T) {
+	in := mustFromFloat32(t, Float32, []float32{1, 2, 3, 4}, 4)
+	for _, c := range []struct {
+		correction int
+		want       float32
+	}{{0, 1.25}, {1, 5.0 / 3}} {
+		out, err := Var(in, c.correction, false)
+		if err != nil {
+			t.Fatalf("Var: %v", err)
+		}
+		expectValues(t, "Var", mustLoad(t, out), []float32{c.want})
+		_ = out.Close()
+	}
+}
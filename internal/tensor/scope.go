@@ -0,0 +1,95 @@
+package tensor
+
+import (
+	"errors"
+	"slices"
+	"sync"
+)
+
+// ---------- Scopes ----------
+//
+// A Scope frees the intermediates of a computation in one call. Tensors
+// belong to a scope only when registered through it, so scopes held by
+// different goroutines, such as one per request, never touch each other's
+// tensors:
+//
+//	s := tensor.NewScope()
+//	defer s.Close()
+//	h, err := s.Op(tensor.MatMul(x, w)) // closed by s.Close
+//	...
+//	s.Keep(result) // survives s.Close
+//
+// Scopes nest through Sub, and Keep hands results to the parent.
+
+// Scope records tensors registered with it and closes them together. Its
+// methods are safe for concurrent use.
+type Scope struct {
+	mu      sync.Mutex
+	parent  *Scope
+	tensors []*Tensor
+}
+
+// NewScope returns an empty scope.
+func NewScope() *Scope { return &Scope{} }
+
+// Sub returns an empty scope nested in s: tensors kept from it move to s.
+func (s *Scope) Sub() *Scope { return &Scope{parent: s} }
+
+// Track registers ts with s, skipping nil tensors, so s.Close closes them.
+func (s *Scope) Track(ts ...*Tensor) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range ts {
+		if t != nil {
+			s.tensors = append(s.tensors, t)
+		}
+	}
+}
+
+// Op registers the result of an op or view call with s and passes it on:
+//
+//	y, err := s.Op(tensor.Add(a, b))
+func (s *Scope) Op(t *Tensor, err error) (*Tensor, error) {
+	if err == nil {
+		s.Track(t)
+	}
+	return t, err
+}
+
+// New is the package-level New for a tensor registered with s.
+func (s *Scope) New(dt DType, shape ...int) (*Tensor, error) {
+	return s.Op(New(dt, shape...))
+}
+
+// Keep removes ts from s so its Close leaves them open, typically the results
+// a computation returns. They move to the parent scope, if any.
+func (s *Scope) Keep(ts ...*Tensor) {
+	s.mu.Lock()
+	var kept []*Tensor
+	for _, t := range ts {
+		if i := slices.Index(s.tensors, t); i >= 0 {
+			s.tensors = slices.Delete(s.tensors, i, i+1)
+			kept = append(kept, t)
+		}
+	}
+	s.mu.Unlock()
+	if s.parent != nil {
+		s.parent.Track(kept...)
+	}
+}
+
+// Close closes every tensor registered with s and not kept, newest first,
+// and empties s; tensors registered afterwards wait for the next Close.
+func (s *Scope) Close() error {
+	s.mu.Lock()
+	tensors := s.tensors
+	s.tensors = nil
+	s.mu.Unlock()
+	var errs []error
+	for i := len(tensors) - 1; i >= 0; i-- {
+		if err := tensors[i].Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,242 @@
+package tensor
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"kylesmith19091/fastgo/internal/metal"
+)
+
+// expectFreed checks whether buf has been released.
+func expectFreed(t *testing.T, name string, buf *metal.Buffer, freed bool) {
+	t.Helper()
+	if got := buf.Size() == 0; got != freed {
+		t.Fatalf("%s: buffer freed = %v, want %v", name, got, freed)
+	}
+}
+
+func TestViewsOutliveParent(t *testing.T) {
+	x := mustFromFloat32(t, Float32, []float32{1, 2, 3, 4, 5, 6}, 2, 3)
+	buf := x.Buffer()
+	row, err := x.Row(1)
+	if err != nil {
+		t.Fatalf("Row: %v", err)
+	}
+	tr, err := x.Transpose(0, 1)
+	if err != nil {
+		t.Fatalf("Transpose: %v", err)
+	}
+	flat, err := x.Reshape(6)
+	if err != nil {
+		t.Fatalf("Reshape: %v", err)
+	}
+	bc, err := row.BroadcastTo(2, 3)
+	if err != nil {
+		t.Fatalf("BroadcastTo: %v", err)
+	}
+	parts, err := flat.Split(0, 2, 4)
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+	v, err := tr.View(tr.Offset+4, []int{2}, []int{12})
+	if err != nil {
+		t.Fatalf("View: %v", err)
+	}
+
+	if err := x.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	expectFreed(t, "after parent Close", buf, false)
+	expectValues(t, "row", mustLoad(t, row), []float32{4, 5, 6})
+	expectValues(t, "transpose", mustLoad(t, tr), []float32{1, 4, 2, 5, 3, 6})
+	expectValues(t, "broadcast", mustLoad(t, bc), []float32{4, 5, 6, 4, 5, 6})
+	expectValues(t, "split", mustLoad(t, parts[1]), []float32{3, 4, 5, 6})
+	expectValues(t, "view of view", mustLoad(t, v), []float32{2, 5})
+	sum, err := Add(bc, row)
+	if err != nil {
+		t.Fatalf("Add of views: %v", err)
+	}
+	expectValues(t, "sum", mustLoad(t, sum), []float32{8, 10, 12, 8, 10, 12})
+	_ = sum.Close()
+
+	views := append([]*Tensor{row, tr, flat, bc, v}, parts...)
+	for i, w := range views {
+		expectFreed(t, "before last Close", buf, false)
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close view %d: %v", i, err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("second Close of view %d: %v", i, err)
+		}
+	}
+	expectFreed(t, "after last Close", buf, true)
+}
+
+func TestUseAfterClose(t *testing.T) {
+	x := mustFromFloat32(t, Float32, []float32{1, 2, 3, 4}, 2, 2)
+	y := mustFromFloat32(t, Float32, []float32{1, 2, 3, 4}, 2, 2)
+	defer y.Close()
+	if err := x.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := x.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+	calls := map[string]func() error{
+		"At":          func() error { _, err := x.At(0, 0); return err },
+		"Row":         func() error { _, err := x.Row(0); return err },
+		"Reshape":     func() error { _, err := x.Reshape(4); return err },
+		"View":        func() error { _, err := x.View(0, []int{1}, []int{4}); return err },
+		"BroadcastTo": func() error { _, err := x.BroadcastTo(2, 2); return err },
+		"Download":    func() error { return x.DownloadFloat32(make([]float32, 4)) },
+		"To":          func() error { _, err := x.To(Float16); return err },
+		"Add":         func() error { _, err := Add(y, x); return err },
+		"MatMul":      func() error { _, err := MatMul(x, y); return err },
+		"Sum":         func() error { _, err := Sum(x, false); return err },
+		"Softmax":     func() error { _, err := Softmax(x, -1); return err },
+		"Concat":      func() error { _, err := Concat(0, y, x); return err },
+		"AddInto":     func() error { return AddInto(x, y, y) },
+	}
+	for name, call := range calls {
+		if err := call(); !errors.Is(err, ErrClosed) {
+			t.Errorf("%s on a closed tensor: got %v, want ErrClosed", name, err)
+		}
+	}
+	if got := x.String(); got != "<closed Tensor>" {
+		t.Errorf("String of a closed tensor: %q", got)
+	}
+}
+
+// Ops take views of their inputs internally; those must not hold references
+// that keep the inputs' buffers alive.
+func TestOpsReleaseInternalViews(t *testing.T) {
+	a := mustFromFloat32(t, Float32, []float32{1, 2, 3, 4, 5, 6}, 2, 3)
+	b := mustFromFloat32(t, Float32, []float32{1, 2, 3}, 3)
+	mask := mustFromFloat32(t, Float32, []float32{0, 0, -1}, 3)
+	cond := mustFromFloat32(t, Bool, []float32{1, 0, 1}, 3)
+	bufA, bufB := a.Buffer(), b.Buffer()
+	var outs []*Tensor
+	run := func(name string, out *Tensor, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		outs = append(outs, out)
+	}
+	out, err := Add(a, b)
+	run("Add", out, err)
+	out, err = MatMul(a, b)
+	run("MatMul", out, err)
+	out, err = SoftmaxWith(a, -1, SoftmaxOptions{Mask: mask})
+	run("SoftmaxWith", out, err)
+	out, err = Stack(0, b, b)
+	run("Stack", out, err)
+	out, err = Concat(0, a, a)
+	run("Concat", out, err)
+	out, err = Where(cond, a, b)
+	run("Where", out, err)
+	for _, x := range append(outs, a, b, mask, cond) {
+		if err := x.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+	}
+	expectFreed(t, "a", bufA, true)
+	expectFreed(t, "b", bufB, true)
+}
+
+func TestScope(t *testing.T) {
+	outer := NewScope()
+	x := mustFromFloat32(t, Float32, []float32{1, 2, 3, 4}, 2, 2)
+	outer.Track(x)
+	inner := outer.Sub()
+	row, err := inner.Op(x.Row(0))
+	if err != nil {
+		t.Fatalf("Row: %v", err)
+	}
+	y, err := inner.Op(Add(row, row))
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	z, err := inner.Op(Mul(y, y))
+	if err != nil {
+		t.Fatalf("Mul: %v", err)
+	}
+	if _, err := inner.Op(x.Row(7)); err == nil {
+		t.Fatal("Row out of range: expected an error")
+	}
+	inner.Keep(z)
+	if err := inner.Close(); err != nil {
+		t.Fatalf("inner Close: %v", err)
+	}
+	for name, v := range map[string]*Tensor{"row": row, "y": y} {
+		if _, err := v.At(0); !errors.Is(err, ErrClosed) {
+			t.Fatalf("%s after inner Close: got %v, want ErrClosed", name, err)
+		}
+	}
+	expectValues(t, "kept", mustLoad(t, z), []float32{4, 16})
+	expectValues(t, "outer", mustLoad(t, x), []float32{1, 2, 3, 4})
+
+	// z moved to the outer scope when kept, so closing it frees everything.
+	bufX, bufZ := x.Buffer(), z.Buffer()
+	if err := outer.Close(); err != nil {
+		t.Fatalf("outer Close: %v", err)
+	}
+	expectFreed(t, "x", bufX, true)
+	expectFreed(t, "z", bufZ, true)
+	if err := outer.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+
+	// Tensors not registered with a scope are not its business.
+	w, err := outer.New(Float32, 1)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	free := mustFromFloat32(t, Float32, []float32{1}, 1)
+	defer free.Close()
+	if err := outer.Close(); err != nil {
+		t.Fatalf("third Close: %v", err)
+	}
+	if _, err := w.At(0); !errors.Is(err, ErrClosed) {
+		t.Errorf("tensor from Scope.New after Close: got %v, want ErrClosed", err)
+	}
+	if _, err := free.At(0); err != nil {
+		t.Errorf("untracked tensor after Close: %v", err)
+	}
+}
+
+// Scopes used by concurrent computations close only their own tensors.
+func TestScopesConcurrent(t *testing.T) {
+	const workers = 8
+	x := mustFromFloat32(t, Float32, []float32{1, 2, 3, 4}, 4)
+	defer x.Close()
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s := NewScope()
+			defer s.Close()
+			for j := 0; j < 50; j++ {
+				y, err := s.Op(MulScalar(x, 2))
+				if err == nil {
+					_, err = s.Op(Add(y, x))
+				}
+				if err == nil && j%10 == 9 {
+					err = s.Close()
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent scope: %v", err)
+	}
+}
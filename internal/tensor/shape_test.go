@@ -0,0 +1,396 @@
+package tensor
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func expectShape(t *testing.T, name string, x *Tensor, shape ...int) {
+	t.Helper()
+	if !equalShapes(x.Shape, shape) {
+		t.Fatalf("%s: shape %v, want %v", name, x.Shape, shape)
+	}
+}
+
+func TestScalarConstructorsAndViews(t *testing.T) {
+	for _, dt := range []DType{Float32, Float16, BFloat16, Int8, Int4, Float8E4M3, Float4E2M1, Int32, Int64, Bool} {
+		s, err := FromFloat32(dt, []float32{-1})
+		if err != nil {
+			t.Fatalf("FromFloat32(%v) scalar: %v", dt, err)
+		}
+		if s.Numel() != 1 || len(s.Strides) != 0 || !s.Contiguous() || s.ByteSize() != BytesFor(dt, 1) {
+			t.Fatalf("%v scalar: numel %d strides %v contiguous %v", dt, s.Numel(), s.Strides, s.Contiguous())
+		}
+		want := float32(-1)
+		if dt == Bool {
+			want = 1
+		}
+		if v, err := s.At(); err != nil || v != want {
+			t.Fatalf("%v At(): %v, %v", dt, v, err)
+		}
+		if _, err := s.At(0); err == nil {
+			t.Fatalf("%v At(0) on a scalar: expected rank error", dt)
+		}
+		expectValues(t, dt.String(), mustLoad(t, s), []float32{want})
+		_ = s.Close()
+	}
+	if _, err := FromFloat32(Float32, []float32{1, 2}); err == nil {
+		t.Fatalf("FromFloat32: two values for a scalar should fail")
+	}
+
+	x := mustFromFloat32(t, Float32, []float32{0, 1, 2, 3, 4, 5}, 2, 3)
+	el, err := x.Select(0, 1)
+	if err == nil {
+		el, err = el.Select(0, 2)
+	}
+	if err != nil {
+		t.Fatalf("Select to scalar: %v", err)
+	}
+	expectShape(t, "Select", el, []int{}...)
+	if v, _ := el.At(); v != 5 || el.Contiguous() {
+		t.Fatalf("scalar view At() = %v, contiguous %v", v, el.Contiguous())
+	}
+	if _, err := el.Select(0, 0); err == nil {
+		t.Fatalf("Select on a scalar: expected error")
+	}
+	if _, err := el.Row(0); err == nil {
+		t.Fatalf("Row on a scalar: expected error")
+	}
+	if _, err := el.Transpose(0, 0); err == nil {
+		t.Fatalf("Transpose on a scalar: expected error")
+	}
+
+	v, err := x.View(8, nil, nil)
+	if err != nil {
+		t.Fatalf("View scalar: %v", err)
+	}
+	if got, _ := v.At(); got != 2 {
+		t.Fatalf("View scalar At() = %v", got)
+	}
+	if _, err := x.View(24, nil, nil); err == nil {
+		t.Fatalf("View: a scalar past the end must be out of bounds")
+	}
+
+	one := mustFromFloat32(t, Float32, []float32{7}, 1, 1)
+	r, err := one.Reshape()
+	if err != nil {
+		t.Fatalf("Reshape to scalar: %v", err)
+	}
+	expectShape(t, "Reshape", r)
+	back, err := r.Reshape(1, 1, 1)
+	if err != nil {
+		t.Fatalf("Reshape from scalar: %v", err)
+	}
+	expectShape(t, "Reshape back", back, 1, 1, 1)
+	if _, err := x.Reshape(); err == nil {
+		t.Fatalf("Reshape of 6 elements to a scalar: expected error")
+	}
+
+	b, err := r.BroadcastTo(2, 3)
+	if err != nil {
+		t.Fatalf("BroadcastTo: %v", err)
+	}
+	expectValues(t, "BroadcastTo", mustLoad(t, b), []float32{7, 7, 7, 7, 7, 7})
+
+	c, err := r.To(Int32)
+	if err != nil {
+		t.Fatalf("To: %v", err)
+	}
+	defer c.Close()
+	expectShape(t, "To", c)
+	if got, _ := c.At(); got != 7 {
+		t.Fatalf("To scalar = %v", got)
+	}
+
+	st, err := Stack(0, r, el)
+	if err != nil {
+		t.Fatalf("Stack scalars: %v", err)
+	}
+	defer st.Close()
+	expectShape(t, "Stack", st, 2)
+	expectValues(t, "Stack", mustLoad(t, st), []float32{7, 5})
+	if _, err := Concat(0, r, el); err == nil {
+		t.Fatalf("Concat of scalars: expected error")
+	}
+
+	if got := r.String(); got != "7." {
+		t.Fatalf("String scalar = %q", got)
+	}
+	if s, err := r.Stats(); err != nil || s.Numel != 1 || s.Mean != 7 {
+		t.Fatalf("Stats scalar: %v, %v", s, err)
+	}
+
+	for _, dist := range []Distribution{Uniform(2, 3), Normal(0, 1), TruncNormal(0, 1, -0.5, 0.5)} {
+		rs, err := Random(rand.New(rand.NewSource(1)), dist, Float32)
+		if err != nil {
+			t.Fatalf("Random %v scalar: %v", dist, err)
+		}
+		expectShape(t, "Random scalar", rs)
+		v, err := rs.At()
+		if err != nil || math.IsNaN(float64(v)) {
+			t.Fatalf("Random %v scalar At() = %v, %v", dist, v, err)
+		}
+		if dist.kind == distUniform && (v < 2 || v > 3) || dist.kind == distTruncNormal && (v < -0.5 || v > 0.5) {
+			t.Fatalf("Random %v scalar = %v, out of range", dist, v)
+		}
+		_ = rs.Close()
+	}
+}
+
+func TestEmptyConstructorsAndViews(t *testing.T) {
+	for _, shape := range [][]int{{0}, {0, 4}, {3, 0}, {2, 0, 5}} {
+		for _, dt := range []DType{Float32, Int4, Bool} {
+			e, err := New(dt, shape...)
+			if err != nil {
+				t.Fatalf("New(%v, %v): %v", dt, shape, err)
+			}
+			if e.Numel() != 0 || e.ByteSize() != 0 || !e.Contiguous() {
+				t.Fatalf("%v%v: numel %d bytes %d", dt, shape, e.Numel(), e.ByteSize())
+			}
+			if got := e.String(); got != "[]" {
+				t.Fatalf("%v%v String = %q", dt, shape, got)
+			}
+			if _, err := e.At(make([]int, len(shape))...); err == nil {
+				t.Fatalf("%v%v At: expected out of bounds", dt, shape)
+			}
+			if err := e.DownloadFloat32(nil); err != nil {
+				t.Fatalf("%v%v DownloadFloat32: %v", dt, shape, err)
+			}
+			_ = e.Close()
+		}
+	}
+	if _, err := New(Float32, 2, -1); err == nil {
+		t.Fatalf("New with a negative dim: expected error")
+	}
+
+	e, err := FromFloat32(Float16, nil, 0, 4)
+	if err != nil {
+		t.Fatalf("FromFloat32 empty: %v", err)
+	}
+	defer e.Close()
+	tr, err := e.Transpose(0, 1)
+	if err != nil {
+		t.Fatalf("Transpose: %v", err)
+	}
+	expectShape(t, "Transpose", tr, 4, 0)
+	row, err := tr.Row(3)
+	if err != nil {
+		t.Fatalf("Row: %v", err)
+	}
+	expectShape(t, "Row", row, 0)
+	if _, err := e.Row(0); err == nil {
+		t.Fatalf("Row of an empty dim: expected error")
+	}
+	rs, err := e.Reshape(2, 0, 7)
+	if err != nil {
+		t.Fatalf("Reshape empty: %v", err)
+	}
+	expectShape(t, "Reshape", rs, 2, 0, 7)
+	b, err := e.BroadcastTo(3, 0, 4)
+	if err != nil {
+		t.Fatalf("BroadcastTo: %v", err)
+	}
+	if len(mustLoad(t, b)) != 0 {
+		t.Fatalf("BroadcastTo empty loaded values")
+	}
+	f32, err := e.To(Float32)
+	if err != nil {
+		t.Fatalf("To: %v", err)
+	}
+	_ = f32.Close()
+
+	// An empty view touches no bytes, so its offset may lie at or past the end
+	// of its buffer; a non-empty one may not.
+	x := mustFromFloat32(t, Float32, []float32{1, 2, 3}, 3)
+	end, err := x.View(12, []int{0}, []int{4})
+	if err != nil {
+		t.Fatalf("View at the end: %v", err)
+	}
+	if _, err := x.View(16, []int{0, 2}, []int{8, 4}); err != nil {
+		t.Fatalf("empty View past the end: %v", err)
+	}
+	if _, err := x.View(12, []int{1}, []int{4}); err == nil {
+		t.Fatalf("View past the end: expected error")
+	}
+	if _, err := x.View(-4, []int{0}, []int{4}); err == nil {
+		t.Fatalf("View at a negative offset: expected error")
+	}
+	parts, err := x.Split(0, 0, 3, 0)
+	if err != nil {
+		t.Fatalf("Split with zero sizes: %v", err)
+	}
+	expectShape(t, "Split[0]", parts[0], 0)
+	expectShape(t, "Split[2]", parts[2], 0)
+	if chunks, err := end.Chunk(0, 2); err != nil || len(chunks) != 0 {
+		t.Fatalf("Chunk of an empty dim: %v, %v", chunks, err)
+	}
+
+	cat, err := Concat(0, end, x, parts[2])
+	if err != nil {
+		t.Fatalf("Concat with empty inputs: %v", err)
+	}
+	defer cat.Close()
+	expectValues(t, "Concat", mustLoad(t, cat), []float32{1, 2, 3})
+
+	kv, err := New(Float32, 2, 0, 8) // a KV cache holding no tokens yet
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer kv.Close()
+	tok := mustFromFloat32(t, Float32, make([]float32, 16), 2, 1, 8)
+	grown, err := Concat(1, kv, tok)
+	if err != nil {
+		t.Fatalf("Concat onto an empty cache: %v", err)
+	}
+	defer grown.Close()
+	expectShape(t, "grown", grown, 2, 1, 8)
+
+	if r, err := Random(rand.New(rand.NewSource(1)), KaimingUniform(0), Float32, 0, 16); err != nil {
+		t.Fatalf("Random empty: %v", err)
+	} else {
+		_ = r.Close()
+	}
+	if _, err := Random(rand.New(rand.NewSource(1)), KaimingUniform(0), Float32); err == nil {
+		t.Fatalf("Random Kaiming scalar: expected error")
+	}
+	if m, err := NewMX(MXFP4, 0, 64); err != nil {
+		t.Fatalf("NewMX empty: %v", err)
+	} else {
+		_ = m.Close()
+	}
+	if _, err := NewMX(MXFP4); err == nil {
+		t.Fatalf("NewMX scalar: expected error")
+	}
+
+	empty, err := CausalMask(0, 5, 5)
+	if err != nil {
+		t.Fatalf("CausalMask empty: %v", err)
+	}
+	defer empty.Close()
+	expectShape(t, "CausalMask", empty, 0, 5)
+	pad, err := PaddingMask(0, nil, []int{0, 0})
+	if err != nil {
+		t.Fatalf("PaddingMask empty: %v", err)
+	}
+	defer pad.Close()
+	expectShape(t, "PaddingMask", pad, 2, 1, 0)
+}
+
+func TestScalarAndEmptyOps(t *testing.T) {
+	s := mustFromFloat32(t, Float32, []float32{2}, []int{}...)
+	x := mustFromFloat32(t, Float32, []float32{1, 2, 3}, 3)
+	e := mustFromFloat32(t, Float32, nil, 0, 3)
+
+	sum, err := Add(s, x)
+	if err != nil {
+		t.Fatalf("Add scalar: %v", err)
+	}
+	defer sum.Close()
+	expectValues(t, "Add scalar", mustLoad(t, sum), []float32{3, 4, 5})
+	sq, err := Mul(s, s)
+	if err != nil {
+		t.Fatalf("Mul scalars: %v", err)
+	}
+	defer sq.Close()
+	expectShape(t, "Mul scalars", sq)
+	expectValues(t, "Mul scalars", mustLoad(t, sq), []float32{4})
+	ee, err := Add(e, x)
+	if err != nil {
+		t.Fatalf("Add empty: %v", err)
+	}
+	defer ee.Close()
+	expectShape(t, "Add empty", ee, 0, 3)
+	if err := ExpInto(e, e); err != nil {
+		t.Fatalf("ExpInto empty: %v", err)
+	}
+
+	for _, c := range []struct {
+		name  string
+		f     func() (*Tensor, error)
+		shape []int
+		want  []float32
+	}{
+		{"Sum all", func() (*Tensor, error) { return Sum(x, false) }, []int{}, []float32{6}},
+		{"Sum scalar", func() (*Tensor, error) { return Sum(s, false, -1) }, []int{}, []float32{2}},
+		{"Max scalar", func() (*Tensor, error) { return Max(s, true) }, []int{}, []float32{2}},
+		{"ArgMax scalar", func() (*Tensor, error) { return ArgMax(s, 0, false, Int64) }, []int{}, []float32{0}},
+		{"Sum empty", func() (*Tensor, error) { return Sum(e, false, 0) }, []int{3}, []float32{0, 0, 0}},
+		{"Sum empty all", func() (*Tensor, error) { return Sum(e, false) }, []int{}, []float32{0}},
+		{"Mean empty", func() (*Tensor, error) { return Mean(e, true, 0) }, []int{1, 3}, []float32{nan32(), nan32(), nan32()}},
+		{"Max keeps empty", func() (*Tensor, error) { return Max(e, false, 1) }, []int{0}, nil},
+		{"MatMul vectors", func() (*Tensor, error) { return MatMul(x, x) }, []int{}, []float32{14}},
+		{"MatMul K=0", func() (*Tensor, error) {
+			return MatMul(mustFromFloat32(t, Float32, nil, 2, 0), mustFromFloat32(t, Float32, nil, 0, 3))
+		}, []int{2, 3}, []float32{0, 0, 0, 0, 0, 0}},
+		{"MatMul empty batch", func() (*Tensor, error) { return MatMul(mustFromFloat32(t, Float32, nil, 0, 2, 3), x) }, []int{0, 2}, nil},
+		{"Softmax scalar", func() (*Tensor, error) { return Softmax(s, -1) }, []int{}, []float32{1}},
+		{"Softmax empty", func() (*Tensor, error) { return Softmax(e, 0) }, []int{0, 3}, nil},
+		{"CumSum scalar", func() (*Tensor, error) { return CumSum(s, 0) }, []int{}, []float32{2}},
+		{"CumSum empty", func() (*Tensor, error) { return CumSum(e, 0) }, []int{0, 3}, nil},
+		{"ArgSort scalar", func() (*Tensor, error) { return ArgSort(s, 0, false) }, []int{}, []float32{0}},
+		{"MaskedFill scalar", func() (*Tensor, error) {
+			return MaskedFill(s, mustFromFloat32(t, Bool, []float32{1}, []int{}...), -1)
+		}, []int{}, []float32{-1}},
+		{"IndexSelect empty", func() (*Tensor, error) { return IndexSelect(x, 0, mustFromFloat32(t, Int32, nil, 0)) }, []int{0}, nil},
+		{"IndexSelect scalar index", func() (*Tensor, error) {
+			return IndexSelect(x, 0, mustFromFloat32(t, Int64, []float32{2}, []int{}...))
+		}, []int{}, []float32{3}},
+	} {
+		out, err := c.f()
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		expectShape(t, c.name, out, c.shape...)
+		expectValues(t, c.name, mustLoad(t, out), c.want)
+		_ = out.Close()
+	}
+
+	vals, idx, err := TopK(x, 0, 0)
+	if err != nil {
+		t.Fatalf("TopK k=0: %v", err)
+	}
+	expectShape(t, "TopK k=0", vals, 0)
+	expectShape(t, "TopK k=0 indices", idx, 0)
+	_, _ = vals.Close(), idx.Close()
+	vals, idx, err = Sort(s, 0, true)
+	if err != nil {
+		t.Fatalf("Sort scalar: %v", err)
+	}
+	expectShape(t, "Sort scalar", vals)
+	expectShape(t, "Sort scalar indices", idx)
+	_, _ = vals.Close(), idx.Close()
+
+	for _, c := range []struct {
+		name, want string
+		f          func() error
+	}{
+		{"Max empty", "no identity", func() error { _, err := Max(e, false, 0); return err }},
+		{"ArgMin empty", "no identity", func() error { _, err := ArgMin(e, 0, false, Int32); return err }},
+		{"Sum scalar dim", "out of range for rank 0", func() error { _, err := Sum(s, false, 1); return err }},
+		{"Softmax scalar dim", "out of range for rank 0", func() error { _, err := Softmax(s, 1); return err }},
+		{"TopK scalar k", "out of range", func() error { _, _, err := TopK(s, 0, 2); return err }},
+		{"MatMul scalar", "rank >= 1", func() error { _, err := MatMul(s, x); return err }},
+	} {
+		if err := c.f(); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Fatalf("%s: got %v, want error containing %q", c.name, err, c.want)
+		}
+	}
+
+	// Scalars and empty tensors round-trip through .npy like NumPy's.
+	for _, v := range []*Tensor{s, e} {
+		var buf bytes.Buffer
+		if err := WriteNPY(&buf, v); err != nil {
+			t.Fatalf("WriteNPY %v: %v", v.Shape, err)
+		}
+		back, err := ReadNPY(&buf)
+		if err != nil {
+			t.Fatalf("ReadNPY %v: %v", v.Shape, err)
+		}
+		expectShape(t, "npy", back, v.Shape...)
+		expectValues(t, "npy", mustLoad(t, back), mustLoad(t, v))
+		_ = back.Close()
+	}
+}
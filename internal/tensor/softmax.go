@@ -0,0 +1,220 @@
+package tensor
+
+import (
+	"fmt"
+	"math"
+
+	"kylesmith19091/fastgo/internal/metal"
+)
+
+// ---------- Softmax ----------
+//
+// Softmax and LogSoftmax normalise along one dim using max subtraction, so
+// large logits never overflow exp. Inputs must be Float32, Float16 or
+// BFloat16; results keep the input dtype and are accumulated in float32 or
+// wider. A row whose logits are all -Inf (e.g. fully masked) yields zeros from
+// Softmax and -Inf from LogSoftmax instead of NaN.
+
+// SoftmaxOptions configures SoftmaxWith and LogSoftmaxWith.
+type SoftmaxOptions struct {
+	// Mask, if set, is added to the scaled logits and must broadcast to the
+	// input's shape. Use -Inf to exclude positions.
+	Mask *Tensor
+	// Temperature divides the logits before the mask is added. Zero means 1.
+	Temperature float32
+}
+
+// Softmax returns exp(x) / sum(exp(x)) along dim.
+func Softmax(t *Tensor, dim int) (*Tensor, error) {
+	return softmax(false, t, dim, SoftmaxOptions{})
+}
+
+// LogSoftmax returns x - log(sum(exp(x))) along dim.
+func LogSoftmax(t *Tensor, dim int) (*Tensor, error) {
+	return softmax(true, t, dim, SoftmaxOptions{})
+}
+
+// SoftmaxWith returns the softmax of t/opts.Temperature + opts.Mask along dim.
+func SoftmaxWith(t *Tensor, dim int, opts SoftmaxOptions) (*Tensor, error) {
+	return softmax(false, t, dim, opts)
+}
+
+// LogSoftmaxWith returns the log-softmax of t/opts.Temperature + opts.Mask
+// along dim.
+func LogSoftmaxWith(t *Tensor, dim int, opts SoftmaxOptions) (*Tensor, error) {
+	return softmax(true, t, dim, opts)
+}
+
+func softmax(logOut bool, t *Tensor, dim int, opts SoftmaxOptions) (*Tensor, error) {
+	name := "Softmax"
+	if logOut {
+		name = "LogSoftmax"
+	}
+	if err := t.live(); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if t.DT != Float32 && t.DT != Float16 && t.DT != BFloat16 {
+		return nil, fmt.Errorf("%s: unsupported dtype %v", name, t.DT)
+	}
+	if len(t.Shape) == 0 {
+		v, err := scalarAlongDim(name, t, dim)
+		if err != nil {
+			return nil, err
+		}
+		out, err := softmax(logOut, v, 0, opts)
+		dropScalarDim(out)
+		return out, err
+	}
+	dim, err := normalizeDim(name, dim, len(t.Shape))
+	if err != nil {
+		return nil, err
+	}
+	if opts.Temperature < 0 || math.IsNaN(float64(opts.Temperature)) {
+		return nil, fmt.Errorf("%s: temperature must be positive, got %v", name, opts.Temperature)
+	}
+	invTemp := float32(1)
+	if opts.Temperature != 0 {
+		invTemp = 1 / opts.Temperature
+	}
+	var mask *Tensor
+	if opts.Mask != nil {
+		if err := opts.Mask.live(); err != nil {
+			return nil, fmt.Errorf("%s: mask: %w", name, err)
+		}
+		if _, err := sameDevice(t, opts.Mask); err != nil {
+			return nil, fmt.Errorf("%s: mask: %w", name, err)
+		}
+		m, err := opts.Mask.broadcastTo(t.Shape)
+		if err != nil {
+			return nil, fmt.Errorf("%s: mask: %w", name, err)
+		}
+		mask = m
+	}
+	out, err := NewOn(t.Device(), t.DT, t.Shape...)
+	if err != nil || out.Device() == Meta {
+		return out, err
+	}
+	if p, ok := softmaxParams(out, t, mask, dim); ok && useMetal(out, t) {
+		p.InvTemp = invTemp
+		if logOut {
+			p.Log = 1
+		}
+		maskBuf := t.buf
+		if mask != nil {
+			p.HasMask = 1
+			maskBuf = mask.buf
+		}
+		err = metal.SoftmaxBuffers(p, t.buf, maskBuf, out.buf)
+	} else {
+		err = softmaxCPU(logOut, out, t, mask, dim, invTemp)
+	}
+	if err == nil {
+		err = checkOutput(out, t, opts.Mask)
+	}
+	if err != nil {
+		_ = out.Close()
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return out, nil
+}
+
+func softmaxCPU(logOut bool, out, t, mask *Tensor, dim int, invTemp float32) error {
+	x, err := t.loadFloat32()
+	if err != nil {
+		return err
+	}
+	for i := range x {
+		x[i] *= invTemp
+	}
+	if mask != nil {
+		m, err := mask.loadFloat32()
+		if err != nil {
+			return err
+		}
+		for i := range x {
+			x[i] += m[i]
+		}
+	}
+	elemStrides := elemStridesFor(t.Shape)
+	dims := make([]bool, len(t.Shape))
+	dims[dim] = true
+	l := newReduceLayout(t.Shape, elemStrides, dims, false)
+	n, step := l.redShape[0], l.redStrides[0]
+	forEachOffset(l.keptShape, l.keptStrides, func(_, base int) {
+		mx := math.Inf(-1)
+		for j := 0; j < n; j++ {
+			mx = math.Max(mx, float64(x[base+j*step]))
+		}
+		if math.IsInf(mx, -1) {
+			fill := float32(0)
+			if logOut {
+				fill = float32(math.Inf(-1))
+			}
+			for j := 0; j < n; j++ {
+				x[base+j*step] = fill
+			}
+			return
+		}
+		var sum float64
+		for j := 0; j < n; j++ {
+			sum += math.Exp(float64(x[base+j*step]) - mx)
+		}
+		lse := math.Log(sum)
+		for j := 0; j < n; j++ {
+			v := float64(x[base+j*step]) - mx
+			if logOut {
+				x[base+j*step] = float32(v - lse)
+			} else {
+				x[base+j*step] = float32(math.Exp(v) / sum)
+			}
+		}
+	})
+	return out.storeFloat32(x)
+}
+
+// softmaxParams builds kernel params when the input, mask and output are
+// float32 and element-aligned; ok is false otherwise.
+func softmaxParams(out, t, mask *Tensor, dim int) (*metal.SoftmaxParams, bool) {
+	if t.DT != Float32 || out.DT != Float32 || (mask != nil && mask.DT != Float32) {
+		return nil, false
+	}
+	if len(t.Shape) > metal.MaxDims || t.Numel() > math.MaxInt32 || t.Numel() == 0 {
+		return nil, false
+	}
+	m := t // stands in for the mask when there is none; the kernel ignores it
+	if mask != nil {
+		m = mask
+	}
+	for _, v := range []*Tensor{t, out, m} {
+		if v.Offset%4 != 0 {
+			return nil, false
+		}
+		for _, s := range v.Strides {
+			if s%4 != 0 {
+				return nil, false
+			}
+		}
+	}
+	p := &metal.SoftmaxParams{
+		NRows:      int32(t.Numel() / t.Shape[dim]),
+		N:          int32(t.Shape[dim]),
+		OffIn:      int32(t.Offset / 4),
+		OffMask:    int32(m.Offset / 4),
+		OffOut:     int32(out.Offset / 4),
+		StrideIn:   int32(t.Strides[dim] / 4),
+		StrideMask: int32(m.Strides[dim] / 4),
+		StrideOut:  int32(out.Strides[dim] / 4),
+	}
+	for d := range t.Shape {
+		if d == dim {
+			continue
+		}
+		r := p.Rank
+		p.Shape[r] = int32(t.Shape[d])
+		p.InStride[r] = int32(t.Strides[d] / 4)
+		p.MaskStride[r] = int32(m.Strides[d] / 4)
+		p.OutStride[r] = int32(out.Strides[d] / 4)
+		p.Rank++
+	}
+	return p, true
+}
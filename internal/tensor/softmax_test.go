@@ -0,0 +1,147 @@
+package tensor
+
+import (
+	"math"
+	"testing"
+)
+
+// refSoftmax computes a float64 softmax (or log-softmax) of one row.
+func refSoftmax(row []float64, logOut bool) []float32 {
+	mx := math.Inf(-1)
+	for _, v := range row {
+		mx = math.Max(mx, v)
+	}
+	sum := 0.0
+	for _, v := range row {
+		sum += math.Exp(v - mx)
+	}
+	out := make([]float32, len(row))
+	for i, v := range row {
+		if logOut {
+			out[i] = float32(v - mx - math.Log(sum))
+		} else {
+			out[i] = float32(math.Exp(v-mx) / sum)
+		}
+	}
+	return out
+}
+
+func expectClose(t *testing.T, name string, got, want []float32, tol float64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: len %d want %d", name, len(got), len(want))
+	}
+	for i := range want {
+		g, w := float64(got[i]), float64(want[i])
+		if g == w {
+			continue
+		}
+		if math.IsNaN(g) || math.IsNaN(w) || math.Abs(g-w) > tol*math.Max(1, math.Abs(w)) {
+			t.Fatalf("%s @%d: got %v want %v", name, i, got[i], want[i])
+		}
+	}
+}
+
+func TestSoftmaxLargeLogits(t *testing.T) {
+	rows := [][]float64{
+		{1e30, 1e30 - 1e24, -1e30, 0},
+		{88, 89, 90, 91}, // exp overflows float32 without max subtraction
+		{-5, 0, 5, 1},
+	}
+	var flat []float32
+	var wantSM, wantLSM []float32
+	for _, r := range rows {
+		for i, v := range r {
+			flat = append(flat, float32(v))
+			r[i] = float64(float32(v)) // reference sees the stored values
+		}
+		wantSM = append(wantSM, refSoftmax(r, false)...)
+		wantLSM = append(wantLSM, refSoftmax(r, true)...)
+	}
+	in := mustFromFloat32(t, Float32, flat, 3, 4)
+	sm, err := Softmax(in, -1)
+	if err != nil {
+		t.Fatalf("Softmax: %v", err)
+	}
+	defer sm.Close()
+	expectClose(t, "Softmax", mustLoad(t, sm), wantSM, 1e-6)
+	lsm, err := LogSoftmax(in, 1)
+	if err != nil {
+		t.Fatalf("LogSoftmax: %v", err)
+	}
+	defer lsm.Close()
+	expectClose(t, "LogSoftmax", mustLoad(t, lsm), wantLSM, 1e-6)
+}
+
+func TestSoftmaxMaskAndTemperature(t *testing.T) {
+	ninf := float32(math.Inf(-1))
+	logits := []float32{1, 2, 3, 4, 5, 6, 7, 8}
+	// Causal-style [2,4] mask broadcast over a leading batch of 1; the second
+	// row masks everything.
+	mask := mustFromFloat32(t, Float32, []float32{0, 0, ninf, ninf, ninf, ninf, ninf, ninf}, 2, 4)
+	for _, dt := range []DType{Float32, Float16, BFloat16} {
+		in := mustFromFloat32(t, dt, logits, 1, 2, 4)
+		xs := mustLoad(t, in)
+		opts := SoftmaxOptions{Mask: mask, Temperature: 0.5}
+		sm, err := SoftmaxWith(in, 2, opts)
+		if err != nil {
+			t.Fatalf("%v SoftmaxWith: %v", dt, err)
+		}
+		if sm.DT != dt || !equalShapes(sm.Shape, []int{1, 2, 4}) {
+			t.Fatalf("%v SoftmaxWith: got %v %v", dt, sm.DT, sm.Shape)
+		}
+		head := refSoftmax([]float64{float64(xs[0]) * 2, float64(xs[1]) * 2}, false)
+		want := []float32{head[0], head[1], 0, 0, 0, 0, 0, 0}
+		raw, _ := encodeFloat32(dt, want)
+		want, _ = decodeFloat32(dt, raw, len(want))
+		expectClose(t, dt.String()+" SoftmaxWith", mustLoad(t, sm), want, 1e-2)
+		_ = sm.Close()
+
+		lsm, err := LogSoftmaxWith(in, -1, opts)
+		if err != nil {
+			t.Fatalf("%v LogSoftmaxWith: %v", dt, err)
+		}
+		got := mustLoad(t, lsm)
+		for i := 2; i < 8; i++ {
+			if !math.IsInf(float64(got[i]), -1) {
+				t.Fatalf("%v LogSoftmaxWith @%d: got %v want -Inf", dt, i, got[i])
+			}
+		}
+		if math.Abs(float64(got[0])+math.Log1p(math.Exp(2))) > 2e-2 {
+			t.Fatalf("%v LogSoftmaxWith @0: got %v", dt, got[0])
+		}
+		_ = lsm.Close()
+	}
+}
+
+func TestSoftmaxAlongLeadingDimOfView(t *testing.T) {
+	a := mustFromFloat32(t, Float32, []float32{0, 1, 2, 3, 4, 5}, 2, 3)
+	tr := mustView(t, a, 0, []int{3, 2}, []int{4, 12}) // [[0,3],[1,4],[2,5]]
+	sm, err := Softmax(tr, 0)
+	if err != nil {
+		t.Fatalf("Softmax: %v", err)
+	}
+	defer sm.Close()
+	col0 := refSoftmax([]float64{0, 1, 2}, false)
+	col1 := refSoftmax([]float64{3, 4, 5}, false)
+	want := []float32{col0[0], col1[0], col0[1], col1[1], col0[2], col1[2]}
+	expectClose(t, "Softmax dim 0", mustLoad(t, sm), want, 1e-6)
+}
+
+func TestSoftmaxErrors(t *testing.T) {
+	in := mustFromFloat32(t, Float32, []float32{1, 2, 3, 4}, 2, 2)
+	if _, err := Softmax(in, 2); err == nil {
+		t.Fatalf("expected dim error")
+	}
+	if _, err := SoftmaxWith(in, 1, SoftmaxOptions{Temperature: -1}); err == nil {
+		t.Fatalf("expected temperature error")
+	}
+	bad := mustFromFloat32(t, Float32, []float32{0, 0, 0}, 3)
+	if _, err := SoftmaxWith(in, 1, SoftmaxOptions{Mask: bad}); err == nil {
+		t.Fatalf("expected mask broadcast error")
+	}
+	i8 := mustFromFloat32(t, Int8, []float32{1, 2}, 2)
+	if _, err := Softmax(i8, 0); err == nil {
+		t.Fatalf("expected dtype error")
+	}
+}
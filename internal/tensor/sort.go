@@ -0,0 +1,432 @@
+package tensor
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"kylesmith19091/fastgo/internal/metal"
+)
+
+// ---------- Sorting and scans ----------
+//
+// TopK, Sort and ArgSort order NaN above every number and are stable: equal
+// values keep their original order, so ties resolve to the lower index.
+// Indices are returned as Int64. Values keep the input dtype.
+//
+// Float32 inputs on Metal sort on the GPU, one thread per row, with no host
+// round trip. Other dtypes and devices sort on the host: the tensor is read
+// back in full and the indices uploaded again, which on the sampling path
+// costs a device sync per call.
+
+// TopK returns the k largest entries of t along dim in descending order,
+// with their indices. Float32 inputs with k <= metal.TopKMax run a
+// k-entry insertion kernel on Metal and larger k a full GPU sort; the CPU
+// path keeps a k-entry heap per row, so picking a few candidates from a
+// 150k-entry vocabulary costs one pass over the logits.
+func TopK(t *Tensor, dim, k int) (values, indices *Tensor, err error) {
+	const op = "TopK"
+	if t != nil && len(t.Shape) == 0 {
+		v, err := scalarAlongDim(op, t, dim)
+		if err != nil {
+			return nil, nil, err
+		}
+		values, indices, err = TopK(v, 0, k)
+		dropScalarDim(values, indices)
+		return values, indices, err
+	}
+	d, err := checkSortOperand(op, t, dim)
+	if err != nil {
+		return nil, nil, err
+	}
+	if k < 0 || k > t.Shape[d] {
+		return nil, nil, fmt.Errorf("%s: k=%d out of range for dim %d of size %d", op, k, d, t.Shape[d])
+	}
+	shape := append([]int(nil), t.Shape...)
+	shape[d] = k
+	if values, err = NewOn(t.Device(), t.DT, shape...); err != nil {
+		return nil, nil, err
+	}
+	if indices, err = NewOn(t.Device(), Int64, shape...); err != nil {
+		_ = values.Close()
+		return nil, nil, err
+	}
+	if t.Device() == Meta {
+		return values, indices, nil
+	}
+	if p, ok := rowOpParams(t, values, d); ok && useMetal(t, values, indices) && k > 0 && k <= metal.TopKMax {
+		p.K = int32(k)
+		err = metal.TopKBuffers(p, t.buf, values.buf, indices.buf)
+	} else if ok && useMetal(t, values, indices) && k > 0 {
+		err = topKBySort(t, values, indices, d)
+	} else if err = sortCPU(t, indices, d, k, true); err == nil {
+		err = indexCopy(op, t, values, indices, d, false)
+	}
+	if err == nil {
+		err = checkOutput(values, t)
+	}
+	if err != nil {
+		_ = values.Close()
+		_ = indices.Close()
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return values, indices, nil
+}
+
+// Sort returns t sorted along dim, ascending unless descending is set, and
+// the indices that produce that order.
+func Sort(t *Tensor, dim int, descending bool) (values, indices *Tensor, err error) {
+	const op = "Sort"
+	if t != nil && len(t.Shape) == 0 {
+		v, err := scalarAlongDim(op, t, dim)
+		if err != nil {
+			return nil, nil, err
+		}
+		values, indices, err = Sort(v, 0, descending)
+		dropScalarDim(values, indices)
+		return values, indices, err
+	}
+	d, err := checkSortOperand(op, t, dim)
+	if err != nil {
+		return nil, nil, err
+	}
+	if values, err = NewOn(t.Device(), t.DT, t.Shape...); err != nil {
+		return nil, nil, err
+	}
+	if indices, err = NewOn(t.Device(), Int64, t.Shape...); err != nil {
+		_ = values.Close()
+		return nil, nil, err
+	}
+	if t.Device() == Meta {
+		return values, indices, nil
+	}
+	ok, err := sortMetal(t, values, indices, d, descending)
+	if !ok && err == nil {
+		if err = sortCPU(t, indices, d, t.Shape[d], descending); err == nil {
+			err = indexCopy(op, t, values, indices, d, false)
+		}
+	}
+	if err == nil {
+		err = checkOutput(values, t)
+	}
+	if err != nil {
+		_ = values.Close()
+		_ = indices.Close()
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return values, indices, nil
+}
+
+// ArgSort returns the Int64 indices that sort t along dim.
+func ArgSort(t *Tensor, dim int, descending bool) (*Tensor, error) {
+	const op = "ArgSort"
+	if t != nil && len(t.Shape) == 0 {
+		v, err := scalarAlongDim(op, t, dim)
+		if err != nil {
+			return nil, err
+		}
+		indices, err := ArgSort(v, 0, descending)
+		dropScalarDim(indices)
+		return indices, err
+	}
+	d, err := checkSortOperand(op, t, dim)
+	if err != nil {
+		return nil, err
+	}
+	indices, err := NewOn(t.Device(), Int64, t.Shape...)
+	if err != nil || indices.Device() == Meta {
+		return indices, err
+	}
+	ok := false
+	if t.DT == Float32 && useMetal(t, indices) {
+		var values *Tensor
+		if values, err = NewOn(Metal, Float32, t.Shape...); err == nil {
+			ok, err = sortMetal(t, values, indices, d, descending)
+			_ = values.Close()
+		}
+	}
+	if !ok && err == nil {
+		err = sortCPU(t, indices, d, t.Shape[d], descending)
+	}
+	if err != nil {
+		_ = indices.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return indices, nil
+}
+
+// CumSum returns the inclusive running sum of t along dim. Float inputs keep
+// their dtype and the CPU path accumulates in float64; integer inputs are
+// summed exactly into Int64.
+func CumSum(t *Tensor, dim int) (*Tensor, error) {
+	const op = "CumSum"
+	if t != nil && len(t.Shape) == 0 {
+		v, err := scalarAlongDim(op, t, dim)
+		if err != nil {
+			return nil, err
+		}
+		out, err := CumSum(v, 0)
+		dropScalarDim(out)
+		return out, err
+	}
+	d, err := checkSortOperand(op, t, dim)
+	if err != nil {
+		return nil, err
+	}
+	dt := t.DT
+	if isIntDType(dt) {
+		dt = Int64
+	}
+	out, err := NewOn(t.Device(), dt, t.Shape...)
+	if err != nil || out.Device() == Meta {
+		return out, err
+	}
+	if p, ok := rowOpParams(t, out, d); ok && useMetal(t, out) {
+		err = metal.CumSumBuffers(p, t.buf, out.buf)
+	} else {
+		err = cumSumCPU(t, out, d)
+	}
+	if err == nil {
+		err = checkOutput(out, t)
+	}
+	if err != nil {
+		_ = out.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return out, nil
+}
+
+func checkSortOperand(op string, t *Tensor, dim int) (int, error) {
+	if err := t.live(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if t.DT == Float8E8M0 || is4Bit(t.DT) {
+		return 0, fmt.Errorf("%s: unsupported dtype %v", op, t.DT)
+	}
+	return normalizeDim(op, dim, len(t.Shape))
+}
+
+// rows describes the packed row-major copy of t as rows along dim: base
+// element offsets of each row and the element step within a row.
+func rows(t *Tensor, dim int) (bases []int, step int) {
+	mask := make([]bool, len(t.Shape))
+	mask[dim] = true
+	l := newReduceLayout(t.Shape, elemStridesFor(t.Shape), mask, false)
+	bases = make([]int, Numel(l.keptShape))
+	forEachOffset(l.keptShape, l.keptStrides, func(i, off int) { bases[i] = off })
+	return bases, l.redStrides[0]
+}
+
+// sortGreater orders NaN above every number.
+func sortGreater(a, b float64) bool {
+	if a != a {
+		return b == b
+	}
+	return b == b && a > b
+}
+
+// sortCPU writes the positions of the first k entries of each row of t,
+// ordered stably (descending or ascending), into indices.
+func sortCPU(t, indices *Tensor, dim, k int, descending bool) error {
+	x, err := t.loadFloat64()
+	if err != nil {
+		return err
+	}
+	n := t.Shape[dim]
+	bases, step := rows(t, dim)
+	outBases, outStep := rows(indices, dim)
+	idx := make([]int64, indices.Numel())
+	order := make([]int, n)
+	for r, base := range bases {
+		at := func(j int) float64 { return x[base+j*step] }
+		if k < n/8 && descending {
+			order = topKHeap(order[:0], at, n, k)
+		} else {
+			order = order[:n]
+			for j := range order {
+				order[j] = j
+			}
+			slices.SortStableFunc(order, func(a, b int) int {
+				va, vb := at(a), at(b)
+				if !descending {
+					va, vb = vb, va
+				}
+				switch {
+				case sortGreater(va, vb):
+					return -1
+				case sortGreater(vb, va):
+					return 1
+				}
+				return 0
+			})
+		}
+		for j := 0; j < k; j++ {
+			idx[outBases[r]+j*outStep] = int64(order[j])
+		}
+	}
+	return indices.storeInt64(idx)
+}
+
+// sortMetal sorts the rows of t along dim on the GPU into values and
+// indices, fresh dense tensors shaped like t. ok is false, with nothing
+// written, when the kernel does not apply.
+func sortMetal(t, values, indices *Tensor, dim int, descending bool) (ok bool, err error) {
+	p, ok := rowOpParams(t, values, dim)
+	if !ok || !useMetal(t, values, indices) {
+		return false, nil
+	}
+	if descending {
+		p.Descending = 1
+	}
+	scratch, err := NewOn(Metal, Int64, t.Shape...)
+	if err != nil {
+		return true, err
+	}
+	defer scratch.Close()
+	return true, metal.ArgSortBuffers(p, t.buf, values.buf, indices.buf, scratch.buf)
+}
+
+// topKBySort fills values and indices, TopK's outputs along dim, with the
+// leading entries of a full descending GPU sort of t.
+func topKBySort(t, values, indices *Tensor, dim int) error {
+	sorted, err := NewOn(Metal, Float32, t.Shape...)
+	if err != nil {
+		return err
+	}
+	defer sorted.Close()
+	order, err := NewOn(Metal, Int64, t.Shape...)
+	if err != nil {
+		return err
+	}
+	defer order.Close()
+	if _, err := sortMetal(t, sorted, order, dim, true); err != nil {
+		return err
+	}
+	for _, c := range [][2]*Tensor{{values, sorted}, {indices, order}} {
+		head, err := c[1].view(0, values.Shape, c[1].Strides)
+		if err != nil {
+			return err
+		}
+		if err := copyView(c[0], head); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// topKHeap returns the positions of the k largest of at(0..n-1) in
+// descending order, ties by lower position, using a min-heap of size k whose
+// root is the current worst candidate.
+func topKHeap(heap []int, at func(int) float64, n, k int) []int {
+	// worse reports whether position a ranks below position b.
+	worse := func(a, b int) bool {
+		va, vb := at(a), at(b)
+		if sortGreater(vb, va) {
+			return true
+		}
+		return !sortGreater(va, vb) && a > b
+	}
+	down := func(i int) {
+		for {
+			c := 2*i + 1
+			if c >= len(heap) {
+				return
+			}
+			if c+1 < len(heap) && worse(heap[c+1], heap[c]) {
+				c++
+			}
+			if !worse(heap[c], heap[i]) {
+				return
+			}
+			heap[i], heap[c] = heap[c], heap[i]
+			i = c
+		}
+	}
+	for j := 0; j < n; j++ {
+		if len(heap) < k {
+			heap = append(heap, j)
+			for i := len(heap) - 1; i > 0 && worse(heap[i], heap[(i-1)/2]); i = (i - 1) / 2 {
+				heap[i], heap[(i-1)/2] = heap[(i-1)/2], heap[i]
+			}
+			continue
+		}
+		if worse(heap[0], j) {
+			heap[0] = j
+			down(0)
+		}
+	}
+	slices.SortFunc(heap, func(a, b int) int {
+		if worse(b, a) {
+			return -1
+		}
+		return 1
+	})
+	return heap
+}
+
+func cumSumCPU(t, out *Tensor, dim int) error {
+	bases, step := rows(t, dim)
+	n := t.Shape[dim]
+	if isIntDType(t.DT) {
+		x, err := t.loadInt64()
+		if err != nil {
+			return err
+		}
+		for _, base := range bases {
+			var acc int64
+			for j := 0; j < n; j++ {
+				acc += x[base+j*step]
+				x[base+j*step] = acc
+			}
+		}
+		return out.storeInt64(x)
+	}
+	x, err := t.loadFloat32()
+	if err != nil {
+		return err
+	}
+	for _, base := range bases {
+		var acc float64
+		for j := 0; j < n; j++ {
+			acc += float64(x[base+j*step])
+			x[base+j*step] = float32(acc)
+		}
+	}
+	return out.storeFloat32(x)
+}
+
+// rowOpParams builds row-kernel params when t and out are float32, out is a
+// fresh dense tensor and t's layout is element-aligned; ok is false
+// otherwise. out's shape may differ from t's along dim.
+func rowOpParams(t, out *Tensor, dim int) (*metal.RowOpParams, bool) {
+	if t.DT != Float32 || out.DT != Float32 || !out.Contiguous() {
+		return nil, false
+	}
+	if len(t.Shape) > metal.MaxDims || t.Numel() > math.MaxInt32 || t.Numel() == 0 || t.Offset%4 != 0 {
+		return nil, false
+	}
+	for _, s := range t.Strides {
+		if s%4 != 0 {
+			return nil, false
+		}
+	}
+	p := &metal.RowOpParams{
+		NRows:     int32(t.Numel() / t.Shape[dim]),
+		N:         int32(t.Shape[dim]),
+		K:         int32(out.Shape[dim]),
+		OffIn:     int32(t.Offset / 4),
+		StrideIn:  int32(t.Strides[dim] / 4),
+		StrideOut: int32(out.Strides[dim] / 4),
+	}
+	for d := range t.Shape {
+		if d == dim {
+			continue
+		}
+		r := p.Rank
+		p.Shape[r] = int32(t.Shape[d])
+		p.InStride[r] = int32(t.Strides[d] / 4)
+		p.OutStride[r] = int32(out.Strides[d] / 4)
+		p.Rank++
+	}
+	return p, true
+}
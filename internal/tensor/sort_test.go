@@ -0,0 +1,244 @@
+package tensor
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestTopKLargeVocab(t *testing.T) {
+	const vocab = 151936
+	rng := rand.New(rand.NewSource(9))
+	x := make([]float32, 2*vocab)
+	for i := range x {
+		x[i] = rng.Float32()
+	}
+	in := mustFromFloat32(t, Float32, x, 2, vocab)
+	const k = 40
+	vals, idx, err := TopK(in, -1, k)
+	if err != nil {
+		t.Fatalf("TopK: %v", err)
+	}
+	defer vals.Close()
+	defer idx.Close()
+	if vals.DT != Float32 || idx.DT != Int64 || !equalShapes(idx.Shape, []int{2, k}) {
+		t.Fatalf("TopK: got %v %v %v", vals.DT, idx.DT, idx.Shape)
+	}
+	gotIdx, _ := idx.loadInt64()
+	gotVals := mustLoad(t, vals)
+	for r := 0; r < 2; r++ {
+		row := x[r*vocab : (r+1)*vocab]
+		order := make([]int, vocab)
+		for i := range order {
+			order[i] = i
+		}
+		slices.SortStableFunc(order, func(a, b int) int {
+			switch {
+			case row[a] > row[b]:
+				return -1
+			case row[a] < row[b]:
+				return 1
+			}
+			return 0
+		})
+		for j := 0; j < k; j++ {
+			if gotIdx[r*k+j] != int64(order[j]) || gotVals[r*k+j] != row[order[j]] {
+				t.Fatalf("row %d rank %d: got %d (%v), want %d (%v)", r, j, gotIdx[r*k+j], gotVals[r*k+j], order[j], row[order[j]])
+			}
+		}
+	}
+}
+
+func TestTopKTiesAndNaN(t *testing.T) {
+	nan := float32(math.NaN())
+	in := mustFromFloat32(t, Float16, []float32{1, 3, 3, nan, 2, 3, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, 16)
+	// k < n/8 selects through the heap, k >= n/8 through a full sort.
+	for _, k := range []int{1, 5} {
+		vals, idx, err := TopK(in, 0, k)
+		if err != nil {
+			t.Fatalf("TopK(%d): %v", k, err)
+		}
+		got, _ := idx.loadInt64()
+		want := []int64{3, 1, 2, 5, 4}[:k]
+		if !slices.Equal(got, want) {
+			t.Fatalf("TopK(%d) indices %v want %v", k, got, want)
+		}
+		if vals.DT != Float16 {
+			t.Fatalf("TopK values dtype %v", vals.DT)
+		}
+		_ = vals.Close()
+		_ = idx.Close()
+	}
+	if _, _, err := TopK(in, 0, 17); err == nil {
+		t.Fatalf("expected k range error")
+	}
+}
+
+func TestSortAndArgSortStable(t *testing.T) {
+	// Sort along dim 0 of a [4,2] tensor: column 0 has ties.
+	in := mustFromFloat32(t, Int32, []float32{
+		2, 40,
+		1, 10,
+		2, 30,
+		1, 20,
+	}, 4, 2)
+	vals, idx, err := Sort(in, 0, false)
+	if err != nil {
+		t.Fatalf("Sort: %v", err)
+	}
+	defer vals.Close()
+	defer idx.Close()
+	gotIdx, _ := idx.loadInt64()
+	if !slices.Equal(gotIdx, []int64{1, 1, 3, 3, 0, 2, 2, 0}) {
+		t.Fatalf("Sort indices %v", gotIdx)
+	}
+	gotVals, _ := vals.loadInt64()
+	if vals.DT != Int32 || !slices.Equal(gotVals, []int64{1, 10, 1, 20, 2, 30, 2, 40}) {
+		t.Fatalf("Sort values %v %v", vals.DT, gotVals)
+	}
+
+	desc, err := ArgSort(in, 0, true)
+	if err != nil {
+		t.Fatalf("ArgSort: %v", err)
+	}
+	defer desc.Close()
+	if got, _ := desc.loadInt64(); !slices.Equal(got, []int64{0, 0, 2, 2, 1, 3, 3, 1}) {
+		t.Fatalf("ArgSort descending %v", got)
+	}
+}
+
+// Float32 rows take the GPU sort when Metal is ready; ties, NaN, a strided
+// dim and TopK past metal.TopKMax must all match a stable host sort.
+func TestSortFloat32Rows(t *testing.T) {
+	const rows, n = 3, 200
+	rng := rand.New(rand.NewSource(35))
+	x := make([]float32, rows*n)
+	for i := range x {
+		x[i] = float32(rng.Intn(9) - 4)
+		if rng.Intn(25) == 0 {
+			x[i] = float32(math.NaN())
+		}
+	}
+	in := mustFromFloat32(t, Float32, x, rows, n)
+	stable := func(r int, descending bool) []int64 {
+		order := make([]int64, n)
+		for i := range order {
+			order[i] = int64(i)
+		}
+		slices.SortStableFunc(order, func(a, b int64) int {
+			va, vb := float64(x[r*n+int(a)]), float64(x[r*n+int(b)])
+			if !descending {
+				va, vb = vb, va
+			}
+			switch {
+			case sortGreater(va, vb):
+				return -1
+			case sortGreater(vb, va):
+				return 1
+			}
+			return 0
+		})
+		return order
+	}
+	inT, err := in.Transpose(0, 1)
+	if err != nil {
+		t.Fatalf("Transpose: %v", err)
+	}
+	for _, descending := range []bool{false, true} {
+		vals, idx, err := Sort(in, 1, descending)
+		if err != nil {
+			t.Fatalf("Sort: %v", err)
+		}
+		argT, err := ArgSort(inT, 0, descending)
+		if err != nil {
+			t.Fatalf("ArgSort: %v", err)
+		}
+		got, _ := idx.loadInt64()
+		gotT, _ := argT.loadInt64()
+		gotVals := mustLoad(t, vals)
+		for r := 0; r < rows; r++ {
+			want := stable(r, descending)
+			for j, w := range want {
+				v, wv := gotVals[r*n+j], x[r*n+int(w)]
+				if got[r*n+j] != w || gotT[j*rows+r] != w || (v != wv && v == v) {
+					t.Fatalf("descending=%v row %d rank %d: Sort %d (%v), ArgSort of transpose %d, want %d",
+						descending, r, j, got[r*n+j], gotVals[r*n+j], gotT[j*rows+r], w)
+				}
+			}
+		}
+		_, _, _ = vals.Close(), idx.Close(), argT.Close()
+	}
+	const k = 100
+	vals, idx, err := TopK(in, 1, k)
+	if err != nil {
+		t.Fatalf("TopK: %v", err)
+	}
+	defer vals.Close()
+	defer idx.Close()
+	got, _ := idx.loadInt64()
+	for r := 0; r < rows; r++ {
+		if want := stable(r, true)[:k]; !slices.Equal(got[r*k:(r+1)*k], want) {
+			t.Fatalf("TopK(%d) row %d: %v want %v", k, r, got[r*k:(r+1)*k], want)
+		}
+	}
+}
+
+func TestCumSum(t *testing.T) {
+	in := mustFromFloat32(t, Float32, []float32{1, 2, 3, 4, 5, 6}, 2, 3)
+	rowsum, err := CumSum(in, 1)
+	if err != nil {
+		t.Fatalf("CumSum: %v", err)
+	}
+	defer rowsum.Close()
+	expectValues(t, "CumSum dim 1", mustLoad(t, rowsum), []float32{1, 3, 6, 4, 9, 15})
+
+	colsum, err := CumSum(in, 0)
+	if err != nil {
+		t.Fatalf("CumSum: %v", err)
+	}
+	defer colsum.Close()
+	expectValues(t, "CumSum dim 0", mustLoad(t, colsum), []float32{1, 2, 3, 5, 7, 9})
+
+	// Top-p style: cumulative probabilities of sorted bf16 values.
+	probs := mustFromFloat32(t, BFloat16, []float32{0.5, 0.25, 0.125, 0.125}, 4)
+	cp, err := CumSum(probs, 0)
+	if err != nil {
+		t.Fatalf("CumSum: %v", err)
+	}
+	defer cp.Close()
+	expectValues(t, "CumSum bf16", mustLoad(t, cp), []float32{0.5, 0.75, 0.875, 1})
+
+	ints := mustFromFloat32(t, Int8, []float32{100, 100, 100}, 3)
+	ci, err := CumSum(ints, 0)
+	if err != nil {
+		t.Fatalf("CumSum: %v", err)
+	}
+	defer ci.Close()
+	if got, _ := ci.loadInt64(); ci.DT != Int64 || !slices.Equal(got, []int64{100, 200, 300}) {
+		t.Fatalf("CumSum int8: %v %v", ci.DT, got)
+	}
+}
+
+func BenchmarkTopKVocab(b *testing.B) {
+	const vocab = 151936
+	rng := rand.New(rand.NewSource(1))
+	x := make([]float32, vocab)
+	for i := range x {
+		x[i] = rng.Float32()
+	}
+	in, err := FromFloat32(Float32, x, 1, vocab)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer in.Close()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		v, idx, err := TopK(in, -1, 50)
+		if err != nil {
+			b.Fatal(err)
+		}
+		_ = v.Close()
+		_ = idx.Close()
+	}
+}
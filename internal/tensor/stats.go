@@ -0,0 +1,182 @@
+package tensor
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync/atomic"
+)
+
+// ---------- Statistics and debug checks ----------
+
+// Stats summarizes a tensor's values. Min, Max, Mean, Std and AbsMax cover
+// the finite values only; Std is the population standard deviation.
+type Stats struct {
+	Numel  int
+	Min    float64
+	Max    float64
+	Mean   float64
+	Std    float64
+	AbsMax float64
+	NaN    int
+	PosInf int
+	NegInf int
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("n=%d min=%.6g max=%.6g mean=%.6g std=%.6g absmax=%.6g nan=%d +inf=%d -inf=%d",
+		s.Numel, s.Min, s.Max, s.Mean, s.Std, s.AbsMax, s.NaN, s.PosInf, s.NegInf)
+}
+
+// Stats downloads t once and summarizes it. Min, Max, Mean and Std are NaN
+// when t has no finite values.
+func (t *Tensor) Stats() (Stats, error) {
+	if err := t.live(); err != nil {
+		return Stats{}, fmt.Errorf("Stats: %w", err)
+	}
+	x, err := t.loadFloat64()
+	if err != nil {
+		return Stats{}, fmt.Errorf("Stats: %w", err)
+	}
+	s := Stats{Numel: len(x), Min: math.Inf(1), Max: math.Inf(-1)}
+	var sum float64
+	finite := 0
+	for _, v := range x {
+		switch {
+		case math.IsNaN(v):
+			s.NaN++
+		case math.IsInf(v, 1):
+			s.PosInf++
+		case math.IsInf(v, -1):
+			s.NegInf++
+		default:
+			finite++
+			sum += v
+			s.Min, s.Max = min(s.Min, v), max(s.Max, v)
+			s.AbsMax = max(s.AbsMax, math.Abs(v))
+		}
+	}
+	if finite == 0 {
+		s.Min, s.Max, s.Mean, s.Std = math.NaN(), math.NaN(), math.NaN(), math.NaN()
+		return s, nil
+	}
+	s.Mean = sum / float64(finite)
+	var sq float64
+	for _, v := range x {
+		if !math.IsNaN(v) && !math.IsInf(v, 0) {
+			sq += (v - s.Mean) * (v - s.Mean)
+		}
+	}
+	s.Std = math.Sqrt(sq / float64(finite))
+	return s, nil
+}
+
+// ErrNonFinite is wrapped by the errors ops return when debug checks are on
+// and an output holds NaN, or Inf the op produced itself.
+var ErrNonFinite = errors.New("non-finite output")
+
+var debugChecks atomic.Bool
+
+// SetDebugChecks turns checking of op outputs on or off. While on, every
+// tensor op downloads its float output and fails with an error wrapping
+// ErrNonFinite, naming the op, the output shape and the shapes of the
+// caller's operands, if the output holds NaN, or holds Inf while its inputs
+// and scalar operands were all finite. Inf copied or propagated from an input,
+// such as the -Inf of MaskedFill(scores, mask, -Inf), passes; so does Inf an
+// op writes over an input it overwrote in place, which the check cannot tell
+// from the input's own. While off an op pays a single atomic load.
+func SetDebugChecks(on bool) { debugChecks.Store(on) }
+
+// DebugChecks reports whether op outputs are being checked.
+func DebugChecks() bool { return debugChecks.Load() }
+
+// checkOutput is called by every op on its result with the caller's tensor
+// operands in the caller's order; nil operands are skipped. It and
+// checkOutputWith inline into their callers, so while checks are off an op
+// pays one atomic load and its operand slice stays on the stack.
+func checkOutput(out *Tensor, inputs ...*Tensor) error {
+	if !debugChecks.Load() {
+		return nil
+	}
+	return checkFinite(out, inputs, nil, nil)
+}
+
+// checkOutputWith is checkOutput for ops that also read values the caller
+// did not pass as tensors: internal tensors such as MaskedFill's staged value
+// and scalar operands. They excuse Inf in the output like inputs do but are
+// not named in the error.
+func checkOutputWith(out *Tensor, inputs, internal []*Tensor, scalars ...float32) error {
+	if !debugChecks.Load() {
+		return nil
+	}
+	return checkFinite(out, inputs, internal, scalars)
+}
+
+func checkFinite(out *Tensor, inputs, internal []*Tensor, scalars []float32) error {
+	if out == nil || out.Device() == Meta || isIntDType(out.DT) || out.DT == Bool {
+		return nil
+	}
+	x, err := out.loadFloat32()
+	if err != nil {
+		return err
+	}
+	nan, inf, first := 0, 0, -1
+	for i, v := range x {
+		f := float64(v)
+		if math.IsNaN(f) {
+			nan++
+		} else if math.IsInf(f, 0) {
+			inf++
+		} else {
+			continue
+		}
+		if first < 0 {
+			first = i
+		}
+	}
+	if first < 0 {
+		return nil
+	}
+	if nan == 0 {
+		// Only Inf: report it only if the op created it.
+		fromInput, err := anyInf(scalars, inputs, internal)
+		if err != nil || fromInput {
+			return err
+		}
+	}
+	var shapes []string
+	for _, in := range inputs {
+		if in != nil {
+			shapes = append(shapes, fmt.Sprintf("%v%v", in.DT, in.Shape))
+		}
+	}
+	return fmt.Errorf("%w %v%v: %d NaN, %d Inf, first at %v; inputs %s",
+		ErrNonFinite, out.DT, out.Shape, nan, inf, unflatten(out.Shape, first), strings.Join(shapes, ", "))
+}
+
+// anyInf reports whether any scalar or float tensor holds ±Inf.
+func anyInf(scalars []float32, lists ...[]*Tensor) (bool, error) {
+	for _, s := range scalars {
+		if math.IsInf(float64(s), 0) {
+			return true, nil
+		}
+	}
+	for _, ts := range lists {
+		for _, t := range ts {
+			if t == nil || isIntDType(t.DT) || t.DT == Bool {
+				continue
+			}
+			x, err := t.loadFloat32()
+			if err != nil {
+				return false, err
+			}
+			for _, v := range x {
+				if math.IsInf(float64(v), 0) {
+					return true, nil
+				}
+			}
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,187 @@
+package tensor
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	nan, inf := float32(math.NaN()), float32(math.Inf(1))
+	x := mustFromFloat32(t, Float32, []float32{1, -3, nan, 2, inf, -inf, 4, nan}, 2, 4)
+	s, err := x.Stats()
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	want := Stats{Numel: 8, Min: -3, Max: 4, Mean: 1, Std: math.Sqrt(6.5), AbsMax: 4, NaN: 2, PosInf: 1, NegInf: 1}
+	if s != want {
+		t.Fatalf("Stats = %+v, want %+v", s, want)
+	}
+	if got := s.String(); !strings.Contains(got, "nan=2 +inf=1 -inf=1") {
+		t.Fatalf("String: %s", got)
+	}
+
+	// Strided bf16 view: the second column of [[1, 10], [2, 20], [3, 30]].
+	y := mustFromFloat32(t, BFloat16, []float32{1, 10, 2, 20, 3, 30}, 3, 2)
+	cols, err := y.Transpose(0, 1)
+	if err != nil {
+		t.Fatalf("Transpose: %v", err)
+	}
+	col, err := cols.Row(1)
+	if err != nil {
+		t.Fatalf("Row: %v", err)
+	}
+	s, err = col.Stats()
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if s.Numel != 3 || s.Min != 10 || s.Max != 30 || s.Mean != 20 || s.AbsMax != 30 {
+		t.Fatalf("column stats %+v", s)
+	}
+
+	allNaN := mustFromFloat32(t, Float16, []float32{nan}, 1)
+	if s, _ := allNaN.Stats(); s.NaN != 1 || !math.IsNaN(s.Mean) {
+		t.Fatalf("all-NaN stats %+v", s)
+	}
+}
+
+func TestDebugChecks(t *testing.T) {
+	t.Cleanup(func() { SetDebugChecks(false) })
+	a := mustFromFloat32(t, Float32, []float32{1, -1, 0, 2}, 2, 2)
+	zero := mustFromFloat32(t, Float32, []float32{0}, 1)
+
+	// Off: non-finite results pass through.
+	q, err := Div(a, zero)
+	if err != nil {
+		t.Fatalf("Div with checks off: %v", err)
+	}
+	_ = q.Close()
+
+	SetDebugChecks(true)
+	if !DebugChecks() {
+		t.Fatalf("DebugChecks() = false after SetDebugChecks(true)")
+	}
+	_, err = Div(a, zero)
+	if !errors.Is(err, ErrNonFinite) {
+		t.Fatalf("Div: got %v, want ErrNonFinite", err)
+	}
+	want := "Div: non-finite output float32[2 2]: 1 NaN, 3 Inf, first at [0 0]; inputs float32[2 2], float32[1]"
+	if err.Error() != want {
+		t.Fatalf("Div error:\n%v\nwant\n%v", err, want)
+	}
+
+	cases := []struct {
+		name, want string
+		fn         func() error
+	}{
+		{"Log", "Log: non-finite output float32[2 2]: 1 NaN, 1 Inf, first at [0 1]", func() error { _, err := Log(a); return err }},
+		{"Sum", "Sum: non-finite output", func() error {
+			big := mustFromFloat32(t, Float32, []float32{3e38, 3e38}, 2)
+			_, err := Sum(big, false)
+			return err
+		}},
+		{"To", "To(float16): non-finite output float16[1]", func() error {
+			big := mustFromFloat32(t, Float32, []float32{1e5}, 1)
+			_, err := big.To(Float16)
+			return err
+		}},
+		{"MatMul", "MatMul: non-finite output float32[1 1]", func() error {
+			big := mustFromFloat32(t, Float32, []float32{1e20, 1e20}, 1, 2)
+			col := mustFromFloat32(t, Float32, []float32{1e20, 1e20}, 2, 1)
+			_, err := MatMul(big, col)
+			return err
+		}},
+		{"Concat", "Concat: non-finite output float32[3]: 1 NaN", func() error {
+			nan := mustFromFloat32(t, Float32, []float32{float32(math.NaN())}, 1)
+			_, err := Concat(0, zero, zero, nan)
+			return err
+		}},
+		{"MaskedFill", "MaskedFill: non-finite output float32[2 2]: 2 NaN, 0 Inf, first at [0 1]; inputs float32[2 2], bool[2 2]", func() error {
+			mask := mustFromFloat32(t, Bool, []float32{0, 1, 1, 0}, 2, 2)
+			_, err := MaskedFill(a, mask, float32(math.NaN()))
+			return err
+		}},
+		{"AddScalar", "AddScalar: non-finite output float32[1]: 0 NaN, 1 Inf", func() error {
+			big := mustFromFloat32(t, Float32, []float32{3e38}, 1)
+			_, err := AddScalar(big, 3e38)
+			return err
+		}},
+	}
+	for _, c := range cases {
+		err := c.fn()
+		if !errors.Is(err, ErrNonFinite) || !strings.Contains(err.Error(), c.want) {
+			t.Fatalf("%s: got %v, want error containing %q", c.name, err, c.want)
+		}
+	}
+
+	// Inf copied or propagated from an operand is not reported.
+	inf := mustFromFloat32(t, Float32, []float32{float32(math.Inf(-1))}, 1)
+	ids := mustFromFloat32(t, Int32, []float32{0, 0}, 2)
+	passes := []struct {
+		name string
+		fn   func() (*Tensor, error)
+	}{
+		{"Concat", func() (*Tensor, error) { return Concat(0, zero, inf) }},
+		{"To", func() (*Tensor, error) { return inf.To(Float16) }},
+		{"IndexSelect", func() (*Tensor, error) { return IndexSelect(inf, 0, ids) }},
+		{"Neg", func() (*Tensor, error) { return Neg(inf) }},
+		{"AddScalar", func() (*Tensor, error) { return AddScalar(a, float32(math.Inf(1))) }},
+		{"Realize", func() (*Tensor, error) { return Lazy(a).Add(Lazy(inf)).MulScalar(2).Realize() }},
+	}
+	for _, c := range passes {
+		out, err := c.fn()
+		if err != nil {
+			t.Errorf("%s with an infinite operand: %v", c.name, err)
+			continue
+		}
+		_ = out.Close()
+	}
+
+	// Finite outputs and integer ops still succeed with checks on.
+	s, err := AddScalar(a, 1)
+	if err != nil {
+		t.Fatalf("AddScalar: %v", err)
+	}
+	_ = s.Close()
+}
+
+func TestDebugChecksOffAllocs(t *testing.T) {
+	SetDebugChecks(false)
+	a := mustFromFloat32(t, Float32, []float32{float32(math.NaN())}, 1)
+	if n := testing.AllocsPerRun(100, func() { _ = checkOutput(a, a, a) }); n != 0 {
+		t.Fatalf("checkOutput allocates %v times with checks off", n)
+	}
+}
+
+func TestDebugChecksMaskedAttention(t *testing.T) {
+	SetDebugChecks(true)
+	t.Cleanup(func() { SetDebugChecks(false) })
+	scores := mustFromFloat32(t, Float32, []float32{1, 2, 3, 4, 5, 6, 7, 8, 9}, 3, 3)
+	mask, err := CausalMask(3, 3, 0)
+	if err != nil {
+		t.Fatalf("CausalMask: %v", err)
+	}
+	defer mask.Close()
+	masked, err := MaskedFill(scores, mask, float32(math.Inf(-1)))
+	if err != nil {
+		t.Fatalf("MaskedFill with -Inf under debug checks: %v", err)
+	}
+	defer masked.Close()
+	probs, err := Softmax(masked, -1)
+	if err != nil {
+		t.Fatalf("Softmax of masked scores under debug checks: %v", err)
+	}
+	defer probs.Close()
+	if got := mustLoad(t, probs); got[0] != 1 || got[1] != 0 || got[2] != 0 {
+		t.Fatalf("first row of probabilities = %v, want [1 0 0]", got[:3])
+	}
+	if err := MaskedFillInto(scores, scores, mask, float32(math.Inf(-1))); err != nil {
+		t.Fatalf("MaskedFill in place with -Inf under debug checks: %v", err)
+	}
+	logp, err := LogSoftmax(scores, -1)
+	if err != nil {
+		t.Fatalf("LogSoftmax of masked scores under debug checks: %v", err)
+	}
+	_ = logp.Close()
+}
@@ -3,10 +3,10 @@ package tensor
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"math"
 	"math/rand"
-	"strconv"
-	"strings"
+	"sync/atomic"
 	"unsafe"
 
 	"kylesmith19091/fastgo/internal/metal"
@@ -20,7 +20,14 @@ const (
 	BFloat16
 	Float32
 	Int8
-	Int4 // packed: 2 values per byte
+	Int4       // packed: 2 values per byte
+	Float8E4M3 // OCP OFP8 E4M3 (e4m3fn): no infinities, max 448
+	Float8E5M2 // OCP OFP8 E5M2: IEEE-style Inf/NaN, max 57344
+	Float4E2M1 // OCP MXFP4 element: packed 2 values per byte, max 6
+	Float8E8M0 // OCP MX block scale: unsigned power of two, 0xFF is NaN
+	Int32
+	Int64
+	Bool // one byte per element: 0 is false, anything else true
 )
 
 func (dt DType) String() string {
@@ -35,6 +42,20 @@ func (dt DType) String() string {
 		return "int8"
 	case Int4:
 		return "int4"
+	case Float8E4M3:
+		return "float8_e4m3"
+	case Float8E5M2:
+		return "float8_e5m2"
+	case Float4E2M1:
+		return "float4_e2m1"
+	case Float8E8M0:
+		return "float8_e8m0"
+	case Int32:
+		return "int32"
+	case Int64:
+		return "int64"
+	case Bool:
+		return "bool"
 	default:
 		return "unknown"
 	}
@@ -45,11 +66,13 @@ func (dt DType) SizeOf() int {
 	switch dt {
 	case Float16, BFloat16:
 		return 2
-	case Float32:
+	case Float32, Int32:
 		return 4
-	case Int8:
+	case Int64:
+		return 8
+	case Int8, Float8E4M3, Float8E5M2, Float8E8M0, Bool:
 		return 1
-	case Int4:
+	case Int4, Float4E2M1:
 		return 1 // two elems per byte; use BytesFor for arrays
 	default:
 		return 0
@@ -62,169 +85,115 @@ func SizeOf(dt DType) int { return dt.SizeOf() }
 // BytesFor returns the number of bytes to store numel elements of dtype.
 func BytesFor(dt DType, numel int) int {
 	switch dt {
-	case Int4:
+	case Int4, Float4E2M1:
 		return (numel + 1) / 2
 	default:
 		return dt.SizeOf() * numel
 	}
 }
 
-// Tensor is a device-resident multi-dimensional array backed by a Metal buffer.
+// Tensor is a multi-dimensional array backed by a Metal buffer or, for a
+// host tensor, by Go memory (see Device).
+//
+// A tensor and the views taken from it (View, Reshape, Select, Row,
+// Transpose, BroadcastTo, Split, Chunk) share reference-counted storage: each
+// holds one reference and must be closed, and the buffer is freed by the
+// last Close, so a view stays valid after its parent is closed. Using a
+// closed tensor returns an error wrapping ErrClosed.
 type Tensor struct {
 	DT      DType
 	Shape   []int
 	Strides []int // in bytes
 	Offset  int   // in bytes
 	buf     *metal.Buffer
-	own     bool // owns underlying buffer
+	st      *storage // holds a reference to buf; nil for views ops borrow internally
+	closed  bool
 }
 
-// New allocates a device buffer and creates a contiguous tensor view.
-func New(dt DType, shape ...int) (*Tensor, error) {
-	if !IsValidShape(shape) {
-		return nil, errors.New("invalid shape")
-	}
-	numel := Numel(shape)
-	nbytes := BytesFor(dt, numel)
-	mbuf, err := metal.NewBuffer(nbytes)
-	if err != nil {
-		return nil, err
-	}
-	return &Tensor{
-		DT:      dt,
-		Shape:   append([]int(nil), shape...),
-		Strides: DefaultStridesBytes(dt, shape),
-		Offset:  0,
-		buf:     mbuf,
-		own:     true,
-	}, nil
+// storage is a buffer shared by a tensor and its views, freed when the last
+// reference is released.
+type storage struct {
+	buf  *metal.Buffer
+	refs atomic.Int64
 }
 
-func NewRandom2D(dt DType, rows int, cols int) (*Tensor, error) {
-	if !IsValidShape([]int{rows, cols}) {
-		return nil, errors.New("invalid shape")
-	}
-	numel := Numel([]int{rows, cols})
-	nbytes := BytesFor(dt, numel)
-	mbuf, err := metal.NewBuffer(nbytes)
-	if err != nil {
-		return nil, err
+// buffersAllocated counts the buffers New has allocated, so tests can check
+// that loops built from Into and InPlace ops allocate none.
+var buffersAllocated atomic.Int64
+
+// ErrClosed is wrapped by the errors returned when a closed tensor is used.
+var ErrClosed = errors.New("use of closed tensor")
+
+// live returns an error if t is nil or has been closed.
+func (t *Tensor) live() error {
+	switch {
+	case t == nil:
+		return errors.New("nil tensor")
+	case t.closed:
+		return ErrClosed
+	case t.buf == nil:
+		return errors.New("nil tensor")
 	}
+	return nil
+}
 
-	// Initialize with PyTorch-like Kaiming uniform for Linear weights:
-	// bound = 1 / sqrt(fan_in), where fan_in = cols for [rows, cols].
-	bound := float32(1.0 / math.Sqrt(float64(cols)))
-	switch dt {
-	case Float32:
-		data := make([]float32, numel)
-		for i := range data {
-			// Uniform in [-bound, bound]
-			data[i] = (rand.Float32()*2 - 1) * bound
-		}
-		bs := unsafe.Slice((*byte)(unsafe.Pointer(&data[0])), len(data)*4)
-		if err := mbuf.Write(bs); err != nil {
-			_ = mbuf.Close()
-			return nil, err
-		}
-	case Float16:
-		f := make([]float32, numel)
-		for i := range f {
-			f[i] = (rand.Float32()*2 - 1) * bound
-		}
-		packed := PackFP16(f)
-		if err := mbuf.Write(uint16SliceAsBytes(packed)); err != nil {
-			_ = mbuf.Close()
-			return nil, err
-		}
-	case BFloat16:
-		f := make([]float32, numel)
-		for i := range f {
-			f[i] = (rand.Float32()*2 - 1) * bound
-		}
-		packed := PackBF16(f)
-		if err := mbuf.Write(uint16SliceAsBytes(packed)); err != nil {
-			_ = mbuf.Close()
-			return nil, err
-		}
-	default:
-		// For non-float dtypes, leave contents unspecified.
+// share makes v, a view of t's buffer, hold its own reference to t's
+// storage, so it outlives a Close of t.
+func (t *Tensor) share(v *Tensor) *Tensor {
+	if t.st != nil {
+		t.st.refs.Add(1)
+		v.st = t.st
 	}
+	return v
+}
+
+// New allocates a buffer on the default device (see SetDefaultDevice) and
+// creates a contiguous tensor view. An
+// empty shape gives a scalar (rank-0) tensor holding one element, and zero
+// dims give an empty tensor.
+func New(dt DType, shape ...int) (*Tensor, error) {
+	return NewOn(DefaultDevice(), dt, shape...)
+}
 
-	return &Tensor{
-		DT:      dt,
-		Shape:   []int{rows, cols},
-		Strides: DefaultStridesBytes(dt, []int{rows, cols}),
-		Offset:  0,
-		buf:     mbuf,
-		own:     true,
-	}, nil
+// NewRandom2D returns a [rows, cols] tensor initialized like a PyTorch Linear
+// weight, uniform in ±1/sqrt(cols), from an unseeded source.
+//
+// Deprecated: use Random with a seeded *rand.Rand for reproducible values.
+func NewRandom2D(dt DType, rows int, cols int) (*Tensor, error) {
+	return newRandomLinear(dt, rows, cols)
 }
 
+// NewRandom3D is NewRandom2D for a [dim0, dim1, dim2] tensor, using dim2 as
+// the fan-in.
+//
+// Deprecated: use Random with a seeded *rand.Rand for reproducible values.
 func NewRandom3D(dt DType, dim0 int, dim1 int, dim2 int) (*Tensor, error) {
-	shape := []int{dim0, dim1, dim2}
-	if !IsValidShape(shape) {
-		return nil, errors.New("invalid shape")
-	}
-	numel := Numel(shape)
-	nbytes := BytesFor(dt, numel)
-	mbuf, err := metal.NewBuffer(nbytes)
-	if err != nil {
-		return nil, err
-	}
+	return newRandomLinear(dt, dim0, dim1, dim2)
+}
 
-	// Use the last dimension as fan-in to match linear weight initialization.
-	bound := float32(1.0 / math.Sqrt(float64(dim2)))
-	switch dt {
-	case Float32:
-		data := make([]float32, numel)
-		for i := range data {
-			data[i] = (rand.Float32()*2 - 1) * bound
-		}
-		bs := unsafe.Slice((*byte)(unsafe.Pointer(&data[0])), len(data)*4)
-		if err := mbuf.Write(bs); err != nil {
-			_ = mbuf.Close()
-			return nil, err
-		}
-	case Float16:
-		f := make([]float32, numel)
-		for i := range f {
-			f[i] = (rand.Float32()*2 - 1) * bound
-		}
-		packed := PackFP16(f)
-		if err := mbuf.Write(uint16SliceAsBytes(packed)); err != nil {
-			_ = mbuf.Close()
-			return nil, err
-		}
-	case BFloat16:
-		f := make([]float32, numel)
-		for i := range f {
-			f[i] = (rand.Float32()*2 - 1) * bound
-		}
-		packed := PackBF16(f)
-		if err := mbuf.Write(uint16SliceAsBytes(packed)); err != nil {
-			_ = mbuf.Close()
-			return nil, err
-		}
-	default:
-		// For non-float dtypes, leave contents unspecified.
+func newRandomLinear(dt DType, shape ...int) (*Tensor, error) {
+	if !IsValidShape(shape) || len(shape) == 0 {
+		return nil, errors.New("invalid shape")
 	}
-
-	return &Tensor{
-		DT:      dt,
-		Shape:   shape,
-		Strides: DefaultStridesBytes(dt, shape),
-		Offset:  0,
-		buf:     mbuf,
-		own:     true,
-	}, nil
+	bound := 1 / math.Sqrt(float64(shape[len(shape)-1]))
+	return Random(rand.New(rand.NewSource(rand.Int63())), Uniform(-bound, bound), dt, shape...)
 }
 
-// FromFloat32 packs and uploads float32 data into a new device tensor.
+// FromFloat32 packs and uploads float32 data into a new tensor on the
+// default device. data must hold exactly Numel(shape) values, so a scalar
+// takes one value and an empty shape such as [0, 4] takes none. A meta
+// tensor drops the values.
 func FromFloat32(dt DType, data []float32, shape ...int) (*Tensor, error) {
+	if IsValidShape(shape) && len(data) != Numel(shape) {
+		return nil, fmt.Errorf("FromFloat32: %d values for shape %v", len(data), shape)
+	}
 	t, err := New(dt, shape...)
 	if err != nil {
 		return nil, err
 	}
+	if len(data) == 0 || t.Device() == Meta {
+		return t, nil
+	}
 	switch dt {
 	case Float32:
 		bs := unsafe.Slice((*byte)(unsafe.Pointer(&data[0])), len(data)*4)
@@ -233,19 +202,46 @@ func FromFloat32(dt DType, data []float32, shape ...int) (*Tensor, error) {
 			return nil, err
 		}
 	case Float16:
-		packed := PackFP16(data)
-		bs := uint16SliceAsBytes(packed)
+		bs := make([]byte, 2*len(data))
+		PackFP16Bytes(bs, data)
 		if err := t.buf.Write(bs); err != nil {
 			_ = t.Close()
 			return nil, err
 		}
 	case BFloat16:
-		packed := PackBF16(data)
-		bs := uint16SliceAsBytes(packed)
+		bs := make([]byte, 2*len(data))
+		PackBF16Bytes(bs, data)
 		if err := t.buf.Write(bs); err != nil {
 			_ = t.Close()
 			return nil, err
 		}
+	case Float8E4M3:
+		// Saturating conversion, as used when casting weights to FP8.
+		if err := t.buf.Write(PackFP8E4M3(data, true)); err != nil {
+			_ = t.Close()
+			return nil, err
+		}
+	case Float8E5M2:
+		if err := t.buf.Write(PackFP8E5M2(data, true)); err != nil {
+			_ = t.Close()
+			return nil, err
+		}
+	case Float4E2M1:
+		if err := t.buf.Write(PackFP4E2M1(data)); err != nil {
+			_ = t.Close()
+			return nil, err
+		}
+	case Int8, Int4, Int32, Int64, Bool:
+		// Round to nearest and saturate, e.g. for token id or index tensors;
+		// Bool stores any nonzero value as true.
+		raw, err := encodeFloat32(dt, data)
+		if err == nil {
+			err = t.buf.Write(raw)
+		}
+		if err != nil {
+			_ = t.Close()
+			return nil, err
+		}
 	default:
 		_ = t.Close()
 		return nil, errors.New("unsupported dtype for FromFloat32")
@@ -260,12 +256,13 @@ func (t *Tensor) ByteSize() int { return BytesFor(t.DT, t.Numel()) }
 
 func (t *Tensor) Buffer() *metal.Buffer { return t.buf }
 
-// At returns the value at the provided multi-dimensional index as float32.
-// For integer tensors, the value is converted to float32.
+// At returns the value at the provided multi-dimensional index as float32;
+// a scalar tensor takes no indices. For integer tensors, the value is
+// converted to float32.
 // For Int4 tensors, only contiguous tensors are supported.
 func (t *Tensor) At(idxs ...int) (float32, error) {
-	if t == nil || t.buf == nil {
-		return 0, errors.New("nil tensor")
+	if err := t.live(); err != nil {
+		return 0, err
 	}
 	if len(idxs) != len(t.Shape) {
 		return 0, errors.New("index rank mismatch")
@@ -307,9 +304,50 @@ func (t *Tensor) At(idxs ...int) (float32, error) {
 			return 0, err
 		}
 		return float32(int8(bs[0])), nil
-	case Int4:
+	case Bool:
+		off := t.byteOffsetForIndices(idxs)
+		bs, err := t.buf.ReadN(off, 1)
+		if err != nil || len(bs) < 1 {
+			return 0, err
+		}
+		if bs[0] != 0 {
+			return 1, nil
+		}
+		return 0, nil
+	case Float8E4M3, Float8E5M2:
+		off := t.byteOffsetForIndices(idxs)
+		bs, err := t.buf.ReadN(off, 1)
+		if err != nil || len(bs) < 1 {
+			return 0, err
+		}
+		if t.DT == Float8E4M3 {
+			return fp8E4M3Table[bs[0]], nil
+		}
+		return fp8E5M2Table[bs[0]], nil
+	case Float8E8M0:
+		off := t.byteOffsetForIndices(idxs)
+		bs, err := t.buf.ReadN(off, 1)
+		if err != nil || len(bs) < 1 {
+			return 0, err
+		}
+		return e8m0ToFloat32(bs[0]), nil
+	case Int32:
+		off := t.byteOffsetForIndices(idxs)
+		bs, err := t.buf.ReadN(off, 4)
+		if err != nil || len(bs) < 4 {
+			return 0, err
+		}
+		return float32(int32(binary.LittleEndian.Uint32(bs))), nil
+	case Int64:
+		off := t.byteOffsetForIndices(idxs)
+		bs, err := t.buf.ReadN(off, 8)
+		if err != nil || len(bs) < 8 {
+			return 0, err
+		}
+		return float32(int64(binary.LittleEndian.Uint64(bs))), nil
+	case Int4, Float4E2M1:
 		if !t.Contiguous() {
-			return 0, errors.New("int4 At unsupported for non-contiguous tensor")
+			return 0, errors.New("4-bit At unsupported for non-contiguous tensor")
 		}
 		li, err := t.flatIndex(idxs)
 		if err != nil {
@@ -327,6 +365,9 @@ func (t *Tensor) At(idxs ...int) (float32, error) {
 		} else {
 			nibble = (b >> 4) & 0x0F
 		}
+		if t.DT == Float4E2M1 {
+			return fp4E2M1Table[nibble], nil
+		}
 		v := int8(nibble<<4) >> 4 // sign-extend 4-bit
 		return float32(v), nil
 	default:
@@ -337,8 +378,8 @@ func (t *Tensor) At(idxs ...int) (float32, error) {
 // Select returns a view by fixing the index at the given dimension.
 // The resulting tensor has rank-1 with that dimension removed.
 func (t *Tensor) Select(dim int, index int) (*Tensor, error) {
-	if t == nil || t.buf == nil {
-		return nil, errors.New("nil tensor")
+	if err := t.live(); err != nil {
+		return nil, err
 	}
 	if dim < 0 || dim >= len(t.Shape) {
 		return nil, errors.New("dim out of range")
@@ -359,6 +400,33 @@ func (t *Tensor) Row(i int) (*Tensor, error) {
 	return t.Select(0, i)
 }
 
+// Transpose returns a view with dims d0 and d1 swapped, without copying.
+// Negative dims count from the end, so Transpose(-2, -1) turns an HF-style
+// [out, in] weight into an [in, out] operand for MatMul.
+func (t *Tensor) Transpose(d0, d1 int) (*Tensor, error) {
+	if err := t.live(); err != nil {
+		return nil, err
+	}
+	rank := len(t.Shape)
+	if d0 < 0 {
+		d0 += rank
+	}
+	if d1 < 0 {
+		d1 += rank
+	}
+	if d0 < 0 || d0 >= rank || d1 < 0 || d1 >= rank {
+		return nil, errors.New("dim out of range")
+	}
+	if is4Bit(t.DT) {
+		return nil, errors.New("4-bit tensors cannot be transposed")
+	}
+	shape := append([]int(nil), t.Shape...)
+	strides := append([]int(nil), t.Strides...)
+	shape[d0], shape[d1] = shape[d1], shape[d0]
+	strides[d0], strides[d1] = strides[d1], strides[d0]
+	return t.View(t.Offset, shape, strides)
+}
+
 // flatIndex computes the row-major linear element index for the given indices.
 func (t *Tensor) flatIndex(idxs []int) (int, error) {
 	if len(idxs) != len(t.Shape) {
@@ -386,182 +454,16 @@ func (t *Tensor) byteOffsetForIndices(idxs []int) int {
 	return off
 }
 
-func (t *Tensor) String() string {
-	if t == nil {
-		return "<nil Tensor>"
-	}
-	var sb strings.Builder
-	sb.WriteString("Tensor(")
-	sb.WriteString(t.DT.String())
-	sb.WriteString(", shape=[")
-	for i, d := range t.Shape {
-		if i > 0 {
-			sb.WriteByte(',')
-		}
-		sb.WriteString(strconv.Itoa(d))
-	}
-	sb.WriteString("]")
-	sb.WriteString(", strides=[")
-	for i, s := range t.Strides {
-		if i > 0 {
-			sb.WriteByte(',')
-		}
-		sb.WriteString(strconv.Itoa(s))
-	}
-	sb.WriteString("]")
-	if t.Offset != 0 {
-		sb.WriteString(", offset=")
-		sb.WriteString(strconv.Itoa(t.Offset))
-	} else {
-		sb.WriteString(", offset=0")
-	}
-	sb.WriteString(", contiguous=")
-	if t.Contiguous() {
-		sb.WriteString("true")
-	} else {
-		sb.WriteString("false")
-	}
-	sb.WriteString(", numel=")
-	sb.WriteString(strconv.Itoa(t.Numel()))
-	sb.WriteString(", bytes=")
-	sb.WriteString(strconv.Itoa(t.ByteSize()))
-
-	// Attempt to pretty-print values according to dtype.
-	sb.WriteString(", values=[")
-	switch t.DT {
-	case Float32:
-		for i := 0; i < t.Numel(); i++ {
-			if i > 0 {
-				sb.WriteByte(',')
-			}
-			off := t.byteOffsetForFlatIndex(i)
-			bs, err := t.buf.ReadN(off, 4)
-			if err != nil || len(bs) < 4 {
-				sb.WriteString("<read error>")
-				break
-			}
-			u := binary.LittleEndian.Uint32(bs)
-			f := math.Float32frombits(u)
-			sb.WriteString(strconv.FormatFloat(float64(f), 'g', 6, 32))
-		}
-	case Float16:
-		for i := 0; i < t.Numel(); i++ {
-			if i > 0 {
-				sb.WriteByte(',')
-			}
-			off := t.byteOffsetForFlatIndex(i)
-			bs, err := t.buf.ReadN(off, 2)
-			if err != nil || len(bs) < 2 {
-				sb.WriteString("<read error>")
-				break
-			}
-			u := binary.LittleEndian.Uint16(bs)
-			f := float16BitsToFloat32(u)
-			sb.WriteString(strconv.FormatFloat(float64(f), 'g', 6, 32))
-		}
-	case BFloat16:
-		for i := 0; i < t.Numel(); i++ {
-			if i > 0 {
-				sb.WriteByte(',')
-			}
-			off := t.byteOffsetForFlatIndex(i)
-			bs, err := t.buf.ReadN(off, 2)
-			if err != nil || len(bs) < 2 {
-				sb.WriteString("<read error>")
-				break
-			}
-			u := binary.LittleEndian.Uint16(bs)
-			f := math.Float32frombits(uint32(u) << 16)
-			sb.WriteString(strconv.FormatFloat(float64(f), 'g', 6, 32))
-		}
-	case Int8:
-		for i := 0; i < t.Numel(); i++ {
-			if i > 0 {
-				sb.WriteByte(',')
-			}
-			off := t.byteOffsetForFlatIndex(i)
-			bs, err := t.buf.ReadN(off, 1)
-			if err != nil || len(bs) < 1 {
-				sb.WriteString("<read error>")
-				break
-			}
-			v := int8(bs[0])
-			sb.WriteString(strconv.Itoa(int(v)))
-		}
-	case Int4:
-		// Only support contiguous views for compact 4-bit printing.
-		if !t.Contiguous() {
-			sb.WriteString("<int4 non-contiguous view unsupported>")
-			break
-		}
-		// Read packed bytes for all elements and unpack nibbles (lo, hi).
-		n := t.Numel()
-		bcnt := BytesFor(Int4, n)
-		if bcnt == 0 {
-			// empty
-			break
-		}
-		bs, err := t.buf.ReadN(t.Offset, bcnt)
-		if err != nil || len(bs) < bcnt {
-			sb.WriteString("<read error>")
-			break
-		}
-		for i := 0; i < n; i++ {
-			if i > 0 {
-				sb.WriteByte(',')
-			}
-			b := bs[i/2]
-			var nibble byte
-			if i%2 == 0 {
-				nibble = b & 0x0F
-			} else {
-				nibble = (b >> 4) & 0x0F
-			}
-			// Sign-extend 4-bit two's complement to int8
-			v := int8(nibble<<4) >> 4
-			sb.WriteString(strconv.Itoa(int(v)))
-		}
-	default:
-		sb.WriteString("<unknown dtype>")
-	}
-	sb.WriteString("]")
-	sb.WriteByte(')')
-	return sb.String()
-}
-
-// byteOffsetForFlatIndex computes the byte offset into the underlying buffer
-// for a given flat element index respecting the tensor's shape/strides/offset.
-func (t *Tensor) byteOffsetForFlatIndex(i int) int {
-	if len(t.Shape) == 0 {
-		return t.Offset
-	}
-	// Convert flat index to multi-dimensional indices (row-major).
-	idx := make([]int, len(t.Shape))
-	rem := i
-	for d := len(t.Shape) - 1; d >= 0; d-- {
-		dim := t.Shape[d]
-		if dim > 0 {
-			idx[d] = rem % dim
-			rem /= dim
-		}
-	}
-	off := t.Offset
-	for d := 0; d < len(t.Shape); d++ {
-		off += idx[d] * t.Strides[d]
-	}
-	return off
-}
-
-// Close releases the underlying buffer if owned.
+// Close releases t's reference to its storage, freeing the buffer once t and
+// every view of it are closed. Closing a tensor twice is a no-op.
 func (t *Tensor) Close() error {
-	if t == nil {
+	if t == nil || t.closed {
 		return nil
 	}
-	if t.own && t.buf != nil {
-		err := t.buf.Close()
-		t.buf = nil
-		t.own = false
-		return err
+	st := t.st
+	t.buf, t.st, t.closed = nil, nil, true
+	if st != nil && st.refs.Add(-1) == 0 {
+		return st.release()
 	}
 	return nil
 }
@@ -588,6 +490,9 @@ func (t *Tensor) Reshape(newShape ...int) (*Tensor, error) {
 	if t == nil {
 		return nil, errors.New("nil tensor")
 	}
+	if t.closed {
+		return nil, ErrClosed
+	}
 	if !IsValidShape(newShape) {
 		return nil, errors.New("invalid shape")
 	}
@@ -598,86 +503,81 @@ func (t *Tensor) Reshape(newShape ...int) (*Tensor, error) {
 	if !t.Contiguous() {
 		return nil, errors.New("reshape requires contiguous tensor")
 	}
-	return &Tensor{DT: t.DT, Shape: append([]int(nil), newShape...), Strides: DefaultStridesBytes(t.DT, newShape), Offset: t.Offset, buf: t.buf, own: false}, nil
+	return t.share(&Tensor{DT: t.DT, Shape: append([]int(nil), newShape...), Strides: DefaultStridesBytes(t.DT, newShape), Offset: t.Offset, buf: t.buf}), nil
 }
 
 // View creates a byte-wise view into the same storage.
 func (t *Tensor) View(offsetBytes int, shape, strides []int) (*Tensor, error) {
-	if t == nil {
-		return nil, errors.New("nil tensor")
+	if err := t.live(); err != nil {
+		return nil, err
+	}
+	v, err := t.view(offsetBytes, shape, strides)
+	if err != nil {
+		return nil, err
 	}
+	return t.share(v), nil
+}
+
+// view is View without a storage reference, for views an op uses only
+// while it runs; they must not escape to the caller.
+func (t *Tensor) view(offsetBytes int, shape, strides []int) (*Tensor, error) {
 	if !IsValidShape(shape) {
 		return nil, errors.New("invalid shape")
 	}
 	if len(shape) != len(strides) {
 		return nil, errors.New("shape/strides rank mismatch")
 	}
-	// Basic bounds check: last element address must fit into buffer
-	maxOff := offsetBytes
-	if len(shape) > 0 {
+	// Basic bounds check: last element address must fit into buffer. An
+	// empty view touches no bytes, so only its offset is checked; a scalar
+	// covers one element.
+	maxOff := 0
+	if Numel(shape) > 0 {
 		// conservative bound: offset + (shape[0]-1)*stride[0] + ... + elemSize
+		maxOff = offsetBytes
 		for i := range shape {
-			if shape[i] <= 0 {
-				continue
-			}
 			maxOff += (shape[i] - 1) * strides[i]
 		}
 		maxOff += t.DT.SizeOf()
 	}
-	if maxOff > t.buf.Size() {
+	if offsetBytes < 0 || maxOff > t.buf.Size() {
 		return nil, errors.New("view out of bounds")
 	}
-	vv := &Tensor{DT: t.DT, Shape: append([]int(nil), shape...), Strides: append([]int(nil), strides...), Offset: offsetBytes, buf: t.buf, own: false}
+	vv := &Tensor{DT: t.DT, Shape: append([]int(nil), shape...), Strides: append([]int(nil), strides...), Offset: offsetBytes, buf: t.buf}
 	return vv, nil
 }
 
-// DownloadFloat32 downloads tensor contents into dst, converting types as needed.
+// DownloadFloat32 downloads the view's values into dst in row-major order,
+// honouring Offset and Strides and converting types as needed.
 func (t *Tensor) DownloadFloat32(dst []float32) error {
-	if t == nil || t.buf == nil {
-		return errors.New("nil tensor")
+	if err := t.live(); err != nil {
+		return err
 	}
 	if t.Numel() != len(dst) {
 		return errors.New("len(dst) mismatch")
 	}
-	switch t.DT {
-	case Float32:
-		bs := unsafe.Slice((*byte)(unsafe.Pointer(&dst[0])), len(dst)*4)
-		return t.buf.Read(bs)
-	case Float16:
-		tmp := make([]uint16, len(dst))
-		if err := t.buf.Read(uint16SliceAsBytes(tmp)); err != nil {
-			return err
-		}
-		out := UnpackFP16(tmp)
-		copy(dst, out)
-		return nil
-	case BFloat16:
-		tmp := make([]uint16, len(dst))
-		if err := t.buf.Read(uint16SliceAsBytes(tmp)); err != nil {
-			return err
-		}
-		out := UnpackBF16(tmp)
-		copy(dst, out)
-		return nil
-	default:
-		return errors.New("unsupported dtype for DownloadFloat32")
+	vals, err := t.loadFloat32()
+	if err != nil {
+		return err
 	}
+	copy(dst, vals)
+	return nil
 }
 
 // ---------- Shape/stride helpers ----------
 
+// IsValidShape reports whether every dim is non-negative. The empty shape is
+// a scalar and a zero dim makes an empty tensor.
 func IsValidShape(shape []int) bool {
-	if len(shape) == 0 {
-		return false
-	}
 	for _, d := range shape {
-		if d <= 0 {
+		if d < 0 {
 			return false
 		}
 	}
 	return true
 }
 
+// Numel returns the element count of shape: 1 for a scalar, 0 when any dim
+// is 0.
 func Numel(shape []int) int {
 	n := 1
 	for _, d := range shape {
@@ -693,7 +593,7 @@ func DefaultStridesBytes(dt DType, shape []int) []int {
 	}
 	elem := dt.SizeOf()
 	// For Int4, element addressability is still 1 byte; we keep stride=1 byte at last dim.
-	if dt == Int4 {
+	if dt == Int4 || dt == Float4E2M1 {
 		elem = 1
 	}
 	rank := len(shape)
@@ -708,130 +608,3 @@ func DefaultStridesBytes(dt DType, shape []int) []int {
 	}
 	return strides
 }
-
-// ---------- FP16/BF16 conversion ----------
-
-func PackBF16(src []float32) []uint16 {
-	out := make([]uint16, len(src))
-	for i, f := range src {
-		bits := math.Float32bits(f)
-		// Round-to-nearest-even: add 0x7FFF + lsb of top 16 bits
-		lsb := (bits >> 16) & 1
-		rounding := uint32(0x7FFF) + lsb
-		out[i] = uint16((bits + rounding) >> 16)
-	}
-	return out
-}
-
-func UnpackBF16(src []uint16) []float32 {
-	out := make([]float32, len(src))
-	for i, b := range src {
-		bits := uint32(b) << 16
-		out[i] = math.Float32frombits(bits)
-	}
-	return out
-}
-
-// PackFP16 converts float32 slice to IEEE 754 half precision (round-to-nearest-even).
-func PackFP16(src []float32) []uint16 {
-	out := make([]uint16, len(src))
-	for i, f := range src {
-		out[i] = float32ToFloat16Bits(f)
-	}
-	return out
-}
-
-// UnpackFP16 converts IEEE 754 half to float32.
-func UnpackFP16(src []uint16) []float32 {
-	out := make([]float32, len(src))
-	for i, h := range src {
-		out[i] = float16BitsToFloat32(h)
-	}
-	return out
-}
-
-func float32ToFloat16Bits(f float32) uint16 {
-	x := math.Float32bits(f)
-	sign := uint16((x >> 16) & 0x8000)
-	mant := x & 0x007fffff
-	exp := (x >> 23) & 0xff
-	if exp == 0xff { // Inf or NaN
-		if mant != 0 { // NaN
-			return uint16(sign | 0x7e00)
-		}
-		return uint16(sign | 0x7c00)
-	}
-	// normal/denorm
-	exp32 := int(exp) - 127
-	exp16 := exp32 + 15
-	if exp16 >= 0x1f { // overflow -> Inf
-		return uint16(sign | 0x7c00)
-	}
-	if exp16 <= 0 { // denorm or underflow
-		if exp16 < -10 {
-			return uint16(sign) // underflow to zero
-		}
-		// denorm: shift mantissa
-		mant |= 0x00800000
-		shift := uint32(14 - exp16)
-		// round to nearest
-		out := mant >> shift
-		if (mant>>(shift-1))&1 == 1 {
-			out += 1
-		}
-		return uint16(sign | uint16(out&0x03ff))
-	}
-	// normal case
-	outExp := uint16(exp16) & 0x1f
-	outMant := uint16(mant >> 13)
-	// round
-	if (mant>>12)&1 == 1 {
-		outMant += 1
-	}
-	if outMant >= 0x0400 { // mantissa overflow
-		outMant = 0
-		outExp += 1
-		if outExp >= 0x1f { // overflow to Inf
-			return uint16(sign | 0x7c00)
-		}
-	}
-	return uint16(sign | (outExp << 10) | (outMant & 0x03ff))
-}
-
-func float16BitsToFloat32(h uint16) float32 {
-	sign := uint32(h&0x8000) << 16
-	exp := (h >> 10) & 0x1f
-	mant := uint32(h & 0x03ff)
-	if exp == 0 {
-		if mant == 0 {
-			return math.Float32frombits(sign)
-		}
-		// subnormal
-		e := int32(-14)
-		m := float64(float32(mant) / 1024.0)
-		f := math.Ldexp(m, int(e))
-		if sign != 0 {
-			f = -f
-		}
-		return float32(f)
-	}
-	if exp == 0x1f {
-		// Inf/NaN
-		if mant == 0 {
-			return math.Float32frombits(sign | 0x7f800000)
-		}
-		return math.Float32frombits(sign | 0x7fc00000)
-	}
-	// normalized
-	e := int32(exp) - 15 + 127
-	bits := sign | (uint32(e) << 23) | (mant << 13)
-	return math.Float32frombits(bits)
-}
-
-func uint16SliceAsBytes(s []uint16) []byte {
-	if len(s) == 0 {
-		return nil
-	}
-	hdr := unsafe.Slice((*byte)(unsafe.Pointer(&s[0])), len(s)*2)
-	return hdr
-}
@@ -44,3 +44,50 @@ func TestTensorUploadDownloadFloat16(t *testing.T) {
 		}
 	}
 }
+
+func TestTensorUploadDownloadFloat8(t *testing.T) {
+	data := []float32{0, 1, -1, 0.5, -448, 1e6}
+	for _, dt := range []DType{Float8E4M3, Float8E5M2} {
+		tt, err := FromFloat32(dt, data, 2, 3)
+		if err != nil {
+			t.Skipf("skipping: Metal buffer unavailable: %v", err)
+		}
+		got := make([]float32, len(data))
+		if err := tt.DownloadFloat32(got); err != nil {
+			t.Fatalf("%v DownloadFloat32: %v", dt, err)
+		}
+		_ = tt.Close()
+		var want []float32
+		if dt == Float8E4M3 {
+			want = UnpackFP8E4M3(PackFP8E4M3(data, true))
+		} else {
+			want = UnpackFP8E5M2(PackFP8E5M2(data, true))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("%v mismatch @%d: got %v want %v", dt, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestMXUploadDownload(t *testing.T) {
+	data := make([]float32, 2*MXBlockSize)
+	for i := range data {
+		data[i] = float32(i%7) - 3
+	}
+	m, err := MXFromFloat32(MXFP8E4M3, data, 2, MXBlockSize)
+	if err != nil {
+		t.Skipf("skipping: Metal buffer unavailable: %v", err)
+	}
+	defer m.Close()
+	got := make([]float32, len(data))
+	if err := m.DownloadFloat32(got); err != nil {
+		t.Fatalf("DownloadFloat32: %v", err)
+	}
+	for i := range data {
+		if got[i] != data[i] {
+			t.Fatalf("mismatch @%d: got %v want %v", i, got[i], data[i])
+		}
+	}
+}
@@ -3,7 +3,7 @@ package tensor
 import "testing"
 
 func TestIsValidShapeAndNumel(t *testing.T) {
-	if IsValidShape(nil) || IsValidShape([]int{}) || IsValidShape([]int{2, 0}) || !IsValidShape([]int{1}) {
+	if !IsValidShape(nil) || !IsValidShape([]int{2, 0}) || IsValidShape([]int{2, -1}) || !IsValidShape([]int{1}) {
 		t.Fatalf("IsValidShape failed basic checks")
 	}
 	if Numel([]int{2, 3, 4}) != 24 {
@@ -19,7 +19,7 @@ func TestContiguousAndReshape(t *testing.T) {
         Strides: DefaultStridesBytes(Float32, []int{2, 3}),
         Offset:  0,
         buf:     nil,
-        own:     false,
+        st:      nil,
     }
     if !tt.Contiguous() {
         t.Fatalf("expected contiguous")
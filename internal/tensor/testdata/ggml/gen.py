@@ -0,0 +1,184 @@
+"""Regenerates the GGML block fixtures used by ggml_test.go.
+
+Each <type>.bin holds raw blocks laid out as ggml's block_<type> structs and
+<type>.f32 the little-endian float32 values ggml's dequantize_row_<type>
+gives for them. For Q4_0 and Q8_0 the blocks are quantize_row_<type>_ref of
+src.f32; the K-quant blocks are pseudo-random bytes with sensible fp16
+scales. The reference functions below are line-by-line ports of ggml-quants.c
+that emulate its float32 arithmetic, and only the standard library is used:
+
+    python3 gen.py
+"""
+
+import math
+import struct
+
+
+def f32(x):
+    return struct.unpack("<f", struct.pack("<f", x))[0]
+
+
+def fp16(x):
+    return struct.pack("<e", x)
+
+
+def unfp16(b):
+    return struct.unpack("<e", b)[0]
+
+
+def lcg(seed):
+    while True:
+        seed = (seed * 1103515245 + 12345) % 2**31
+        yield seed >> 8
+
+
+# 3 blocks of 32: mixed signs and magnitudes, then a block of zeros.
+rng = lcg(1)
+SRC = [f32((next(rng) % 2001 - 1000) / 250.0 * (1 + i % 3)) for i in range(64)] + [0.0] * 32
+
+
+def quantize_q4_0(x):
+    out = b""
+    for b in range(0, len(x), 32):
+        blk = x[b:b + 32]
+        amax, vmax = 0.0, 0.0
+        for v in blk:
+            if amax < abs(v):
+                amax, vmax = abs(v), v
+        d = f32(vmax / -8)
+        idv = f32(1.0 / d) if d else 0.0
+        qs = bytearray(16)
+        for j in range(16):
+            x0 = min(15, int(f32(f32(blk[j] * idv) + 8.5)))
+            x1 = min(15, int(f32(f32(blk[j + 16] * idv) + 8.5)))
+            qs[j] = x0 | x1 << 4
+        out += fp16(d) + bytes(qs)
+    return out
+
+
+def quantize_q8_0(x):
+    out = b""
+    for b in range(0, len(x), 32):
+        blk = x[b:b + 32]
+        amax = max(abs(v) for v in blk)
+        d = f32(amax / 127)
+        idv = f32(1.0 / d) if d else 0.0
+        qs = bytearray()
+        for v in blk:
+            x0 = f32(v * idv)
+            q = int(math.copysign(math.floor(abs(x0) + 0.5), x0))
+            qs.append(q & 0xFF)
+        out += fp16(d) + bytes(qs)
+    return out
+
+
+def dequantize_q4_0(raw):
+    y = []
+    for b in range(0, len(raw), 18):
+        d, qs = unfp16(raw[b:b + 2]), raw[b + 2:b + 18]
+        lo = [f32(((q & 0xF) - 8) * d) for q in qs]
+        hi = [f32(((q >> 4) - 8) * d) for q in qs]
+        y += lo + hi
+    return y
+
+
+def dequantize_q8_0(raw):
+    y = []
+    for b in range(0, len(raw), 34):
+        d = unfp16(raw[b:b + 2])
+        y += [f32(struct.unpack("<b", raw[b + 2 + j:b + 3 + j])[0] * d) for j in range(32)]
+    return y
+
+
+def get_scale_min_k4(j, q):
+    if j < 4:
+        return q[j] & 63, q[j + 4] & 63
+    return (q[j + 4] & 0xF) | ((q[j - 4] >> 6) << 4), (q[j + 4] >> 4) | ((q[j] >> 6) << 4)
+
+
+def dequantize_q4_k(raw):
+    y = []
+    for b in range(0, len(raw), 144):
+        d, dmin = unfp16(raw[b:b + 2]), unfp16(raw[b + 2:b + 4])
+        scales, q = raw[b + 4:b + 16], raw[b + 16:b + 144]
+        is_ = 0
+        for j in range(0, 256, 64):
+            sc, m = get_scale_min_k4(is_, scales)
+            d1, m1 = f32(d * sc), f32(dmin * m)
+            sc, m = get_scale_min_k4(is_ + 1, scales)
+            d2, m2 = f32(d * sc), f32(dmin * m)
+            y += [f32(f32(d1 * (v & 0xF)) - m1) for v in q[:32]]
+            y += [f32(f32(d2 * (v >> 4)) - m2) for v in q[:32]]
+            q = q[32:]
+            is_ += 2
+    return y
+
+
+def dequantize_q6_k(raw):
+    y = []
+    for b in range(0, len(raw), 210):
+        blk = raw[b:b + 210]
+        d = unfp16(blk[208:210])
+        ql, qh = blk[0:128], blk[128:192]
+        sc = struct.unpack("<16b", blk[192:208])
+        for n in range(2):
+            out = [0.0] * 128
+            for l in range(32):
+                is_ = l // 16
+                q1 = ((ql[l] & 0xF) | (((qh[l] >> 0) & 3) << 4)) - 32
+                q2 = ((ql[l + 32] & 0xF) | (((qh[l] >> 2) & 3) << 4)) - 32
+                q3 = ((ql[l] >> 4) | (((qh[l] >> 4) & 3) << 4)) - 32
+                q4 = ((ql[l + 32] >> 4) | (((qh[l] >> 6) & 3) << 4)) - 32
+                out[l] = f32(f32(d * sc[is_]) * q1)
+                out[l + 32] = f32(f32(d * sc[is_ + 2]) * q2)
+                out[l + 64] = f32(f32(d * sc[is_ + 4]) * q3)
+                out[l + 96] = f32(f32(d * sc[is_ + 6]) * q4)
+            y += out
+            ql, qh, sc = ql[64:], qh[32:], sc[8:]
+    return y
+
+
+def random_bytes(rng, n):
+    return bytes(next(rng) & 0xFF for _ in range(n))
+
+
+def q4_k_blocks():
+    rng = lcg(2)
+    out = b""
+    for d, dmin in ((0.0123, 0.0045), (0.5, 0.25)):
+        out += fp16(d) + fp16(dmin) + random_bytes(rng, 12 + 128)
+    return out
+
+
+def q6_k_blocks():
+    rng = lcg(3)
+    out = b""
+    for d in (0.0031, -0.125):
+        out += random_bytes(rng, 128 + 64 + 16) + fp16(d)
+    return out
+
+
+def f32s(vals):
+    return struct.pack("<%df" % len(vals), *vals)
+
+
+q4_0 = quantize_q4_0(SRC)
+q8_0 = quantize_q8_0(SRC)
+q4_k = q4_k_blocks()
+q6_k = q6_k_blocks()
+FILES = {
+    "src.f32": f32s(SRC),
+    "q4_0.bin": q4_0,
+    "q4_0.f32": f32s(dequantize_q4_0(q4_0)),
+    "q8_0.bin": q8_0,
+    "q8_0.f32": f32s(dequantize_q8_0(q8_0)),
+    "q4_K.bin": q4_k,
+    "q4_K.f32": f32s(dequantize_q4_k(q4_k)),
+    "q6_K.bin": q6_k,
+    "q6_K.f32": f32s(dequantize_q6_k(q6_k)),
+}
+
+if __name__ == "__main__":
+    for name, data in FILES.items():
+        with open(name, "wb") as f:
+            f.write(data)
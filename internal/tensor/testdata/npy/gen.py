@@ -0,0 +1,67 @@
+"""Regenerates the .npy/.npz fixtures used by npy_test.go.
+
+The files match what NumPy writes byte for byte; the NumPy call that
+produces each one is noted next to it. Only the standard library is used so
+the fixtures can be rebuilt without NumPy installed:
+
+    python3 gen.py
+"""
+
+import struct
+import zipfile
+
+F32 = [0.0, 1.5, -2.0, 3.25, -4.0, 1e-3]  # as a [2, 3] array
+
+
+def npy(descr, shape, data, fortran=False):
+    dims = ", ".join(str(d) for d in shape)
+    if len(shape) == 1:
+        dims += ","
+    header = "{'descr': '%s', 'fortran_order': %s, 'shape': (%s), }" % (
+        descr, fortran, dims)
+    header += " " * (63 - (10 + len(header)) % 64) + "\n"
+    return b"\x93NUMPY\x01\x00" + struct.pack("<H", len(header)) + header.encode() + data
+
+
+def pack(fmt, vals):
+    return struct.pack(fmt[0] + str(len(vals)) + fmt[1:], *vals)
+
+
+FILES = {
+    # np.save(p, np.array(F32, np.float32).reshape(2, 3))
+    "f32_2x3.npy": npy("<f4", (2, 3), pack("<f", F32)),
+    # np.save(p, np.array(F32, np.float16).reshape(2, 3))
+    "f16_2x3.npy": npy("<f2", (2, 3), pack("<e", F32)),
+    # np.save(p, np.asfortranarray(np.array(F32, np.float32).reshape(2, 3)))
+    "f32_fortran_2x3.npy": npy("<f4", (2, 3), pack("<f", [F32[i] for i in (0, 3, 1, 4, 2, 5)]), True),
+    # np.save(p, np.array([-128, -1, 0, 127], np.int8))
+    "i8_4.npy": npy("|i1", (4,), pack("<b", [-128, -1, 0, 127])),
+    # np.save(p, np.array([[-2**31, -1], [0, 2**31 - 1]], np.int32))
+    "i32_2x2.npy": npy("<i4", (2, 2), pack("<i", [-2**31, -1, 0, 2**31 - 1])),
+    # np.save(p, np.array([1, -2, 300], '>i4'))
+    "i32_be_3.npy": npy(">i4", (3,), pack(">i", [1, -2, 300])),
+    # np.save(p, np.array([-2**62, 1, 2**53 + 1], np.int64))
+    "i64_3.npy": npy("<i8", (3,), pack("<q", [-2**62, 1, 2**53 + 1])),
+    # np.save(p, np.array([[True, False], [False, True]]))
+    "bool_2x2.npy": npy("|b1", (2, 2), bytes([1, 0, 0, 1])),
+    # np.save(p, np.array([1, -2.5, 0.15625], ml_dtypes.bfloat16))
+    "bf16_void_3.npy": npy("|V2", (3,), pack("<H", [0x3F80, 0xC020, 0x3E20])),
+}
+
+# np.savez(p, logits=..., ids=..., mask=...) and np.savez_compressed.
+NPZ = {
+    "logits.npy": FILES["f32_2x3.npy"],
+    "ids.npy": FILES["i64_3.npy"],
+    "mask.npy": FILES["bool_2x2.npy"],
+}
+
+for name, data in FILES.items():
+    with open(name, "wb") as f:
+        f.write(data)
+
+for name, method in (("arrays.npz", zipfile.ZIP_STORED), ("arrays_compressed.npz", zipfile.ZIP_DEFLATED)):
+    with zipfile.ZipFile(name, "w", method) as z:
+        for member, data in NPZ.items():
+            info = zipfile.ZipInfo(member, date_time=(1980, 1, 1, 0, 0, 0))
+            info.compress_type = method
+            z.writestr(info, data)